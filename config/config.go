@@ -5,23 +5,91 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
+
+	"pharmacy-test/db/migrations"
 
 	_ "github.com/lib/pq"
 )
 
-var DB *sql.DB
+// Config содержит настройки подключения к базе данных и пула соединений
+type Config struct {
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+
+	// Настройки пула соединений database/sql
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// SessionTTL — срок действия сессии пользователя, продлеваемый при каждом обращении
+	SessionTTL time.Duration
+
+	// SignupRole — роль, которую получает пользователь при самостоятельной регистрации
+	SignupRole string
+
+	// GeocoderFile — CSV-справочник адресов для офлайн-геокодера; пустая строка отключает геокодирование
+	GeocoderFile string
+
+	// PriceSchedulerInterval — период проверки запланированных изменений цен; 0 отключает обработчик
+	PriceSchedulerInterval time.Duration
+
+	// ReplenishmentInterval — период пересчёта предложений пополнения; 0 отключает пересчёт
+	ReplenishmentInterval time.Duration
+}
+
+// Load читает настройки из переменных окружения
+func Load() Config {
+	return Config{
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "discorre"),
+		DBPassword: getEnv("DB_PASSWORD", "0412"),
+		DBName:     getEnv("DB_NAME", "pharmacy_system"),
+
+		MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		SessionTTL: getEnvDuration("SESSION_TTL", 24*time.Hour),
+
+		SignupRole: getEnv("SIGNUP_ROLE", "Buyer"),
+
+		GeocoderFile: getEnv("GEOCODER_FILE", ""),
+
+		PriceSchedulerInterval: getEnvDuration("PRICE_SCHEDULER_INTERVAL", time.Minute),
+		ReplenishmentInterval:  getEnvDuration("REPLENISHMENT_INTERVAL", 24*time.Hour),
+	}
+}
+
+// connString возвращает строку подключения к указанной базе данных
+func (c Config) connString(dbName string) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, dbName)
+}
 
-func InitDB() {
-	// Строка подключения к PostgreSQL с использованием административных прав (например, база данных "postgres")
-	adminConnStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=postgres sslmode=disable",
-		getEnv("DB_HOST", "localhost"),
-		getEnv("DB_PORT", "5432"),
-		getEnv("DB_USER", "discorre"), // Здесь используем администратора "discorre"
-		getEnv("DB_PASSWORD", "0412"),
-	)
+// InitDB открывает пул соединений и применяет недостающие миграции
+func InitDB(cfg Config) *sql.DB {
+	db := Connect(cfg)
+
+	applied, err := migrations.Up(db)
+	if err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
+	}
+	log.Printf("Database schema is up to date (%d migrations applied)", applied)
+	return db
+}
 
+// Connect создаёт базу данных при необходимости и открывает общий пул соединений к ней
+func Connect(cfg Config) *sql.DB {
 	// Подключаемся с правами администратора к базе данных "postgres"
-	adminDB, err := sql.Open("postgres", adminConnStr)
+	adminDB, err := sql.Open("postgres", cfg.connString("postgres"))
 	if err != nil {
 		log.Fatalf("Unable to connect to DB as admin: %v", err)
 	}
@@ -29,138 +97,68 @@ func InitDB() {
 
 	// Проверка существования базы данных
 	var exists bool
-	err = adminDB.QueryRow(fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = '%s')", getEnv("DB_NAME", "pharmacy_system"))).Scan(&exists)
+	err = adminDB.QueryRow("SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)", cfg.DBName).Scan(&exists)
 	if err != nil {
 		log.Fatalf("Error checking database existence: %v", err)
 	}
 
 	// Если база данных не существует, создаем её
 	if !exists {
-		_, err := adminDB.Exec(fmt.Sprintf("CREATE DATABASE %s", getEnv("DB_NAME", "pharmacy_system")))
+		_, err := adminDB.Exec(fmt.Sprintf("CREATE DATABASE %s", cfg.DBName))
 		if err != nil {
 			log.Fatalf("Failed to create database: %v", err)
 		}
-		log.Printf("Database %s created successfully!", getEnv("DB_NAME", "pharmacy_system"))
+		log.Printf("Database %s created successfully!", cfg.DBName)
 	}
 
-	// Теперь подключаемся к базе данных "pharmacy_system"
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		getEnv("DB_HOST", "localhost"),
-		getEnv("DB_PORT", "5432"),
-		getEnv("DB_USER", "discorre"),
-		getEnv("DB_PASSWORD", "0412"),
-		getEnv("DB_NAME", "pharmacy_system"),
-	)
-
-	DB, err = sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", cfg.connString(cfg.DBName))
 	if err != nil {
 		log.Fatalf("Unable to connect to DB: %v", err)
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	// Проверка соединения с базой данных
-	if err = DB.Ping(); err != nil {
+	if err = db.Ping(); err != nil {
 		log.Fatalf("Unable to ping DB: %v", err)
 	}
 
-	log.Println("Connected to the database!")
-
-	// Применяем миграции для создания таблиц
-	createTablesIfNotExist()
+	log.Printf("Connected to the database (max open %d, max idle %d)", cfg.MaxOpenConns, cfg.MaxIdleConns)
+	return db
 }
 
-func createTablesIfNotExist() {
-	// Проверка и создание таблицы pharmacies
-	var exists bool
-	err := DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'pharmacies'
-		)
-	`).Scan(&exists)
-	if err != nil {
-		log.Fatalf("Error checking pharmacies table existence: %v", err)
-	}
-
-	if !exists {
-		createPharmaciesTable := `
-		CREATE TABLE pharmacies (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255),
-			address VARCHAR(255) UNIQUE NOT NULL
-		);
-		`
-		_, err := DB.Exec(createPharmaciesTable)
-		if err != nil {
-			log.Fatalf("Failed to create pharmacies table: %v", err)
-		}
-		log.Println("pharmacies table created successfully!")
-	}
-
-	// Проверка и создание таблицы medicines
-	err = DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'medicines'
-		)
-	`).Scan(&exists)
-	if err != nil {
-		log.Fatalf("Error checking medicines table existence: %v", err)
+// Функция для получения переменной окружения с значением по умолчанию
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
 	}
+	return fallback
+}
 
+// getEnvInt читает целочисленную переменную окружения
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
 	if !exists {
-		createMedicinesTable := `
-		CREATE TABLE medicines (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255),
-			manufacturer VARCHAR(255),
-			production_date DATE,
-			packaging VARCHAR(255),
-			price NUMERIC(10, 2)
-		);
-		`
-		_, err := DB.Exec(createMedicinesTable)
-		if err != nil {
-			log.Fatalf("Failed to create medicines table: %v", err)
-		}
-		log.Println("medicines table created successfully!")
+		return fallback
 	}
-
-	// Проверка и создание таблицы pharmacy_medicines
-	err = DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_name = 'pharmacy_medicines'
-		)
-	`).Scan(&exists)
-	if err != nil {
-		log.Fatalf("Error checking pharmacy_medicines table existence: %v", err)
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid value for %s: %q", key, value)
 	}
+	return n
+}
 
+// getEnvDuration читает переменную окружения с длительностью, например "30m"
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
 	if !exists {
-		createManyToManyTable := `
-		CREATE TABLE pharmacy_medicines (
-			pharmacy_id INT REFERENCES pharmacies(id) ON DELETE CASCADE,
-			medicine_id INT REFERENCES medicines(id) ON DELETE CASCADE,
-			PRIMARY KEY (pharmacy_id, medicine_id)
-		);
-		`
-		_, err := DB.Exec(createManyToManyTable)
-		if err != nil {
-			log.Fatalf("Failed to create pharmacy_medicines table: %v", err)
-		}
-		log.Println("pharmacy_medicines table created successfully!")
+		return fallback
 	}
-
-	log.Println("All tables checked and created if necessary!")
-}
-
-// Функция для получения переменной окружения с значением по умолчанию
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Fatalf("Invalid duration for %s: %q", key, value)
 	}
-	return fallback
+	return d
 }
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_details;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS pharmacy_medicines;
+DROP TABLE IF EXISTS medicines;
+DROP TABLE IF EXISTS pharmacies;
+DROP TABLE IF EXISTS addresses;
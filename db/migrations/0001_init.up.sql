@@ -0,0 +1,70 @@
+-- Базовая схема. Использует IF NOT EXISTS, чтобы принять базы,
+-- созданные ранее через initTables.sql или createTablesIfNotExist.
+
+-- Таблица адресов
+CREATE TABLE IF NOT EXISTS addresses (
+    id SERIAL PRIMARY KEY,
+    street VARCHAR(255) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    state VARCHAR(255),
+    postal_code VARCHAR(20),
+    country VARCHAR(100) NOT NULL
+);
+
+-- Таблица аптек
+CREATE TABLE IF NOT EXISTS pharmacies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address_id INT REFERENCES addresses(id) ON DELETE CASCADE
+);
+
+-- Старая схема хранила адрес аптеки строкой в колонке address
+ALTER TABLE pharmacies ADD COLUMN IF NOT EXISTS address_id INT REFERENCES addresses(id) ON DELETE CASCADE;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'pharmacies' AND column_name = 'address'
+    ) THEN
+        ALTER TABLE pharmacies ALTER COLUMN address DROP NOT NULL;
+    END IF;
+END $$;
+
+-- Таблица лекарств
+CREATE TABLE IF NOT EXISTS medicines (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255),
+    manufacturer VARCHAR(255),
+    production_date DATE,
+    packaging VARCHAR(255),
+    price NUMERIC(10, 2)
+);
+
+-- Связь аптек и лекарств (многие ко многим)
+CREATE TABLE IF NOT EXISTS pharmacy_medicines (
+    pharmacy_id INT REFERENCES pharmacies(id) ON DELETE CASCADE,
+    medicine_id INT REFERENCES medicines(id) ON DELETE CASCADE,
+    PRIMARY KEY (pharmacy_id, medicine_id)
+);
+
+-- Таблица пользователей
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(255) UNIQUE NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    cookie VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Дата создания
+    last_login_at TIMESTAMP                         -- Дата последнего входа
+);
+
+-- Таблица деталей пользователей
+CREATE TABLE IF NOT EXISTS user_details (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    first_name VARCHAR(255) NOT NULL,
+    second_name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    phone_number VARCHAR(20) UNIQUE NOT NULL,
+    position VARCHAR(100)
+);
@@ -0,0 +1 @@
+ALTER TABLE pharmacy_medicines DROP COLUMN IF EXISTS quantity;
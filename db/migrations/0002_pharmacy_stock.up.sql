@@ -0,0 +1,2 @@
+-- Количество упаковок лекарства в наличии в аптеке
+ALTER TABLE pharmacy_medicines ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 0 CHECK (quantity >= 0);
@@ -0,0 +1,14 @@
+-- Партии (серии) лекарств в аптеках
+CREATE TABLE IF NOT EXISTS medicine_lots (
+    id SERIAL PRIMARY KEY,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    lot_number VARCHAR(100) NOT NULL,
+    production_date DATE,
+    expiry_date DATE NOT NULL,
+    quantity INT NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (medicine_id, pharmacy_id, lot_number)
+);
+
+CREATE INDEX IF NOT EXISTS medicine_lots_expiry_idx ON medicine_lots (pharmacy_id, expiry_date);
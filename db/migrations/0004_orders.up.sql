@@ -0,0 +1,31 @@
+-- Таблица заказов (продаж)
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    seller_id INT REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'paid', 'cancelled', 'refunded')),
+    total NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    paid_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS orders_pharmacy_idx ON orders (pharmacy_id, created_at);
+
+-- Позиции заказа с ценой, зафиксированной на момент продажи
+CREATE TABLE IF NOT EXISTS order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(10, 2) NOT NULL,
+    line_total NUMERIC(12, 2) NOT NULL
+);
+
+-- Партии, из которых была отпущена позиция заказа
+CREATE TABLE IF NOT EXISTS order_item_lots (
+    order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    lot_id INT NOT NULL REFERENCES medicine_lots(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (order_item_id, lot_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN cookie VARCHAR(255);
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,18 @@
+-- Серверные сессии: у пользователя может быть несколько активных сессий (по одной на устройство).
+-- Хранится только SHA-256 хеш токена, сам токен знает лишь клиент.
+CREATE TABLE sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX sessions_user_idx ON sessions(user_id);
+
+-- Единственный токен в users.cookie больше не используется
+ALTER TABLE users DROP COLUMN cookie;
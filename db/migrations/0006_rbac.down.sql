@@ -0,0 +1,4 @@
+ALTER TABLE user_details DROP CONSTRAINT IF EXISTS user_details_position_fkey;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,53 @@
+-- Разрешения и роли как наборы разрешений. Имя роли хранится в user_details.position
+CREATE TABLE permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE roles (
+    name VARCHAR(100) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_name VARCHAR(100) NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(64) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission)
+);
+
+INSERT INTO permissions(name, description) VALUES
+    ('pharmacy:read', 'Просмотр аптек'),
+    ('pharmacy:write', 'Создание, изменение и удаление аптек'),
+    ('medicine:read', 'Просмотр каталога лекарств'),
+    ('medicine:write', 'Создание, изменение и удаление лекарств'),
+    ('stock:read', 'Просмотр остатков и партий'),
+    ('stock:write', 'Изменение остатков, списание и приёмка партий'),
+    ('order:read', 'Просмотр заказов'),
+    ('order:write', 'Создание заказов и смена их статуса'),
+    ('user:admin', 'Управление пользователями'),
+    ('role:admin', 'Управление ролями и их разрешениями'),
+    ('system:admin', 'Служебная информация о системе');
+
+INSERT INTO roles(name, description) VALUES
+    ('Developer', 'Полный доступ'),
+    ('Seller', 'Продавец аптеки'),
+    ('Buyer', 'Покупатель');
+
+INSERT INTO role_permissions(role_name, permission)
+SELECT 'Developer', name FROM permissions;
+
+INSERT INTO role_permissions(role_name, permission) VALUES
+    ('Seller', 'pharmacy:read'),
+    ('Seller', 'pharmacy:write'),
+    ('Seller', 'medicine:read'),
+    ('Seller', 'medicine:write'),
+    ('Seller', 'stock:read'),
+    ('Seller', 'stock:write'),
+    ('Seller', 'order:read'),
+    ('Seller', 'order:write'),
+    ('Buyer', 'pharmacy:read'),
+    ('Buyer', 'medicine:read');
+
+-- Роль пользователя должна существовать; старые строки с произвольной должностью не проверяются
+ALTER TABLE user_details ADD CONSTRAINT user_details_position_fkey
+    FOREIGN KEY (position) REFERENCES roles(name) ON UPDATE CASCADE NOT VALID;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS medicines_search_key_trgm_idx;
+DROP INDEX IF EXISTS medicines_search_document_idx;
+ALTER TABLE medicines DROP COLUMN IF EXISTS search_key;
+ALTER TABLE medicines DROP COLUMN IF EXISTS search_document;
+DROP FUNCTION IF EXISTS search_translit(TEXT);
@@ -0,0 +1,27 @@
+-- Полнотекстовый и нечёткий поиск лекарств
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Транслитерация кириллицы в латиницу; должна совпадать с search.Translit
+CREATE FUNCTION search_translit(t TEXT) RETURNS TEXT
+LANGUAGE SQL IMMUTABLE PARALLEL SAFE AS $$
+    SELECT translate(
+        replace(replace(replace(replace(replace(replace(lower(t),
+            'щ', 'shch'), 'ж', 'zh'), 'ч', 'ch'), 'ш', 'sh'), 'ю', 'yu'), 'я', 'ya'),
+        'абвгдеёзийклмнопрстуфхцыэъь',
+        'abvgdeeziyklmnoprstufhcye')
+$$;
+
+-- Документ для полнотекстового поиска: название важнее производителя, производитель важнее упаковки
+ALTER TABLE medicines ADD COLUMN search_document TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', search_translit(COALESCE(name, ''))), 'A') ||
+    setweight(to_tsvector('simple', search_translit(COALESCE(manufacturer, ''))), 'B') ||
+    setweight(to_tsvector('simple', search_translit(COALESCE(packaging, ''))), 'C')
+) STORED;
+
+-- Строка для триграммного сравнения при опечатках
+ALTER TABLE medicines ADD COLUMN search_key TEXT GENERATED ALWAYS AS (
+    search_translit(COALESCE(name, '') || ' ' || COALESCE(manufacturer, '') || ' ' || COALESCE(packaging, ''))
+) STORED;
+
+CREATE INDEX medicines_search_document_idx ON medicines USING GIN (search_document);
+CREATE INDEX medicines_search_key_trgm_idx ON medicines USING GIN (search_key gin_trgm_ops);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS addresses_coordinates_idx;
+ALTER TABLE addresses
+    DROP CONSTRAINT IF EXISTS addresses_coordinates_pair,
+    DROP COLUMN IF EXISTS longitude,
+    DROP COLUMN IF EXISTS latitude;
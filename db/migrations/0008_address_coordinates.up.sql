@@ -0,0 +1,8 @@
+-- Координаты адресов для поиска ближайших аптек
+ALTER TABLE addresses
+    ADD COLUMN latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT addresses_coordinates_pair CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Грубый фильтр по прямоугольнику координат перед точным расчётом расстояния
+CREATE INDEX addresses_coordinates_idx ON addresses(latitude, longitude) WHERE latitude IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS pharmacy_is_open(INT, TIMESTAMPTZ);
+DROP TABLE IF EXISTS pharmacy_exception_hours;
+DROP TABLE IF EXISTS pharmacy_exceptions;
+DROP TABLE IF EXISTS pharmacy_hours;
+ALTER TABLE pharmacies DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,53 @@
+-- Часовой пояс, в котором задано расписание аптеки
+ALTER TABLE pharmacies ADD COLUMN timezone TEXT NOT NULL DEFAULT 'Europe/Moscow';
+
+-- Недельное расписание: несколько интервалов в день допускают перерыв на обед.
+-- Интервал не переходит через полночь; закрытие в полночь записывается как 24:00
+CREATE TABLE pharmacy_hours (
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 1 AND 7), -- ISO 8601: 1 — понедельник
+    opens TIME NOT NULL,
+    closes TIME NOT NULL,
+    CHECK (opens < closes)
+);
+
+CREATE INDEX pharmacy_hours_pharmacy_idx ON pharmacy_hours(pharmacy_id, weekday);
+
+-- Исключения заменяют недельное расписание на конкретную дату; дата без интервалов — выходной
+CREATE TABLE pharmacy_exceptions (
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (pharmacy_id, date)
+);
+
+CREATE TABLE pharmacy_exception_hours (
+    pharmacy_id INT NOT NULL,
+    date DATE NOT NULL,
+    opens TIME NOT NULL,
+    closes TIME NOT NULL,
+    CHECK (opens < closes),
+    FOREIGN KEY (pharmacy_id, date) REFERENCES pharmacy_exceptions(pharmacy_id, date) ON DELETE CASCADE
+);
+
+CREATE INDEX pharmacy_exception_hours_idx ON pharmacy_exception_hours(pharmacy_id, date);
+
+-- Открыта ли аптека в момент p_at по местному времени. Повторяет models.Pharmacy.ScheduleStatus
+CREATE FUNCTION pharmacy_is_open(p_pharmacy_id INT, p_at TIMESTAMPTZ) RETURNS BOOLEAN
+LANGUAGE SQL STABLE AS $$
+    SELECT CASE
+        WHEN EXISTS (SELECT 1 FROM pharmacy_exceptions e WHERE e.pharmacy_id = l.id AND e.date = l.local::date) THEN
+            EXISTS (
+                SELECT 1 FROM pharmacy_exception_hours h
+                WHERE h.pharmacy_id = l.id AND h.date = l.local::date
+                    AND l.local::time >= h.opens AND l.local::time < h.closes
+            )
+        ELSE
+            EXISTS (
+                SELECT 1 FROM pharmacy_hours h
+                WHERE h.pharmacy_id = l.id AND h.weekday = EXTRACT(ISODOW FROM l.local)
+                    AND l.local::time >= h.opens AND l.local::time < h.closes
+            )
+    END
+    FROM (SELECT p.id, p_at AT TIME ZONE p.timezone AS local FROM pharmacies p WHERE p.id = p_pharmacy_id) l
+$$;
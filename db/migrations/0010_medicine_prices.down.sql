@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS medicine_price_history;
+DROP TABLE IF EXISTS medicine_scheduled_prices;
+DROP TABLE IF EXISTS medicine_price_overrides;
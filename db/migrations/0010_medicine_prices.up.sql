@@ -0,0 +1,42 @@
+-- Цены лекарств в отдельных аптеках, заменяющие цену каталога
+CREATE TABLE medicine_price_overrides (
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (pharmacy_id, medicine_id)
+);
+
+-- Запланированные изменения цен; применяются фоновым обработчиком после effective_at
+CREATE TABLE medicine_scheduled_prices (
+    id SERIAL PRIMARY KEY,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    pharmacy_id INT REFERENCES pharmacies(id) ON DELETE CASCADE, -- NULL — цена каталога
+    price NUMERIC(10, 2) NOT NULL CHECK (price >= 0),
+    effective_at TIMESTAMPTZ NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMP,
+    cancelled_at TIMESTAMP
+);
+
+CREATE INDEX medicine_scheduled_prices_due_idx ON medicine_scheduled_prices(effective_at)
+    WHERE applied_at IS NULL AND cancelled_at IS NULL;
+CREATE INDEX medicine_scheduled_prices_medicine_idx ON medicine_scheduled_prices(medicine_id);
+
+-- История изменений цен каталога и переопределений
+CREATE TABLE medicine_price_history (
+    id SERIAL PRIMARY KEY,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    pharmacy_id INT REFERENCES pharmacies(id) ON DELETE CASCADE, -- NULL — цена каталога
+    old_price NUMERIC(10, 2),
+    new_price NUMERIC(10, 2), -- NULL — переопределение снято
+    changed_by INT REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scheduled_price_id INT REFERENCES medicine_scheduled_prices(id) ON DELETE SET NULL
+);
+
+CREATE INDEX medicine_price_history_medicine_idx ON medicine_price_history(medicine_id, changed_at);
+
+-- Текущие цены каталога становятся первой записью истории
+INSERT INTO medicine_price_history(medicine_id, new_price)
+SELECT id, price FROM medicines WHERE price IS NOT NULL;
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE name IN ('prescription:read', 'prescription:write');
+DROP TABLE IF EXISTS prescription_dispensings;
+ALTER TABLE order_items DROP COLUMN IF EXISTS prescription_id;
+DROP TABLE IF EXISTS prescription_items;
+DROP TABLE IF EXISTS prescriptions;
+ALTER TABLE medicines DROP COLUMN IF EXISTS rx_required;
@@ -0,0 +1,64 @@
+-- Лекарства, отпускаемые только по рецепту
+ALTER TABLE medicines ADD COLUMN rx_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Рецепты. Статусы expired и exhausted вычисляются по датам и остаткам позиций
+CREATE TABLE prescriptions (
+    id SERIAL PRIMARY KEY,
+    number VARCHAR(64) NOT NULL,
+    patient_name VARCHAR(255) NOT NULL,
+    patient_birth_date DATE,
+    prescriber_name VARCHAR(255) NOT NULL,
+    prescriber_license VARCHAR(64) NOT NULL DEFAULT '',
+    issued_on DATE NOT NULL,
+    valid_until DATE NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    CONSTRAINT prescriptions_number_key UNIQUE (number),
+    CONSTRAINT prescriptions_validity CHECK (valid_until >= issued_on)
+);
+
+CREATE INDEX prescriptions_patient_idx ON prescriptions(lower(patient_name));
+
+-- Назначенные лекарства: quantity единиц на один отпуск и refills повторных отпусков.
+-- remaining_quantity — сколько осталось отпустить в текущий раз, remaining_refills — сколько повторов осталось
+CREATE TABLE prescription_items (
+    id SERIAL PRIMARY KEY,
+    prescription_id INT NOT NULL REFERENCES prescriptions(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    refills INT NOT NULL DEFAULT 0 CHECK (refills >= 0),
+    remaining_quantity INT NOT NULL,
+    remaining_refills INT NOT NULL,
+    UNIQUE (prescription_id, medicine_id),
+    CHECK (remaining_quantity BETWEEN 0 AND quantity),
+    CHECK (remaining_refills BETWEEN 0 AND refills)
+);
+
+-- Рецепт, по которому продана позиция заказа
+ALTER TABLE order_items ADD COLUMN prescription_id INT REFERENCES prescriptions(id);
+
+-- Отпуск по рецепту: сколько единиц позиции рецепта списано оплатой позиции заказа.
+-- fill — номер отпуска позиции, из которого списаны единицы (0 — первый, далее номер повтора),
+-- refunded_at — момент возврата заказа, вернувшего единицы на рецепт
+CREATE TABLE prescription_dispensings (
+    id SERIAL PRIMARY KEY,
+    prescription_item_id INT NOT NULL REFERENCES prescription_items(id) ON DELETE CASCADE,
+    order_item_id INT NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    fill INT NOT NULL DEFAULT 0 CHECK (fill >= 0),
+    dispensed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refunded_at TIMESTAMP
+);
+
+CREATE INDEX prescription_dispensings_item_idx ON prescription_dispensings(prescription_item_id);
+
+INSERT INTO permissions(name, description) VALUES
+    ('prescription:read', 'Просмотр рецептов'),
+    ('prescription:write', 'Регистрация и отмена рецептов');
+
+INSERT INTO role_permissions(role_name, permission)
+SELECT role_name, permission
+FROM (VALUES ('Developer'), ('Seller')) AS r(role_name),
+     (VALUES ('prescription:read'), ('prescription:write')) AS p(permission)
+WHERE EXISTS (SELECT 1 FROM roles WHERE name = r.role_name);
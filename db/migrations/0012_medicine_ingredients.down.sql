@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS medicine_ingredients;
+ALTER TABLE medicines
+    DROP COLUMN IF EXISTS ingredients_key,
+    DROP COLUMN IF EXISTS dosage_form,
+    DROP COLUMN IF EXISTS atc_code;
@@ -0,0 +1,21 @@
+-- Классификация лекарств: код ATC ВОЗ и лекарственная форма.
+-- ingredients_key — нормализованный состав (вещества и дозировки), по нему подбираются заменители
+ALTER TABLE medicines
+    ADD COLUMN atc_code VARCHAR(7) CHECK (atc_code ~ '^[A-Z]([0-9]{2}([A-Z]([A-Z]([0-9]{2})?)?)?)?$'),
+    ADD COLUMN dosage_form VARCHAR(32) NOT NULL DEFAULT '',
+    ADD COLUMN ingredients_key TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX medicines_atc_code_idx ON medicines(atc_code text_pattern_ops);
+CREATE INDEX medicines_ingredients_key_idx ON medicines(ingredients_key) WHERE ingredients_key <> '';
+
+-- Действующие вещества лекарства с дозировкой
+CREATE TABLE medicine_ingredients (
+    id SERIAL PRIMARY KEY,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    strength NUMERIC(14, 6) NOT NULL CHECK (strength > 0),
+    unit VARCHAR(16) NOT NULL
+);
+
+CREATE UNIQUE INDEX medicine_ingredients_name_idx ON medicine_ingredients(medicine_id, lower(name));
+CREATE INDEX medicine_ingredients_lower_name_idx ON medicine_ingredients(lower(name));
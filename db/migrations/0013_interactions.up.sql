@@ -0,0 +1,14 @@
+-- База взаимодействий действующих веществ. Названия хранятся в нижнем регистре,
+-- пара упорядочена (ingredient_a <= ingredient_b), поэтому каждая пара встречается один раз
+CREATE TABLE ingredient_interactions (
+    id SERIAL PRIMARY KEY,
+    ingredient_a VARCHAR(255) NOT NULL,
+    ingredient_b VARCHAR(255) NOT NULL,
+    severity VARCHAR(20) NOT NULL CHECK (severity IN ('minor', 'moderate', 'major', 'contraindicated')),
+    description TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ingredient_interactions_pair_key UNIQUE (ingredient_a, ingredient_b),
+    CHECK (ingredient_a <= ingredient_b)
+);
+
+CREATE INDEX ingredient_interactions_b_idx ON ingredient_interactions(ingredient_b);
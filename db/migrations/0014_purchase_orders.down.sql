@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE name IN ('purchase:read', 'purchase:write');
+DROP TABLE IF EXISTS goods_receipt_items;
+DROP TABLE IF EXISTS goods_receipts;
+DROP TABLE IF EXISTS purchase_order_items;
+DROP TABLE IF EXISTS purchase_orders;
+DROP TABLE IF EXISTS suppliers;
@@ -0,0 +1,71 @@
+-- Поставщики лекарств
+CREATE TABLE suppliers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    tax_id VARCHAR(32) NOT NULL DEFAULT '',
+    contact_name VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT suppliers_name_key UNIQUE (name)
+);
+
+-- Заказы поставщикам на поставку в аптеку. Статусы partially_received и received выставляются приёмкой
+CREATE TABLE purchase_orders (
+    id SERIAL PRIMARY KEY,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    supplier_id INT NOT NULL REFERENCES suppliers(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'sent', 'partially_received', 'received', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    total NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX purchase_orders_pharmacy_idx ON purchase_orders(pharmacy_id, created_at);
+CREATE INDEX purchase_orders_supplier_idx ON purchase_orders(supplier_id);
+
+-- Позиции заказа поставщику с закупочной ценой; received_quantity — принято по всем приёмкам
+CREATE TABLE purchase_order_items (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_quantity INT NOT NULL DEFAULT 0 CHECK (received_quantity BETWEEN 0 AND quantity),
+    unit_cost NUMERIC(10, 2) NOT NULL CHECK (unit_cost >= 0),
+    line_total NUMERIC(12, 2) NOT NULL,
+    UNIQUE (purchase_order_id, medicine_id)
+);
+
+-- Приёмки поставок по заказу
+CREATE TABLE goods_receipts (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    received_by INT REFERENCES users(id) ON DELETE SET NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX goods_receipts_order_idx ON goods_receipts(purchase_order_id);
+
+-- Партии, оприходованные приёмкой
+CREATE TABLE goods_receipt_items (
+    id SERIAL PRIMARY KEY,
+    goods_receipt_id INT NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    purchase_order_item_id INT NOT NULL REFERENCES purchase_order_items(id) ON DELETE CASCADE,
+    lot_id INT NOT NULL REFERENCES medicine_lots(id),
+    quantity INT NOT NULL CHECK (quantity > 0)
+);
+
+INSERT INTO permissions(name, description) VALUES
+    ('purchase:read', 'Просмотр поставщиков и заказов поставщикам'),
+    ('purchase:write', 'Управление поставщиками, заказами поставщикам и приёмка поставок');
+
+INSERT INTO role_permissions(role_name, permission)
+SELECT role_name, permission
+FROM (VALUES ('Developer'), ('Seller')) AS r(role_name),
+     (VALUES ('purchase:read'), ('purchase:write')) AS p(permission)
+WHERE EXISTS (SELECT 1 FROM roles WHERE name = r.role_name);
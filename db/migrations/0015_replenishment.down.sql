@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS replenishment_suggestions;
+DROP TABLE IF EXISTS stock_levels;
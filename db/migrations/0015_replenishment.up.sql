@@ -0,0 +1,27 @@
+-- Пороги остатка лекарства в аптеке: при доступном количестве не выше min_quantity
+-- лекарство дозаказывается до max_quantity у поставщика по умолчанию
+CREATE TABLE stock_levels (
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    min_quantity INT NOT NULL CHECK (min_quantity >= 0),
+    max_quantity INT NOT NULL,
+    supplier_id INT REFERENCES suppliers(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pharmacy_id, medicine_id),
+    CHECK (max_quantity >= min_quantity)
+);
+
+-- Последние рассчитанные предложения пополнения; пересчитываются фоновым обработчиком
+CREATE TABLE replenishment_suggestions (
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    on_hand INT NOT NULL,
+    on_order INT NOT NULL,
+    daily_sales NUMERIC(12, 3) NOT NULL,
+    reorder_point INT NOT NULL,
+    target_quantity INT NOT NULL,
+    suggested_quantity INT NOT NULL CHECK (suggested_quantity > 0),
+    supplier_id INT REFERENCES suppliers(id) ON DELETE SET NULL,
+    calculated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (pharmacy_id, medicine_id)
+);
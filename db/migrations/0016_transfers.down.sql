@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transfer_item_lots;
+DROP TABLE IF EXISTS transfer_items;
+DROP TABLE IF EXISTS transfers;
@@ -0,0 +1,39 @@
+-- Перемещения лекарств между аптеками
+CREATE TABLE transfers (
+    id SERIAL PRIMARY KEY,
+    from_pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    to_pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'requested'
+        CHECK (status IN ('requested', 'approved', 'in_transit', 'received', 'rejected', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    requested_by INT REFERENCES users(id) ON DELETE SET NULL,
+    approved_by INT REFERENCES users(id) ON DELETE SET NULL,
+    shipped_by INT REFERENCES users(id) ON DELETE SET NULL,
+    received_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    approved_at TIMESTAMP,
+    shipped_at TIMESTAMP,
+    received_at TIMESTAMP,
+    CHECK (from_pharmacy_id <> to_pharmacy_id)
+);
+
+CREATE INDEX transfers_from_pharmacy_idx ON transfers(from_pharmacy_id, created_at);
+CREATE INDEX transfers_to_pharmacy_idx ON transfers(to_pharmacy_id, created_at);
+
+-- Позиции перемещения
+CREATE TABLE transfer_items (
+    id SERIAL PRIMARY KEY,
+    transfer_id INT NOT NULL REFERENCES transfers(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    UNIQUE (transfer_id, medicine_id)
+);
+
+-- Партии аптеки-отправителя, списанные при отправке позиции
+CREATE TABLE transfer_item_lots (
+    transfer_item_id INT NOT NULL REFERENCES transfer_items(id) ON DELETE CASCADE,
+    lot_id INT NOT NULL REFERENCES medicine_lots(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (transfer_item_id, lot_id)
+);
@@ -0,0 +1,51 @@
+-- Инвентаризации аптек. У аптеки может быть только одна открытая инвентаризация
+CREATE TABLE stock_counts (
+    id SERIAL PRIMARY KEY,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'approved', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    opened_by INT REFERENCES users(id) ON DELETE SET NULL,
+    closed_by INT REFERENCES users(id) ON DELETE SET NULL,
+    opened_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX stock_counts_pharmacy_idx ON stock_counts(pharmacy_id, opened_at);
+CREATE UNIQUE INDEX stock_counts_open_key ON stock_counts(pharmacy_id) WHERE status = 'open';
+
+-- Строки инвентаризации: остаток на момент открытия и учётный остаток на момент последнего подсчёта
+CREATE TABLE stock_count_lines (
+    stock_count_id INT NOT NULL REFERENCES stock_counts(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id) ON DELETE CASCADE,
+    expected_quantity INT NOT NULL DEFAULT 0,
+    book_quantity INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (stock_count_id, medicine_id)
+);
+
+-- Подсчёты устройств; повторный подсчёт тем же устройством заменяет предыдущий
+CREATE TABLE stock_count_entries (
+    stock_count_id INT NOT NULL,
+    medicine_id INT NOT NULL,
+    device_id VARCHAR(100) NOT NULL CHECK (device_id <> ''),
+    quantity INT NOT NULL CHECK (quantity >= 0),
+    reason TEXT NOT NULL DEFAULT '',
+    counted_by INT REFERENCES users(id) ON DELETE SET NULL,
+    counted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (stock_count_id, medicine_id, device_id),
+    FOREIGN KEY (stock_count_id, medicine_id) REFERENCES stock_count_lines(stock_count_id, medicine_id) ON DELETE CASCADE
+);
+
+-- Корректировки остатков с причиной
+CREATE TABLE stock_adjustments (
+    id SERIAL PRIMARY KEY,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    stock_count_id INT REFERENCES stock_counts(id) ON DELETE SET NULL,
+    quantity INT NOT NULL CHECK (quantity <> 0),
+    reason TEXT NOT NULL,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX stock_adjustments_pharmacy_idx ON stock_adjustments(pharmacy_id, created_at);
+CREATE INDEX stock_adjustments_count_idx ON stock_adjustments(stock_count_id);
@@ -0,0 +1,51 @@
+-- Журнал движений остатков. Строки только добавляются: сумма движений аптеки по лекарству
+-- равна остатку в pharmacy_medicines, по партии — остатку партии
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    lot_id INT REFERENCES medicine_lots(id),
+    type VARCHAR(20) NOT NULL CHECK (type IN ('opening', 'receipt', 'sale', 'return', 'transfer_out', 'transfer_in', 'write_off', 'adjustment')),
+    quantity INT NOT NULL CHECK (quantity <> 0),
+    reference_type VARCHAR(20) CHECK (reference_type IN ('order', 'goods_receipt', 'transfer', 'stock_count')),
+    reference_id INT,
+    reason TEXT NOT NULL DEFAULT '',
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((reference_type IS NULL) = (reference_id IS NULL))
+);
+
+CREATE INDEX stock_movements_pharmacy_idx ON stock_movements(pharmacy_id, created_at);
+CREATE INDEX stock_movements_medicine_idx ON stock_movements(medicine_id, pharmacy_id);
+CREATE INDEX stock_movements_reference_idx ON stock_movements(reference_type, reference_id);
+
+-- Изменять и удалять движения нельзя; допускается только обнуление user_id при удалении пользователя
+CREATE FUNCTION stock_movements_immutable() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.user_id IS NULL AND to_jsonb(NEW) - 'user_id' = to_jsonb(OLD) - 'user_id' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'stock movements are append-only' USING ERRCODE = 'restrict_violation';
+END
+$$;
+
+CREATE TRIGGER stock_movements_immutable
+BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW EXECUTE FUNCTION stock_movements_immutable();
+
+-- Начальные остатки: по строке на партию и строка на остаток без партии
+INSERT INTO stock_movements(pharmacy_id, medicine_id, lot_id, type, quantity)
+SELECT pharmacy_id, medicine_id, id, 'opening', quantity
+FROM medicine_lots
+WHERE quantity > 0;
+
+INSERT INTO stock_movements(pharmacy_id, medicine_id, type, quantity)
+SELECT pm.pharmacy_id, pm.medicine_id, 'opening', pm.quantity - COALESCE(l.quantity, 0)
+FROM pharmacy_medicines pm
+LEFT JOIN (
+    SELECT pharmacy_id, medicine_id, SUM(quantity) AS quantity
+    FROM medicine_lots
+    GROUP BY pharmacy_id, medicine_id
+) l ON l.pharmacy_id = pm.pharmacy_id AND l.medicine_id = pm.medicine_id
+WHERE pm.quantity > COALESCE(l.quantity, 0);
@@ -0,0 +1,4 @@
+-- Ограничения stock_movements не сужаются: движения по отзывам остаются в журнале
+DROP TABLE IF EXISTS recall_actions;
+DROP TABLE IF EXISTS recall_items;
+DROP TABLE IF EXISTS recalls;
@@ -0,0 +1,47 @@
+-- Отзывы серий лекарств производителями. Пока отзыв не отменён, партии его серий не продаются
+CREATE TABLE recalls (
+    id SERIAL PRIMARY KEY,
+    manufacturer VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    recall_date DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled')),
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP
+);
+
+CREATE INDEX recalls_status_idx ON recalls(status, recall_date);
+
+-- Отозванные серии; серия отзывается во всех аптеках
+CREATE TABLE recall_items (
+    recall_id INT NOT NULL REFERENCES recalls(id) ON DELETE CASCADE,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    lot_number VARCHAR(100) NOT NULL,
+    PRIMARY KEY (recall_id, medicine_id, lot_number)
+);
+
+CREATE INDEX recall_items_lot_idx ON recall_items(medicine_id, lot_number);
+
+-- Карантин и возврат производителю отозванных единиц партий
+CREATE TABLE recall_actions (
+    id SERIAL PRIMARY KEY,
+    recall_id INT NOT NULL REFERENCES recalls(id),
+    lot_id INT NOT NULL REFERENCES medicine_lots(id),
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    action VARCHAR(20) NOT NULL CHECK (action IN ('quarantine', 'return')),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    notes TEXT NOT NULL DEFAULT '',
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX recall_actions_recall_idx ON recall_actions(recall_id, lot_id);
+
+-- Возврат отозванных единиц записывается в журнал движений
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_type_check;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_type_check
+    CHECK (type IN ('opening', 'receipt', 'sale', 'return', 'transfer_out', 'transfer_in', 'write_off', 'adjustment', 'recall_return'));
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_reference_type_check;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_reference_type_check
+    CHECK (reference_type IN ('order', 'goods_receipt', 'transfer', 'stock_count', 'recall'));
@@ -0,0 +1,3 @@
+-- Штрихкод лекарства: GTIN, приведённый к 14 цифрам (EAN-13 хранится с ведущим нулём)
+ALTER TABLE medicines
+    ADD COLUMN gtin VARCHAR(14) CONSTRAINT medicines_gtin_key UNIQUE CHECK (gtin ~ '^[0-9]{14}$');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pack_events;
+DROP TABLE IF EXISTS packs;
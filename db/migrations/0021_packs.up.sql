@@ -0,0 +1,36 @@
+-- Реестр серийных упаковок: упаковка определяется GTIN и серийным номером из кода DataMatrix.
+-- Моменты хранятся с часовым поясом: выгрузка выбытия передаётся регулятору в UTC
+CREATE TABLE packs (
+    id SERIAL PRIMARY KEY,
+    medicine_id INT NOT NULL REFERENCES medicines(id),
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    gtin VARCHAR(14) NOT NULL CHECK (gtin ~ '^[0-9]{14}$'),
+    serial VARCHAR(20) NOT NULL CHECK (serial <> ''),
+    lot_number VARCHAR(20) NOT NULL DEFAULT '',
+    expiry_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'received'
+        CHECK (status IN ('received', 'in_stock', 'dispensed', 'returned', 'destroyed')),
+    order_id INT REFERENCES orders(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT packs_gtin_serial_key UNIQUE (gtin, serial)
+);
+
+CREATE INDEX packs_pharmacy_idx ON packs(pharmacy_id, status);
+CREATE INDEX packs_medicine_idx ON packs(medicine_id);
+
+-- История статусов упаковки; у регистрации from_status пуст
+CREATE TABLE pack_events (
+    id SERIAL PRIMARY KEY,
+    pack_id INT NOT NULL REFERENCES packs(id),
+    pharmacy_id INT NOT NULL REFERENCES pharmacies(id),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    order_id INT REFERENCES orders(id),
+    reason TEXT NOT NULL DEFAULT '',
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX pack_events_pack_idx ON pack_events(pack_id, id);
+CREATE INDEX pack_events_dispensed_idx ON pack_events(created_at) WHERE to_status = 'dispensed';
@@ -0,0 +1,266 @@
+// Package migrations содержит версионированные SQL-миграции схемы базы данных,
+// встроенные в бинарный файл, и средства для их применения и отката.
+//
+// Каждая миграция состоит из пары файлов NNNN_name.up.sql и NNNN_name.down.sql.
+// Применённые версии хранятся в таблице schema_migrations.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockKey — ключ advisory lock, под которым выполняются миграции,
+// чтобы несколько реплик API не применяли их одновременно.
+const lockKey int64 = 7243510428
+
+// Migration represents a single numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus represents a migration together with its applied state.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load читает встроенные файлы миграций и возвращает их в порядке версий
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+// load читает миграции из корня fsys. Версии должны идти подряд с 1, у каждой — ровно по одному
+// файлу up и down, чтобы пропущенная или повторённая миграция обнаружилась до применения
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	fileNames := map[string]string{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", fileName, direction)
+		}
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %v", fileName, err)
+		}
+
+		key := fmt.Sprintf("%d.%s", version, direction)
+		if previous, ok := fileNames[key]; ok {
+			return nil, fmt.Errorf("migration files %s and %s have the same version %d", previous, fileName, version)
+		}
+		fileNames[key] = fileName
+
+		body, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, migration.Name, parts[1])
+		}
+		if direction == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, migration := range migrations {
+		if want := int64(i + 1); migration.Version != want {
+			return nil, fmt.Errorf("migration %04d_%s found where version %04d is expected: versions must be consecutive from 1", migration.Version, migration.Name, want)
+		}
+	}
+	return migrations, nil
+}
+
+// Up применяет все ещё не применённые миграции и возвращает их количество
+func Up(db *sql.DB) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := apply(conn, migration.Up, "INSERT INTO schema_migrations(version, name) VALUES($1, $2)", migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("migration %04d_%s up: %v", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает последние steps применённых миграций и возвращает их количество
+func Down(db *sql.DB, steps int) (int, error) {
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	err = withLock(db, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if err := apply(conn, migration.Down, "DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+				return fmt.Errorf("migration %04d_%s down: %v", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status возвращает список известных миграций с отметкой о применении
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	err = withLock(db, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock выполняет fn на отдельном соединении, удерживая advisory lock
+func withLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("unable to acquire migration lock: %v", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %v", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// apply выполняет SQL миграции и запись в schema_migrations в одной транзакции
+func apply(conn *sql.Conn, body, record string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,148 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  []Migration
+		err   string
+	}{
+		{
+			// Версии сравниваются как числа: 10 идёт после 9, хотя по имени файла раньше
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"10_orders.up.sql":             file("CREATE TABLE orders ();"),
+				"10_orders.down.sql":           file("DROP TABLE orders;"),
+				"0002_pharmacy_stock.up.sql":   file("CREATE TABLE pharmacy_stock ();"),
+				"0002_pharmacy_stock.down.sql": file("DROP TABLE pharmacy_stock;"),
+				"0001_init.up.sql":             file("CREATE TABLE users ();"),
+				"0001_init.down.sql":           file("DROP TABLE users;"),
+				"0003_a_b_c.up.sql":            file("SELECT 3;"),
+				"0003_a_b_c.down.sql":          file("SELECT -3;"),
+				"0004_d.up.sql":                file("SELECT 4;"),
+				"0004_d.down.sql":              file("SELECT -4;"),
+				"0005_e.up.sql":                file("SELECT 5;"),
+				"0005_e.down.sql":              file("SELECT -5;"),
+				"0006_f.up.sql":                file("SELECT 6;"),
+				"0006_f.down.sql":              file("SELECT -6;"),
+				"0007_g.up.sql":                file("SELECT 7;"),
+				"0007_g.down.sql":              file("SELECT -7;"),
+				"0008_h.up.sql":                file("SELECT 8;"),
+				"0008_h.down.sql":              file("SELECT -8;"),
+				"0009_i.up.sql":                file("SELECT 9;"),
+				"0009_i.down.sql":              file("SELECT -9;"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "init", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"},
+				{Version: 2, Name: "pharmacy_stock", Up: "CREATE TABLE pharmacy_stock ();", Down: "DROP TABLE pharmacy_stock;"},
+				{Version: 3, Name: "a_b_c", Up: "SELECT 3;", Down: "SELECT -3;"},
+				{Version: 4, Name: "d", Up: "SELECT 4;", Down: "SELECT -4;"},
+				{Version: 5, Name: "e", Up: "SELECT 5;", Down: "SELECT -5;"},
+				{Version: 6, Name: "f", Up: "SELECT 6;", Down: "SELECT -6;"},
+				{Version: 7, Name: "g", Up: "SELECT 7;", Down: "SELECT -7;"},
+				{Version: 8, Name: "h", Up: "SELECT 8;", Down: "SELECT -8;"},
+				{Version: 9, Name: "i", Up: "SELECT 9;", Down: "SELECT -9;"},
+				{Version: 10, Name: "orders", Up: "CREATE TABLE orders ();", Down: "DROP TABLE orders;"},
+			},
+		},
+		{name: "no migrations", files: fstest.MapFS{}, want: []Migration{}},
+		{
+			name:  "unexpected file",
+			files: fstest.MapFS{"0001_init.up.sql": file("SELECT 1;"), "0001_init.down.sql": file("SELECT 1;"), "README.md": file("")},
+			err:   "unexpected migration file README.md",
+		},
+		{
+			name:  "missing name",
+			files: fstest.MapFS{"0001.up.sql": file("SELECT 1;"), "0001.down.sql": file("SELECT 1;")},
+			err:   "must be named NNNN_name.down.sql",
+		},
+		{
+			name:  "invalid version",
+			files: fstest.MapFS{"first_init.up.sql": file("SELECT 1;"), "first_init.down.sql": file("SELECT 1;")},
+			err:   "has invalid version",
+		},
+		{
+			name:  "missing down",
+			files: fstest.MapFS{"0001_init.up.sql": file("SELECT 1;")},
+			err:   "migration 0001_init must have both up and down files",
+		},
+		{
+			name:  "empty up",
+			files: fstest.MapFS{"0001_init.up.sql": file(""), "0001_init.down.sql": file("SELECT 1;")},
+			err:   "migration 0001_init must have both up and down files",
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"0001_init.up.sql":    file("SELECT 1;"),
+				"0001_users.down.sql": file("SELECT 1;"),
+			},
+			err: "migration 1 has conflicting names",
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"0001_init.up.sql":   file("SELECT 1;"),
+				"0001_init.down.sql": file("SELECT 1;"),
+				"001_init.up.sql":    file("SELECT 2;"),
+			},
+			err: "migration files 0001_init.up.sql and 001_init.up.sql have the same version 1",
+		},
+		{
+			name: "gap",
+			files: fstest.MapFS{
+				"0001_init.up.sql":    file("SELECT 1;"),
+				"0001_init.down.sql":  file("SELECT 1;"),
+				"0003_packs.up.sql":   file("SELECT 3;"),
+				"0003_packs.down.sql": file("SELECT 3;"),
+			},
+			err: "migration 0003_packs found where version 0002 is expected",
+		},
+		{
+			name:  "not starting from 1",
+			files: fstest.MapFS{"0002_init.up.sql": file("SELECT 1;"), "0002_init.down.sql": file("SELECT 1;")},
+			err:   "migration 0002_init found where version 0001 is expected",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := load(tt.files)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("migrations = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("migrations[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Встроенные миграции проходят те же проверки, что и в TestLoad
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+}
@@ -0,0 +1,58 @@
+// Package geo содержит расчёт расстояний между точками на Земле
+// и геокодирование адресов в координаты.
+package geo
+
+import "math"
+
+// EarthRadiusKm — средний радиус Земли, используемый в формуле гаверсинусов
+const EarthRadiusKm = 6371.0
+
+// Point — точка на поверхности Земли в градусах
+type Point struct {
+	Lat float64 `json:"latitude"`
+	Lon float64 `json:"longitude"`
+}
+
+// Valid сообщает, лежат ли координаты в допустимых диапазонах
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Distance возвращает расстояние между точками по дуге большого круга в километрах
+func Distance(a, b Point) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLon := radians(b.Lon - a.Lon)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// Box — прямоугольник координат, в который гарантированно попадают все точки круга.
+// Используется как грубый индексируемый фильтр перед точным расчётом расстояния.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// BoundingBox возвращает прямоугольник вокруг круга радиусом radiusKm.
+// Если круг захватывает полюс или линию перемены дат, долгота не ограничивается.
+func BoundingBox(center Point, radiusKm float64) Box {
+	angle := radiusKm / EarthRadiusKm
+	dLat := angle * 180 / math.Pi
+	box := Box{MinLat: center.Lat - dLat, MaxLat: center.Lat + dLat, MinLon: -180, MaxLon: 180}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat = math.Max(box.MinLat, -90)
+		box.MaxLat = math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	// Наибольшее отклонение по долготе точек круга на сфере
+	dLon := math.Asin(math.Sin(angle)/math.Cos(radians(center.Lat))) * 180 / math.Pi
+	if center.Lon-dLon >= -180 && center.Lon+dLon <= 180 {
+		box.MinLon, box.MaxLon = center.Lon-dLon, center.Lon+dLon
+	}
+	return box
+}
@@ -0,0 +1,67 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// destination возвращает точку на расстоянии distanceKm от start по азимуту bearing в градусах
+func destination(start Point, distanceKm, bearing float64) Point {
+	angle := distanceKm / EarthRadiusKm
+	lat1, lon1, theta := radians(start.Lat), radians(start.Lon), radians(bearing)
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angle) + math.Cos(lat1)*math.Sin(angle)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(angle)*math.Cos(lat1), math.Cos(angle)-math.Sin(lat1)*math.Sin(lat2))
+	lon := math.Mod(lon2*180/math.Pi+540, 360) - 180
+	return Point{Lat: lat2 * 180 / math.Pi, Lon: lon}
+}
+
+func TestBoundingBox(t *testing.T) {
+	const eps = 1e-6
+
+	tests := []struct {
+		name     string
+		center   Point
+		radiusKm float64
+		// wholeLon — круг захватывает полюс или линию перемены дат, долгота не ограничивается
+		wholeLon bool
+		want     Box
+	}{
+		{
+			name: "equator", center: Point{Lat: 0, Lon: 0}, radiusKm: 2 * math.Pi * EarthRadiusKm / 360,
+			want: Box{MinLat: -1, MaxLat: 1, MinLon: -1, MaxLon: 1},
+		},
+		{name: "mid latitude", center: Point{Lat: 55.7558, Lon: 37.6173}, radiusKm: 5},
+		{name: "southern hemisphere", center: Point{Lat: -33.8688, Lon: 151.2093}, radiusKm: 50},
+		{
+			name: "north pole", center: Point{Lat: 89.99, Lon: 10}, radiusKm: 5, wholeLon: true,
+			want: Box{MinLat: 89.99 - 5/EarthRadiusKm*180/math.Pi, MaxLat: 90, MinLon: -180, MaxLon: 180},
+		},
+		{
+			name: "south pole", center: Point{Lat: -89.99, Lon: 10}, radiusKm: 5, wholeLon: true,
+			want: Box{MinLat: -90, MaxLat: -89.99 + 5/EarthRadiusKm*180/math.Pi, MinLon: -180, MaxLon: 180},
+		},
+		{name: "date line", center: Point{Lat: 65, Lon: 179.99}, radiusKm: 5, wholeLon: true},
+		{name: "date line west", center: Point{Lat: -17, Lon: -179.98}, radiusKm: 10, wholeLon: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := BoundingBox(tt.center, tt.radiusKm)
+			if tt.want != (Box{}) {
+				if math.Abs(box.MinLat-tt.want.MinLat) > eps || math.Abs(box.MaxLat-tt.want.MaxLat) > eps ||
+					math.Abs(box.MinLon-tt.want.MinLon) > eps || math.Abs(box.MaxLon-tt.want.MaxLon) > eps {
+					t.Errorf("box = %+v, want %+v", box, tt.want)
+				}
+			}
+			if whole := box.MinLon == -180 && box.MaxLon == 180; whole != tt.wholeLon {
+				t.Errorf("box = %+v, unbounded longitude = %v, want %v", box, whole, tt.wholeLon)
+			}
+			// Все точки окружности радиуса radiusKm должны попасть в прямоугольник
+			for bearing := 0.0; bearing < 360; bearing += 5 {
+				p := destination(tt.center, tt.radiusKm, bearing)
+				if p.Lat < box.MinLat-eps || p.Lat > box.MaxLat+eps || p.Lon < box.MinLon-eps || p.Lon > box.MaxLon+eps {
+					t.Errorf("point %+v at bearing %v is outside box %+v", p, bearing, box)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package geo
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"pharmacy-test/models"
+)
+
+// ErrNotFound возвращается, если геокодер не знает адреса
+var ErrNotFound = errors.New("address not found")
+
+// Geocoder определяет координаты адреса. Реализация может обращаться к внешнему
+// сервису; FileGeocoder — офлайн-замена, работающая по справочнику из файла.
+type Geocoder interface {
+	Geocode(ctx context.Context, address models.Address) (Point, error)
+}
+
+// FileGeocoder ищет адреса в справочнике, загруженном из CSV-файла
+type FileGeocoder struct {
+	points map[string]Point
+}
+
+// fileColumns — обязательные колонки справочника. Пустая улица задаёт координаты
+// центра города, которые используются, если точного адреса в справочнике нет.
+var fileColumns = []string{"country", "city", "street", "latitude", "longitude"}
+
+// LoadFileGeocoder читает справочник адресов из CSV-файла с заголовком
+// country,city,street,latitude,longitude
+func LoadFileGeocoder(path string) (*FileGeocoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range fileColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%s: missing column %q", path, name)
+		}
+	}
+
+	g := &FileGeocoder{points: map[string]Point{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string { return record[index[name]] }
+
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(field("latitude")), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(field("longitude")), 64)
+		point := Point{Lat: lat, Lon: lon}
+		if latErr != nil || lonErr != nil || !point.Valid() {
+			return nil, fmt.Errorf("%s:%d: invalid coordinates", path, line)
+		}
+		g.points[addressKey(field("country"), field("city"), field("street"))] = point
+	}
+	return g, nil
+}
+
+// Geocode возвращает координаты адреса, а если улица неизвестна — координаты центра города
+func (g *FileGeocoder) Geocode(ctx context.Context, address models.Address) (Point, error) {
+	if point, ok := g.points[addressKey(address.Country, address.City, address.Street)]; ok {
+		return point, nil
+	}
+	if point, ok := g.points[addressKey(address.Country, address.City, "")]; ok {
+		return point, nil
+	}
+	return Point{}, ErrNotFound
+}
+
+// addressKey нормализует адрес: регистр, пунктуация, лишние пробелы и порядок слов
+// не учитываются, поэтому "ул. Тверская, 1" и "Тверская ул 1" совпадают
+func addressKey(country, city, street string) string {
+	normalize := func(s string) string {
+		words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		sort.Strings(words)
+		return strings.Join(words, " ")
+	}
+	return normalize(country) + "|" + normalize(city) + "|" + normalize(street)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// DBStatsResponse структура со статистикой пула соединений с базой данных
+type DBStatsResponse struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
+
+// Получение статистики пула соединений с базой данных
+func (h *Handler) GetDBStats(w http.ResponseWriter, r *http.Request) {
+	if h.Pool == nil {
+		writeError(w, r, notFound("Database pool is not configured"))
+		return
+	}
+
+	stats := h.Pool.Stats()
+	writeJSON(w, http.StatusOK, DBStatsResponse{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"pharmacy-test/store"
+
+	"github.com/google/uuid"
+)
+
+// Коды ошибок API. Клиенты опираются на них, поэтому коды не меняются
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeInsufficientStock    = "insufficient_stock"
+	CodeInvalidTransition    = "invalid_transition"
+	CodePrescriptionRequired = "prescription_required"
+	CodePrescriptionInvalid  = "prescription_invalid"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError описывает ошибку в конкретном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// APIError ошибка, которая отдаётся клиенту в теле ответа {"error": {...}}
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// ErrorResponse конверт ответа с ошибкой
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+func newError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// validationError возвращает ошибку валидации с перечнем неверных полей
+func validationError(details ...FieldError) *APIError {
+	e := newError(http.StatusBadRequest, CodeValidationFailed, "Validation failed")
+	e.Details = details
+	return e
+}
+
+// fieldError возвращает ошибку валидации одного поля
+func fieldError(field, message string) *APIError {
+	return validationError(FieldError{Field: field, Message: message})
+}
+
+// invalidJSON возвращает ошибку для тела запроса, которое не удалось разобрать
+func invalidJSON() *APIError {
+	return newError(http.StatusBadRequest, CodeBadRequest, "Request body is not valid JSON")
+}
+
+func unauthorized(message string) *APIError {
+	return newError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func forbidden(message string) *APIError {
+	return newError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func notFound(message string) *APIError {
+	return newError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func conflict(message string) *APIError {
+	return newError(http.StatusConflict, CodeConflict, message)
+}
+
+// writeError отправляет ошибку клиенту, добавляя идентификатор запроса
+func writeError(w http.ResponseWriter, r *http.Request, e *APIError) {
+	e.RequestID = RequestIDFromContext(r.Context())
+	writeJSON(w, e.Status, ErrorResponse{Error: e})
+}
+
+// writeInternalError пишет исходную ошибку в лог, а клиенту отдаёт только общий текст
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	log.Printf("request %s: error %s: %v", RequestIDFromContext(r.Context()), action, err)
+	writeError(w, r, newError(http.StatusInternalServerError, CodeInternal, "Internal server error"))
+}
+
+// writeStoreError переводит ошибку хранилища в HTTP-ответ.
+// Текст ошибок хранилища формируется пакетом store и не содержит внутренних подробностей,
+// поэтому его можно показывать; остальные ошибки скрываются за internal_error.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	var conflictErr *store.ConflictError
+	var notFoundErr *store.NotFoundError
+	switch {
+	case errors.As(err, &conflictErr):
+		e := conflict("Value already exists")
+		e.Details = []FieldError{{Field: conflictErr.Field, Message: "already exists"}}
+		writeError(w, r, e)
+	case errors.As(err, &notFoundErr):
+		// Запрос ссылается на несуществующую запись — это ошибка поля, а не отсутствие ресурса
+		writeError(w, r, fieldError(notFoundErr.Field, "does not exist"))
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, r, notFound(storeMessage(err, store.ErrNotFound, "Resource not found")))
+	case errors.Is(err, store.ErrConflict):
+		writeError(w, r, conflict(storeMessage(err, store.ErrConflict, "Resource conflicts with existing data")))
+	case errors.Is(err, store.ErrInsufficientStock):
+		writeError(w, r, newError(http.StatusConflict, CodeInsufficientStock, storeMessage(err, store.ErrInsufficientStock, "Insufficient stock")))
+	case errors.Is(err, store.ErrInvalidTransition):
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, storeMessage(err, store.ErrInvalidTransition, "Invalid status transition")))
+	case errors.Is(err, store.ErrPrescriptionRequired):
+		writeError(w, r, newError(http.StatusBadRequest, CodePrescriptionRequired, storeMessage(err, store.ErrPrescriptionRequired, "Prescription required")))
+	case errors.Is(err, store.ErrForbidden):
+		writeError(w, r, forbidden(storeMessage(err, store.ErrForbidden, "Forbidden")))
+	case errors.Is(err, store.ErrPrescriptionInvalid):
+		writeError(w, r, newError(http.StatusConflict, CodePrescriptionInvalid, storeMessage(err, store.ErrPrescriptionInvalid, "Prescription is not valid")))
+	default:
+		writeInternalError(w, r, err, action)
+	}
+}
+
+// storeMessage возвращает текст уточнённой ошибки хранилища или fallback для голой ошибки-образца
+func storeMessage(err, sentinel error, fallback string) string {
+	if err == sentinel {
+		return fallback
+	}
+	return err.Error()
+}
+
+type requestIDKey struct{}
+
+// RequestID присваивает запросу идентификатор: берёт корректный X-Request-ID клиента или создаёт новый.
+// Идентификатор возвращается в заголовке X-Request-ID и в теле ошибок.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext возвращает идентификатор запроса или пустую строку
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID допускает только короткие идентификаторы из печатных ASCII-символов
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+	"pharmacy-test/store/memory"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "secret"
+
+// testNow фиксированное время хранилища, чтобы сроки годности и рецептов не зависели от даты запуска
+var testNow = time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// testAPI обработчики поверх хранилища в памяти с маршрутами и проверкой разрешений, как в main.go
+type testAPI struct {
+	store  *memory.Store
+	router *mux.Router
+}
+
+func newTestAPI() *testAPI {
+	s := memory.New()
+	s.Now = func() time.Time { return testNow }
+	h := handlers.New(s)
+	r := mux.NewRouter()
+
+	r.HandleFunc("/api/users", h.CreateUserWithDetails).Methods("POST")
+	r.HandleFunc("/api/users/login", h.LoginUser).Methods("POST")
+	r.HandleFunc("/api/users/logout", h.LogoutUser).Methods("PUT")
+	r.HandleFunc("/api/user/details", h.GetUserWithDetailsByCookie).Methods("GET")
+
+	r.HandleFunc("/api/pharmacies", h.RequirePermission(models.PermPharmacyRead, h.GetPharmacies)).Methods("GET")
+	r.HandleFunc("/api/pharmacies/{id:[0-9]+}", h.RequirePermission(models.PermPharmacyWrite, h.DeletePharmacy)).Methods("DELETE")
+
+	r.HandleFunc("/api/medicines/{id:[0-9]+}", h.RequirePermission(models.PermMedicineWrite, h.UpdateMedicine)).Methods("PUT")
+	r.HandleFunc("/api/interactions", h.RequirePermission(models.PermMedicineRead, h.GetInteractions)).Methods("GET")
+	r.HandleFunc("/api/interactions/import", h.RequirePermission(models.PermMedicineWrite, h.ImportInteractions)).Methods("POST")
+
+	r.HandleFunc("/api/pharmacies/{id:[0-9]+}/stock", h.RequirePermission(models.PermStockRead, h.GetPharmacyStock)).Methods("GET")
+	r.HandleFunc("/api/pharmacies/{id:[0-9]+}/stock/{medicineId:[0-9]+}", h.RequirePermission(models.PermStockWrite, h.UpdatePharmacyStock)).Methods("PUT")
+	r.HandleFunc("/api/pharmacies/{id:[0-9]+}/stock/{medicineId:[0-9]+}/consume", h.RequirePermission(models.PermStockWrite, h.ConsumePharmacyStock)).Methods("POST")
+	r.HandleFunc("/api/medicines/{id:[0-9]+}/availability", h.RequirePermission(models.PermMedicineRead, h.GetMedicineAvailability)).Methods("GET")
+	r.HandleFunc("/api/medicines/{id:[0-9]+}/lots", h.RequirePermission(models.PermStockWrite, h.CreateMedicineLot)).Methods("POST")
+
+	r.HandleFunc("/api/orders", h.RequirePermission(models.PermOrderWrite, h.CreateOrder)).Methods("POST")
+	r.HandleFunc("/api/orders/{id:[0-9]+}/status", h.RequirePermission(models.PermOrderWrite, h.UpdateOrderStatus)).Methods("PUT")
+	r.HandleFunc("/api/transfers", h.RequirePermission(models.PermStockWrite, h.CreateTransfer)).Methods("POST")
+	r.HandleFunc("/api/transfers/{id:[0-9]+}/status", h.RequirePermission(models.PermStockWrite, h.UpdateTransferStatus)).Methods("PUT")
+
+	r.HandleFunc("/api/stock-counts", h.RequirePermission(models.PermStockWrite, h.OpenStockCount)).Methods("POST")
+	r.HandleFunc("/api/stock-counts/{id:[0-9]+}/entries", h.RequirePermission(models.PermStockWrite, h.SubmitStockCountEntries)).Methods("POST")
+
+	r.HandleFunc("/api/prescriptions/{id:[0-9]+}", h.RequirePermission(models.PermPrescriptionRead, h.GetPrescriptionByID)).Methods("GET")
+
+	return &testAPI{store: s, router: r}
+}
+
+// do выполняет запрос; body кодируется в JSON, пустой token означает запрос без сессии
+func (a *testAPI) do(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect проверяет код ответа и раскладывает тело в out, если он не nil
+func (a *testAPI) expect(t *testing.T, rec *httptest.ResponseRecorder, status int, out interface{}) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("decoding response: %v; body: %s", err, rec.Body.String())
+		}
+	}
+}
+
+// expectError проверяет код ответа и код ошибки в конверте error
+func (a *testAPI) expectError(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	a.expect(t, rec, status, &body)
+	if body.Error.Code != code {
+		t.Fatalf("error code = %q, want %q; body: %s", body.Error.Code, code, rec.Body.String())
+	}
+}
+
+// userWithRole создаёт пользователя с ролью position напрямую в хранилище и входит под ним
+func (a *testAPI) userWithRole(t *testing.T, username, position string) (models.UserWithDetails, string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.UserWithDetails{
+		Username: username,
+		Password: string(hash),
+		Details:  models.UserDetails{Email: username + "@example.com", PhoneNumber: "+7-" + username, Position: position},
+	}
+	if err := a.store.CreateUser(context.Background(), &user); err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return user, a.login(t, username)
+}
+
+func (a *testAPI) login(t *testing.T, username string) string {
+	t.Helper()
+	var session struct {
+		Cookie string `json:"cookie"`
+	}
+	rec := a.do(t, "POST", "/api/users/login", "", handlers.LoginRequest{Username: username, Password: testPassword})
+	a.expect(t, rec, http.StatusOK, &session)
+	return session.Cookie
+}
+
+func (a *testAPI) pharmacy(t *testing.T) models.Pharmacy {
+	t.Helper()
+	pharmacy := models.Pharmacy{Name: "Аптека №1", Address: models.Address{Street: "ул. Ленина, 1", City: "Москва", Country: "Россия"}}
+	if err := a.store.CreatePharmacy(context.Background(), &pharmacy); err != nil {
+		t.Fatalf("creating pharmacy: %v", err)
+	}
+	return pharmacy
+}
+
+func (a *testAPI) medicine(t *testing.T, name string, price float64, rxRequired bool) models.Medicine {
+	t.Helper()
+	medicine := models.Medicine{Name: name, Manufacturer: "Фармстандарт", ProductionDate: "2024-01-01", Packaging: "10 таблеток",
+		Price: price, RxRequired: rxRequired}
+	if err := a.store.CreateMedicine(context.Background(), &medicine); err != nil {
+		t.Fatalf("creating medicine: %v", err)
+	}
+	return medicine
+}
+
+// stock возвращает остаток лекарства в аптеке по ответу GET /api/pharmacies/{id}/stock
+func (a *testAPI) stock(t *testing.T, token string, pharmacyID, medicineID int) int {
+	t.Helper()
+	var items []models.StockItem
+	a.expect(t, a.do(t, "GET", "/api/pharmacies/"+strconv.Itoa(pharmacyID)+"/stock", token, nil), http.StatusOK, &items)
+	for _, item := range items {
+		if item.MedicineID == medicineID {
+			return item.Quantity
+		}
+	}
+	return 0
+}
+
+func TestRequirePermission(t *testing.T) {
+	api := newTestAPI()
+	_, buyer := api.userWithRole(t, "buyer", "Buyer")
+	_, seller := api.userWithRole(t, "seller", "Seller")
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+		code   string
+	}{
+		{"no session", "GET", "", http.StatusUnauthorized, handlers.CodeUnauthorized},
+		{"unknown token", "GET", "not-a-session", http.StatusUnauthorized, handlers.CodeUnauthorized},
+		{"read allowed", "GET", buyer, http.StatusOK, ""},
+		{"write without permission", "DELETE", buyer, http.StatusForbidden, handlers.CodeForbidden},
+		{"write with permission", "DELETE", seller, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/pharmacies"
+			if tt.method == "DELETE" {
+				path = "/api/pharmacies/" + strconv.Itoa(api.pharmacy(t).ID)
+			}
+			rec := api.do(t, tt.method, path, tt.token, nil)
+			if tt.code != "" {
+				api.expectError(t, rec, tt.status, tt.code)
+				return
+			}
+			api.expect(t, rec, tt.status, nil)
+		})
+	}
+}
+
+func TestSignUpIgnoresRequestedRole(t *testing.T) {
+	api := newTestAPI()
+	_, admin := api.userWithRole(t, "admin", "Developer")
+
+	signUp := func(token, username string) models.UserWithDetails {
+		t.Helper()
+		var user models.UserWithDetails
+		rec := api.do(t, "POST", "/api/users", token, models.UserWithDetails{
+			Username: username,
+			Password: testPassword,
+			Details:  models.UserDetails{Email: username + "@example.com", PhoneNumber: "+7-" + username, Position: "Seller"},
+		})
+		api.expect(t, rec, http.StatusOK, &user)
+		return user
+	}
+
+	if user := signUp("", "anonymous"); user.Details.Position != models.DefaultSignupRole {
+		t.Errorf("anonymous sign-up position = %q, want %q", user.Details.Position, models.DefaultSignupRole)
+	}
+	if user := signUp(admin, "hired"); user.Details.Position != "Seller" {
+		t.Errorf("position assigned by user admin = %q, want Seller", user.Details.Position)
+	}
+}
+
+func TestRevokedSessionIsUnauthorized(t *testing.T) {
+	api := newTestAPI()
+	user, token := api.userWithRole(t, "buyer", "Buyer")
+
+	var details models.UserWithDetails
+	api.expect(t, api.do(t, "GET", "/api/user/details", token, nil), http.StatusOK, &details)
+	if details.ID != user.ID || details.Password != "" {
+		t.Fatalf("details = %+v, want user %d without password", details, user.ID)
+	}
+
+	api.expect(t, api.do(t, "PUT", "/api/users/logout", token, nil), http.StatusOK, nil)
+	api.expectError(t, api.do(t, "GET", "/api/user/details", token, nil), http.StatusUnauthorized, handlers.CodeUnauthorized)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// MaxInteractionsImportBytes — наибольший размер CSV-файла импорта взаимодействий
+const MaxInteractionsImportBytes = 10 << 20
+
+// interactionColumns — колонки CSV-файла импорта; description необязательна
+var interactionColumns = []string{"ingredient_a", "ingredient_b", "severity"}
+
+// InteractionCheckRequest структура для проверки взаимодействий
+type InteractionCheckRequest struct {
+	MedicineIDs []int `json:"medicine_ids"`
+}
+
+// Получение базы взаимодействий, с фильтром по действующему веществу
+func (h *Handler) GetInteractions(w http.ResponseWriter, r *http.Request) {
+	interactions, err := h.Interactions.ListInteractions(r.Context(), r.URL.Query().Get("ingredient"))
+	if err != nil {
+		writeStoreError(w, r, err, "fetching interactions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, interactions)
+}
+
+// Импорт базы взаимодействий из CSV с заголовком ingredient_a,ingredient_b,severity,description.
+// Файл загружается целиком или не загружается вовсе; известные пары обновляются
+func (h *Handler) ImportInteractions(w http.ResponseWriter, r *http.Request) {
+	interactions, apiErr := parseInteractionsCSV(http.MaxBytesReader(w, r.Body, MaxInteractionsImportBytes))
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	result, err := h.Interactions.ImportInteractions(r.Context(), interactions)
+	if err != nil {
+		writeStoreError(w, r, err, "importing interactions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Удаление взаимодействия из базы
+func (h *Handler) DeleteInteraction(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	if err := h.Interactions.DeleteInteraction(r.Context(), id); err != nil {
+		writeStoreError(w, r, err, "deleting interaction")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Проверка взаимодействий между лекарствами корзины
+func (h *Handler) CheckInteractions(w http.ResponseWriter, r *http.Request) {
+	var request InteractionCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if len(request.MedicineIDs) == 0 {
+		writeError(w, r, fieldError("medicine_ids", "must contain at least one medicine"))
+		return
+	}
+
+	warnings, err := h.Interactions.CheckInteractions(r.Context(), request.MedicineIDs)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, fieldError("medicine_ids", err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "checking interactions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, warnings)
+}
+
+// orderWarnings проверяет взаимодействия между лекарствами созданного заказа.
+// Заказ уже сохранён, поэтому ошибка проверки только пишется в лог
+func (h *Handler) orderWarnings(r *http.Request, order *models.Order) {
+	ids := make([]int, 0, len(order.Items))
+	for _, item := range order.Items {
+		ids = append(ids, item.MedicineID)
+	}
+	warnings, err := h.Interactions.CheckInteractions(r.Context(), ids)
+	if err != nil {
+		log.Printf("request %s: checking order interactions: %v", RequestIDFromContext(r.Context()), err)
+		return
+	}
+	order.Warnings = warnings
+}
+
+// parseInteractionsCSV читает файл импорта; ошибки строк возвращаются вместе с номерами строк
+func parseInteractionsCSV(body io.Reader) ([]models.Interaction, *APIError) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fieldError("body", "must be a CSV file with header "+strings.Join(interactionColumns, ",")+",description")
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	var details []FieldError
+	for _, name := range interactionColumns {
+		if _, ok := index[name]; !ok {
+			details = append(details, FieldError{Field: "header", Message: fmt.Sprintf("missing column %q", name)})
+		}
+	}
+	if details != nil {
+		return nil, validationError(details...)
+	}
+
+	interactions := []models.Interaction{}
+	pairs := map[[2]string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fieldError("body", err.Error())
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		interaction := models.Interaction{
+			IngredientA: field("ingredient_a"),
+			IngredientB: field("ingredient_b"),
+			Severity:    strings.ToLower(field("severity")),
+			Description: field("description"),
+		}
+		interaction.Normalize()
+		prefix := fmt.Sprintf("line[%d].", line)
+		if interaction.IngredientA == "" || interaction.IngredientB == "" {
+			details = append(details, FieldError{Field: prefix + "ingredient", Message: "both ingredients are required"})
+		}
+		if models.SeverityRank(interaction.Severity) == 0 {
+			details = append(details, FieldError{Field: prefix + "severity", Message: "must be one of: " + strings.Join(models.Severities, ", ")})
+		}
+		key := [2]string{interaction.IngredientA, interaction.IngredientB}
+		if first, ok := pairs[key]; ok {
+			details = append(details, FieldError{Field: prefix + "ingredient", Message: fmt.Sprintf("pair is already listed on line %d", first)})
+		}
+		pairs[key] = line
+		interactions = append(interactions, interaction)
+	}
+
+	if details != nil {
+		return nil, validationError(details...)
+	}
+	if len(interactions) == 0 {
+		return nil, fieldError("body", "must contain at least one interaction")
+	}
+	return interactions, nil
+}
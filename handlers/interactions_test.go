@@ -0,0 +1,131 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+// importCSV отправляет файл импорта взаимодействий как есть, без кодирования в JSON
+func (a *testAPI) importCSV(t *testing.T, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/interactions/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportInteractionsCSV(t *testing.T) {
+	const header = "ingredient_a,ingredient_b,severity,description\n"
+
+	tests := []struct {
+		name    string
+		body    string
+		details []handlers.FieldError
+	}{
+		{
+			name:    "empty file",
+			details: []handlers.FieldError{{Field: "body", Message: "must be a CSV file with header ingredient_a,ingredient_b,severity,description"}},
+		},
+		{
+			name:    "header only",
+			body:    header,
+			details: []handlers.FieldError{{Field: "body", Message: "must contain at least one interaction"}},
+		},
+		{
+			name: "missing columns",
+			body: "ingredient_a,description\nibuprofen,\n",
+			details: []handlers.FieldError{
+				{Field: "header", Message: `missing column "ingredient_b"`},
+				{Field: "header", Message: `missing column "severity"`},
+			},
+		},
+		{
+			name: "malformed row",
+			body: header + "ibuprofen,warfarin,major,\"незакрытая кавычка\n",
+			// Текст ошибки разбора задаёт encoding/csv, поэтому проверяется только поле
+			details: []handlers.FieldError{{Field: "body"}},
+		},
+		{
+			name: "row errors with line numbers",
+			body: header +
+				"ibuprofen,warfarin,major,Риск кровотечения\n" +
+				"aspirin,,moderate,\n" +
+				"aspirin,ibuprofen,severe,\n" +
+				"Warfarin , IBUPROFEN,minor,\n",
+			details: []handlers.FieldError{
+				{Field: "line[3].ingredient", Message: "both ingredients are required"},
+				{Field: "line[4].severity", Message: "must be one of: minor, moderate, major, contraindicated"},
+				{Field: "line[5].ingredient", Message: "pair is already listed on line 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI()
+			_, token := api.userWithRole(t, "seller", "Seller")
+			rec := api.importCSV(t, token, tt.body)
+			var body struct {
+				Error struct {
+					Code    string                `json:"code"`
+					Details []handlers.FieldError `json:"details"`
+				} `json:"error"`
+			}
+			api.expect(t, rec, http.StatusBadRequest, &body)
+			if body.Error.Code != handlers.CodeValidationFailed {
+				t.Errorf("error code = %q, want %q", body.Error.Code, handlers.CodeValidationFailed)
+			}
+			if len(body.Error.Details) != len(tt.details) {
+				t.Fatalf("details = %+v, want %+v", body.Error.Details, tt.details)
+			}
+			for i := range tt.details {
+				got := body.Error.Details[i]
+				if tt.details[i].Message == "" {
+					got.Message = ""
+				}
+				if got != tt.details[i] {
+					t.Errorf("details[%d] = %+v, want %+v", i, body.Error.Details[i], tt.details[i])
+				}
+			}
+
+			// Файл с ошибкой не загружается даже частично
+			var interactions []models.Interaction
+			api.expect(t, api.do(t, "GET", "/api/interactions", token, nil), http.StatusOK, &interactions)
+			if len(interactions) != 0 {
+				t.Errorf("interactions after failed import = %+v, want none", interactions)
+			}
+		})
+	}
+}
+
+func TestImportInteractionsUpdatesKnownPairs(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+
+	// Колонки в любом порядке и регистре; description необязательна
+	var result models.InteractionImportResult
+	api.expect(t, api.importCSV(t, token, " Severity ,Ingredient_B,ingredient_a\nMAJOR,Ibuprofen,Warfarin\nminor,paracetamol,warfarin\n"), http.StatusOK, &result)
+	if result != (models.InteractionImportResult{Created: 2}) {
+		t.Errorf("first import = %+v, want 2 created", result)
+	}
+	api.expect(t, api.importCSV(t, token, "ingredient_a,ingredient_b,severity,description\nibuprofen,WARFARIN,contraindicated,Риск кровотечения\n"), http.StatusOK, &result)
+	if result != (models.InteractionImportResult{Updated: 1}) {
+		t.Errorf("second import = %+v, want 1 updated", result)
+	}
+
+	var interactions []models.Interaction
+	api.expect(t, api.do(t, "GET", "/api/interactions?ingredient=Ibuprofen", token, nil), http.StatusOK, &interactions)
+	if len(interactions) != 1 {
+		t.Fatalf("interactions = %+v, want one ibuprofen pair", interactions)
+	}
+	got := interactions[0]
+	if got.IngredientA != "ibuprofen" || got.IngredientB != "warfarin" || got.Severity != models.SeverityContraindicated || got.Description != "Риск кровотечения" {
+		t.Errorf("interaction = %+v, want normalized pair with updated severity and description", got)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// ConsumeRequest структура для списания лекарства со склада аптеки
+type ConsumeRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// ConsumeResult структура с результатом списания по FEFO
+type ConsumeResult struct {
+	PharmacyID  int                    `json:"pharmacy_id"`
+	MedicineID  int                    `json:"medicine_id"`
+	Quantity    int                    `json:"quantity"`
+	Allocations []models.LotAllocation `json:"allocations"`
+}
+
+// Получение партий лекарства
+func (h *Handler) GetMedicineLots(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	lots, err := h.Stock.ListMedicineLots(r.Context(), medicineID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching lots")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
+
+// Поступление новой партии лекарства в аптеку
+func (h *Handler) CreateMedicineLot(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var lot models.Lot
+	if err := json.NewDecoder(r.Body).Decode(&lot); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	lot.MedicineID = medicineID
+
+	if lot.LotNumber == "" {
+		writeError(w, r, fieldError("lot_number", "is required"))
+		return
+	}
+	if lot.Quantity <= 0 {
+		writeError(w, r, fieldError("quantity", "must be positive"))
+		return
+	}
+	expiry, err := time.Parse(models.DateLayout, lot.ExpiryDate)
+	if err != nil {
+		writeError(w, r, fieldError("expiry_date", "must be a date in YYYY-MM-DD format"))
+		return
+	}
+	if lot.ProductionDate != "" {
+		production, err := time.Parse(models.DateLayout, lot.ProductionDate)
+		if err != nil {
+			writeError(w, r, fieldError("production_date", "must be a date in YYYY-MM-DD format"))
+			return
+		}
+		if production.After(expiry) {
+			writeError(w, r, fieldError("production_date", "must not be after expiry date"))
+			return
+		}
+	}
+
+	err = h.Stock.ReceiveLot(r.Context(), &lot, currentUserID(r))
+	if errors.Is(err, store.ErrConflict) {
+		e := conflict(fmt.Sprintf("Lot %s already exists with a different expiry date", lot.LotNumber))
+		e.Details = []FieldError{{Field: "expiry_date", Message: "does not match the existing lot"}}
+		writeError(w, r, e)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "inserting lot")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, lot)
+}
+
+// Списание лекарства из аптеки по правилу FEFO
+func (h *Handler) ConsumePharmacyStock(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	medicineID, err := pathID(r, "medicineId")
+	if err != nil {
+		writeError(w, r, fieldError("medicineId", "must be an integer"))
+		return
+	}
+
+	var request ConsumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if request.Quantity <= 0 {
+		writeError(w, r, fieldError("quantity", "must be positive"))
+		return
+	}
+
+	allocations, err := h.Stock.ConsumeStock(r.Context(), pharmacyID, medicineID, request.Quantity, currentUserID(r))
+	if err != nil {
+		writeStoreError(w, r, err, "consuming stock")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ConsumeResult{
+		PharmacyID:  pharmacyID,
+		MedicineID:  medicineID,
+		Quantity:    request.Quantity,
+		Allocations: allocations,
+	})
+}
+
+// Получение партий аптеки, срок годности которых истекает в ближайшие N дней
+func (h *Handler) GetExpiringLots(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 {
+			writeError(w, r, fieldError("days", "must be a non-negative integer"))
+			return
+		}
+	}
+
+	lots, err := h.Stock.ListExpiringLots(r.Context(), pharmacyID, days)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching lots")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lots)
+}
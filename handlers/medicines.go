@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/search"
+	"pharmacy-test/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Получение страницы лекарств с фильтрами по производителю, цене, аптеке, отпуску по рецепту,
+// коду ATC и действующему веществу
+func (h *Handler) GetMedicines(w http.ResponseWriter, r *http.Request) {
+	page, apiErr := parsePage(r, models.MedicineSorts)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter := models.MedicineFilter{Manufacturer: r.URL.Query().Get("manufacturer")}
+	if filter.MinPrice, apiErr = parseFloatParam(r, "min_price"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.MaxPrice, apiErr = parseFloatParam(r, "max_price"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.PharmacyID, apiErr = parseIntParam(r, "pharmacy_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.RxRequired, apiErr = parseBoolParam(r, "rx_required"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter.ATCCode = strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("atc")))
+	if filter.ATCCode != "" && !models.ValidATCCode(filter.ATCCode) {
+		writeError(w, r, fieldError("atc", "must be a WHO ATC code or its prefix, e.g. N02BE"))
+		return
+	}
+	filter.Ingredient = models.IngredientName(r.URL.Query().Get("ingredient"))
+
+	medicines, err := h.Medicines.ListMedicines(r.Context(), filter, page)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching medicines")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, medicines)
+}
+
+// Получение лекарства по ID
+func (h *Handler) GetMedicineByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	medicine, err := h.Medicines.GetMedicine(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching medicine")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, medicine)
+}
+
+// Создание нового лекарства
+func (h *Handler) CreateMedicine(w http.ResponseWriter, r *http.Request) {
+	var medicine models.Medicine
+	if err := json.NewDecoder(r.Body).Decode(&medicine); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateClassification(&medicine); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	medicine.ChangedBy = currentUserID(r)
+
+	err := h.Medicines.CreateMedicine(r.Context(), &medicine)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека
+		writeError(w, r, fieldError("pharmacy_ids", err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "inserting medicine")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, medicine)
+}
+
+// Обновление информации о лекарстве
+func (h *Handler) UpdateMedicine(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	// Тело накладывается на сохранённое лекарство: поля, которых нет в запросе, сохраняют прежние значения.
+	// Иначе запрос без rx_required снял бы с рецептурного лекарства проверку рецепта при продаже
+	stored, err := h.Medicines.GetMedicine(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching medicine")
+		return
+	}
+	// Состав из запроса заменяет сохранённый целиком: при разборе поверх старого среза
+	// у элементов остались бы прежние дозировка и единица, если в запросе их нет
+	updatedMedicine := stored
+	updatedMedicine.Ingredients = nil
+	if err := json.NewDecoder(r.Body).Decode(&updatedMedicine); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if updatedMedicine.Ingredients == nil {
+		updatedMedicine.Ingredients = stored.Ingredients
+	}
+	if apiErr := validateClassification(&updatedMedicine); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	updatedMedicine.ID = id
+	updatedMedicine.ChangedBy = currentUserID(r)
+
+	if err := h.Medicines.UpdateMedicine(r.Context(), &updatedMedicine); err != nil {
+		writeStoreError(w, r, err, "updating medicine")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updatedMedicine)
+}
+
+// Поиск лекарства по отсканированному коду: EAN-13 (EAN-8, UPC-A, GTIN-14) или строке GS1 DataMatrix.
+// Вместе с лекарством отдаются его партии с серией из кода, в аптеке pharmacy_id, если она указана
+func (h *Handler) GetMedicineByBarcode(w http.ResponseWriter, r *http.Request) {
+	barcode, err := models.ParseBarcode(mux.Vars(r)["code"])
+	if err != nil {
+		writeError(w, r, fieldError("code", err.Error()))
+		return
+	}
+	pharmacyID, apiErr := parseIntParam(r, "pharmacy_id")
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	medicine, err := h.Medicines.GetMedicineByGTIN(r.Context(), barcode.GTIN)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, notFound(fmt.Sprintf("No medicine with GTIN %s", barcode.GTIN)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "fetching medicine by barcode")
+		return
+	}
+
+	lookup := models.BarcodeLookup{Barcode: barcode, Lots: []models.Lot{}}
+	for _, lot := range medicine.Lots {
+		if pharmacyID != 0 && lot.PharmacyID != pharmacyID {
+			continue
+		}
+		if barcode.LotNumber != "" && lot.LotNumber != barcode.LotNumber {
+			continue
+		}
+		lookup.Lots = append(lookup.Lots, lot)
+	}
+	medicine.Lots = nil
+	lookup.Medicine = medicine
+
+	writeJSON(w, http.StatusOK, lookup)
+}
+
+// Удаление лекарства
+func (h *Handler) DeleteMedicine(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	if err := h.Medicines.DeleteMedicine(r.Context(), id); err != nil {
+		writeStoreError(w, r, err, "deleting medicine")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Поиск лекарств по названию, производителю и упаковке с подсветкой совпадений
+func (h *Handler) SearchMedicines(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(search.Tokens(query)) == 0 {
+		writeError(w, r, fieldError("q", "must contain at least one letter or digit"))
+		return
+	}
+
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			writeError(w, r, fieldError("limit", "must be an integer from 1 to 100"))
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.Medicines.SearchMedicines(r.Context(), query, limit)
+	if err != nil {
+		writeStoreError(w, r, err, "searching medicines")
+		return
+	}
+
+	tokens := search.Tokens(query)
+	for i := range results {
+		medicine := results[i].Medicine
+		fields := map[string]string{"name": medicine.Name, "manufacturer": medicine.Manufacturer, "packaging": medicine.Packaging}
+		for field, text := range fields {
+			if fragment, ok := search.Highlight(text, tokens); ok {
+				if results[i].Highlights == nil {
+					results[i].Highlights = map[string]string{}
+				}
+				results[i].Highlights[field] = fragment
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// Заменители лекарства: те же действующие вещества и дозировки, с разницей в цене
+// и остатком в аптеке pharmacy_id, если она указана
+func (h *Handler) GetMedicineSubstitutes(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	pharmacyID, apiErr := parseIntParam(r, "pharmacy_id")
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	substitutes, err := h.Medicines.ListSubstitutes(r.Context(), id, pharmacyID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching substitutes")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, substitutes)
+}
+
+// validateClassification проверяет код ATC, GTIN, лекарственную форму и состав лекарства
+// и приводит их к каноническому виду
+func validateClassification(medicine *models.Medicine) *APIError {
+	var details []FieldError
+	medicine.ATCCode = strings.ToUpper(strings.TrimSpace(medicine.ATCCode))
+	if medicine.ATCCode != "" && !models.ValidATCCode(medicine.ATCCode) {
+		details = append(details, FieldError{Field: "atc_code", Message: "must be a WHO ATC code, e.g. N02BE01"})
+	}
+	if gtin := strings.TrimSpace(medicine.GTIN); gtin != "" {
+		var ok bool
+		if medicine.GTIN, ok = models.NormalizeGTIN(gtin); !ok {
+			details = append(details, FieldError{Field: "gtin", Message: "must be an EAN-13, EAN-8, UPC-A or GTIN-14 code with a valid check digit"})
+		}
+	} else {
+		medicine.GTIN = ""
+	}
+	medicine.DosageForm = strings.ToLower(strings.TrimSpace(medicine.DosageForm))
+	if medicine.DosageForm != "" && !containsString(models.DosageForms, medicine.DosageForm) {
+		details = append(details, FieldError{Field: "dosage_form", Message: "must be one of: " + strings.Join(models.DosageForms, ", ")})
+	}
+
+	names := map[string]bool{}
+	for i := range medicine.Ingredients {
+		ingredient := &medicine.Ingredients[i]
+		field := fmt.Sprintf("ingredients[%d]", i)
+		ingredient.Name = strings.Join(strings.Fields(ingredient.Name), " ")
+		if ingredient.Name == "" {
+			details = append(details, FieldError{Field: field + ".name", Message: "is required"})
+		} else if names[models.IngredientName(ingredient.Name)] {
+			details = append(details, FieldError{Field: field + ".name", Message: "is listed more than once"})
+		}
+		names[models.IngredientName(ingredient.Name)] = true
+		if ingredient.Strength <= 0 {
+			details = append(details, FieldError{Field: field + ".strength", Message: "must be positive"})
+		}
+		unit := ""
+		for _, u := range models.IngredientUnits {
+			if strings.EqualFold(u, strings.TrimSpace(ingredient.Unit)) {
+				unit = u
+			}
+		}
+		if unit == "" {
+			details = append(details, FieldError{Field: field + ".unit", Message: "must be one of: " + strings.Join(models.IngredientUnits, ", ")})
+		}
+		ingredient.Unit = unit
+	}
+
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestUpdateMedicineMergesBody(t *testing.T) {
+	newMedicine := func(t *testing.T, api *testAPI) models.Medicine {
+		t.Helper()
+		medicine := models.Medicine{
+			Name: "Панадол", Manufacturer: "GSK", ProductionDate: "2024-01-01", Packaging: "12 таблеток", Price: 120,
+			RxRequired: true, ATCCode: "N02BE01", GTIN: "04006381333931", DosageForm: "tablet",
+			Ingredients: []models.ActiveIngredient{{Name: "Paracetamol", Strength: 500, Unit: "mg"}},
+		}
+		if err := api.store.CreateMedicine(context.Background(), &medicine); err != nil {
+			t.Fatalf("creating medicine: %v", err)
+		}
+		return medicine
+	}
+
+	tests := []struct {
+		name   string
+		body   map[string]interface{}
+		status int
+		code   string
+		want   []models.ActiveIngredient
+	}{
+		{
+			name:   "omitted ingredients are kept",
+			body:   map[string]interface{}{"price": 130},
+			status: http.StatusOK,
+			want:   []models.ActiveIngredient{{Name: "Paracetamol", Strength: 500, Unit: "mg"}},
+		},
+		{
+			name:   "ingredients are replaced whole",
+			body:   map[string]interface{}{"ingredients": []map[string]interface{}{{"name": "Ibuprofen", "strength": 200, "unit": "mg"}}},
+			status: http.StatusOK,
+			want:   []models.ActiveIngredient{{Name: "Ibuprofen", Strength: 200, Unit: "mg"}},
+		},
+		{
+			name:   "partial ingredient is validated",
+			body:   map[string]interface{}{"ingredients": []map[string]interface{}{{"name": "Ibuprofen"}}},
+			status: http.StatusBadRequest,
+			code:   handlers.CodeValidationFailed,
+		},
+		{
+			name:   "empty list clears ingredients",
+			body:   map[string]interface{}{"ingredients": []interface{}{}},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI()
+			_, token := api.userWithRole(t, "seller", "Seller")
+			medicine := newMedicine(t, api)
+			rec := api.do(t, "PUT", "/api/medicines/"+strconv.Itoa(medicine.ID), token, tt.body)
+			if tt.code != "" {
+				api.expectError(t, rec, tt.status, tt.code)
+				return
+			}
+			var updated models.Medicine
+			api.expect(t, rec, tt.status, &updated)
+
+			stored, err := api.store.GetMedicine(context.Background(), medicine.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Ingredients) != len(tt.want) {
+				t.Fatalf("ingredients = %+v, want %+v", stored.Ingredients, tt.want)
+			}
+			for i := range tt.want {
+				if stored.Ingredients[i] != tt.want[i] {
+					t.Errorf("ingredients[%d] = %+v, want %+v", i, stored.Ingredients[i], tt.want[i])
+				}
+			}
+			if !stored.RxRequired || stored.GTIN != medicine.GTIN || stored.ATCCode != medicine.ATCCode || stored.DosageForm != medicine.DosageForm {
+				t.Errorf("stored = %+v, want rx_required, gtin, atc_code and dosage_form of %+v", stored, medicine)
+			}
+		})
+	}
+}
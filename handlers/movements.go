@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+)
+
+// Журнал движений остатков аптеки за период, с фильтром по лекарству и типу движения.
+// Параметры from и to принимают момент в RFC 3339 или дату YYYY-MM-DD; дата в to включает весь день
+func (h *Handler) GetStockMovements(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	page, apiErr := parsePage(r, models.MovementSorts)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	filter := models.MovementFilter{PharmacyID: pharmacyID, Type: r.URL.Query().Get("type")}
+	if filter.MedicineID, apiErr = parseIntParam(r, "medicine_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.Type != "" && !containsString(models.MovementTypes, filter.Type) {
+		writeError(w, r, fieldError("type", "must be one of: "+strings.Join(models.MovementTypes, ", ")))
+		return
+	}
+	if filter.From, apiErr = parsePeriodParam(r, "from", false); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.To, apiErr = parsePeriodParam(r, "to", true); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		writeError(w, r, fieldError("to", "must be after from"))
+		return
+	}
+
+	movements, err := h.Movements.ListStockMovements(r.Context(), filter, page)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock movements")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, movements)
+}
+
+// Остатки аптеки, рассчитанные по журналу движений на момент at (по умолчанию — текущий)
+func (h *Handler) GetStockBalances(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		at, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, r, fieldError("at", "must be a time in RFC 3339 format"))
+			return
+		}
+	}
+
+	balances, err := h.Movements.ListStockBalances(r.Context(), pharmacyID, at)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock balances")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// parsePeriodParam читает необязательную границу периода: момент в RFC 3339 или дату YYYY-MM-DD.
+// Для верхней границы (end) дата означает конец дня
+func parsePeriodParam(r *http.Request, name string, end bool) (*time.Time, *APIError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(models.DateLayout, value, time.Local)
+	if err != nil {
+		return nil, fieldError(name, "must be a time in RFC 3339 format or a date in YYYY-MM-DD format")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
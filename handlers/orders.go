@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// OrderStatusRequest структура для смены статуса заказа
+type OrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// Создание заказа. Цены берутся из каталога на сервере,
+// при статусе "paid" товары списываются со склада и с рецептов в той же транзакции.
+func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	var order models.Order
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	if order.Status == "" {
+		order.Status = models.OrderStatusDraft
+	}
+	if order.Status != models.OrderStatusDraft && order.Status != models.OrderStatusPaid {
+		writeError(w, r, fieldError("status", "must be draft or paid"))
+		return
+	}
+	if len(order.Items) == 0 {
+		writeError(w, r, fieldError("items", "must contain at least one item"))
+		return
+	}
+	var details []FieldError
+	for i, item := range order.Items {
+		if item.Quantity <= 0 {
+			details = append(details, FieldError{Field: fmt.Sprintf("items[%d].quantity", i), Message: "must be positive"})
+		}
+	}
+	if len(details) > 0 {
+		writeError(w, r, validationError(details...))
+		return
+	}
+
+	// Продавец определяется по токену сессии, если он передан
+	order.SellerID = currentUserID(r)
+
+	err := h.Orders.CreateOrder(r.Context(), &order)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека, лекарство или рецепт
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "creating order")
+		return
+	}
+
+	// Предупреждения о взаимодействиях не мешают созданию заказа
+	h.orderWarnings(r, &order)
+	writeJSON(w, http.StatusCreated, order)
+}
+
+// Получение заказа по ID
+func (h *Handler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	order, err := h.Orders.GetOrder(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+// Получение списка заказов, с фильтром по аптеке и статусу
+func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	var filter models.OrderFilter
+	if value := r.URL.Query().Get("pharmacy_id"); value != "" {
+		pharmacyID, err := strconv.Atoi(value)
+		if err != nil {
+			writeError(w, r, fieldError("pharmacy_id", "must be an integer"))
+			return
+		}
+		filter.PharmacyID = pharmacyID
+	}
+	filter.Status = r.URL.Query().Get("status")
+
+	orders, err := h.Orders.ListOrders(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching orders")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+// Смена статуса заказа: оплата, отмена черновика или возврат
+func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request OrderStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	order, err := h.Orders.UpdateOrderStatus(r.Context(), id, request.Status, currentUserID(r))
+	if errors.Is(err, store.ErrInvalidTransition) {
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Cannot change order status to %s", request.Status)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating order status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
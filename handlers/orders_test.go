@@ -0,0 +1,176 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestOrderPayAndRefund(t *testing.T) {
+	api := newTestAPI()
+	seller, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Парацетамол", 45.5, false)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, token, handlers.StockUpdateRequest{Quantity: intPtr(10)}), http.StatusOK, nil)
+
+	var order models.Order
+	api.expect(t, api.do(t, "POST", "/api/orders", token, models.Order{
+		PharmacyID: pharmacy.ID,
+		Status:     models.OrderStatusPaid,
+		Items:      []models.OrderItem{{MedicineID: medicine.ID, Quantity: 3}},
+	}), http.StatusCreated, &order)
+	if order.Status != models.OrderStatusPaid || order.PaidAt == nil {
+		t.Fatalf("order status = %q, paid_at = %v, want paid", order.Status, order.PaidAt)
+	}
+	if order.SellerID == nil || *order.SellerID != seller.ID {
+		t.Errorf("seller_id = %v, want %d", order.SellerID, seller.ID)
+	}
+	if order.Total != 136.5 {
+		t.Errorf("total = %v, want 136.5", order.Total)
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 7 {
+		t.Fatalf("stock after payment = %d, want 7", got)
+	}
+
+	statusPath := "/api/orders/" + strconv.Itoa(order.ID) + "/status"
+	api.expect(t, api.do(t, "PUT", statusPath, token, handlers.OrderStatusRequest{Status: models.OrderStatusRefunded}), http.StatusOK, &order)
+	if order.Status != models.OrderStatusRefunded {
+		t.Fatalf("order status = %q, want refunded", order.Status)
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 10 {
+		t.Errorf("stock after refund = %d, want 10", got)
+	}
+
+	// Возвращённый заказ нельзя вернуть повторно
+	rec := api.do(t, "PUT", statusPath, token, handlers.OrderStatusRequest{Status: models.OrderStatusRefunded})
+	api.expectError(t, rec, http.StatusConflict, handlers.CodeInvalidTransition)
+}
+
+func TestPayDraftOrder(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Ибупрофен", 80, false)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, token, handlers.StockUpdateRequest{Quantity: intPtr(5)}), http.StatusOK, nil)
+
+	var order models.Order
+	api.expect(t, api.do(t, "POST", "/api/orders", token, models.Order{
+		PharmacyID: pharmacy.ID,
+		Items:      []models.OrderItem{{MedicineID: medicine.ID, Quantity: 2}},
+	}), http.StatusCreated, &order)
+	if order.Status != models.OrderStatusDraft {
+		t.Fatalf("order status = %q, want draft", order.Status)
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 5 {
+		t.Fatalf("stock after draft = %d, want 5", got)
+	}
+
+	statusPath := "/api/orders/" + strconv.Itoa(order.ID) + "/status"
+	api.expect(t, api.do(t, "PUT", statusPath, token, handlers.OrderStatusRequest{Status: models.OrderStatusPaid}), http.StatusOK, &order)
+	if order.Status != models.OrderStatusPaid {
+		t.Fatalf("order status = %q, want paid", order.Status)
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 3 {
+		t.Errorf("stock after payment = %d, want 3", got)
+	}
+}
+
+func TestCreateOrderErrors(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	otc := api.medicine(t, "Аскорбиновая кислота", 30, false)
+	rx := api.medicine(t, "Амоксициллин", 150, true)
+	for _, medicine := range []models.Medicine{otc, rx} {
+		stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+		api.expect(t, api.do(t, "PUT", stockPath, token, handlers.StockUpdateRequest{Quantity: intPtr(2)}), http.StatusOK, nil)
+	}
+
+	tests := []struct {
+		name   string
+		item   models.OrderItem
+		status int
+		code   string
+	}{
+		{"insufficient stock", models.OrderItem{MedicineID: otc.ID, Quantity: 3}, http.StatusConflict, handlers.CodeInsufficientStock},
+		{"prescription required", models.OrderItem{MedicineID: rx.ID, Quantity: 1}, http.StatusBadRequest, handlers.CodePrescriptionRequired},
+		{"unknown medicine", models.OrderItem{MedicineID: 999, Quantity: 1}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"non-positive quantity", models.OrderItem{MedicineID: otc.ID}, http.StatusBadRequest, handlers.CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do(t, "POST", "/api/orders", token, models.Order{
+				PharmacyID: pharmacy.ID,
+				Status:     models.OrderStatusPaid,
+				Items:      []models.OrderItem{tt.item},
+			})
+			api.expectError(t, rec, tt.status, tt.code)
+		})
+	}
+
+	// Неудачная оплата ничего не списывает
+	if got := api.stock(t, token, pharmacy.ID, otc.ID); got != 2 {
+		t.Errorf("stock after failed orders = %d, want 2", got)
+	}
+}
+
+func TestRefundRestoresPrescription(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Амоксициллин", 150, true)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, token, handlers.StockUpdateRequest{Quantity: intPtr(20)}), http.StatusOK, nil)
+
+	prescription := models.Prescription{
+		Number:         "RX-1",
+		PatientName:    "Иванов Иван",
+		PrescriberName: "Петров Пётр",
+		IssuedOn:       "2025-02-20",
+		ValidUntil:     "2025-04-20",
+		Items: []models.PrescriptionItem{{
+			MedicineID: medicine.ID, Quantity: 10, Refills: 1, RemainingQuantity: 10, RemainingRefills: 1,
+		}},
+	}
+	if err := api.store.CreatePrescription(context.Background(), &prescription); err != nil {
+		t.Fatalf("creating prescription: %v", err)
+	}
+	prescriptionPath := "/api/prescriptions/" + strconv.Itoa(prescription.ID)
+
+	var order models.Order
+	api.expect(t, api.do(t, "POST", "/api/orders", token, models.Order{
+		PharmacyID: pharmacy.ID,
+		Status:     models.OrderStatusPaid,
+		Items:      []models.OrderItem{{MedicineID: medicine.ID, Quantity: 4, PrescriptionID: &prescription.ID}},
+	}), http.StatusCreated, &order)
+
+	api.expect(t, api.do(t, "GET", prescriptionPath, token, nil), http.StatusOK, &prescription)
+	if item := prescription.Items[0]; item.RemainingQuantity != 6 || item.RemainingRefills != 1 {
+		t.Fatalf("after sale remaining = %d/%d, want 6/1", item.RemainingQuantity, item.RemainingRefills)
+	}
+
+	statusPath := "/api/orders/" + strconv.Itoa(order.ID) + "/status"
+	api.expect(t, api.do(t, "PUT", statusPath, token, handlers.OrderStatusRequest{Status: models.OrderStatusRefunded}), http.StatusOK, nil)
+
+	api.expect(t, api.do(t, "GET", prescriptionPath, token, nil), http.StatusOK, &prescription)
+	item := prescription.Items[0]
+	if item.RemainingQuantity != 10 || item.RemainingRefills != 1 {
+		t.Errorf("after refund remaining = %d/%d, want 10/1", item.RemainingQuantity, item.RemainingRefills)
+	}
+	if len(item.Dispensings) != 1 || item.Dispensings[0].RefundedAt == nil {
+		t.Errorf("dispensings = %+v, want one refunded dispensing", item.Dispensings)
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 20 {
+		t.Errorf("stock after refund = %d, want 20", got)
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// maxPackCodes ограничивает число кодов в одной регистрации упаковок
+const maxPackCodes = 1000
+
+// RegisterPacksRequest структура для регистрации принятых упаковок по кодам DataMatrix
+type RegisterPacksRequest struct {
+	PharmacyID int      `json:"pharmacy_id"`
+	Codes      []string `json:"codes"`
+}
+
+// Получение страницы реестра упаковок с фильтрами по аптеке, лекарству, статусу, GTIN и серийному номеру
+func (h *Handler) GetPacks(w http.ResponseWriter, r *http.Request) {
+	page, apiErr := parsePage(r, models.PackSorts)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	filter := models.PackFilter{Status: r.URL.Query().Get("status"), Serial: r.URL.Query().Get("serial")}
+	if filter.PharmacyID, apiErr = parseIntParam(r, "pharmacy_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.MedicineID, apiErr = parseIntParam(r, "medicine_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.Status != "" && !containsString(models.PackStatuses, filter.Status) {
+		writeError(w, r, fieldError("status", "must be one of: "+strings.Join(models.PackStatuses, ", ")))
+		return
+	}
+	if gtin := r.URL.Query().Get("gtin"); gtin != "" {
+		var ok bool
+		if filter.GTIN, ok = models.NormalizeGTIN(gtin); !ok {
+			writeError(w, r, fieldError("gtin", "must be a GTIN with a valid check digit"))
+			return
+		}
+	}
+
+	packs, err := h.Packs.ListPacks(r.Context(), filter, page)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching packs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, packs)
+}
+
+// Получение упаковки с историей статусов
+func (h *Handler) GetPackByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	pack, err := h.Packs.GetPack(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching pack")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pack)
+}
+
+// Регистрация принятых в аптеку упаковок по отсканированным кодам DataMatrix; все коды или ни одного
+func (h *Handler) RegisterPacks(w http.ResponseWriter, r *http.Request) {
+	var request RegisterPacksRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	packs, apiErr := parsePackCodes(request)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	err := h.Packs.RegisterPacks(r.Context(), packs, currentUserID(r))
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека или GTIN, не присвоенный лекарству
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "registering packs")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, packs)
+}
+
+// Смена статуса упаковки. При продаже можно указать оплаченный заказ, по которому она отпущена
+func (h *Handler) UpdatePackStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var change models.PackStatusChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	change.Reason = strings.TrimSpace(change.Reason)
+	var details []FieldError
+	if change.Status == models.PackStatusReceived || !containsString(models.PackStatuses, change.Status) {
+		details = append(details, FieldError{Field: "status", Message: "must be in_stock, dispensed, returned or destroyed"})
+	}
+	if change.OrderID != nil && change.Status != models.PackStatusDispensed {
+		details = append(details, FieldError{Field: "order_id", Message: "is allowed only for dispensed status"})
+	} else if change.OrderID != nil && *change.OrderID <= 0 {
+		details = append(details, FieldError{Field: "order_id", Message: "must be positive"})
+	}
+	if details != nil {
+		writeError(w, r, validationError(details...))
+		return
+	}
+	change.UserID = currentUserID(r)
+
+	pack, err := h.Packs.UpdatePackStatus(r.Context(), id, change)
+	if errors.Is(err, store.ErrInvalidTransition) {
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Cannot change pack status to %s", change.Status)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating pack status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pack)
+}
+
+// Выгрузка продаж серийных упаковок за период в XML по схеме schemas/dispensing_report.xsd.
+// Параметры from и to принимают момент в RFC 3339 или дату YYYY-MM-DD, как в журнале движений
+func (h *Handler) GetDispensingReport(w http.ResponseWriter, r *http.Request) {
+	var filter models.DispensingFilter
+	var apiErr *APIError
+	if filter.PharmacyID, apiErr = parseIntParam(r, "pharmacy_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.From, apiErr = parsePeriodParam(r, "from", false); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.To, apiErr = parsePeriodParam(r, "to", true); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		writeError(w, r, fieldError("to", "must be after from"))
+		return
+	}
+
+	events, err := h.Packs.ListDispensingEvents(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching dispensing events")
+		return
+	}
+
+	body, err := xml.MarshalIndent(models.NewDispensingReport(filter, events, time.Now()), "", "  ")
+	if err != nil {
+		writeInternalError(w, r, err, "encoding dispensing report")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// parsePackCodes разбирает коды DataMatrix в упаковки аптеки; каждый код должен содержать GTIN и серийный номер
+func parsePackCodes(request RegisterPacksRequest) ([]models.Pack, *APIError) {
+	var details []FieldError
+	if request.PharmacyID <= 0 {
+		details = append(details, FieldError{Field: "pharmacy_id", Message: "is required"})
+	}
+	if len(request.Codes) == 0 || len(request.Codes) > maxPackCodes {
+		details = append(details, FieldError{Field: "codes", Message: fmt.Sprintf("must contain from 1 to %d codes", maxPackCodes)})
+	}
+	packs := make([]models.Pack, 0, len(request.Codes))
+	seen := map[string]bool{}
+	for i, code := range request.Codes {
+		field := fmt.Sprintf("codes[%d]", i)
+		barcode, err := models.ParseBarcode(strings.TrimSpace(code))
+		if err != nil {
+			details = append(details, FieldError{Field: field, Message: err.Error()})
+			continue
+		}
+		if barcode.Serial == "" {
+			details = append(details, FieldError{Field: field, Message: "must contain a serial number (21)"})
+			continue
+		}
+		key := barcode.GTIN + "/" + barcode.Serial
+		if seen[key] {
+			details = append(details, FieldError{Field: field, Message: "is listed more than once"})
+			continue
+		}
+		seen[key] = true
+		packs = append(packs, models.Pack{
+			PharmacyID: request.PharmacyID,
+			GTIN:       barcode.GTIN,
+			Serial:     barcode.Serial,
+			LotNumber:  barcode.LotNumber,
+			ExpiryDate: barcode.ExpiryDate,
+		})
+	}
+	if details != nil {
+		return nil, validationError(details...)
+	}
+	return packs, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pharmacy-test/models"
+)
+
+// parsePage читает параметры limit, sort и cursor. sort — имя поля из sorts,
+// с префиксом "-" для сортировки по убыванию; по умолчанию сортировка по id
+func parsePage(r *http.Request, sorts []string) (models.PageRequest, *APIError) {
+	query := r.URL.Query()
+	page := models.PageRequest{Limit: models.DefaultPageLimit, Sort: "id"}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > models.MaxPageLimit {
+			return page, fieldError("limit", "must be an integer from 1 to "+strconv.Itoa(models.MaxPageLimit))
+		}
+		page.Limit = limit
+	}
+
+	if value := query.Get("sort"); value != "" {
+		page.Desc = strings.HasPrefix(value, "-")
+		page.Sort = strings.TrimPrefix(value, "-")
+		if !containsString(sorts, page.Sort) {
+			return page, fieldError("sort", "must be one of: "+strings.Join(sorts, ", "))
+		}
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.DecodeCursor(value)
+		if err != nil {
+			return page, fieldError("cursor", "is malformed")
+		}
+		if cursor.Sort != page.Sort || cursor.Desc != page.Desc {
+			return page, fieldError("cursor", "was issued for a different sort order")
+		}
+		page.After = &cursor
+	}
+	return page, nil
+}
+
+// parseIntParam читает необязательный целочисленный параметр запроса
+func parseIntParam(r *http.Request, name string) (int, *APIError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fieldError(name, "must be an integer")
+	}
+	return n, nil
+}
+
+// parseFloatParam читает необязательный числовой параметр запроса
+func parseFloatParam(r *http.Request, name string) (*float64, *APIError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fieldError(name, "must be a number")
+	}
+	return &f, nil
+}
+
+// parseBoolParam читает необязательный логический параметр запроса
+func parseBoolParam(r *http.Request, name string) (*bool, *APIError) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fieldError(name, "must be true or false")
+	}
+	return &b, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
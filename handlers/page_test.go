@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestListPagination(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "buyer", "Buyer")
+	nameCursor := models.EncodeCursor(models.Cursor{Sort: "name", Value: "Аптека", ID: 1})
+
+	tests := []struct {
+		name  string
+		query string
+		field string
+	}{
+		{"defaults", "", ""},
+		{"smallest limit", "limit=1", ""},
+		{"largest limit", "limit=" + strconv.Itoa(models.MaxPageLimit), ""},
+		{"zero limit", "limit=0", "limit"},
+		{"limit above maximum", "limit=" + strconv.Itoa(models.MaxPageLimit+1), "limit"},
+		{"limit not a number", "limit=ten", "limit"},
+		{"whitelisted sort", "sort=city", ""},
+		{"descending sort", "sort=-name", ""},
+		{"sort not in whitelist", "sort=price", "sort"},
+		{"sort without field", "sort=-", "sort"},
+		{"malformed cursor", "cursor=not-a-cursor", "cursor"},
+		{"cursor for the same sort", "sort=name&cursor=" + nameCursor, ""},
+		{"cursor for another field", "sort=city&cursor=" + nameCursor, "cursor"},
+		{"cursor for another direction", "sort=-name&cursor=" + nameCursor, "cursor"},
+		{"cursor for default sort", "cursor=" + nameCursor, "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do(t, "GET", "/api/pharmacies?"+tt.query, token, nil)
+			if tt.field == "" {
+				api.expect(t, rec, http.StatusOK, nil)
+				return
+			}
+			api.expectError(t, rec, http.StatusBadRequest, handlers.CodeValidationFailed)
+			if !strings.Contains(rec.Body.String(), `"field":"`+tt.field+`"`) {
+				t.Errorf("body = %s, want error for field %q", rec.Body.String(), tt.field)
+			}
+		})
+	}
+}
+
+func TestListPaginationFollowsCursor(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "buyer", "Buyer")
+	// Две аптеки с одинаковым названием: порядок между ними задаёт id из курсора
+	for _, name := range []string{"Вита", "Асна", "Ригла", "Асна", "Горздрав"} {
+		pharmacy := models.Pharmacy{Name: name, Address: models.Address{Street: "ул. Ленина, 1", City: "Москва", Country: "Россия"}}
+		if err := api.store.CreatePharmacy(context.Background(), &pharmacy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{"name", "Асна#2 Асна#4 Вита#1 Горздрав#5 Ригла#3"},
+		{"-name", "Ригла#3 Горздрав#5 Вита#1 Асна#4 Асна#2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			var got []string
+			query := url.Values{"limit": {"2"}, "sort": {tt.sort}}
+			for pages := 0; ; pages++ {
+				if pages == 3 {
+					t.Fatalf("more than 3 pages of 2 for 5 pharmacies; got %v", got)
+				}
+				var page models.Page[models.Pharmacy]
+				api.expect(t, api.do(t, "GET", "/api/pharmacies?"+query.Encode(), token, nil), http.StatusOK, &page)
+				if page.Total != 5 {
+					t.Errorf("total = %d, want 5", page.Total)
+				}
+				for _, pharmacy := range page.Items {
+					got = append(got, pharmacy.Name+"#"+strconv.Itoa(pharmacy.ID))
+				}
+				if page.NextCursor == nil {
+					break
+				}
+				query.Set("cursor", *page.NextCursor)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("pharmacies = %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
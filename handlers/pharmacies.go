@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"pharmacy-test/geo"
+	"pharmacy-test/models"
+)
+
+// Получение страницы аптек с фильтром по городу и стране
+func (h *Handler) GetPharmacies(w http.ResponseWriter, r *http.Request) {
+	page, apiErr := parsePage(r, models.PharmacySorts)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter := models.PharmacyFilter{
+		City:    r.URL.Query().Get("city"),
+		Country: r.URL.Query().Get("country"),
+	}
+	switch value := r.URL.Query().Get("open_at"); value {
+	case "":
+	case "now":
+		now := time.Now()
+		filter.OpenAt = &now
+	default:
+		openAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, r, fieldError("open_at", "must be \"now\" or an RFC 3339 timestamp"))
+			return
+		}
+		filter.OpenAt = &openAt
+	}
+
+	pharmacies, err := h.Pharmacies.ListPharmacies(r.Context(), filter, page)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching pharmacies")
+		return
+	}
+	now := time.Now()
+	for i := range pharmacies.Items {
+		pharmacies.Items[i].FillScheduleStatus(now)
+	}
+
+	writeJSON(w, http.StatusOK, pharmacies)
+}
+
+// Получение аптеки по ID
+func (h *Handler) GetPharmacyByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	pharmacy, err := h.Pharmacies.GetPharmacy(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching pharmacy")
+		return
+	}
+	pharmacy.FillScheduleStatus(time.Now())
+
+	writeJSON(w, http.StatusOK, pharmacy)
+}
+
+// Create a new pharmacy with an address.
+func (h *Handler) CreatePharmacy(w http.ResponseWriter, r *http.Request) {
+	var pharmacy models.Pharmacy
+	if err := json.NewDecoder(r.Body).Decode(&pharmacy); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	if apiErr := validateSchedule(&pharmacy); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if apiErr := h.locateAddress(r, &pharmacy.Address); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	if err := h.Pharmacies.CreatePharmacy(r.Context(), &pharmacy); err != nil {
+		writeStoreError(w, r, err, "inserting pharmacy")
+		return
+	}
+	pharmacy.FillScheduleStatus(time.Now())
+
+	writeJSON(w, http.StatusOK, pharmacy)
+}
+
+// Обновление информации о аптеке
+func (h *Handler) UpdatePharmacy(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var updatedPharmacy models.Pharmacy
+	if err := json.NewDecoder(r.Body).Decode(&updatedPharmacy); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	updatedPharmacy.ID = id
+	if apiErr := validateSchedule(&updatedPharmacy); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if apiErr := h.locateAddress(r, &updatedPharmacy.Address); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	if err := h.Pharmacies.UpdatePharmacy(r.Context(), &updatedPharmacy); err != nil {
+		writeStoreError(w, r, err, "updating pharmacy")
+		return
+	}
+	updatedPharmacy.FillScheduleStatus(time.Now())
+
+	writeJSON(w, http.StatusOK, updatedPharmacy)
+}
+
+// Удаление аптеки
+func (h *Handler) DeletePharmacy(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	if err := h.Pharmacies.DeletePharmacy(r.Context(), id); err != nil {
+		writeStoreError(w, r, err, "deleting pharmacy")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// locateAddress проверяет координаты адреса, а если они не переданы — запрашивает их у геокодера.
+// Адрес, неизвестный геокодеру, сохраняется без координат
+func (h *Handler) locateAddress(r *http.Request, address *models.Address) *APIError {
+	if (address.Latitude == nil) != (address.Longitude == nil) {
+		return fieldError("address.latitude", "must be set together with address.longitude")
+	}
+	if address.Latitude != nil {
+		var details []FieldError
+		if *address.Latitude < -90 || *address.Latitude > 90 {
+			details = append(details, FieldError{Field: "address.latitude", Message: "must be from -90 to 90"})
+		}
+		if *address.Longitude < -180 || *address.Longitude > 180 {
+			details = append(details, FieldError{Field: "address.longitude", Message: "must be from -180 to 180"})
+		}
+		if details != nil {
+			return validationError(details...)
+		}
+		return nil
+	}
+	if h.Geocoder == nil {
+		return nil
+	}
+
+	point, err := h.Geocoder.Geocode(r.Context(), *address)
+	if err != nil {
+		if !errors.Is(err, geo.ErrNotFound) {
+			log.Printf("request %s: geocoding address: %v", RequestIDFromContext(r.Context()), err)
+		}
+		return nil
+	}
+	address.Latitude, address.Longitude = &point.Lat, &point.Lon
+	return nil
+}
+
+// validateSchedule проверяет часовой пояс и расписание аптеки и приводит время к виду "ЧЧ:ММ".
+// Пустой часовой пояс заменяется на models.DefaultTimezone
+func validateSchedule(pharmacy *models.Pharmacy) *APIError {
+	var details []FieldError
+	if pharmacy.Timezone == "" {
+		pharmacy.Timezone = models.DefaultTimezone
+	}
+	if _, err := time.LoadLocation(pharmacy.Timezone); err != nil || pharmacy.Timezone == "Local" {
+		details = append(details, FieldError{Field: "timezone", Message: "must be an IANA time zone, e.g. Europe/Moscow"})
+	}
+
+	for i := range pharmacy.Hours {
+		hours := &pharmacy.Hours[i]
+		field := fmt.Sprintf("hours[%d]", i)
+		if hours.Weekday < 1 || hours.Weekday > 7 {
+			details = append(details, FieldError{Field: field + ".weekday", Message: "must be from 1 (Monday) to 7 (Sunday)"})
+		}
+		details = append(details, validateTimeRange(field, &hours.TimeRange)...)
+	}
+
+	dates := map[string]bool{}
+	for i := range pharmacy.Exceptions {
+		exception := &pharmacy.Exceptions[i]
+		field := fmt.Sprintf("exceptions[%d]", i)
+		if _, err := time.Parse(models.DateLayout, exception.Date); err != nil {
+			details = append(details, FieldError{Field: field + ".date", Message: "must be a date in YYYY-MM-DD format"})
+		} else if dates[exception.Date] {
+			details = append(details, FieldError{Field: field + ".date", Message: "is listed more than once"})
+		}
+		dates[exception.Date] = true
+		if exception.Hours == nil {
+			exception.Hours = []models.TimeRange{}
+		}
+		for j := range exception.Hours {
+			details = append(details, validateTimeRange(fmt.Sprintf("%s.hours[%d]", field, j), &exception.Hours[j])...)
+		}
+	}
+
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
+
+// validateTimeRange проверяет интервал работы в пределах суток
+func validateTimeRange(field string, hours *models.TimeRange) []FieldError {
+	opens, okOpens := models.ParseClock(hours.Opens)
+	closes, okCloses := models.ParseClock(hours.Closes)
+	switch {
+	case !okOpens || opens == 24*60:
+		return []FieldError{{Field: field + ".opens", Message: "must be a time from 00:00 to 23:59"}}
+	case !okCloses:
+		return []FieldError{{Field: field + ".closes", Message: "must be a time from 00:01 to 24:00"}}
+	case closes <= opens:
+		return []FieldError{{Field: field + ".closes", Message: "must be later than opens; split intervals that pass midnight"}}
+	}
+	hours.Opens = fmt.Sprintf("%02d:%02d", opens/60, opens%60)
+	hours.Closes = fmt.Sprintf("%02d:%02d", closes/60, closes%60)
+	return nil
+}
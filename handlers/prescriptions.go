@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// Получение списка рецептов с фильтром по номеру и имени пациента
+func (h *Handler) GetPrescriptions(w http.ResponseWriter, r *http.Request) {
+	filter := models.PrescriptionFilter{
+		Number:  r.URL.Query().Get("number"),
+		Patient: r.URL.Query().Get("patient"),
+	}
+
+	prescriptions, err := h.Prescriptions.ListPrescriptions(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching prescriptions")
+		return
+	}
+
+	now := time.Now()
+	for i := range prescriptions {
+		prescriptions[i].FillStatus(now)
+	}
+	writeJSON(w, http.StatusOK, prescriptions)
+}
+
+// Получение рецепта по ID вместе с остатками и отпусками по позициям
+func (h *Handler) GetPrescriptionByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	prescription, err := h.Prescriptions.GetPrescription(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching prescription")
+		return
+	}
+
+	prescription.FillStatus(time.Now())
+	writeJSON(w, http.StatusOK, prescription)
+}
+
+// Регистрация рецепта. Остатки позиций равны назначенному количеству и числу повторов
+func (h *Handler) CreatePrescription(w http.ResponseWriter, r *http.Request) {
+	var prescription models.Prescription
+	if err := json.NewDecoder(r.Body).Decode(&prescription); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validatePrescription(&prescription); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	prescription.CreatedBy = currentUserID(r)
+
+	err := h.Prescriptions.CreatePrescription(r.Context(), &prescription)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указано несуществующее лекарство
+		writeError(w, r, fieldError("items", err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "inserting prescription")
+		return
+	}
+
+	prescription.FillStatus(time.Now())
+	writeJSON(w, http.StatusCreated, prescription)
+}
+
+// Отмена рецепта: после отмены по нему нельзя отпускать лекарства
+func (h *Handler) CancelPrescription(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	prescription, err := h.Prescriptions.CancelPrescription(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "cancelling prescription")
+		return
+	}
+
+	prescription.FillStatus(time.Now())
+	writeJSON(w, http.StatusOK, prescription)
+}
+
+// validatePrescription проверяет реквизиты и позиции рецепта и заполняет остатки позиций
+func validatePrescription(prescription *models.Prescription) *APIError {
+	var details []FieldError
+	prescription.Number = strings.TrimSpace(prescription.Number)
+	prescription.PatientName = strings.TrimSpace(prescription.PatientName)
+	prescription.PrescriberName = strings.TrimSpace(prescription.PrescriberName)
+	if prescription.Number == "" {
+		details = append(details, FieldError{Field: "number", Message: "is required"})
+	}
+	if prescription.PatientName == "" {
+		details = append(details, FieldError{Field: "patient_name", Message: "is required"})
+	}
+	if prescription.PrescriberName == "" {
+		details = append(details, FieldError{Field: "prescriber_name", Message: "is required"})
+	}
+	if prescription.PatientBirthDate != "" {
+		if _, err := time.Parse(models.DateLayout, prescription.PatientBirthDate); err != nil {
+			details = append(details, FieldError{Field: "patient_birth_date", Message: "must be a date in YYYY-MM-DD format"})
+		}
+	}
+
+	today := time.Now().Format(models.DateLayout)
+	issued, issuedErr := time.Parse(models.DateLayout, prescription.IssuedOn)
+	validUntil, validErr := time.Parse(models.DateLayout, prescription.ValidUntil)
+	switch {
+	case issuedErr != nil:
+		details = append(details, FieldError{Field: "issued_on", Message: "must be a date in YYYY-MM-DD format"})
+	case prescription.IssuedOn > today:
+		details = append(details, FieldError{Field: "issued_on", Message: "must not be in the future"})
+	}
+	switch {
+	case validErr != nil:
+		details = append(details, FieldError{Field: "valid_until", Message: "must be a date in YYYY-MM-DD format"})
+	case issuedErr == nil && validUntil.Before(issued):
+		details = append(details, FieldError{Field: "valid_until", Message: "must not be before issued_on"})
+	}
+
+	if len(prescription.Items) == 0 {
+		details = append(details, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	medicines := map[int]bool{}
+	for i := range prescription.Items {
+		item := &prescription.Items[i]
+		field := fmt.Sprintf("items[%d]", i)
+		if medicines[item.MedicineID] {
+			details = append(details, FieldError{Field: field + ".medicine_id", Message: "is listed more than once"})
+		}
+		medicines[item.MedicineID] = true
+		if item.Quantity <= 0 {
+			details = append(details, FieldError{Field: field + ".quantity", Message: "must be positive"})
+		}
+		if item.Refills < 0 {
+			details = append(details, FieldError{Field: field + ".refills", Message: "must not be negative"})
+		}
+		item.RemainingQuantity, item.RemainingRefills = item.Quantity, item.Refills
+	}
+
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
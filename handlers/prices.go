@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"pharmacy-test/models"
+)
+
+// PriceChangeRequest структура для немедленного изменения цены.
+// Без pharmacy_id меняется цена каталога, с ним — цена в аптеке; price: null снимает цену аптеки
+type PriceChangeRequest struct {
+	PharmacyID *int     `json:"pharmacy_id"`
+	Price      *float64 `json:"price"`
+}
+
+// ScheduledPriceRequest структура для планирования изменения цены
+type ScheduledPriceRequest struct {
+	PharmacyID  *int       `json:"pharmacy_id"`
+	Price       *float64   `json:"price"`
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+// Цены лекарства: каталог, аптеки, запланированные изменения и история
+func (h *Handler) GetMedicinePrices(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	prices, err := h.Prices.GetMedicinePrices(r.Context(), medicineID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching prices")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prices)
+}
+
+// Немедленное изменение цены каталога или цены в аптеке
+func (h *Handler) ChangeMedicinePrice(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request PriceChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if request.Price == nil && request.PharmacyID == nil {
+		writeError(w, r, fieldError("price", "is required for the catalog price"))
+		return
+	}
+	if request.Price != nil && *request.Price < 0 {
+		writeError(w, r, fieldError("price", "must not be negative"))
+		return
+	}
+
+	change := models.PriceChange{
+		MedicineID: medicineID,
+		PharmacyID: request.PharmacyID,
+		NewPrice:   request.Price,
+		ChangedBy:  currentUserID(r),
+	}
+	if change.NewPrice != nil {
+		price := models.RoundMoney(*change.NewPrice)
+		change.NewPrice = &price
+	}
+	if err := h.Prices.ChangePrice(r.Context(), &change); err != nil {
+		writeStoreError(w, r, err, "changing price")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, change)
+}
+
+// Планирование изменения цены на будущий момент
+func (h *Handler) ScheduleMedicinePrice(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request ScheduledPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	var details []FieldError
+	if request.Price == nil || *request.Price < 0 {
+		details = append(details, FieldError{Field: "price", Message: "must be a non-negative number"})
+	}
+	if request.EffectiveAt == nil || !request.EffectiveAt.After(time.Now()) {
+		details = append(details, FieldError{Field: "effective_at", Message: "must be an RFC 3339 timestamp in the future"})
+	}
+	if details != nil {
+		writeError(w, r, validationError(details...))
+		return
+	}
+
+	price := models.ScheduledPrice{
+		MedicineID:  medicineID,
+		PharmacyID:  request.PharmacyID,
+		Price:       models.RoundMoney(*request.Price),
+		EffectiveAt: *request.EffectiveAt,
+		CreatedBy:   currentUserID(r),
+	}
+	if err := h.Prices.SchedulePrice(r.Context(), &price); err != nil {
+		writeStoreError(w, r, err, "scheduling price")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, price)
+}
+
+// Отмена запланированного изменения цены
+func (h *Handler) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	scheduledID, err := pathID(r, "scheduledId")
+	if err != nil {
+		writeError(w, r, fieldError("scheduledId", "must be an integer"))
+		return
+	}
+
+	price, err := h.Prices.CancelScheduledPrice(r.Context(), medicineID, scheduledID)
+	if err != nil {
+		writeStoreError(w, r, err, "cancelling scheduled price")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, price)
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// PurchaseStatusRequest структура для смены статуса заказа поставщику
+type PurchaseStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// GoodsReceiptRequest структура для приёмки поставки
+type GoodsReceiptRequest struct {
+	Items []models.GoodsReceiptItem `json:"items"`
+}
+
+// Получение списка поставщиков
+func (h *Handler) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := h.Purchases.ListSuppliers(r.Context())
+	if err != nil {
+		writeStoreError(w, r, err, "fetching suppliers")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, suppliers)
+}
+
+// Получение поставщика по ID
+func (h *Handler) GetSupplierByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	supplier, err := h.Purchases.GetSupplier(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching supplier")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, supplier)
+}
+
+// Добавление поставщика
+func (h *Handler) CreateSupplier(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateSupplier(&supplier); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	if err := h.Purchases.CreateSupplier(r.Context(), &supplier); err != nil {
+		writeStoreError(w, r, err, "inserting supplier")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, supplier)
+}
+
+// Обновление реквизитов поставщика
+func (h *Handler) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var supplier models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&supplier); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	supplier.ID = id
+	if apiErr := validateSupplier(&supplier); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	if err := h.Purchases.UpdateSupplier(r.Context(), &supplier); err != nil {
+		writeStoreError(w, r, err, "updating supplier")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, supplier)
+}
+
+// Удаление поставщика, по которому нет заказов
+func (h *Handler) DeleteSupplier(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	err = h.Purchases.DeleteSupplier(r.Context(), id)
+	if errors.Is(err, store.ErrConflict) {
+		writeError(w, r, conflict("Supplier has purchase orders"))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "deleting supplier")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Получение списка заказов поставщикам, с фильтром по аптеке, поставщику и статусу
+func (h *Handler) GetPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	var filter models.PurchaseOrderFilter
+	var apiErr *APIError
+	if filter.PharmacyID, apiErr = parseIntParam(r, "pharmacy_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	if filter.SupplierID, apiErr = parseIntParam(r, "supplier_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter.Status = r.URL.Query().Get("status")
+
+	orders, err := h.Purchases.ListPurchaseOrders(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching purchase orders")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orders)
+}
+
+// Получение заказа поставщику вместе с приёмками
+func (h *Handler) GetPurchaseOrderByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	order, err := h.Purchases.GetPurchaseOrder(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching purchase order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+// Создание заказа поставщику (статус draft по умолчанию или сразу sent)
+func (h *Handler) CreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var order models.PurchaseOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validatePurchaseOrder(&order); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	order.CreatedBy = currentUserID(r)
+
+	err := h.Purchases.CreatePurchaseOrder(r.Context(), &order)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека, поставщик или лекарство
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "creating purchase order")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+}
+
+// Смена статуса заказа поставщику: отправка или отмена. Статусы приёмки выставляются приёмкой товара
+func (h *Handler) UpdatePurchaseOrderStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request PurchaseStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if request.Status != models.PurchaseStatusSent && request.Status != models.PurchaseStatusCancelled {
+		writeError(w, r, fieldError("status", "must be sent or cancelled"))
+		return
+	}
+
+	order, err := h.Purchases.UpdatePurchaseOrderStatus(r.Context(), id, request.Status)
+	if errors.Is(err, store.ErrInvalidTransition) {
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Cannot change purchase order status to %s", request.Status)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating purchase order status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+}
+
+// Приёмка поставки по заказу: партии оприходуются в аптеку заказа, остатки увеличиваются.
+// Заказ переходит в partially_received или received по принятым количествам
+func (h *Handler) ReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request GoodsReceiptRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateGoodsReceipt(request.Items); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	receipt := models.GoodsReceipt{
+		PurchaseOrderID: id,
+		ReceivedBy:      currentUserID(r),
+		Items:           request.Items,
+	}
+	order, err := h.Purchases.ReceiveGoods(r.Context(), &receipt)
+	if err != nil {
+		writeStoreError(w, r, err, "receiving goods")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+}
+
+// validateSupplier проверяет реквизиты поставщика
+func validateSupplier(supplier *models.Supplier) *APIError {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.Email = strings.TrimSpace(supplier.Email)
+	var details []FieldError
+	if supplier.Name == "" {
+		details = append(details, FieldError{Field: "name", Message: "is required"})
+	}
+	if supplier.Email != "" && !strings.Contains(supplier.Email, "@") {
+		details = append(details, FieldError{Field: "email", Message: "must be an email address"})
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
+
+// validatePurchaseOrder проверяет статус и позиции нового заказа поставщику
+func validatePurchaseOrder(order *models.PurchaseOrder) *APIError {
+	if order.Status == "" {
+		order.Status = models.PurchaseStatusDraft
+	}
+	var details []FieldError
+	if order.Status != models.PurchaseStatusDraft && order.Status != models.PurchaseStatusSent {
+		details = append(details, FieldError{Field: "status", Message: "must be draft or sent"})
+	}
+	if len(order.Items) == 0 {
+		details = append(details, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	medicines := map[int]bool{}
+	for i, item := range order.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if medicines[item.MedicineID] {
+			details = append(details, FieldError{Field: field + ".medicine_id", Message: "is listed more than once"})
+		}
+		medicines[item.MedicineID] = true
+		if item.Quantity <= 0 {
+			details = append(details, FieldError{Field: field + ".quantity", Message: "must be positive"})
+		}
+		if item.UnitCost < 0 {
+			details = append(details, FieldError{Field: field + ".unit_cost", Message: "must not be negative"})
+		}
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
+
+// validateGoodsReceipt проверяет принимаемые партии
+func validateGoodsReceipt(items []models.GoodsReceiptItem) *APIError {
+	if len(items) == 0 {
+		return fieldError("items", "must contain at least one item")
+	}
+	var details []FieldError
+	lots := map[string]bool{}
+	for i := range items {
+		item := &items[i]
+		field := fmt.Sprintf("items[%d]", i)
+		item.LotNumber = strings.TrimSpace(item.LotNumber)
+		if item.LotNumber == "" {
+			details = append(details, FieldError{Field: field + ".lot_number", Message: "is required"})
+		}
+		key := fmt.Sprintf("%d/%s", item.MedicineID, item.LotNumber)
+		if lots[key] {
+			details = append(details, FieldError{Field: field + ".lot_number", Message: "is listed more than once for this medicine"})
+		}
+		lots[key] = true
+		if item.Quantity <= 0 {
+			details = append(details, FieldError{Field: field + ".quantity", Message: "must be positive"})
+		}
+		expiry, err := time.Parse(models.DateLayout, item.ExpiryDate)
+		if err != nil {
+			details = append(details, FieldError{Field: field + ".expiry_date", Message: "must be a date in YYYY-MM-DD format"})
+		}
+		if item.ProductionDate != "" {
+			production, perr := time.Parse(models.DateLayout, item.ProductionDate)
+			switch {
+			case perr != nil:
+				details = append(details, FieldError{Field: field + ".production_date", Message: "must be a date in YYYY-MM-DD format"})
+			case err == nil && production.After(expiry):
+				details = append(details, FieldError{Field: field + ".production_date", Message: "must not be after expiry date"})
+			}
+		}
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
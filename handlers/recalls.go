@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// RecallStatusRequest структура для смены статуса отзыва
+type RecallStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// Получение списка отзывов, с фильтром по статусу и лекарству
+func (h *Handler) GetRecalls(w http.ResponseWriter, r *http.Request) {
+	filter := models.RecallFilter{Status: r.URL.Query().Get("status")}
+	var apiErr *APIError
+	if filter.MedicineID, apiErr = parseIntParam(r, "medicine_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	recalls, err := h.Recalls.ListRecalls(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching recalls")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recalls)
+}
+
+// Получение отзыва с сериями и действиями с отозванными единицами
+func (h *Handler) GetRecallByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	recall, err := h.Recalls.GetRecall(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching recall")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recall)
+}
+
+// Регистрация отзыва производителем: партии указанных серий сразу блокируются для продажи во всех аптеках
+func (h *Handler) CreateRecall(w http.ResponseWriter, r *http.Request) {
+	var recall models.Recall
+	if err := json.NewDecoder(r.Body).Decode(&recall); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateRecall(&recall); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	recall.CreatedBy = currentUserID(r)
+
+	err := h.Recalls.CreateRecall(r.Context(), &recall)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указано несуществующее лекарство
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "creating recall")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, recall)
+}
+
+// Завершение или отмена отзыва. Отмена снимает блокировку партий, завершение — нет
+func (h *Handler) UpdateRecallStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request RecallStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if request.Status != models.RecallStatusCompleted && request.Status != models.RecallStatusCancelled {
+		writeError(w, r, fieldError("status", "must be completed or cancelled"))
+		return
+	}
+
+	recall, err := h.Recalls.UpdateRecallStatus(r.Context(), id, request.Status)
+	if errors.Is(err, store.ErrInvalidTransition) {
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Cannot change recall status to %s", request.Status)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating recall status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, recall)
+}
+
+// Помещение единиц отозванной партии в карантин или их возврат производителю.
+// Возврат списывает единицы с остатка аптеки
+func (h *Handler) RecordRecallAction(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var action models.RecallAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	action.RecallID = id
+	action.Notes = strings.TrimSpace(action.Notes)
+	var details []FieldError
+	if action.LotID <= 0 {
+		details = append(details, FieldError{Field: "lot_id", Message: "is required"})
+	}
+	if action.Action != models.RecallActionQuarantine && action.Action != models.RecallActionReturn {
+		details = append(details, FieldError{Field: "action", Message: "must be quarantine or return"})
+	}
+	if action.Quantity <= 0 {
+		details = append(details, FieldError{Field: "quantity", Message: "must be positive"})
+	}
+	if details != nil {
+		writeError(w, r, validationError(details...))
+		return
+	}
+	action.CreatedBy = currentUserID(r)
+
+	if err := h.Recalls.RecordRecallAction(r.Context(), &action); err != nil {
+		writeStoreError(w, r, err, "recording recall action")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, action)
+}
+
+// Отчёт по отзыву: остатки отозванных партий по аптекам и оплаченные заказы, в которых они уже проданы
+func (h *Handler) GetRecallReport(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	report, err := h.Recalls.GetRecallReport(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching recall report")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// validateRecall проверяет реквизиты и серии нового отзыва
+func validateRecall(recall *models.Recall) *APIError {
+	recall.Manufacturer = strings.TrimSpace(recall.Manufacturer)
+	recall.Reason = strings.TrimSpace(recall.Reason)
+	var details []FieldError
+	if recall.Manufacturer == "" {
+		details = append(details, FieldError{Field: "manufacturer", Message: "is required"})
+	}
+	if recall.Reason == "" {
+		details = append(details, FieldError{Field: "reason", Message: "is required"})
+	}
+	if _, err := time.Parse(models.DateLayout, recall.RecallDate); err != nil {
+		details = append(details, FieldError{Field: "recall_date", Message: "must be a date in YYYY-MM-DD format"})
+	}
+	if len(recall.Items) == 0 {
+		details = append(details, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	lots := map[string]bool{}
+	for i := range recall.Items {
+		item := &recall.Items[i]
+		field := fmt.Sprintf("items[%d]", i)
+		item.LotNumber = strings.TrimSpace(item.LotNumber)
+		if item.MedicineID <= 0 {
+			details = append(details, FieldError{Field: field + ".medicine_id", Message: "is required"})
+		}
+		if item.LotNumber == "" {
+			details = append(details, FieldError{Field: field + ".lot_number", Message: "is required"})
+		}
+		key := fmt.Sprintf("%d/%s", item.MedicineID, item.LotNumber)
+		if lots[key] {
+			details = append(details, FieldError{Field: field + ".lot_number", Message: "is listed more than once for this medicine"})
+		}
+		lots[key] = true
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
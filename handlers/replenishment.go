@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// StockLevelRequest структура для задания порогов остатка лекарства в аптеке
+type StockLevelRequest struct {
+	MinQuantity *int `json:"min_quantity"`
+	MaxQuantity *int `json:"max_quantity"`
+	SupplierID  *int `json:"supplier_id"`
+}
+
+// Получение порогов остатка лекарств в аптеке
+func (h *Handler) GetStockLevels(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	levels, err := h.Replenishment.ListStockLevels(r.Context(), pharmacyID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock levels")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, levels)
+}
+
+// Задание минимального и максимального остатка лекарства в аптеке и поставщика по умолчанию
+func (h *Handler) SetStockLevel(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	medicineID, err := pathID(r, "medicineId")
+	if err != nil {
+		writeError(w, r, fieldError("medicineId", "must be an integer"))
+		return
+	}
+
+	var request StockLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	var details []FieldError
+	if request.MinQuantity == nil || *request.MinQuantity < 0 {
+		details = append(details, FieldError{Field: "min_quantity", Message: "must be a non-negative integer"})
+	}
+	if request.MaxQuantity == nil {
+		details = append(details, FieldError{Field: "max_quantity", Message: "is required"})
+	} else if request.MinQuantity != nil && *request.MaxQuantity < *request.MinQuantity {
+		details = append(details, FieldError{Field: "max_quantity", Message: "must not be less than min_quantity"})
+	}
+	if details != nil {
+		writeError(w, r, validationError(details...))
+		return
+	}
+
+	level := models.StockLevel{
+		PharmacyID:  pharmacyID,
+		MedicineID:  medicineID,
+		MinQuantity: *request.MinQuantity,
+		MaxQuantity: *request.MaxQuantity,
+		SupplierID:  request.SupplierID,
+	}
+	err = h.Replenishment.SetStockLevel(r.Context(), &level)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека, лекарство или поставщик
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating stock level")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, level)
+}
+
+// Удаление порогов остатка: лекарство больше не дозаказывается автоматически
+func (h *Handler) DeleteStockLevel(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	medicineID, err := pathID(r, "medicineId")
+	if err != nil {
+		writeError(w, r, fieldError("medicineId", "must be an integer"))
+		return
+	}
+
+	if err := h.Replenishment.DeleteStockLevel(r.Context(), pharmacyID, medicineID); err != nil {
+		writeStoreError(w, r, err, "deleting stock level")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Получение предложений пополнения аптеки; refresh=true пересчитывает их перед выдачей
+func (h *Handler) GetReplenishment(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	refresh, apiErr := parseBoolParam(r, "refresh")
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	if refresh != nil && *refresh {
+		if _, err := h.Replenishment.RefreshReplenishment(r.Context(), pharmacyID); err != nil {
+			writeStoreError(w, r, err, "calculating replenishment")
+			return
+		}
+	}
+	suggestions, err := h.Replenishment.ListReplenishment(r.Context(), pharmacyID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching replenishment")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// Создание черновиков заказов поставщикам по предложениям пополнения, по одному на поставщика
+func (h *Handler) CreateReplenishmentOrders(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request models.ReplenishmentOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	request.CreatedBy = currentUserID(r)
+
+	// Конфликт — нет предложения по указанному лекарству или поставщика для него
+	orders, err := h.Replenishment.CreateReplenishmentOrders(r.Context(), pharmacyID, request)
+	if err != nil {
+		writeStoreError(w, r, err, "creating replenishment orders")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, orders)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Получение справочника разрешений
+func (h *Handler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := h.Roles.ListPermissions(r.Context())
+	if err != nil {
+		writeStoreError(w, r, err, "fetching permissions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permissions)
+}
+
+// Получение списка ролей с их разрешениями
+func (h *Handler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.Roles.ListRoles(r.Context())
+	if err != nil {
+		writeStoreError(w, r, err, "fetching roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// Получение роли по имени
+func (h *Handler) GetRoleByName(w http.ResponseWriter, r *http.Request) {
+	role, err := h.Roles.GetRole(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
+
+// Создание роли
+func (h *Handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" {
+		writeError(w, r, fieldError("name", "is required"))
+		return
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+
+	err := h.Roles.CreateRole(r.Context(), &role)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указано неизвестное разрешение
+		writeError(w, r, fieldError("permissions", err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "creating role")
+		return
+	}
+
+	// Возвращаем роль в том виде, в котором она сохранена: без повторов и в порядке имён
+	saved, err := h.Roles.GetRole(r.Context(), role.Name)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// Изменение описания и разрешений роли
+func (h *Handler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	role.Name = mux.Vars(r)["name"]
+	if role.Permissions == nil {
+		role.Permissions = []string{}
+	}
+
+	// Нельзя лишить собственную роль права управлять ролями, иначе его не вернуть
+	if user, ok := userFromContext(r.Context()); ok && user.Details.Position == role.Name && !role.HasPermission(models.PermRoleAdmin) {
+		writeError(w, r, conflict(fmt.Sprintf("Cannot remove %s from your own role", models.PermRoleAdmin)))
+		return
+	}
+
+	if _, err := h.Roles.GetRole(r.Context(), role.Name); err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+	err := h.Roles.UpdateRole(r.Context(), &role)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, fieldError("permissions", err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating role")
+		return
+	}
+
+	// Возвращаем роль в том виде, в котором она сохранена: без повторов и в порядке имён
+	saved, err := h.Roles.GetRole(r.Context(), role.Name)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// Удаление роли, не назначенной ни одному пользователю
+func (h *Handler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := h.Roles.DeleteRole(r.Context(), mux.Vars(r)["name"]); err != nil {
+		writeStoreError(w, r, err, "deleting role")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"pharmacy-test/store"
+)
+
+// Получение активных сессий текущего пользователя
+func (h *Handler) GetUserSessions(w http.ResponseWriter, r *http.Request) {
+	current, user, err := h.authenticate(w, r)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, unauthorized("Authentication required"))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "fetching session")
+		return
+	}
+
+	sessions, err := h.Sessions.ListUserSessions(r.Context(), user.ID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching sessions")
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current.ID
+	}
+
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// Отзыв сессии текущего пользователя, например на потерянном устройстве
+func (h *Handler) RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	_, user, err := h.authenticate(w, r)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, unauthorized("Authentication required"))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "fetching session")
+		return
+	}
+
+	if err := h.Sessions.RevokeSession(r.Context(), user.ID, sessionID); err != nil {
+		writeStoreError(w, r, err, "revoking session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
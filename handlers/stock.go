@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"pharmacy-test/geo"
+)
+
+// StockUpdateRequest структура для изменения остатка лекарства в аптеке
+type StockUpdateRequest struct {
+	Quantity *int `json:"quantity"`
+}
+
+// Получение остатков лекарств в аптеке
+func (h *Handler) GetPharmacyStock(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	stock, err := h.Stock.ListPharmacyStock(r.Context(), pharmacyID)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+// Установка остатка лекарства в аптеке
+func (h *Handler) UpdatePharmacyStock(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	medicineID, err := pathID(r, "medicineId")
+	if err != nil {
+		writeError(w, r, fieldError("medicineId", "must be an integer"))
+		return
+	}
+
+	var request StockUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if request.Quantity == nil || *request.Quantity < 0 {
+		writeError(w, r, fieldError("quantity", "must be a non-negative integer"))
+		return
+	}
+
+	item, err := h.Stock.SetStock(r.Context(), pharmacyID, medicineID, *request.Quantity, currentUserID(r))
+	if err != nil {
+		writeStoreError(w, r, err, "updating stock")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// Радиус поиска аптек по умолчанию и наибольший допустимый, км
+const (
+	DefaultAvailabilityRadiusKm = 5.0
+	MaxAvailabilityRadiusKm     = 100.0
+)
+
+// Аптеки рядом с точкой, где есть лекарство, от ближайшей к дальней
+func (h *Handler) GetMedicineAvailability(w http.ResponseWriter, r *http.Request) {
+	medicineID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var details []FieldError
+	lat, apiErr := parseFloatParam(r, "lat")
+	if apiErr != nil || lat == nil || *lat < -90 || *lat > 90 {
+		details = append(details, FieldError{Field: "lat", Message: "must be a number from -90 to 90"})
+	}
+	lon, apiErr := parseFloatParam(r, "lon")
+	if apiErr != nil || lon == nil || *lon < -180 || *lon > 180 {
+		details = append(details, FieldError{Field: "lon", Message: "must be a number from -180 to 180"})
+	}
+	radius, apiErr := parseFloatParam(r, "radius")
+	if radius == nil && apiErr == nil {
+		defaultRadius := DefaultAvailabilityRadiusKm
+		radius = &defaultRadius
+	}
+	if apiErr != nil || *radius <= 0 || *radius > MaxAvailabilityRadiusKm {
+		details = append(details, FieldError{Field: "radius", Message: fmt.Sprintf("must be a number of kilometres greater than 0 and at most %g", MaxAvailabilityRadiusKm)})
+	}
+	if details != nil {
+		writeError(w, r, validationError(details...))
+		return
+	}
+
+	nearby, err := h.Stock.ListNearbyAvailability(r.Context(), medicineID, geo.Point{Lat: *lat, Lon: *lon}, *radius)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching nearby availability")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nearby)
+}
@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestUpdatePharmacyStock(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Парацетамол", 45.5, false)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   interface{}
+		status int
+		code   string
+	}{
+		{"set quantity", stockPath, handlers.StockUpdateRequest{Quantity: intPtr(12)}, http.StatusOK, ""},
+		{"zero quantity", stockPath, handlers.StockUpdateRequest{Quantity: intPtr(0)}, http.StatusOK, ""},
+		{"missing quantity", stockPath, handlers.StockUpdateRequest{}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"negative quantity", stockPath, handlers.StockUpdateRequest{Quantity: intPtr(-1)}, http.StatusBadRequest, handlers.CodeValidationFailed},
+		{"unknown pharmacy", "/api/pharmacies/999/stock/" + strconv.Itoa(medicine.ID), handlers.StockUpdateRequest{Quantity: intPtr(1)}, http.StatusNotFound, handlers.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := api.do(t, "PUT", tt.path, token, tt.body)
+			if tt.code != "" {
+				api.expectError(t, rec, tt.status, tt.code)
+				return
+			}
+			var item models.StockItem
+			api.expect(t, rec, tt.status, &item)
+			if want := *tt.body.(handlers.StockUpdateRequest).Quantity; item.Quantity != want {
+				t.Errorf("quantity = %d, want %d", item.Quantity, want)
+			}
+			if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != item.Quantity {
+				t.Errorf("listed stock = %d, want %d", got, item.Quantity)
+			}
+		})
+	}
+}
+
+func TestConsumePharmacyStockFEFO(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Ибупрофен", 80, false)
+	lotsPath := "/api/medicines/" + strconv.Itoa(medicine.ID) + "/lots"
+	consumePath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID) + "/consume"
+
+	var late, early models.Lot
+	api.expect(t, api.do(t, "POST", lotsPath, token, models.Lot{
+		PharmacyID: pharmacy.ID, LotNumber: "B-2", ExpiryDate: "2026-01-31", Quantity: 5,
+	}), http.StatusCreated, &late)
+	api.expect(t, api.do(t, "POST", lotsPath, token, models.Lot{
+		PharmacyID: pharmacy.ID, LotNumber: "A-1", ExpiryDate: "2025-06-30", Quantity: 3,
+	}), http.StatusCreated, &early)
+
+	var result handlers.ConsumeResult
+	api.expect(t, api.do(t, "POST", consumePath, token, handlers.ConsumeRequest{Quantity: 4}), http.StatusOK, &result)
+	want := []models.LotAllocation{
+		{LotID: early.ID, LotNumber: "A-1", ExpiryDate: "2025-06-30", Quantity: 3},
+		{LotID: late.ID, LotNumber: "B-2", ExpiryDate: "2026-01-31", Quantity: 1},
+	}
+	if len(result.Allocations) != len(want) {
+		t.Fatalf("allocations = %+v, want %+v", result.Allocations, want)
+	}
+	for i := range want {
+		if result.Allocations[i] != want[i] {
+			t.Errorf("allocations[%d] = %+v, want %+v", i, result.Allocations[i], want[i])
+		}
+	}
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 4 {
+		t.Errorf("stock after consume = %d, want 4", got)
+	}
+
+	rec := api.do(t, "POST", consumePath, token, handlers.ConsumeRequest{Quantity: 5})
+	api.expectError(t, rec, http.StatusConflict, handlers.CodeInsufficientStock)
+	if got := api.stock(t, token, pharmacy.ID, medicine.ID); got != 4 {
+		t.Errorf("stock after failed consume = %d, want 4", got)
+	}
+}
+
+func TestMedicineAvailabilityCountsSellableStock(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "buyer", "Buyer")
+	medicine := api.medicine(t, "Ибупрофен", 80, false)
+	ctx := context.Background()
+
+	// Аптеки в центре Москвы; остаток каждой задаётся партиями и остатком без партии
+	stocked := func(lat, lon float64, unlotted int, lots ...models.Lot) models.Pharmacy {
+		t.Helper()
+		pharmacy := models.Pharmacy{Name: "Аптека", Address: models.Address{
+			Street: "ул. Тверская, 1", City: "Москва", Country: "Россия", Latitude: &lat, Longitude: &lon,
+		}}
+		if err := api.store.CreatePharmacy(ctx, &pharmacy); err != nil {
+			t.Fatalf("creating pharmacy: %v", err)
+		}
+		for _, lot := range lots {
+			lot.PharmacyID, lot.MedicineID = pharmacy.ID, medicine.ID
+			if err := api.store.ReceiveLot(ctx, &lot, nil); err != nil {
+				t.Fatalf("receiving lot: %v", err)
+			}
+			unlotted += lot.Quantity
+		}
+		if _, err := api.store.SetStock(ctx, pharmacy.ID, medicine.ID, unlotted, nil); err != nil {
+			t.Fatalf("setting stock: %v", err)
+		}
+		return pharmacy
+	}
+	expired := stocked(55.7570, 37.6150, 0, models.Lot{LotNumber: "OLD", ExpiryDate: "2025-02-01", Quantity: 5})
+	recalled := stocked(55.7580, 37.6160, 1, models.Lot{LotNumber: "BAD", ExpiryDate: "2026-01-31", Quantity: 4})
+	sellable := stocked(55.7590, 37.6170, 0,
+		models.Lot{LotNumber: "GOOD", ExpiryDate: "2026-01-31", Quantity: 3},
+		models.Lot{LotNumber: "OLD", ExpiryDate: "2025-02-01", Quantity: 2})
+	if err := api.store.CreateRecall(ctx, &models.Recall{
+		Manufacturer: "Фармстандарт", Reason: "Примесь", RecallDate: "2025-02-20",
+		Items: []models.RecallItem{{MedicineID: medicine.ID, LotNumber: "BAD"}},
+	}); err != nil {
+		t.Fatalf("creating recall: %v", err)
+	}
+
+	var nearby []models.NearbyPharmacy
+	path := "/api/medicines/" + strconv.Itoa(medicine.ID) + "/availability?lat=55.7558&lon=37.6173&radius=5"
+	api.expect(t, api.do(t, "GET", path, token, nil), http.StatusOK, &nearby)
+	got := map[int]int{}
+	for _, n := range nearby {
+		got[n.Pharmacy.ID] = n.Quantity
+	}
+	want := map[int]int{recalled.ID: 1, sellable.ID: 3}
+	if len(got) != len(want) || got[recalled.ID] != want[recalled.ID] || got[sellable.ID] != want[sellable.ID] {
+		t.Errorf("availability = %v, want %v (pharmacy %d has only expired stock)", got, want, expired.ID)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// StockCountEntriesRequest структура для передачи подсчётов с одного устройства
+type StockCountEntriesRequest struct {
+	DeviceID string                   `json:"device_id"`
+	Items    []models.StockCountEntry `json:"items"`
+}
+
+// Получение списка инвентаризаций, с фильтром по аптеке и статусу
+func (h *Handler) GetStockCounts(w http.ResponseWriter, r *http.Request) {
+	var filter models.StockCountFilter
+	var apiErr *APIError
+	if filter.PharmacyID, apiErr = parseIntParam(r, "pharmacy_id"); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter.Status = r.URL.Query().Get("status")
+
+	counts, err := h.StockCounts.ListStockCounts(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock counts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, counts)
+}
+
+// Получение инвентаризации с расхождениями по каждому лекарству
+func (h *Handler) GetStockCountByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	count, err := h.StockCounts.GetStockCount(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching stock count")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, count)
+}
+
+// Открытие инвентаризации аптеки: текущие остатки запоминаются как ожидаемые
+func (h *Handler) OpenStockCount(w http.ResponseWriter, r *http.Request) {
+	var count models.StockCount
+	if err := json.NewDecoder(r.Body).Decode(&count); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if count.PharmacyID <= 0 {
+		writeError(w, r, fieldError("pharmacy_id", "is required"))
+		return
+	}
+	count.Notes = strings.TrimSpace(count.Notes)
+	count.OpenedBy = currentUserID(r)
+
+	err := h.StockCounts.OpenStockCount(r.Context(), &count)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, fieldError("pharmacy_id", "pharmacy does not exist"))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "opening stock count")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, count)
+}
+
+// Передача подсчитанных количеств с устройства; повторная передача лекарства заменяет подсчёт этого устройства
+func (h *Handler) SubmitStockCountEntries(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request StockCountEntriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateStockCountEntries(&request); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+
+	count, err := h.StockCounts.SubmitStockCountEntries(r.Context(), id, request.Items, currentUserID(r))
+	if err != nil {
+		writeStoreError(w, r, err, "submitting stock count entries")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, count)
+}
+
+// Утверждение инвентаризации: остатки корректируются на расхождения с указанными причинами
+func (h *Handler) ApproveStockCount(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	count, err := h.StockCounts.ApproveStockCount(r.Context(), id, currentUserID(r))
+	if err != nil {
+		writeStoreError(w, r, err, "approving stock count")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, count)
+}
+
+// Отмена инвентаризации без корректировки остатков
+func (h *Handler) CancelStockCount(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	count, err := h.StockCounts.CancelStockCount(r.Context(), id, currentUserID(r))
+	if err != nil {
+		writeStoreError(w, r, err, "cancelling stock count")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, count)
+}
+
+// validateStockCountEntries проверяет подсчёты и проставляет им устройство из запроса
+func validateStockCountEntries(request *StockCountEntriesRequest) *APIError {
+	request.DeviceID = strings.TrimSpace(request.DeviceID)
+	var details []FieldError
+	// Подсчёты различаются по устройству: без него подсчёты разных сотрудников заменяли бы друг друга
+	if request.DeviceID == "" {
+		details = append(details, FieldError{Field: "device_id", Message: "is required"})
+	} else if len(request.DeviceID) > 100 {
+		details = append(details, FieldError{Field: "device_id", Message: "must be at most 100 characters"})
+	}
+	if len(request.Items) == 0 {
+		details = append(details, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	medicines := map[int]bool{}
+	for i := range request.Items {
+		item := &request.Items[i]
+		field := fmt.Sprintf("items[%d]", i)
+		item.DeviceID = request.DeviceID
+		item.Reason = strings.TrimSpace(item.Reason)
+		if medicines[item.MedicineID] {
+			details = append(details, FieldError{Field: field + ".medicine_id", Message: "is listed more than once"})
+		}
+		medicines[item.MedicineID] = true
+		if item.Quantity < 0 {
+			details = append(details, FieldError{Field: field + ".quantity", Message: "must not be negative"})
+		}
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
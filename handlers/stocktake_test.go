@@ -0,0 +1,52 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestSubmitStockCountEntriesByDevice(t *testing.T) {
+	api := newTestAPI()
+	_, token := api.userWithRole(t, "seller", "Seller")
+	pharmacy := api.pharmacy(t)
+	medicine := api.medicine(t, "Парацетамол", 45.5, false)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(pharmacy.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, token, handlers.StockUpdateRequest{Quantity: intPtr(10)}), http.StatusOK, nil)
+
+	var count models.StockCount
+	api.expect(t, api.do(t, "POST", "/api/stock-counts", token, models.StockCount{PharmacyID: pharmacy.ID}), http.StatusCreated, &count)
+	entriesPath := "/api/stock-counts/" + strconv.Itoa(count.ID) + "/entries"
+	submit := func(device string, quantity int) models.StockCount {
+		t.Helper()
+		var count models.StockCount
+		api.expect(t, api.do(t, "POST", entriesPath, token, handlers.StockCountEntriesRequest{
+			DeviceID: device,
+			Items:    []models.StockCountEntry{{MedicineID: medicine.ID, Quantity: quantity}},
+		}), http.StatusOK, &count)
+		return count
+	}
+
+	for _, device := range []string{"", "   "} {
+		rec := api.do(t, "POST", entriesPath, token, handlers.StockCountEntriesRequest{
+			DeviceID: device,
+			Items:    []models.StockCountEntry{{MedicineID: medicine.ID, Quantity: 4}},
+		})
+		api.expectError(t, rec, http.StatusBadRequest, handlers.CodeValidationFailed)
+	}
+
+	// Подсчёты разных устройств складываются, повторный подсчёт устройства заменяет прежний
+	submit("scanner-1", 4)
+	submit("scanner-2", 5)
+	count = submit("scanner-1", 3)
+	line := count.Line(medicine.ID)
+	if line == nil || line.CountedQuantity == nil || *line.CountedQuantity != 8 || len(line.Entries) != 2 {
+		t.Fatalf("line = %+v, want 8 counted by two devices", line)
+	}
+	if *line.Variance != -2 {
+		t.Errorf("variance = %d, want -2", *line.Variance)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// TransferStatusRequest структура для смены статуса перемещения
+type TransferStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// Получение списка перемещений, с фильтром по аптеке, направлению и статусу
+func (h *Handler) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, apiErr := parseIntParam(r, "pharmacy_id")
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	h.writeTransfers(w, r, pharmacyID)
+}
+
+// История перемещений аптеки: входящие и исходящие
+func (h *Handler) GetPharmacyTransfers(w http.ResponseWriter, r *http.Request) {
+	pharmacyID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+	h.writeTransfers(w, r, pharmacyID)
+}
+
+// writeTransfers отдаёт перемещения аптеки pharmacyID (0 — всех аптек) с фильтрами direction и status из запроса
+func (h *Handler) writeTransfers(w http.ResponseWriter, r *http.Request, pharmacyID int) {
+	filter := models.TransferFilter{
+		PharmacyID: pharmacyID,
+		Direction:  r.URL.Query().Get("direction"),
+		Status:     r.URL.Query().Get("status"),
+	}
+	if filter.Direction != "" && filter.Direction != models.TransferDirectionIncoming && filter.Direction != models.TransferDirectionOutgoing {
+		writeError(w, r, fieldError("direction", "must be incoming or outgoing"))
+		return
+	}
+
+	transfers, err := h.Transfers.ListTransfers(r.Context(), filter)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching transfers")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+// Получение перемещения вместе с отправленными партиями
+func (h *Handler) GetTransferByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	transfer, err := h.Transfers.GetTransfer(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching transfer")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+// Создание запроса на перемещение лекарств из одной аптеки в другую
+func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var transfer models.Transfer
+	if err := json.NewDecoder(r.Body).Decode(&transfer); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	if apiErr := validateTransfer(&transfer); apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	transfer.RequestedBy = currentUserID(r)
+
+	err := h.Transfers.CreateTransfer(r.Context(), &transfer)
+	if errors.Is(err, store.ErrNotFound) {
+		// Указана несуществующая аптека или лекарство
+		writeError(w, r, newError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "creating transfer")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, transfer)
+}
+
+// Смена статуса перемещения: одобрение или отклонение отправителем, отмена, отправка и приёмка.
+// Автор запроса не может сам его одобрить или отклонить.
+// Отправка списывает товар в аптеке-отправителе, приёмка оприходует его в аптеке-получателе
+func (h *Handler) UpdateTransferStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	var request TransferStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+	switch request.Status {
+	case models.TransferStatusApproved, models.TransferStatusRejected, models.TransferStatusCancelled,
+		models.TransferStatusInTransit, models.TransferStatusReceived:
+	default:
+		writeError(w, r, fieldError("status", "must be approved, rejected, cancelled, in_transit or received"))
+		return
+	}
+
+	transfer, err := h.Transfers.UpdateTransferStatus(r.Context(), id, request.Status, currentUserID(r))
+	if errors.Is(err, store.ErrInvalidTransition) {
+		writeError(w, r, newError(http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Cannot change transfer status to %s", request.Status)))
+		return
+	}
+	if err != nil {
+		writeStoreError(w, r, err, "updating transfer status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+// validateTransfer проверяет аптеки и позиции нового перемещения
+func validateTransfer(transfer *models.Transfer) *APIError {
+	transfer.Notes = strings.TrimSpace(transfer.Notes)
+	var details []FieldError
+	if transfer.FromPharmacyID <= 0 {
+		details = append(details, FieldError{Field: "from_pharmacy_id", Message: "is required"})
+	}
+	if transfer.ToPharmacyID <= 0 {
+		details = append(details, FieldError{Field: "to_pharmacy_id", Message: "is required"})
+	} else if transfer.ToPharmacyID == transfer.FromPharmacyID {
+		details = append(details, FieldError{Field: "to_pharmacy_id", Message: "must differ from from_pharmacy_id"})
+	}
+	if len(transfer.Items) == 0 {
+		details = append(details, FieldError{Field: "items", Message: "must contain at least one item"})
+	}
+	medicines := map[int]bool{}
+	for i, item := range transfer.Items {
+		field := fmt.Sprintf("items[%d]", i)
+		if medicines[item.MedicineID] {
+			details = append(details, FieldError{Field: field + ".medicine_id", Message: "is listed more than once"})
+		}
+		medicines[item.MedicineID] = true
+		if item.Quantity <= 0 {
+			details = append(details, FieldError{Field: field + ".quantity", Message: "must be positive"})
+		}
+	}
+	if details != nil {
+		return validationError(details...)
+	}
+	return nil
+}
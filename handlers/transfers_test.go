@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"pharmacy-test/handlers"
+	"pharmacy-test/models"
+)
+
+func TestTransferShipAndReceive(t *testing.T) {
+	api := newTestAPI()
+	requester, requesterToken := api.userWithRole(t, "requester", "Seller")
+	approver, approverToken := api.userWithRole(t, "approver", "Seller")
+	from, to := api.pharmacy(t), api.pharmacy(t)
+	medicine := api.medicine(t, "Ибупрофен", 80, false)
+
+	// У отправителя две партии и две единицы без партии
+	lotsPath := "/api/medicines/" + strconv.Itoa(medicine.ID) + "/lots"
+	api.expect(t, api.do(t, "POST", lotsPath, approverToken, models.Lot{
+		PharmacyID: from.ID, LotNumber: "B-2", ExpiryDate: "2026-01-31", Quantity: 5,
+	}), http.StatusCreated, nil)
+	api.expect(t, api.do(t, "POST", lotsPath, approverToken, models.Lot{
+		PharmacyID: from.ID, LotNumber: "A-1", ExpiryDate: "2025-06-30", Quantity: 3,
+	}), http.StatusCreated, nil)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(from.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, approverToken, handlers.StockUpdateRequest{Quantity: intPtr(10)}), http.StatusOK, nil)
+
+	var transfer models.Transfer
+	api.expect(t, api.do(t, "POST", "/api/transfers", requesterToken, models.Transfer{
+		FromPharmacyID: from.ID,
+		ToPharmacyID:   to.ID,
+		Items:          []models.TransferItem{{MedicineID: medicine.ID, Quantity: 5}},
+	}), http.StatusCreated, &transfer)
+	if transfer.Status != models.TransferStatusRequested || transfer.RequestedBy == nil || *transfer.RequestedBy != requester.ID {
+		t.Fatalf("transfer = %+v, want requested by %d", transfer, requester.ID)
+	}
+	statusPath := "/api/transfers/" + strconv.Itoa(transfer.ID) + "/status"
+	setStatus := func(token, status string) *httptest.ResponseRecorder {
+		return api.do(t, "PUT", statusPath, token, handlers.TransferStatusRequest{Status: status})
+	}
+
+	api.expectError(t, setStatus(requesterToken, models.TransferStatusApproved), http.StatusForbidden, handlers.CodeForbidden)
+	api.expectError(t, setStatus(requesterToken, models.TransferStatusRejected), http.StatusForbidden, handlers.CodeForbidden)
+	api.expectError(t, setStatus(approverToken, models.TransferStatusInTransit), http.StatusConflict, handlers.CodeInvalidTransition)
+
+	api.expect(t, setStatus(approverToken, models.TransferStatusApproved), http.StatusOK, &transfer)
+	if transfer.ApprovedBy == nil || *transfer.ApprovedBy != approver.ID {
+		t.Errorf("approved_by = %v, want %d", transfer.ApprovedBy, approver.ID)
+	}
+	if got := api.stock(t, approverToken, from.ID, medicine.ID); got != 10 {
+		t.Fatalf("source stock after approval = %d, want 10", got)
+	}
+
+	// Отправка списывает у отправителя по FEFO: сначала партия с ближайшим сроком
+	api.expect(t, setStatus(approverToken, models.TransferStatusInTransit), http.StatusOK, &transfer)
+	allocations := transfer.Items[0].Allocations
+	if len(allocations) != 2 || allocations[0].LotNumber != "A-1" || allocations[0].Quantity != 3 ||
+		allocations[1].LotNumber != "B-2" || allocations[1].Quantity != 2 {
+		t.Errorf("allocations = %+v, want A-1×3 and B-2×2", allocations)
+	}
+	if got := api.stock(t, approverToken, from.ID, medicine.ID); got != 5 {
+		t.Errorf("source stock after shipping = %d, want 5", got)
+	}
+	if got := api.stock(t, approverToken, to.ID, medicine.ID); got != 0 {
+		t.Errorf("destination stock after shipping = %d, want 0", got)
+	}
+	api.expectError(t, setStatus(approverToken, models.TransferStatusCancelled), http.StatusConflict, handlers.CodeInvalidTransition)
+
+	// Приёмка оприходует те же серии у получателя
+	api.expect(t, setStatus(requesterToken, models.TransferStatusReceived), http.StatusOK, &transfer)
+	if got := api.stock(t, requesterToken, to.ID, medicine.ID); got != 5 {
+		t.Errorf("destination stock after receipt = %d, want 5", got)
+	}
+	if got := api.stock(t, requesterToken, from.ID, medicine.ID); got != 5 {
+		t.Errorf("source stock after receipt = %d, want 5", got)
+	}
+	lots, err := api.store.ListMedicineLots(context.Background(), medicine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := map[string]int{}
+	for _, lot := range lots {
+		if lot.PharmacyID == to.ID {
+			received[lot.LotNumber+" "+lot.ExpiryDate] = lot.Quantity
+		}
+	}
+	if len(received) != 2 || received["A-1 2025-06-30"] != 3 || received["B-2 2026-01-31"] != 2 {
+		t.Errorf("destination lots = %v, want A-1×3 and B-2×2 with source expiry dates", received)
+	}
+	api.expectError(t, setStatus(requesterToken, models.TransferStatusReceived), http.StatusConflict, handlers.CodeInvalidTransition)
+}
+
+func TestTransferShipInsufficientStock(t *testing.T) {
+	api := newTestAPI()
+	_, requesterToken := api.userWithRole(t, "requester", "Seller")
+	_, approverToken := api.userWithRole(t, "approver", "Seller")
+	from, to := api.pharmacy(t), api.pharmacy(t)
+	medicine := api.medicine(t, "Парацетамол", 45.5, false)
+	stockPath := "/api/pharmacies/" + strconv.Itoa(from.ID) + "/stock/" + strconv.Itoa(medicine.ID)
+	api.expect(t, api.do(t, "PUT", stockPath, approverToken, handlers.StockUpdateRequest{Quantity: intPtr(2)}), http.StatusOK, nil)
+
+	var transfer models.Transfer
+	api.expect(t, api.do(t, "POST", "/api/transfers", requesterToken, models.Transfer{
+		FromPharmacyID: from.ID,
+		ToPharmacyID:   to.ID,
+		Items:          []models.TransferItem{{MedicineID: medicine.ID, Quantity: 3}},
+	}), http.StatusCreated, &transfer)
+	statusPath := "/api/transfers/" + strconv.Itoa(transfer.ID) + "/status"
+	api.expect(t, api.do(t, "PUT", statusPath, approverToken, handlers.TransferStatusRequest{Status: models.TransferStatusApproved}), http.StatusOK, nil)
+
+	rec := api.do(t, "PUT", statusPath, approverToken, handlers.TransferStatusRequest{Status: models.TransferStatusInTransit})
+	api.expectError(t, rec, http.StatusConflict, handlers.CodeInsufficientStock)
+	stored, err := api.store.GetTransfer(context.Background(), transfer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.TransferStatusApproved {
+		t.Errorf("status after failed shipping = %q, want approved", stored.Status)
+	}
+	if got := api.stock(t, approverToken, from.ID, medicine.ID); got != 2 {
+		t.Errorf("source stock after failed shipping = %d, want 2", got)
+	}
+
+	// Одобренное, но не отправленное перемещение можно отменить
+	api.expect(t, api.do(t, "PUT", statusPath, requesterToken, handlers.TransferStatusRequest{Status: models.TransferStatusCancelled}), http.StatusOK, nil)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LoginUser обрабатывает вход пользователя
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
+	var credentials LoginRequest
+
+	// Чтение JSON из запроса
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	// Получение данных пользователя из базы
+	user, err := h.Users.GetUserByUsername(r.Context(), credentials.Username)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, unauthorized("Invalid username or password"))
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err, "fetching user")
+		return
+	}
+
+	// Проверка пароля
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
+		writeError(w, r, unauthorized("Invalid username or password"))
+		return
+	}
+
+	// Новая сессия для этого устройства; остальные сессии пользователя остаются активными
+	token := uuid.New().String()
+	session := models.Session{
+		UserID:    user.ID,
+		TokenHash: models.HashToken(token),
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}
+	if err := h.Sessions.CreateSession(r.Context(), &session, h.SessionTTL); err != nil {
+		writeInternalError(w, r, err, "creating session")
+		return
+	}
+
+	setAuthCookie(w, token, session.ExpiresAt)
+
+	// Возврат роли и данных пользователя
+	response := map[string]interface{}{
+		"cookie":     token,
+		"username":   user.Username,
+		"position":   user.Details.Position,
+		"session_id": session.ID,
+		"expires_at": session.ExpiresAt,
+	}
+	w.Header().Set("Auth_token", token)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	// Получаем токен из cookie или заголовков запроса
+	token := tokenFromRequest(r)
+	if token == "" {
+		writeError(w, r, unauthorized("Authentication required"))
+		return
+	}
+
+	// Обнуляем cookie
+	setAuthCookie(w, "", time.Unix(0, 0))
+
+	if err := h.Sessions.RevokeSessionByToken(r.Context(), models.HashToken(token)); err != nil {
+		writeInternalError(w, r, err, "revoking session")
+		return
+	}
+
+	// Возвращаем успешный ответ
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Logout successful",
+	})
+}
+
+// callerHasPermission проверяет разрешение у пользователя текущей сессии; без сессии разрешений нет
+func (h *Handler) callerHasPermission(w http.ResponseWriter, r *http.Request, permission string) bool {
+	_, user, err := h.authenticate(w, r)
+	if err != nil {
+		return false
+	}
+	role, err := h.userRole(r, user)
+	return err == nil && role.HasPermission(permission)
+}
+
+func (h *Handler) CreateUserWithDetails(w http.ResponseWriter, r *http.Request) {
+	var userWithDetails models.UserWithDetails
+	if err := json.NewDecoder(r.Body).Decode(&userWithDetails); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	// Роль при регистрации выбирает только администратор пользователей;
+	// остальные, в том числе анонимные клиенты, получают роль по умолчанию независимо от запрошенной
+	if !h.callerHasPermission(w, r, models.PermUserAdmin) {
+		userWithDetails.Details.Position = h.SignupRole
+	}
+
+	// Проверка на валидность позиции: должность — это имя роли
+	if _, err := h.Roles.GetRole(r.Context(), userWithDetails.Details.Position); errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, fieldError("details.position", "unknown role"))
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+
+	// Хэширование пароля
+	hashedPassword, err := hashPassword(userWithDetails.Password)
+	if err != nil {
+		writeInternalError(w, r, err, "hashing password")
+		return
+	}
+	userWithDetails.Password = hashedPassword
+
+	if err := h.Users.CreateUser(r.Context(), &userWithDetails); err != nil {
+		writeStoreError(w, r, err, "inserting user")
+		return
+	}
+	userWithDetails.Password = ""
+
+	writeJSON(w, http.StatusOK, userWithDetails)
+}
+
+func (h *Handler) UpdateUserWithDetails(w http.ResponseWriter, r *http.Request) {
+	if tokenFromRequest(r) == "" {
+		writeError(w, r, unauthorized("Authentication required"))
+		return
+	}
+
+	// Проверка и получение пользователя по токену
+	_, current, err := h.authenticate(w, r)
+	if err != nil {
+		writeError(w, r, unauthorized("Invalid or expired session"))
+		return
+	}
+
+	var userWithDetails models.UserWithDetails
+	if err := json.NewDecoder(r.Body).Decode(&userWithDetails); err != nil {
+		writeError(w, r, invalidJSON())
+		return
+	}
+
+	// Проверка на валидность позиции: должность — это имя роли
+	if _, err := h.Roles.GetRole(r.Context(), userWithDetails.Details.Position); errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, fieldError("details.position", "unknown role"))
+		return
+	} else if err != nil {
+		writeStoreError(w, r, err, "fetching role")
+		return
+	}
+
+	// Сменить собственную роль может только администратор пользователей
+	if userWithDetails.Details.Position != current.Details.Position && !h.callerHasPermission(w, r, models.PermUserAdmin) {
+		writeError(w, r, forbidden("Changing position requires user administration permission"))
+		return
+	}
+
+	// Хэширование пароля
+	hashedPassword, err := hashPassword(userWithDetails.Password)
+	if err != nil {
+		writeInternalError(w, r, err, "hashing password")
+		return
+	}
+	userWithDetails.Password = hashedPassword
+	userWithDetails.ID = current.ID
+
+	if err := h.Users.UpdateUser(r.Context(), &userWithDetails); err != nil {
+		writeStoreError(w, r, err, "updating user")
+		return
+	}
+	userWithDetails.Password = ""
+
+	writeJSON(w, http.StatusOK, userWithDetails)
+}
+
+func (h *Handler) GetUserWithDetailsByCookie(w http.ResponseWriter, r *http.Request) {
+	if tokenFromRequest(r) == "" {
+		writeError(w, r, unauthorized("Authentication required"))
+		return
+	}
+
+	// Ищем пользователя по токену
+	_, user, err := h.authenticate(w, r)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, r, unauthorized("Invalid or expired session"))
+		return
+	}
+	if err != nil {
+		writeInternalError(w, r, err, "fetching user")
+		return
+	}
+	user.Password = ""
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Удаление пользователя и его деталей
+func (h *Handler) DeleteUserWithDetails(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, r, fieldError("id", "must be an integer"))
+		return
+	}
+
+	if err := h.Users.DeleteUser(r.Context(), id); err != nil {
+		writeStoreError(w, r, err, "deleting user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Получение страницы пользователей с их деталями, с фильтром по должности
+func (h *Handler) GetAllUsersWithDetails(w http.ResponseWriter, r *http.Request) {
+	page, apiErr := parsePage(r, models.UserSorts)
+	if apiErr != nil {
+		writeError(w, r, apiErr)
+		return
+	}
+	filter := models.UserFilter{Position: r.URL.Query().Get("position")}
+
+	users, err := h.Users.ListUsers(r.Context(), filter, page)
+	if err != nil {
+		writeStoreError(w, r, err, "fetching users")
+		return
+	}
+	for i := range users.Items {
+		users.Items[i].Password = ""
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
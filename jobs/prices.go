@@ -0,0 +1,32 @@
+// Package jobs содержит фоновые задачи сервера
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pharmacy-test/store"
+)
+
+// RunPriceScheduler применяет запланированные изменения цен сразу при запуске и далее
+// каждые interval, пока не отменён ctx. Несколько реплик могут работать одновременно:
+// хранилище не применяет одно изменение дважды
+func RunPriceScheduler(ctx context.Context, prices store.PriceStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		applied, err := prices.ApplyDuePrices(ctx)
+		if err != nil {
+			log.Printf("price scheduler: %v", err)
+		} else if applied > 0 {
+			log.Printf("price scheduler: applied %d scheduled price changes", applied)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
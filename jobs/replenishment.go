@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pharmacy-test/store"
+)
+
+// RunReplenishment пересчитывает предложения пополнения всех аптек сразу при запуске и далее
+// каждые interval, пока не отменён ctx
+func RunReplenishment(ctx context.Context, replenishment store.ReplenishmentStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		count, err := replenishment.RefreshReplenishment(ctx, 0)
+		if err != nil {
+			log.Printf("replenishment: %v", err)
+		} else {
+			log.Printf("replenishment: %d medicines need reordering", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
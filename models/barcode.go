@@ -0,0 +1,189 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// gs1Separator — символ GS (FNC1), завершающий поля переменной длины в GS1 DataMatrix
+const gs1Separator = "\x1d"
+
+// gs1Field описывает идентификатор применения GS1: fixed — длина фиксированного поля,
+// max — наибольшая длина поля переменной длины
+type gs1Field struct {
+	fixed int
+	max   int
+}
+
+// gs1Fields перечисляет поддерживаемые идентификаторы применения. Поля 91–93 —
+// внутренние данные производителя (в российской маркировке — ключ и код проверки), они пропускаются
+var gs1Fields = map[string]gs1Field{
+	"01": {fixed: 14},
+	"10": {max: 20},
+	"11": {fixed: 6},
+	"17": {fixed: 6},
+	"21": {max: 20},
+	"91": {max: 90},
+	"92": {max: 90},
+	"93": {max: 90},
+}
+
+// Barcode разобранный штрихкод упаковки: EAN-13 (а также EAN-8, UPC-A, GTIN-14)
+// или GS1 DataMatrix, в котором кроме GTIN бывают серийный номер, серия и срок годности
+type Barcode struct {
+	GTIN       string `json:"gtin"`
+	Serial     string `json:"serial,omitempty"`
+	LotNumber  string `json:"lot_number,omitempty"`
+	ExpiryDate string `json:"expiry_date,omitempty"`
+}
+
+// BarcodeLookup результат поиска лекарства по штрихкоду.
+// Lots — партии лекарства с серией из кода, а если серии в коде нет — все партии с остатком
+type BarcodeLookup struct {
+	Barcode  Barcode  `json:"barcode"`
+	Medicine Medicine `json:"medicine"`
+	Lots     []Lot    `json:"lots"`
+}
+
+// NormalizeGTIN проверяет контрольную цифру GTIN-8, GTIN-12 (UPC-A), GTIN-13 (EAN-13) или GTIN-14
+// и приводит код к 14 цифрам: так один товар находится и по EAN-13, и по коду из DataMatrix
+func NormalizeGTIN(code string) (string, bool) {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", false
+	}
+	sum := 0
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return "", false
+		}
+		digit := int(code[i] - '0')
+		// Веса 3 и 1 чередуются справа налево, начиная с цифры перед контрольной
+		if (len(code)-1-i)%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	if sum%10 != 0 {
+		return "", false
+	}
+	return strings.Repeat("0", 14-len(code)) + code, true
+}
+
+// ParseBarcode разбирает отсканированный код: строку из 8, 12, 13 или 14 цифр как GTIN,
+// остальное — как GS1 DataMatrix (с разделителями GS или в читаемом виде «(01)…(21)…»)
+func ParseBarcode(code string) (Barcode, error) {
+	code = strings.TrimSpace(code)
+	if len(code) <= 14 && code != "" && strings.Trim(code, "0123456789") == "" {
+		gtin, ok := NormalizeGTIN(code)
+		if !ok {
+			return Barcode{}, fmt.Errorf("%s is not a valid GTIN", code)
+		}
+		return Barcode{GTIN: gtin}, nil
+	}
+	return ParseDataMatrix(code)
+}
+
+// ParseDataMatrix разбирает строку GS1 DataMatrix. Префикс символики (]d2), ведущий FNC1
+// и неизвестные поля 91–93 допускаются; GTIN обязателен
+func ParseDataMatrix(data string) (Barcode, error) {
+	var barcode Barcode
+	if strings.HasPrefix(data, "]") && len(data) >= 3 {
+		data = data[3:]
+	}
+	data = strings.TrimPrefix(data, gs1Separator)
+	if strings.HasPrefix(data, "(") {
+		data = bracketedToRaw(data)
+	}
+
+	for data != "" {
+		// Все поддерживаемые идентификаторы двузначные
+		ai := truncate(data, 2)
+		field, ok := gs1Fields[ai]
+		if !ok {
+			return barcode, fmt.Errorf("unsupported GS1 application identifier %q", ai)
+		}
+		data = data[len(ai):]
+
+		var value string
+		if field.fixed > 0 {
+			if len(data) < field.fixed {
+				return barcode, fmt.Errorf("GS1 field (%s) must be %d characters", ai, field.fixed)
+			}
+			value, data = data[:field.fixed], data[field.fixed:]
+			// Разделитель после поля фиксированной длины не обязателен, но допустим
+			data = strings.TrimPrefix(data, gs1Separator)
+		} else {
+			end := strings.Index(data, gs1Separator)
+			if end < 0 {
+				end = len(data)
+			}
+			value, data = data[:end], strings.TrimPrefix(data[end:], gs1Separator)
+			if value == "" || len(value) > field.max {
+				return barcode, fmt.Errorf("GS1 field (%s) must be 1 to %d characters", ai, field.max)
+			}
+		}
+
+		switch ai {
+		case "01":
+			gtin, ok := NormalizeGTIN(value)
+			if !ok {
+				return barcode, fmt.Errorf("GS1 field (01) %s is not a valid GTIN", value)
+			}
+			barcode.GTIN = gtin
+		case "10":
+			barcode.LotNumber = value
+		case "17":
+			expiry, err := gs1Date(value)
+			if err != nil {
+				return barcode, fmt.Errorf("GS1 field (17) %s is not a valid date", value)
+			}
+			barcode.ExpiryDate = expiry
+		case "21":
+			barcode.Serial = value
+		}
+	}
+
+	if barcode.GTIN == "" {
+		return barcode, fmt.Errorf("GS1 field (01) with GTIN is required")
+	}
+	return barcode, nil
+}
+
+// bracketedToRaw переводит читаемую запись «(01)…(10)…» в строку с разделителями GS
+func bracketedToRaw(data string) string {
+	var raw strings.Builder
+	for _, part := range strings.Split(data, "(")[1:] {
+		if raw.Len() > 0 {
+			raw.WriteString(gs1Separator)
+		}
+		raw.WriteString(strings.Replace(part, ")", "", 1))
+	}
+	return raw.String()
+}
+
+// gs1Date переводит дату GS1 YYMMDD в YYYY-MM-DD; день 00 означает последний день месяца
+func gs1Date(value string) (string, error) {
+	if strings.HasSuffix(value, "00") {
+		month, err := time.Parse("060102", value[:4]+"01")
+		if err != nil {
+			return "", err
+		}
+		return month.AddDate(0, 1, -1).Format(DateLayout), nil
+	}
+	date, err := time.Parse("060102", value)
+	if err != nil {
+		return "", err
+	}
+	return date.Format(DateLayout), nil
+}
+
+// truncate обрезает строку до n байт для сообщений об ошибках
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
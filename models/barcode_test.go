@@ -0,0 +1,82 @@
+package models
+
+import "testing"
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"96385074", "00000096385074", true},
+		{"036000291452", "00036000291452", true},
+		{"4006381333931", "04006381333931", true},
+		{"04006381333931", "04006381333931", true},
+		{"4006381333932", "", false},
+		{"400638133393", "", false},
+		{"40063813339311", "", false},
+		{"40063813339a1", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, ok := NormalizeGTIN(tt.code)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("NormalizeGTIN(%q) = %q, %v; want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseBarcode(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    Barcode
+		wantErr bool
+	}{
+		{name: "EAN-13", code: "4006381333931", want: Barcode{GTIN: "04006381333931"}},
+		{name: "EAN-13 with spaces", code: " 4006381333931\n", want: Barcode{GTIN: "04006381333931"}},
+		{name: "bad check digit", code: "4006381333932", wantErr: true},
+		{name: "GTIN only DataMatrix", code: "0104006381333931", want: Barcode{GTIN: "04006381333931"}},
+		{
+			name: "DataMatrix with separators",
+			code: "]d2\x1d010400638133393121ABC123\x1d10LOT-7\x1d17260531\x1d91EE06\x1d92dGVzdA==",
+			want: Barcode{GTIN: "04006381333931", Serial: "ABC123", LotNumber: "LOT-7", ExpiryDate: "2026-05-31"},
+		},
+		{
+			name: "fixed fields without separators",
+			code: "010400638133393117260500" + "10LOT-7",
+			want: Barcode{GTIN: "04006381333931", LotNumber: "LOT-7", ExpiryDate: "2026-05-31"},
+		},
+		{
+			name: "bracketed",
+			code: "(01)04006381333931(21)XYZ(17)250200(10)A1",
+			want: Barcode{GTIN: "04006381333931", Serial: "XYZ", LotNumber: "A1", ExpiryDate: "2025-02-28"},
+		},
+		{name: "missing GTIN", code: "(21)XYZ", wantErr: true},
+		{name: "DataMatrix bad GTIN", code: "0104006381333932", wantErr: true},
+		{name: "unsupported identifier", code: "0104006381333931\x1d30100", wantErr: true},
+		{name: "short fixed field", code: "01040063813339", wantErr: true},
+		{name: "empty variable field", code: "010400638133393121\x1d10A", wantErr: true},
+		{name: "bad expiry", code: "(01)04006381333931(17)251301", wantErr: true},
+		{name: "empty", code: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBarcode(tt.code)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseBarcode(%q) = %+v, want error", tt.code, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseBarcode(%q): %v", tt.code, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBarcode(%q) = %+v, want %+v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
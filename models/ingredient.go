@@ -0,0 +1,103 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Массовые единицы дозировки; при сравнении составов приводятся к миллиграммам
+const (
+	UnitMilligram = "mg"
+	UnitGram      = "g"
+	UnitMicrogram = "mcg"
+)
+
+// IngredientUnits — допустимые единицы дозировки
+var IngredientUnits = []string{
+	UnitMilligram, UnitGram, UnitMicrogram, "IU", "ml", "%", "mg/ml", "mcg/ml", "IU/ml", "mcg/dose",
+}
+
+// DosageForms — допустимые лекарственные формы
+var DosageForms = []string{
+	"tablet", "film-coated tablet", "effervescent tablet", "capsule", "powder", "granules",
+	"solution", "injection", "suspension", "syrup", "drops", "spray", "aerosol", "inhaler",
+	"ointment", "cream", "gel", "suppository", "patch", "lozenge",
+}
+
+// atcPattern — код ATC ВОЗ любого уровня: от группы "N" до вещества "N02BE01"
+var atcPattern = regexp.MustCompile(`^[A-Z]([0-9]{2}([A-Z]([A-Z]([0-9]{2})?)?)?)?$`)
+
+// ActiveIngredient действующее вещество лекарства с дозировкой
+type ActiveIngredient struct {
+	Name     string  `json:"name"`
+	Strength float64 `json:"strength"`
+	Unit     string  `json:"unit"`
+}
+
+// MedicineSubstitute лекарство с теми же действующими веществами и дозировками
+type MedicineSubstitute struct {
+	Medicine Medicine `json:"medicine"`
+	// Price — цена заменителя в аптеке запроса или цена каталога
+	Price float64 `json:"price"`
+	// PriceDifference — разница с ценой исходного лекарства: отрицательная, если заменитель дешевле
+	PriceDifference float64 `json:"price_difference"`
+	SameDosageForm  bool    `json:"same_dosage_form"`
+	// Quantity — остаток в аптеке запроса; nil, если аптека не указана
+	Quantity *int `json:"quantity,omitempty"`
+}
+
+// ValidATCCode проверяет код анатомо-терапевтическо-химической классификации ВОЗ (ATC)
+func ValidATCCode(code string) bool {
+	return atcPattern.MatchString(code)
+}
+
+// normalizedStrength приводит массовые единицы к миллиграммам, остальные оставляет как есть
+func (i ActiveIngredient) normalizedStrength() (float64, string) {
+	switch i.Unit {
+	case UnitGram:
+		return i.Strength * 1000, UnitMilligram
+	case UnitMicrogram:
+		return i.Strength / 1000, UnitMilligram
+	}
+	return i.Strength, i.Unit
+}
+
+// IngredientName нормализует название вещества для сравнения: регистр и лишние пробелы не учитываются
+func IngredientName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// IngredientsKey строит ключ состава: лекарства с одинаковым ключом взаимозаменяемы.
+// Порядок веществ и единица массы (g, mg, mcg) на ключ не влияют; пустой состав даёт пустой ключ
+func IngredientsKey(ingredients []ActiveIngredient) string {
+	parts := make([]string, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		strength, unit := ingredient.normalizedStrength()
+		strength = math.Round(strength*1e6) / 1e6
+		parts = append(parts, IngredientName(ingredient.Name)+"="+strconv.FormatFloat(strength, 'f', -1, 64)+unit)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ";")
+}
+
+// SortSubstitutes упорядочивает заменители: сначала те, что есть в аптеке запроса,
+// затем той же лекарственной формы, затем от дешёвых к дорогим
+func SortSubstitutes(substitutes []MedicineSubstitute) {
+	inStock := func(s MedicineSubstitute) bool { return s.Quantity != nil && *s.Quantity > 0 }
+	sort.SliceStable(substitutes, func(i, j int) bool {
+		a, b := substitutes[i], substitutes[j]
+		if inStock(a) != inStock(b) {
+			return inStock(a)
+		}
+		if a.SameDosageForm != b.SameDosageForm {
+			return a.SameDosageForm
+		}
+		if a.Price != b.Price {
+			return a.Price < b.Price
+		}
+		return a.Medicine.ID < b.Medicine.ID
+	})
+}
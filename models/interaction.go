@@ -0,0 +1,114 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Степени тяжести взаимодействия, от слабой к наиболее опасной
+const (
+	SeverityMinor           = "minor"
+	SeverityModerate        = "moderate"
+	SeverityMajor           = "major"
+	SeverityContraindicated = "contraindicated"
+)
+
+// Severities — степени тяжести по возрастанию
+var Severities = []string{SeverityMinor, SeverityModerate, SeverityMajor, SeverityContraindicated}
+
+// SeverityRank возвращает порядковый номер степени тяжести: чем больше, тем опаснее; 0 — неизвестная степень
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Interaction запись базы взаимодействий: пара действующих веществ в нормализованном виде,
+// IngredientA не больше IngredientB. Пара из одного вещества описывает дублирование терапии
+type Interaction struct {
+	ID          int       `json:"id"`
+	IngredientA string    `json:"ingredient_a"`
+	IngredientB string    `json:"ingredient_b"`
+	Severity    string    `json:"severity"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// InteractionImportResult итог импорта базы взаимодействий
+type InteractionImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// InteractionWarning взаимодействие между двумя лекарствами корзины
+type InteractionWarning struct {
+	MedicineA   MedicineRef `json:"medicine_a"`
+	MedicineB   MedicineRef `json:"medicine_b"`
+	IngredientA string      `json:"ingredient_a"`
+	IngredientB string      `json:"ingredient_b"`
+	Severity    string      `json:"severity"`
+	Description string      `json:"description"`
+}
+
+// MedicineRef краткая ссылка на лекарство
+type MedicineRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Normalize приводит названия веществ к виду IngredientName и упорядочивает пару
+func (i *Interaction) Normalize() {
+	i.IngredientA, i.IngredientB = IngredientName(i.IngredientA), IngredientName(i.IngredientB)
+	if i.IngredientA > i.IngredientB {
+		i.IngredientA, i.IngredientB = i.IngredientB, i.IngredientA
+	}
+}
+
+// FindInteractions возвращает взаимодействия между каждой парой разных лекарств по их составу.
+// Предупреждения упорядочены от наиболее опасных
+func FindInteractions(medicines []Medicine, interactions []Interaction) []InteractionWarning {
+	known := make(map[[2]string]Interaction, len(interactions))
+	for _, interaction := range interactions {
+		interaction.Normalize()
+		known[[2]string{interaction.IngredientA, interaction.IngredientB}] = interaction
+	}
+
+	warnings := []InteractionWarning{}
+	for i := range medicines {
+		for j := i + 1; j < len(medicines); j++ {
+			a, b := medicines[i], medicines[j]
+			if a.ID == b.ID {
+				continue
+			}
+			seen := map[[2]string]bool{}
+			for _, ia := range a.Ingredients {
+				for _, ib := range b.Ingredients {
+					pair := Interaction{IngredientA: ia.Name, IngredientB: ib.Name}
+					pair.Normalize()
+					key := [2]string{pair.IngredientA, pair.IngredientB}
+					interaction, ok := known[key]
+					if !ok || seen[key] {
+						continue
+					}
+					seen[key] = true
+					warnings = append(warnings, InteractionWarning{
+						MedicineA:   MedicineRef{ID: a.ID, Name: a.Name},
+						MedicineB:   MedicineRef{ID: b.ID, Name: b.Name},
+						IngredientA: IngredientName(ia.Name),
+						IngredientB: IngredientName(ib.Name),
+						Severity:    interaction.Severity,
+						Description: interaction.Description,
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return SeverityRank(warnings[i].Severity) > SeverityRank(warnings[j].Severity)
+	})
+	return warnings
+}
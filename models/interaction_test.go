@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindInteractions(t *testing.T) {
+	nurofen := Medicine{ID: 1, Name: "Нурофен", Ingredients: []ActiveIngredient{{Name: "Ibuprofen"}}}
+	warfarin := Medicine{ID: 2, Name: "Варфарин", Ingredients: []ActiveIngredient{{Name: " Warfarin "}}}
+	ibuklin := Medicine{ID: 3, Name: "Ибуклин", Ingredients: []ActiveIngredient{{Name: "Ibuprofen"}, {Name: "Paracetamol"}}}
+	// Пары записаны в произвольном порядке и регистре: FindInteractions нормализует их сама
+	interactions := []Interaction{
+		{IngredientA: "WARFARIN", IngredientB: "ibuprofen", Severity: SeverityMajor, Description: "Риск кровотечения"},
+		{IngredientA: "ibuprofen", IngredientB: "Ibuprofen", Severity: SeverityModerate, Description: "Дублирование терапии"},
+		{IngredientA: "paracetamol", IngredientB: "warfarin", Severity: SeverityMinor, Description: "Усиление действия"},
+		{IngredientA: "aspirin", IngredientB: "ibuprofen", Severity: SeverityModerate},
+	}
+
+	tests := []struct {
+		name      string
+		medicines []Medicine
+		want      []string
+	}{
+		{name: "single medicine", medicines: []Medicine{ibuklin}},
+		{name: "same medicine twice", medicines: []Medicine{nurofen, nurofen}},
+		{name: "reversed pair", medicines: []Medicine{nurofen, warfarin}, want: []string{"1+2 ibuprofen/warfarin major"}},
+		{name: "medicine order", medicines: []Medicine{warfarin, nurofen}, want: []string{"2+1 warfarin/ibuprofen major"}},
+		{name: "duplicate therapy", medicines: []Medicine{ibuklin, nurofen}, want: []string{"3+1 ibuprofen/ibuprofen moderate"}},
+		{
+			name: "ordered by severity", medicines: []Medicine{warfarin, ibuklin, nurofen},
+			want: []string{
+				"2+3 warfarin/ibuprofen major",
+				"2+1 warfarin/ibuprofen major",
+				"3+1 ibuprofen/ibuprofen moderate",
+				"2+3 warfarin/paracetamol minor",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := FindInteractions(tt.medicines, interactions)
+			if warnings == nil {
+				t.Fatal("warnings = nil, want an empty slice")
+			}
+			got := make([]string, 0, len(warnings))
+			for _, w := range warnings {
+				got = append(got, fmt.Sprintf("%d+%d %s/%s %s", w.MedicineA.ID, w.MedicineB.ID, w.IngredientA, w.IngredientB, w.Severity))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("warnings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	warnings := FindInteractions([]Medicine{warfarin, nurofen}, interactions)
+	if w := warnings[0]; w.MedicineA != (MedicineRef{ID: 2, Name: "Варфарин"}) || w.Description != "Риск кровотечения" {
+		t.Errorf("warning = %+v, want medicine names and description", w)
+	}
+}
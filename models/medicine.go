@@ -0,0 +1,152 @@
+package models
+
+import "time"
+
+// DateLayout — формат дат (производства, годности) в API и базе данных
+const DateLayout = "2006-01-02"
+
+// Medicine represents a medicine with associated pharmacies.
+type Medicine struct {
+	ID             int                `json:"id"`
+	Name           string             `json:"name"`
+	Manufacturer   string             `json:"manufacturer"`
+	ProductionDate string             `json:"production_date"`
+	Packaging      string             `json:"packaging"`
+	Price          float64            `json:"price"`
+	RxRequired     bool               `json:"rx_required"`
+	ATCCode        string             `json:"atc_code,omitempty"`
+	GTIN           string             `json:"gtin,omitempty"`
+	DosageForm     string             `json:"dosage_form,omitempty"`
+	Ingredients    []ActiveIngredient `json:"ingredients,omitempty"`
+	PharmacyIDs    []int              `json:"pharmacy_ids,omitempty"`
+	Availability   []StockItem        `json:"availability"`
+	Lots           []Lot              `json:"lots,omitempty"`
+	// ChangedBy — пользователь, создавший или изменивший лекарство; попадает в историю цен
+	ChangedBy *int `json:"-"`
+}
+
+// StockItem represents the quantity of a medicine on hand in a pharmacy.
+type StockItem struct {
+	PharmacyID   int    `json:"pharmacy_id"`
+	PharmacyName string `json:"pharmacy_name,omitempty"`
+	MedicineID   int    `json:"medicine_id"`
+	MedicineName string `json:"medicine_name,omitempty"`
+	Quantity     int    `json:"quantity"`
+}
+
+// Lot represents a delivered batch of a medicine held by a pharmacy.
+// Recalled означает, что серия отозвана производителем: такая партия, как и просроченная, не продаётся.
+type Lot struct {
+	ID             int       `json:"id"`
+	MedicineID     int       `json:"medicine_id"`
+	PharmacyID     int       `json:"pharmacy_id"`
+	LotNumber      string    `json:"lot_number"`
+	ProductionDate string    `json:"production_date,omitempty"`
+	ExpiryDate     string    `json:"expiry_date"`
+	Quantity       int       `json:"quantity"`
+	Expired        bool      `json:"expired"`
+	Recalled       bool      `json:"recalled"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// LotAllocation describes how many units were taken from a lot.
+// Для остатка, не привязанного к партиям, LotID равен нулю.
+type LotAllocation struct {
+	LotID      int    `json:"lot_id,omitempty"`
+	LotNumber  string `json:"lot_number,omitempty"`
+	ExpiryDate string `json:"expiry_date,omitempty"`
+	Quantity   int    `json:"quantity"`
+}
+
+// IsExpired сообщает, истёк ли срок годности партии на дату today
+func (l Lot) IsExpired(today time.Time) bool {
+	return l.ExpiryDate < today.Format(DateLayout)
+}
+
+// Sellable сообщает, можно ли продавать и отгружать партию: она не просрочена и не отозвана
+func (l Lot) Sellable() bool {
+	return !l.Expired && !l.Recalled
+}
+
+// SellableQuantity возвращает, сколько единиц из остатка onHand можно продать: единицы партий,
+// кроме просроченных и отозванных, и остаток, не привязанный к партиям; но не больше самого остатка
+func SellableQuantity(onHand int, lots []Lot) int {
+	lotted, sellable := 0, 0
+	for _, lot := range lots {
+		lotted += lot.Quantity
+		if lot.Sellable() {
+			sellable += lot.Quantity
+		}
+	}
+	if unlotted := onHand - lotted; unlotted > 0 {
+		sellable += unlotted
+	}
+	if sellable > onHand {
+		return onHand
+	}
+	return sellable
+}
+
+// PlanConsumption распределяет списание quantity единиц по правилу FEFO:
+// сначала партии с ближайшим сроком годности, затем остаток без партии.
+// Партии должны быть отсортированы по сроку годности, просроченные и отозванные пропускаются.
+// Второе значение равно false, если пригодного остатка недостаточно.
+func PlanConsumption(onHand int, lots []Lot, quantity int) ([]LotAllocation, bool) {
+	if quantity > SellableQuantity(onHand, lots) {
+		return nil, false
+	}
+
+	allocations := []LotAllocation{}
+	remaining := quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+		if !lot.Sellable() || lot.Quantity == 0 {
+			continue
+		}
+		take := lot.Quantity
+		if take > remaining {
+			take = remaining
+		}
+		allocations = append(allocations, LotAllocation{LotID: lot.ID, LotNumber: lot.LotNumber, ExpiryDate: lot.ExpiryDate, Quantity: take})
+		remaining -= take
+	}
+	if remaining > 0 {
+		allocations = append(allocations, LotAllocation{Quantity: remaining})
+	}
+	return allocations, true
+}
+
+// PlanTrim возвращает, сколько нужно убрать из каждой партии (начиная с ближайшего
+// срока годности), чтобы их суммарное количество не превышало остаток onHand.
+func PlanTrim(onHand int, lots []Lot) []LotAllocation {
+	excess := -onHand
+	for _, lot := range lots {
+		excess += lot.Quantity
+	}
+
+	var allocations []LotAllocation
+	for _, lot := range lots {
+		if excess <= 0 {
+			break
+		}
+		take := lot.Quantity
+		if take > excess {
+			take = excess
+		}
+		if take > 0 {
+			allocations = append(allocations, LotAllocation{LotID: lot.ID, LotNumber: lot.LotNumber, ExpiryDate: lot.ExpiryDate, Quantity: take})
+		}
+		excess -= take
+	}
+	return allocations
+}
+
+// MedicineSearchResult найденное лекарство с релевантностью и подсветкой совпавших слов
+type MedicineSearchResult struct {
+	Medicine Medicine `json:"medicine"`
+	Rank     float64  `json:"rank"`
+	// Highlights — HTML-фрагменты полей name, manufacturer, packaging с совпадениями в <mark>
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
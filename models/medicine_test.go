@@ -0,0 +1,142 @@
+package models
+
+import "testing"
+
+// sameAllocations сравнивает распределения по партиям, не различая nil и пустой срез
+func sameAllocations(got, want []LotAllocation) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPlanConsumption(t *testing.T) {
+	early := Lot{ID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3}
+	late := Lot{ID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 5}
+	expired := Lot{ID: 3, LotNumber: "C", ExpiryDate: "2025-01-31", Quantity: 4, Expired: true}
+	recalled := Lot{ID: 4, LotNumber: "D", ExpiryDate: "2025-02-28", Quantity: 4, Recalled: true}
+
+	tests := []struct {
+		name     string
+		onHand   int
+		lots     []Lot
+		quantity int
+		want     []LotAllocation
+		ok       bool
+	}{
+		{
+			name: "single lot", onHand: 8, lots: []Lot{early, late}, quantity: 2, ok: true,
+			want: []LotAllocation{{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 2}},
+		},
+		{
+			name: "spans lots in expiry order", onHand: 8, lots: []Lot{early, late}, quantity: 6, ok: true,
+			want: []LotAllocation{
+				{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3},
+				{LotID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 3},
+			},
+		},
+		{
+			name: "unlotted stock after lots", onHand: 10, lots: []Lot{early}, quantity: 5, ok: true,
+			want: []LotAllocation{
+				{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3},
+				{Quantity: 2},
+			},
+		},
+		{
+			name: "no lots", onHand: 4, quantity: 4, ok: true,
+			want: []LotAllocation{{Quantity: 4}},
+		},
+		{
+			name: "skips expired and recalled lots", onHand: 13, lots: []Lot{expired, recalled, late}, quantity: 5, ok: true,
+			want: []LotAllocation{{LotID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 5}},
+		},
+		{name: "expired lots are not sellable", onHand: 13, lots: []Lot{expired, recalled, late}, quantity: 6},
+		{name: "more than on hand", onHand: 8, lots: []Lot{early, late}, quantity: 9},
+		{name: "lots exceed on hand", onHand: 2, lots: []Lot{early, late}, quantity: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PlanConsumption(tt.onHand, tt.lots, tt.quantity)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !sameAllocations(got, tt.want) {
+				t.Errorf("allocations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanTrim(t *testing.T) {
+	lots := []Lot{
+		{ID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3},
+		{ID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 5},
+	}
+
+	tests := []struct {
+		name   string
+		onHand int
+		want   []LotAllocation
+	}{
+		{name: "on hand covers lots", onHand: 8},
+		{name: "on hand above lots", onHand: 12},
+		{
+			name: "trims earliest lot", onHand: 6,
+			want: []LotAllocation{{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 2}},
+		},
+		{
+			name: "trims across lots", onHand: 1,
+			want: []LotAllocation{
+				{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3},
+				{LotID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 4},
+			},
+		},
+		{
+			name: "empties all lots", onHand: 0,
+			want: []LotAllocation{
+				{LotID: 1, LotNumber: "A", ExpiryDate: "2025-03-31", Quantity: 3},
+				{LotID: 2, LotNumber: "B", ExpiryDate: "2025-09-30", Quantity: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanTrim(tt.onHand, lots); !sameAllocations(got, tt.want) {
+				t.Errorf("PlanTrim(%d) = %+v, want %+v", tt.onHand, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSellableQuantity(t *testing.T) {
+	good := Lot{ID: 1, Quantity: 3}
+	expired := Lot{ID: 2, Quantity: 4, Expired: true}
+	recalled := Lot{ID: 3, Quantity: 5, Recalled: true}
+
+	tests := []struct {
+		name   string
+		onHand int
+		lots   []Lot
+		want   int
+	}{
+		{"no lots", 7, nil, 7},
+		{"sellable lot and unlotted", 5, []Lot{good}, 5},
+		{"expired and recalled lots", 12, []Lot{good, expired, recalled}, 3},
+		{"unlotted beside unsellable lots", 14, []Lot{expired, recalled}, 5},
+		{"only unsellable lots", 9, []Lot{expired, recalled}, 0},
+		{"lots exceed on hand", 2, []Lot{good}, 2},
+		{"nothing on hand", 0, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SellableQuantity(tt.onHand, tt.lots); got != tt.want {
+				t.Errorf("SellableQuantity(%d) = %d, want %d", tt.onHand, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// Типы движений остатка
+const (
+	MovementOpening      = "opening"
+	MovementReceipt      = "receipt"
+	MovementSale         = "sale"
+	MovementReturn       = "return"
+	MovementTransferOut  = "transfer_out"
+	MovementTransferIn   = "transfer_in"
+	MovementWriteOff     = "write_off"
+	MovementAdjustment   = "adjustment"
+	MovementRecallReturn = "recall_return"
+)
+
+// Типы документов-оснований движения
+const (
+	MovementRefOrder        = "order"
+	MovementRefGoodsReceipt = "goods_receipt"
+	MovementRefTransfer     = "transfer"
+	MovementRefStockCount   = "stock_count"
+	MovementRefRecall       = "recall"
+)
+
+// MovementTypes перечисляет допустимые типы движений
+var MovementTypes = []string{
+	MovementOpening, MovementReceipt, MovementSale, MovementReturn,
+	MovementTransferOut, MovementTransferIn, MovementWriteOff, MovementAdjustment, MovementRecallReturn,
+}
+
+// MovementSorts поля сортировки журнала движений
+var MovementSorts = []string{"id", "created_at"}
+
+// StockMovement represents an immutable change of a medicine quantity in a pharmacy.
+// Quantity — изменение остатка со знаком; LotID равен nil для остатка без партии.
+// Сумма движений аптеки по лекарству равна его остатку
+type StockMovement struct {
+	ID            int       `json:"id"`
+	PharmacyID    int       `json:"pharmacy_id"`
+	MedicineID    int       `json:"medicine_id"`
+	MedicineName  string    `json:"medicine_name,omitempty"`
+	LotID         *int      `json:"lot_id,omitempty"`
+	LotNumber     string    `json:"lot_number,omitempty"`
+	Type          string    `json:"type"`
+	Quantity      int       `json:"quantity"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   *int      `json:"reference_id,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	UserID        *int      `json:"user_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StockBalance остаток лекарства в аптеке, рассчитанный по журналу движений
+type StockBalance struct {
+	MedicineID   int    `json:"medicine_id"`
+	MedicineName string `json:"medicine_name,omitempty"`
+	Quantity     int    `json:"quantity"`
+}
+
+// MovementFilter задаёт фильтры журнала движений аптеки; From включается в период, To — нет
+type MovementFilter struct {
+	PharmacyID int
+	MedicineID int
+	Type       string
+	From       *time.Time
+	To         *time.Time
+}
+
+// Split раскладывает движение по партиям allocations: строка на каждую партию
+// и строка без партии на остаток, не покрытый партиями. Знак берётся из Quantity
+func (m StockMovement) Split(allocations []LotAllocation) []StockMovement {
+	sign, remaining := 1, m.Quantity
+	if m.Quantity < 0 {
+		sign, remaining = -1, -m.Quantity
+	}
+	var movements []StockMovement
+	for _, allocation := range allocations {
+		if allocation.LotID == 0 || allocation.Quantity == 0 {
+			continue
+		}
+		lotID := allocation.LotID
+		movement := m
+		movement.LotID, movement.LotNumber = &lotID, allocation.LotNumber
+		movement.Quantity = sign * allocation.Quantity
+		movements = append(movements, movement)
+		remaining -= allocation.Quantity
+	}
+	if remaining > 0 {
+		movement := m
+		movement.LotID, movement.LotNumber = nil, ""
+		movement.Quantity = sign * remaining
+		movements = append(movements, movement)
+	}
+	return movements
+}
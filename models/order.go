@@ -0,0 +1,68 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Статусы заказа
+const (
+	OrderStatusDraft     = "draft"
+	OrderStatusPaid      = "paid"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// orderTransitions описывает допустимые переходы между статусами заказа
+var orderTransitions = map[string][]string{
+	OrderStatusDraft: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:  {OrderStatusRefunded},
+}
+
+// Order represents a sale made in a pharmacy.
+type Order struct {
+	ID         int         `json:"id"`
+	PharmacyID int         `json:"pharmacy_id"`
+	SellerID   *int        `json:"seller_id,omitempty"`
+	Status     string      `json:"status"`
+	Total      float64     `json:"total"`
+	Items      []OrderItem `json:"items"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	PaidAt     *time.Time  `json:"paid_at,omitempty"`
+	// Warnings — взаимодействия между лекарствами заказа; заполняются при создании и не хранятся
+	Warnings []InteractionWarning `json:"warnings,omitempty"`
+}
+
+// OrderItem represents a line of an order with the price captured at sale time.
+type OrderItem struct {
+	ID             int             `json:"id"`
+	MedicineID     int             `json:"medicine_id"`
+	MedicineName   string          `json:"medicine_name,omitempty"`
+	Quantity       int             `json:"quantity"`
+	UnitPrice      float64         `json:"unit_price"`
+	LineTotal      float64         `json:"line_total"`
+	PrescriptionID *int            `json:"prescription_id,omitempty"`
+	Allocations    []LotAllocation `json:"allocations,omitempty"`
+}
+
+// OrderFilter задаёт необязательные фильтры списка заказов
+type OrderFilter struct {
+	PharmacyID int
+	Status     string
+}
+
+// CanTransitionOrder сообщает, допустим ли переход заказа из статуса from в статус to
+func CanTransitionOrder(from, to string) bool {
+	for _, status := range orderTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundMoney округляет денежную сумму до копеек
+func RoundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
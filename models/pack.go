@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Статусы серийной упаковки
+const (
+	PackStatusReceived  = "received"
+	PackStatusInStock   = "in_stock"
+	PackStatusDispensed = "dispensed"
+	PackStatusReturned  = "returned"
+	PackStatusDestroyed = "destroyed"
+)
+
+// PackStatuses перечисляет статусы упаковки
+var PackStatuses = []string{PackStatusReceived, PackStatusInStock, PackStatusDispensed, PackStatusReturned, PackStatusDestroyed}
+
+// PackSorts поля сортировки реестра упаковок
+var PackSorts = []string{"id", "updated_at"}
+
+// packTransitions описывает допустимые переходы между статусами упаковки.
+// Возвращённая упаковка (покупателем или из оборота) снова выставляется в продажу или уничтожается
+var packTransitions = map[string][]string{
+	PackStatusReceived:  {PackStatusInStock, PackStatusReturned, PackStatusDestroyed},
+	PackStatusInStock:   {PackStatusDispensed, PackStatusReturned, PackStatusDestroyed},
+	PackStatusDispensed: {PackStatusReturned},
+	PackStatusReturned:  {PackStatusInStock, PackStatusDestroyed},
+}
+
+// Pack represents a single serialized medicine pack identified by GTIN and serial number.
+// OrderID заполнен, пока упаковка продана по заказу
+type Pack struct {
+	ID           int         `json:"id"`
+	MedicineID   int         `json:"medicine_id"`
+	MedicineName string      `json:"medicine_name,omitempty"`
+	PharmacyID   int         `json:"pharmacy_id"`
+	GTIN         string      `json:"gtin"`
+	Serial       string      `json:"serial"`
+	LotNumber    string      `json:"lot_number,omitempty"`
+	ExpiryDate   string      `json:"expiry_date,omitempty"`
+	Status       string      `json:"status"`
+	OrderID      *int        `json:"order_id,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	Events       []PackEvent `json:"events,omitempty"`
+}
+
+// PackEvent смена статуса упаковки; у регистрации упаковки FromStatus пуст
+type PackEvent struct {
+	ID         int       `json:"id"`
+	PackID     int       `json:"pack_id"`
+	PharmacyID int       `json:"pharmacy_id"`
+	FromStatus string    `json:"from_status,omitempty"`
+	ToStatus   string    `json:"to_status"`
+	OrderID    *int      `json:"order_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	UserID     *int      `json:"user_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PackStatusChange запрос смены статуса упаковки; OrderID указывается только при продаже
+type PackStatusChange struct {
+	Status  string `json:"status"`
+	OrderID *int   `json:"order_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	UserID  *int   `json:"-"`
+}
+
+// PackFilter задаёт необязательные фильтры реестра упаковок
+type PackFilter struct {
+	PharmacyID int
+	MedicineID int
+	Status     string
+	GTIN       string
+	Serial     string
+}
+
+// DispensingFilter задаёт аптеку (0 — все аптеки) и период выгрузки выбытия; From включается в период, To — нет
+type DispensingFilter struct {
+	PharmacyID int
+	From       *time.Time
+	To         *time.Time
+}
+
+// DispensingReport выгрузка выбытия упаковок через продажу в формате XML; формат описан схемой
+// schemas/dispensing_report.xsd
+type DispensingReport struct {
+	XMLName    xml.Name          `xml:"urn:pharmacy-test:dispensing:1 dispensing_report"`
+	Version    string            `xml:"version,attr"`
+	CreatedAt  string            `xml:"created_at,attr"`
+	PeriodFrom string            `xml:"period_from,attr,omitempty"`
+	PeriodTo   string            `xml:"period_to,attr,omitempty"`
+	PharmacyID int               `xml:"pharmacy_id,attr,omitempty"`
+	Events     []DispensingEvent `xml:"event"`
+}
+
+// DispensingEvent продажа одной серийной упаковки
+type DispensingEvent struct {
+	ID          int    `xml:"id,attr"`
+	DispensedAt string `xml:"dispensed_at"`
+	PharmacyID  int    `xml:"pharmacy_id"`
+	GTIN        string `xml:"gtin"`
+	Serial      string `xml:"serial"`
+	LotNumber   string `xml:"lot_number,omitempty"`
+	ExpiryDate  string `xml:"expiry_date,omitempty"`
+	OrderID     *int   `xml:"order_id,omitempty"`
+}
+
+// CanTransitionPack сообщает, допустим ли переход упаковки из статуса from в статус to
+func CanTransitionPack(from, to string) bool {
+	for _, status := range packTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDispensingReport собирает выгрузку выбытия; моменты записываются в UTC в формате RFC 3339
+func NewDispensingReport(filter DispensingFilter, events []DispensingEvent, now time.Time) DispensingReport {
+	report := DispensingReport{
+		Version:    "1.0",
+		CreatedAt:  now.UTC().Format(time.RFC3339),
+		PharmacyID: filter.PharmacyID,
+		Events:     events,
+	}
+	if filter.From != nil {
+		report.PeriodFrom = filter.From.UTC().Format(time.RFC3339)
+	}
+	if filter.To != nil {
+		report.PeriodTo = filter.To.UTC().Format(time.RFC3339)
+	}
+	return report
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/xml"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCanTransitionPack(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{PackStatusReceived, PackStatusInStock}:   true,
+		{PackStatusReceived, PackStatusReturned}:  true,
+		{PackStatusReceived, PackStatusDestroyed}: true,
+		{PackStatusInStock, PackStatusDispensed}:  true,
+		{PackStatusInStock, PackStatusReturned}:   true,
+		{PackStatusInStock, PackStatusDestroyed}:  true,
+		{PackStatusDispensed, PackStatusReturned}: true,
+		{PackStatusReturned, PackStatusInStock}:   true,
+		{PackStatusReturned, PackStatusDestroyed}: true,
+	}
+
+	// Проверяются все пары статусов, включая переход в тот же статус и неизвестный статус
+	statuses := append([]string{"lost"}, PackStatuses...)
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransitionPack(from, to); got != want {
+				t.Errorf("CanTransitionPack(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+// Эталоны в testdata проверены схемой: xmllint --noout --schema schemas/dispensing_report.xsd models/testdata/*.xml
+func TestDispensingReportXML(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, moscow)
+	to := time.Date(2025, time.April, 1, 0, 0, 0, 0, moscow)
+	orderID := 17
+
+	tests := []struct {
+		name   string
+		filter DispensingFilter
+		events []DispensingEvent
+		golden string
+	}{
+		{
+			name:   "period and events",
+			filter: DispensingFilter{PharmacyID: 3, From: &from, To: &to},
+			events: []DispensingEvent{
+				{
+					ID: 1, DispensedAt: "2025-03-05T09:30:00Z", PharmacyID: 3, GTIN: "04607034170122", Serial: "5A<&>\"'x",
+					LotNumber: "A-1", ExpiryDate: "2026-01-31", OrderID: &orderID,
+				},
+				{ID: 2, DispensedAt: "2025-03-06T14:00:00Z", PharmacyID: 3, GTIN: "04607034170122", Serial: "7QWERTY1234567"},
+			},
+			golden: "testdata/dispensing_report.xml",
+		},
+		{
+			name:   "no filter and no events",
+			golden: "testdata/dispensing_report_empty.xml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Момент создания задан не в UTC: в выгрузку он попадает в UTC
+			now := time.Date(2025, time.April, 1, 10, 15, 0, 0, moscow)
+			body, err := xml.MarshalIndent(NewDispensingReport(tt.filter, tt.events, now), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := xml.Header + string(body) + "\n"
+			want, err := os.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("report differs from %s:\n%s\nwant:\n%s", tt.golden, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Ограничения размера страницы
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Поля сортировки, допустимые для списков
+var (
+	PharmacySorts = []string{"id", "name", "city"}
+	MedicineSorts = []string{"id", "name", "manufacturer", "price"}
+	UserSorts     = []string{"id", "username", "created_at"}
+)
+
+// PageRequest параметры постраничной выборки
+type PageRequest struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	// After — курсор предыдущей страницы; nil для первой страницы
+	After *Cursor
+}
+
+// Cursor позиция последней записи страницы: значение поля сортировки и id.
+// Сортировка входит в курсор, чтобы его нельзя было применить к другому порядку.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// Page страница списка
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int     `json:"total"`
+}
+
+// PharmacyFilter фильтр списка аптек по адресу
+type PharmacyFilter struct {
+	City    string
+	Country string
+	// OpenAt оставляет только аптеки, открытые в этот момент по своему расписанию
+	OpenAt *time.Time
+}
+
+// MedicineFilter фильтр списка лекарств
+type MedicineFilter struct {
+	Manufacturer string
+	MinPrice     *float64
+	MaxPrice     *float64
+	// PharmacyID оставляет только лекарства, привязанные к аптеке
+	PharmacyID int
+	// RxRequired оставляет только рецептурные (true) или безрецептурные (false) лекарства
+	RxRequired *bool
+	// ATCCode оставляет лекарства, код ATC которых начинается с этого значения: N02 — анальгетики
+	ATCCode string
+	// Ingredient оставляет лекарства с этим действующим веществом
+	Ingredient string
+}
+
+// UserFilter фильтр списка пользователей
+type UserFilter struct {
+	Position string
+}
+
+// ErrInvalidCursor возвращается для курсора, который не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor кодирует курсор в непрозрачную строку для клиента
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает строку, полученную из EncodeCursor
+func DecodeCursor(value string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// NextCursor возвращает курсор после последней записи страницы
+func (p PageRequest) NextCursor(value string, id int) *string {
+	cursor := EncodeCursor(Cursor{Sort: p.Sort, Desc: p.Desc, Value: value, ID: id})
+	return &cursor
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"ascending by id", Cursor{Sort: "id", Value: "42", ID: 42}},
+		{"descending by price", Cursor{Sort: "price", Desc: true, Value: "45.5", ID: 7}},
+		{"non-latin value", Cursor{Sort: "name", Value: "Аптека «Ромашка» & Co", ID: 3}},
+		{"empty value", Cursor{Sort: "city", ID: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := EncodeCursor(tt.cursor)
+			if _, err := base64.RawURLEncoding.DecodeString(encoded); err != nil {
+				t.Fatalf("cursor %q is not URL-safe base64: %v", encoded, err)
+			}
+			got, err := DecodeCursor(encoded)
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q): %v", encoded, err)
+			}
+			if got != tt.cursor {
+				t.Errorf("round trip = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"id","v":"1","id":1}`))},
+		{"not json", encode("id:1")},
+		{"wrong types", encode(`{"s":"id","v":"1","id":"1"}`)},
+		{"no sort", encode(`{"v":"1","id":1}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.value); err != ErrInvalidCursor {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.value, err)
+			}
+		})
+	}
+}
+
+func TestPageRequestNextCursor(t *testing.T) {
+	page := PageRequest{Limit: 10, Sort: "name", Desc: true}
+	next := page.NextCursor("Ромашка", 5)
+	if next == nil {
+		t.Fatal("NextCursor = nil")
+	}
+	got, err := DecodeCursor(*next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Cursor{Sort: "name", Desc: true, Value: "Ромашка", ID: 5}); got != want {
+		t.Errorf("next cursor = %+v, want %+v", got, want)
+	}
+}
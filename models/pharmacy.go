@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Address represents an address.
+type Address struct {
+	ID         int    `json:"id"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country"`
+	// Координаты задаются вместе или не задаются вовсе; без них аптека не участвует в поиске по расстоянию
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+}
+
+// Pharmacy represents a pharmacy with an address and opening hours.
+type Pharmacy struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Address Address `json:"address"`
+
+	// Timezone — часовой пояс IANA, в котором задано расписание, например "Europe/Moscow"
+	Timezone   string              `json:"timezone,omitempty"`
+	Hours      []OpeningHours      `json:"hours,omitempty"`
+	Exceptions []ScheduleException `json:"exceptions,omitempty"`
+
+	// OpenNow и NextOpening вычисляются по расписанию при выдаче аптеки; без расписания они равны null
+	OpenNow     *bool      `json:"open_now"`
+	NextOpening *time.Time `json:"next_opening"`
+}
+
+// NearbyPharmacy аптека, в которой есть лекарство, и расстояние до неё.
+// Quantity — пригодный к продаже остаток: без просроченных и отозванных партий
+type NearbyPharmacy struct {
+	Pharmacy Pharmacy `json:"pharmacy"`
+	Quantity int      `json:"quantity"`
+	// Price — цена лекарства в этой аптеке с учётом переопределения
+	Price      float64 `json:"price"`
+	DistanceKm float64 `json:"distance_km"`
+}
@@ -0,0 +1,127 @@
+package models
+
+import "time"
+
+// Статусы рецепта. Хранится только отмена, остальные статусы вычисляются по датам и остаткам
+const (
+	PrescriptionActive    = "active"
+	PrescriptionExpired   = "expired"
+	PrescriptionExhausted = "exhausted"
+	PrescriptionCancelled = "cancelled"
+)
+
+// Prescription represents a prescription issued to a patient.
+type Prescription struct {
+	ID                int                `json:"id"`
+	Number            string             `json:"number"`
+	PatientName       string             `json:"patient_name"`
+	PatientBirthDate  string             `json:"patient_birth_date,omitempty"`
+	PrescriberName    string             `json:"prescriber_name"`
+	PrescriberLicense string             `json:"prescriber_license,omitempty"`
+	IssuedOn          string             `json:"issued_on"`
+	ValidUntil        string             `json:"valid_until"`
+	Status            string             `json:"status"`
+	Items             []PrescriptionItem `json:"items"`
+	CreatedBy         *int               `json:"created_by,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	CancelledAt       *time.Time         `json:"cancelled_at,omitempty"`
+}
+
+// PrescriptionItem назначенное лекарство: Quantity единиц на один отпуск и Refills повторных отпусков.
+// RemainingQuantity — сколько единиц ещё можно отпустить в текущий раз, RemainingRefills — сколько повторов осталось
+type PrescriptionItem struct {
+	ID                int                      `json:"id"`
+	MedicineID        int                      `json:"medicine_id"`
+	MedicineName      string                   `json:"medicine_name,omitempty"`
+	Quantity          int                      `json:"quantity"`
+	Refills           int                      `json:"refills"`
+	RemainingQuantity int                      `json:"remaining_quantity"`
+	RemainingRefills  int                      `json:"remaining_refills"`
+	Dispensings       []PrescriptionDispensing `json:"dispensings,omitempty"`
+}
+
+// PrescriptionDispensing отпуск по рецепту в оплаченном заказе. Fill — номер отпуска позиции рецепта,
+// из которого списаны единицы: 0 — первый, 1 — первый повтор и т. д.
+// RefundedAt заполняется, когда заказ возвращён и единицы вернулись на рецепт
+type PrescriptionDispensing struct {
+	OrderID     int        `json:"order_id"`
+	OrderItemID int        `json:"order_item_id"`
+	Quantity    int        `json:"quantity"`
+	Fill        int        `json:"fill"`
+	DispensedAt time.Time  `json:"dispensed_at"`
+	RefundedAt  *time.Time `json:"refunded_at,omitempty"`
+}
+
+// PrescriptionFilter задаёт необязательные фильтры списка рецептов
+type PrescriptionFilter struct {
+	Number string
+	// Patient — часть имени пациента без учёта регистра
+	Patient string
+}
+
+// StatusAt вычисляет статус рецепта на момент at
+func (p Prescription) StatusAt(at time.Time) string {
+	if p.CancelledAt != nil {
+		return PrescriptionCancelled
+	}
+	if at.Format(DateLayout) > p.ValidUntil {
+		return PrescriptionExpired
+	}
+	for _, item := range p.Items {
+		if item.RemainingQuantity > 0 {
+			return PrescriptionActive
+		}
+	}
+	return PrescriptionExhausted
+}
+
+// FillStatus заполняет вычисляемое поле status на момент now
+func (p *Prescription) FillStatus(now time.Time) {
+	p.Status = p.StatusAt(now)
+}
+
+// Item возвращает позицию рецепта с лекарством medicineID или nil
+func (p *Prescription) Item(medicineID int) *PrescriptionItem {
+	for i := range p.Items {
+		if p.Items[i].MedicineID == medicineID {
+			return &p.Items[i]
+		}
+	}
+	return nil
+}
+
+// CurrentFill возвращает номер текущего отпуска: 0 — первый, далее номер повтора
+func (item PrescriptionItem) CurrentFill() int {
+	return item.Refills - item.RemainingRefills
+}
+
+// Dispense списывает quantity единиц с текущего отпуска. Когда текущий отпуск исчерпан,
+// открывается следующий повтор. За один раз нельзя отпустить больше, чем осталось в текущем отпуске;
+// в этом случае возвращается false и позиция не меняется
+func (item *PrescriptionItem) Dispense(quantity int) bool {
+	if quantity <= 0 || quantity > item.RemainingQuantity {
+		return false
+	}
+	item.RemainingQuantity -= quantity
+	if item.RemainingQuantity == 0 && item.RemainingRefills > 0 {
+		item.RemainingRefills--
+		item.RemainingQuantity = item.Quantity
+	}
+	return true
+}
+
+// Restore возвращает на позицию quantity ранее отпущенных единиц, например при возврате заказа.
+// Остаток позиции — это единицы текущего отпуска и всех оставшихся повторов, поэтому возвращённые
+// единицы сначала дополняют текущий отпуск, а излишек снова открывает израсходованные повторы.
+// Результат не зависит от того, в каком порядке возвращаются отпуски
+func (item *PrescriptionItem) Restore(quantity int) {
+	total := item.RemainingQuantity + item.RemainingRefills*item.Quantity + quantity
+	if limit := (item.Refills + 1) * item.Quantity; total > limit {
+		total = limit
+	}
+	item.RemainingQuantity, item.RemainingRefills = total, 0
+	if total > 0 && item.Quantity > 0 {
+		item.RemainingRefills = (total - 1) / item.Quantity
+		item.RemainingQuantity = total - item.RemainingRefills*item.Quantity
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrescriptionItemDispense(t *testing.T) {
+	tests := []struct {
+		name          string
+		remaining     int
+		refills       int
+		quantity      int
+		ok            bool
+		wantRemaining int
+		wantRefills   int
+	}{
+		{name: "part of fill", remaining: 10, refills: 2, quantity: 4, ok: true, wantRemaining: 6, wantRefills: 2},
+		{name: "whole fill opens refill", remaining: 10, refills: 2, quantity: 10, ok: true, wantRemaining: 10, wantRefills: 1},
+		{name: "rest of fill opens refill", remaining: 3, refills: 1, quantity: 3, ok: true, wantRemaining: 10, wantRefills: 0},
+		{name: "last fill exhausted", remaining: 3, refills: 0, quantity: 3, ok: true, wantRemaining: 0, wantRefills: 0},
+		{name: "more than current fill", remaining: 3, refills: 2, quantity: 4, wantRemaining: 3, wantRefills: 2},
+		{name: "exhausted", remaining: 0, refills: 0, quantity: 1},
+		{name: "zero quantity", remaining: 10, refills: 1, quantity: 0, wantRemaining: 10, wantRefills: 1},
+		{name: "negative quantity", remaining: 10, refills: 1, quantity: -2, wantRemaining: 10, wantRefills: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := PrescriptionItem{Quantity: 10, Refills: 2, RemainingQuantity: tt.remaining, RemainingRefills: tt.refills}
+			if ok := item.Dispense(tt.quantity); ok != tt.ok {
+				t.Errorf("Dispense(%d) = %v, want %v", tt.quantity, ok, tt.ok)
+			}
+			if item.RemainingQuantity != tt.wantRemaining || item.RemainingRefills != tt.wantRefills {
+				t.Errorf("remaining = %d/%d, want %d/%d", item.RemainingQuantity, item.RemainingRefills, tt.wantRemaining, tt.wantRefills)
+			}
+		})
+	}
+}
+
+func TestPrescriptionItemRestore(t *testing.T) {
+	tests := []struct {
+		name          string
+		remaining     int
+		refills       int
+		quantity      int
+		wantRemaining int
+		wantRefills   int
+	}{
+		{name: "fills current fill", remaining: 6, refills: 1, quantity: 4, wantRemaining: 10, wantRefills: 1},
+		{name: "reopens refill", remaining: 6, refills: 0, quantity: 10, wantRemaining: 6, wantRefills: 1},
+		{name: "exhausted item", remaining: 0, refills: 0, quantity: 4, wantRemaining: 4, wantRefills: 0},
+		{name: "whole prescription", remaining: 0, refills: 0, quantity: 20, wantRemaining: 10, wantRefills: 1},
+		{name: "capped at prescribed", remaining: 10, refills: 1, quantity: 5, wantRemaining: 10, wantRefills: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := PrescriptionItem{Quantity: 10, Refills: 1, RemainingQuantity: tt.remaining, RemainingRefills: tt.refills}
+			item.Restore(tt.quantity)
+			if item.RemainingQuantity != tt.wantRemaining || item.RemainingRefills != tt.wantRefills {
+				t.Errorf("remaining = %d/%d, want %d/%d", item.RemainingQuantity, item.RemainingRefills, tt.wantRemaining, tt.wantRefills)
+			}
+		})
+	}
+
+	// Возврат отпусков в любом порядке приводит позицию к исходному состоянию
+	for _, order := range [][]int{{10, 4}, {4, 10}} {
+		item := PrescriptionItem{Quantity: 10, Refills: 1, RemainingQuantity: 10, RemainingRefills: 1}
+		item.Dispense(10)
+		item.Dispense(4)
+		for _, quantity := range order {
+			item.Restore(quantity)
+		}
+		if item.RemainingQuantity != 10 || item.RemainingRefills != 1 {
+			t.Errorf("restore order %v: remaining = %d/%d, want 10/1", order, item.RemainingQuantity, item.RemainingRefills)
+		}
+	}
+}
+
+func TestPrescriptionStatusAt(t *testing.T) {
+	at := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	cancelledAt := at.Add(-time.Hour)
+	active := []PrescriptionItem{{Quantity: 10, RemainingQuantity: 0}, {Quantity: 5, RemainingQuantity: 2}}
+	exhausted := []PrescriptionItem{{Quantity: 10, RemainingQuantity: 0}}
+
+	tests := []struct {
+		name         string
+		prescription Prescription
+		want         string
+	}{
+		{"active", Prescription{ValidUntil: "2025-04-01", Items: active}, PrescriptionActive},
+		{"valid through last day", Prescription{ValidUntil: "2025-03-01", Items: active}, PrescriptionActive},
+		{"expired", Prescription{ValidUntil: "2025-02-28", Items: active}, PrescriptionExpired},
+		{"exhausted", Prescription{ValidUntil: "2025-04-01", Items: exhausted}, PrescriptionExhausted},
+		{"expired before exhausted", Prescription{ValidUntil: "2025-02-28", Items: exhausted}, PrescriptionExpired},
+		{"cancelled", Prescription{ValidUntil: "2025-04-01", Items: active, CancelledAt: &cancelledAt}, PrescriptionCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.prescription.StatusAt(at); got != tt.want {
+				t.Errorf("StatusAt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PriceChange запись истории цены лекарства: цены каталога или переопределения в аптеке
+type PriceChange struct {
+	ID         int `json:"id"`
+	MedicineID int `json:"medicine_id"`
+	// PharmacyID задан для переопределения цены в аптеке; nil — цена каталога
+	PharmacyID *int     `json:"pharmacy_id,omitempty"`
+	OldPrice   *float64 `json:"old_price"`
+	// NewPrice равен nil, если переопределение цены в аптеке снято
+	NewPrice  *float64  `json:"new_price"`
+	ChangedBy *int      `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+	// ScheduledPriceID указывает на запланированное изменение, которое применил фоновый обработчик
+	ScheduledPriceID *int `json:"scheduled_price_id,omitempty"`
+}
+
+// Статусы запланированного изменения цены
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceApplied   = "applied"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+// ScheduledPrice изменение цены, которое вступит в силу в момент EffectiveAt
+type ScheduledPrice struct {
+	ID          int        `json:"id"`
+	MedicineID  int        `json:"medicine_id"`
+	PharmacyID  *int       `json:"pharmacy_id,omitempty"`
+	Price       float64    `json:"price"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Status      string     `json:"status"`
+	CreatedBy   *int       `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// PriceOverride цена лекарства в конкретной аптеке, заменяющая цену каталога
+type PriceOverride struct {
+	PharmacyID   int     `json:"pharmacy_id"`
+	PharmacyName string  `json:"pharmacy_name,omitempty"`
+	Price        float64 `json:"price"`
+}
+
+// MedicinePrices текущие цены лекарства, запланированные изменения и история
+type MedicinePrices struct {
+	MedicineID   int              `json:"medicine_id"`
+	CatalogPrice float64          `json:"catalog_price"`
+	Overrides    []PriceOverride  `json:"overrides"`
+	Scheduled    []ScheduledPrice `json:"scheduled"`
+	History      []PriceChange    `json:"history"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// Статусы заказа поставщику. partially_received и received выставляются приёмкой товара
+const (
+	PurchaseStatusDraft             = "draft"
+	PurchaseStatusSent              = "sent"
+	PurchaseStatusPartiallyReceived = "partially_received"
+	PurchaseStatusReceived          = "received"
+	PurchaseStatusCancelled         = "cancelled"
+)
+
+// purchaseTransitions описывает допустимые переходы между статусами заказа поставщику
+var purchaseTransitions = map[string][]string{
+	PurchaseStatusDraft:             {PurchaseStatusSent, PurchaseStatusCancelled},
+	PurchaseStatusSent:              {PurchaseStatusPartiallyReceived, PurchaseStatusReceived, PurchaseStatusCancelled},
+	PurchaseStatusPartiallyReceived: {PurchaseStatusPartiallyReceived, PurchaseStatusReceived, PurchaseStatusCancelled},
+}
+
+// Supplier represents a supplier that pharmacies buy medicines from.
+type Supplier struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	TaxID       string    `json:"tax_id,omitempty"`
+	ContactName string    `json:"contact_name,omitempty"`
+	Phone       string    `json:"phone,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Address     string    `json:"address,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PurchaseOrder represents an order placed with a supplier for delivery to a pharmacy.
+type PurchaseOrder struct {
+	ID           int                 `json:"id"`
+	PharmacyID   int                 `json:"pharmacy_id"`
+	SupplierID   int                 `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name,omitempty"`
+	Status       string              `json:"status"`
+	Notes        string              `json:"notes,omitempty"`
+	Total        float64             `json:"total"`
+	Items        []PurchaseOrderItem `json:"items"`
+	Receipts     []GoodsReceipt      `json:"receipts,omitempty"`
+	CreatedBy    *int                `json:"created_by,omitempty"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	SentAt       *time.Time          `json:"sent_at,omitempty"`
+}
+
+// PurchaseOrderItem заказанное лекарство по закупочной цене UnitCost;
+// ReceivedQuantity — сколько единиц уже принято по всем приёмкам
+type PurchaseOrderItem struct {
+	ID               int     `json:"id"`
+	MedicineID       int     `json:"medicine_id"`
+	MedicineName     string  `json:"medicine_name,omitempty"`
+	Quantity         int     `json:"quantity"`
+	ReceivedQuantity int     `json:"received_quantity"`
+	UnitCost         float64 `json:"unit_cost"`
+	LineTotal        float64 `json:"line_total"`
+}
+
+// GoodsReceipt приёмка поставки по заказу поставщику
+type GoodsReceipt struct {
+	ID              int                `json:"id"`
+	PurchaseOrderID int                `json:"purchase_order_id"`
+	ReceivedBy      *int               `json:"received_by,omitempty"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	Items           []GoodsReceiptItem `json:"items"`
+}
+
+// GoodsReceiptItem принятая партия лекарства; одно лекарство может прийти несколькими партиями
+type GoodsReceiptItem struct {
+	MedicineID     int    `json:"medicine_id"`
+	LotID          int    `json:"lot_id"`
+	LotNumber      string `json:"lot_number"`
+	ProductionDate string `json:"production_date,omitempty"`
+	ExpiryDate     string `json:"expiry_date"`
+	Quantity       int    `json:"quantity"`
+}
+
+// PurchaseOrderFilter задаёт необязательные фильтры списка заказов поставщикам
+type PurchaseOrderFilter struct {
+	PharmacyID int
+	SupplierID int
+	Status     string
+}
+
+// CanTransitionPurchase сообщает, допустим ли переход заказа поставщику из статуса from в статус to
+func CanTransitionPurchase(from, to string) bool {
+	for _, status := range purchaseTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Item возвращает позицию заказа с лекарством medicineID или nil
+func (o *PurchaseOrder) Item(medicineID int) *PurchaseOrderItem {
+	for i := range o.Items {
+		if o.Items[i].MedicineID == medicineID {
+			return &o.Items[i]
+		}
+	}
+	return nil
+}
+
+// ReceivedStatus возвращает статус по принятым количествам: received, когда все позиции приняты полностью
+func (o PurchaseOrder) ReceivedStatus() string {
+	for _, item := range o.Items {
+		if item.ReceivedQuantity < item.Quantity {
+			return PurchaseStatusPartiallyReceived
+		}
+	}
+	return PurchaseStatusReceived
+}
+
+// Remaining возвращает, сколько единиц позиции ещё не принято
+func (item PurchaseOrderItem) Remaining() int {
+	if item.ReceivedQuantity >= item.Quantity {
+		return 0
+	}
+	return item.Quantity - item.ReceivedQuantity
+}
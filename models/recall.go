@@ -0,0 +1,142 @@
+package models
+
+import "time"
+
+// Статусы отзыва. Партии блокируются, пока отзыв не отменён
+const (
+	RecallStatusActive    = "active"
+	RecallStatusCompleted = "completed"
+	RecallStatusCancelled = "cancelled"
+)
+
+// Действия с отозванными единицами
+const (
+	RecallActionQuarantine = "quarantine"
+	RecallActionReturn     = "return"
+)
+
+// recallTransitions описывает допустимые переходы между статусами отзыва
+var recallTransitions = map[string][]string{
+	RecallStatusActive: {RecallStatusCompleted, RecallStatusCancelled},
+}
+
+// Recall represents a manufacturer recall of medicine lots.
+type Recall struct {
+	ID           int            `json:"id"`
+	Manufacturer string         `json:"manufacturer"`
+	Reason       string         `json:"reason"`
+	RecallDate   string         `json:"recall_date"`
+	Status       string         `json:"status"`
+	Items        []RecallItem   `json:"items"`
+	Actions      []RecallAction `json:"actions,omitempty"`
+	CreatedBy    *int           `json:"created_by,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	ClosedAt     *time.Time     `json:"closed_at,omitempty"`
+}
+
+// RecallItem отозванная серия лекарства; серия отзывается во всех аптеках
+type RecallItem struct {
+	MedicineID   int    `json:"medicine_id"`
+	MedicineName string `json:"medicine_name,omitempty"`
+	LotNumber    string `json:"lot_number"`
+}
+
+// RecallAction помещение единиц партии в карантин или их возврат производителю.
+// Возвращать можно только единицы, помещённые в карантин
+type RecallAction struct {
+	ID         int       `json:"id"`
+	RecallID   int       `json:"recall_id"`
+	LotID      int       `json:"lot_id"`
+	PharmacyID int       `json:"pharmacy_id"`
+	MedicineID int       `json:"medicine_id"`
+	LotNumber  string    `json:"lot_number,omitempty"`
+	Action     string    `json:"action"`
+	Quantity   int       `json:"quantity"`
+	Notes      string    `json:"notes,omitempty"`
+	CreatedBy  *int      `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RecallReport отчёт по отзыву: где находятся отозванные партии и кому они уже проданы
+type RecallReport struct {
+	Recall    Recall            `json:"recall"`
+	Stock     []RecallStock     `json:"stock"`
+	Dispensed []RecallDispensed `json:"dispensed"`
+}
+
+// RecallStock отозванная партия в аптеке. Quantity — учётный остаток партии,
+// Quarantined — единицы в карантине, ещё не возвращённые, Returned — возвращённые производителю
+type RecallStock struct {
+	PharmacyID   int    `json:"pharmacy_id"`
+	PharmacyName string `json:"pharmacy_name,omitempty"`
+	MedicineID   int    `json:"medicine_id"`
+	MedicineName string `json:"medicine_name,omitempty"`
+	LotID        int    `json:"lot_id"`
+	LotNumber    string `json:"lot_number"`
+	ExpiryDate   string `json:"expiry_date"`
+	Quantity     int    `json:"quantity"`
+	Quarantined  int    `json:"quarantined"`
+	Returned     int    `json:"returned"`
+}
+
+// RecallDispensed продажа единиц отозванной партии по оплаченному заказу
+type RecallDispensed struct {
+	OrderID      int        `json:"order_id"`
+	PharmacyID   int        `json:"pharmacy_id"`
+	PharmacyName string     `json:"pharmacy_name,omitempty"`
+	MedicineID   int        `json:"medicine_id"`
+	MedicineName string     `json:"medicine_name,omitempty"`
+	LotID        int        `json:"lot_id"`
+	LotNumber    string     `json:"lot_number"`
+	Quantity     int        `json:"quantity"`
+	PaidAt       *time.Time `json:"paid_at,omitempty"`
+}
+
+// RecallFilter задаёт необязательные фильтры списка отзывов
+type RecallFilter struct {
+	Status     string
+	MedicineID int
+}
+
+// CanTransitionRecall сообщает, допустим ли переход отзыва из статуса from в статус to
+func CanTransitionRecall(from, to string) bool {
+	for _, status := range recallTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers сообщает, входит ли серия lotNumber лекарства medicineID в отзыв
+func (r Recall) Covers(medicineID int, lotNumber string) bool {
+	for _, item := range r.Items {
+		if item.MedicineID == medicineID && item.LotNumber == lotNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocks сообщает, блокирует ли отзыв продажу партий: отменённый отзыв партии не блокирует
+func (r Recall) Blocks() bool {
+	return r.Status != RecallStatusCancelled
+}
+
+// LotActions возвращает по действиям отзыва единицы партии lotID, которые находятся в карантине
+// и ещё не возвращены, и единицы, уже возвращённые производителю
+func (r Recall) LotActions(lotID int) (quarantined, returned int) {
+	for _, action := range r.Actions {
+		if action.LotID != lotID {
+			continue
+		}
+		switch action.Action {
+		case RecallActionQuarantine:
+			quarantined += action.Quantity
+		case RecallActionReturn:
+			returned += action.Quantity
+		}
+	}
+	return quarantined - returned, returned
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Параметры расчёта пополнения
+const (
+	// ReplenishmentSalesWindowDays — за сколько последних дней считается средняя скорость продаж
+	ReplenishmentSalesWindowDays = 28
+	// ReplenishmentLeadTimeDays — сколько дней нужно продавать до прихода поставки
+	ReplenishmentLeadTimeDays = 7
+)
+
+// StockLevel пороги остатка лекарства в аптеке: при доступном количестве не выше MinQuantity
+// лекарство дозаказывается до MaxQuantity. SupplierID — поставщик, у которого заказывать по умолчанию
+type StockLevel struct {
+	PharmacyID   int       `json:"pharmacy_id"`
+	MedicineID   int       `json:"medicine_id"`
+	MedicineName string    `json:"medicine_name,omitempty"`
+	MinQuantity  int       `json:"min_quantity"`
+	MaxQuantity  int       `json:"max_quantity"`
+	SupplierID   *int      `json:"supplier_id,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ReplenishmentSuggestion предложение дозаказать лекарство в аптеку.
+// OnOrder — ещё не принятое количество в открытых заказах поставщикам, включая черновики
+type ReplenishmentSuggestion struct {
+	PharmacyID        int       `json:"pharmacy_id"`
+	MedicineID        int       `json:"medicine_id"`
+	MedicineName      string    `json:"medicine_name,omitempty"`
+	OnHand            int       `json:"on_hand"`
+	OnOrder           int       `json:"on_order"`
+	DailySales        float64   `json:"daily_sales"`
+	ReorderPoint      int       `json:"reorder_point"`
+	TargetQuantity    int       `json:"target_quantity"`
+	SuggestedQuantity int       `json:"suggested_quantity"`
+	SupplierID        *int      `json:"supplier_id,omitempty"`
+	CalculatedAt      time.Time `json:"calculated_at"`
+}
+
+// ReplenishmentOrderRequest параметры создания заказов поставщикам по предложениям пополнения
+type ReplenishmentOrderRequest struct {
+	// SupplierID заменяет поставщика по умолчанию для всех позиций
+	SupplierID *int `json:"supplier_id,omitempty"`
+	// MedicineIDs ограничивает заказ этими лекарствами; пустой список берёт все предложения
+	MedicineIDs []int `json:"medicine_ids,omitempty"`
+	CreatedBy   *int  `json:"-"`
+}
+
+// Suggest рассчитывает предложение по порогам, доступному количеству и продажам за окно ReplenishmentSalesWindowDays.
+// Точка заказа — MinQuantity, но не меньше продаж за время поставки; заказывается количество,
+// которого не хватает до MaxQuantity (не меньше точки заказа). Нулевое SuggestedQuantity — дозаказ не нужен
+func (l StockLevel) Suggest(onHand, onOrder, sold int) ReplenishmentSuggestion {
+	daily := float64(sold) / ReplenishmentSalesWindowDays
+	reorderPoint := l.MinQuantity
+	if leadDemand := int(math.Ceil(daily * ReplenishmentLeadTimeDays)); leadDemand > reorderPoint {
+		reorderPoint = leadDemand
+	}
+	target := l.MaxQuantity
+	if target < reorderPoint {
+		target = reorderPoint
+	}
+
+	suggestion := ReplenishmentSuggestion{
+		PharmacyID:     l.PharmacyID,
+		MedicineID:     l.MedicineID,
+		MedicineName:   l.MedicineName,
+		OnHand:         onHand,
+		OnOrder:        onOrder,
+		DailySales:     math.Round(daily*1000) / 1000,
+		ReorderPoint:   reorderPoint,
+		TargetQuantity: target,
+		SupplierID:     l.SupplierID,
+	}
+	if available := onHand + onOrder; available <= reorderPoint && available < target {
+		suggestion.SuggestedQuantity = target - available
+	}
+	return suggestion
+}
+
+// SelectReplenishment отбирает предложения для заказа по request. Ошибка описывает лекарство
+// без предложения или без поставщика, а также отсутствие предложений
+func SelectReplenishment(suggestions []ReplenishmentSuggestion, request ReplenishmentOrderRequest) ([]ReplenishmentSuggestion, error) {
+	selected := suggestions
+	if len(request.MedicineIDs) > 0 {
+		index := make(map[int]ReplenishmentSuggestion, len(suggestions))
+		for _, suggestion := range suggestions {
+			index[suggestion.MedicineID] = suggestion
+		}
+		selected = make([]ReplenishmentSuggestion, 0, len(request.MedicineIDs))
+		for _, id := range request.MedicineIDs {
+			suggestion, ok := index[id]
+			if !ok {
+				return nil, fmt.Errorf("no replenishment suggestion for medicine %d", id)
+			}
+			selected = append(selected, suggestion)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no replenishment suggestions")
+	}
+	if request.SupplierID == nil {
+		for _, suggestion := range selected {
+			if suggestion.SupplierID == nil {
+				return nil, fmt.Errorf("medicine %d has no default supplier, supplier_id is required", suggestion.MedicineID)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// PlanReplenishmentOrders группирует предложения в черновики заказов, по одному на поставщика.
+// unitCost возвращает закупочную цену лекарства
+func PlanReplenishmentOrders(pharmacyID int, suggestions []ReplenishmentSuggestion, request ReplenishmentOrderRequest, unitCost func(medicineID int) float64) []PurchaseOrder {
+	bySupplier := map[int]*PurchaseOrder{}
+	var supplierIDs []int
+	for _, suggestion := range suggestions {
+		supplierID := request.SupplierID
+		if supplierID == nil {
+			supplierID = suggestion.SupplierID
+		}
+		order, ok := bySupplier[*supplierID]
+		if !ok {
+			order = &PurchaseOrder{
+				PharmacyID: pharmacyID,
+				SupplierID: *supplierID,
+				Status:     PurchaseStatusDraft,
+				Notes:      "Создан по предложениям пополнения",
+				CreatedBy:  request.CreatedBy,
+			}
+			bySupplier[*supplierID] = order
+			supplierIDs = append(supplierIDs, *supplierID)
+		}
+		order.Items = append(order.Items, PurchaseOrderItem{
+			MedicineID: suggestion.MedicineID,
+			Quantity:   suggestion.SuggestedQuantity,
+			UnitCost:   unitCost(suggestion.MedicineID),
+		})
+	}
+
+	sort.Ints(supplierIDs)
+	orders := make([]PurchaseOrder, 0, len(supplierIDs))
+	for _, id := range supplierIDs {
+		orders = append(orders, *bySupplier[id])
+	}
+	return orders
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStockLevelSuggest(t *testing.T) {
+	supplierID := 7
+
+	tests := []struct {
+		name         string
+		min, max     int
+		onHand       int
+		onOrder      int
+		sold         int
+		daily        float64
+		reorderPoint int
+		target       int
+		suggested    int
+	}{
+		{name: "above min", min: 10, max: 30, onHand: 20, reorderPoint: 10, target: 30},
+		{name: "at min", min: 10, max: 30, onHand: 10, reorderPoint: 10, target: 30, suggested: 20},
+		{name: "on order lifts available above min", min: 10, max: 30, onHand: 5, onOrder: 6, reorderPoint: 10, target: 30},
+		{name: "on order reduces quantity", min: 10, max: 30, onHand: 3, onOrder: 4, reorderPoint: 10, target: 30, suggested: 23},
+		// 84 продажи за 28 дней — 3 в день, за 7 дней поставки нужно 21
+		{
+			name: "lead-time demand raises reorder point", min: 10, max: 30, onHand: 15, sold: 84,
+			daily: 3, reorderPoint: 21, target: 30, suggested: 15,
+		},
+		// 10 / 28 × 7 = 2,5 округляется вверх
+		{
+			name: "lead-time demand rounds up", min: 2, max: 5, onHand: 3, sold: 10,
+			daily: 0.357, reorderPoint: 3, target: 5, suggested: 2,
+		},
+		{
+			name: "target clamped to lead-time demand", min: 10, max: 30, onHand: 20, sold: 140,
+			daily: 5, reorderPoint: 35, target: 35, suggested: 15,
+		},
+		{name: "max below min", min: 10, max: 4, onHand: 2, reorderPoint: 10, target: 10, suggested: 8},
+		{name: "zero thresholds", onHand: 0, reorderPoint: 0, target: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := StockLevel{PharmacyID: 1, MedicineID: 2, MedicineName: "Ибупрофен", MinQuantity: tt.min, MaxQuantity: tt.max, SupplierID: &supplierID}
+			got := level.Suggest(tt.onHand, tt.onOrder, tt.sold)
+			if got.DailySales != tt.daily || got.ReorderPoint != tt.reorderPoint || got.TargetQuantity != tt.target || got.SuggestedQuantity != tt.suggested {
+				t.Errorf("daily, reorder point, target, suggested = %v, %d, %d, %d; want %v, %d, %d, %d",
+					got.DailySales, got.ReorderPoint, got.TargetQuantity, got.SuggestedQuantity,
+					tt.daily, tt.reorderPoint, tt.target, tt.suggested)
+			}
+			if got.PharmacyID != 1 || got.MedicineID != 2 || got.MedicineName != "Ибупрофен" || got.SupplierID != &supplierID ||
+				got.OnHand != tt.onHand || got.OnOrder != tt.onOrder {
+				t.Errorf("suggestion = %+v, want fields copied from level and arguments", got)
+			}
+		})
+	}
+}
+
+func TestSelectReplenishment(t *testing.T) {
+	supplierID, overrideID := 7, 9
+	first := ReplenishmentSuggestion{MedicineID: 1, SupplierID: &supplierID}
+	noSupplier := ReplenishmentSuggestion{MedicineID: 2}
+	third := ReplenishmentSuggestion{MedicineID: 3, SupplierID: &supplierID}
+
+	tests := []struct {
+		name        string
+		suggestions []ReplenishmentSuggestion
+		request     ReplenishmentOrderRequest
+		want        []int
+		ok          bool
+	}{
+		{name: "all suggestions", suggestions: []ReplenishmentSuggestion{first, third}, want: []int{1, 3}, ok: true},
+		{
+			name: "selected medicines in request order", suggestions: []ReplenishmentSuggestion{first, noSupplier, third},
+			request: ReplenishmentOrderRequest{MedicineIDs: []int{3, 1}}, want: []int{3, 1}, ok: true,
+		},
+		{
+			name: "unselected medicine needs no supplier", suggestions: []ReplenishmentSuggestion{first, noSupplier},
+			request: ReplenishmentOrderRequest{MedicineIDs: []int{1}}, want: []int{1}, ok: true,
+		},
+		{
+			name: "supplier override", suggestions: []ReplenishmentSuggestion{first, noSupplier},
+			request: ReplenishmentOrderRequest{SupplierID: &overrideID}, want: []int{1, 2}, ok: true,
+		},
+		{name: "no default supplier", suggestions: []ReplenishmentSuggestion{first, noSupplier}},
+		{
+			name: "medicine without suggestion", suggestions: []ReplenishmentSuggestion{first},
+			request: ReplenishmentOrderRequest{MedicineIDs: []int{1, 4}},
+		},
+		{name: "no suggestions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := SelectReplenishment(tt.suggestions, tt.request)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			got := make([]int, 0, len(selected))
+			for _, suggestion := range selected {
+				got = append(got, suggestion.MedicineID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("selected medicines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanReplenishmentOrders(t *testing.T) {
+	supplierA, supplierB, overrideID, userID := 7, 3, 9, 42
+	suggestions := []ReplenishmentSuggestion{
+		{MedicineID: 1, SuggestedQuantity: 5, SupplierID: &supplierA},
+		{MedicineID: 2, SuggestedQuantity: 2, SupplierID: &supplierB},
+		{MedicineID: 3, SuggestedQuantity: 4, SupplierID: &supplierA},
+	}
+	unitCost := func(medicineID int) float64 { return float64(medicineID) * 10 }
+
+	tests := []struct {
+		name     string
+		supplier *int
+		want     map[int][]PurchaseOrderItem
+		order    []int
+	}{
+		{
+			name:  "grouped by default supplier",
+			order: []int{3, 7},
+			want: map[int][]PurchaseOrderItem{
+				3: {{MedicineID: 2, Quantity: 2, UnitCost: 20}},
+				7: {{MedicineID: 1, Quantity: 5, UnitCost: 10}, {MedicineID: 3, Quantity: 4, UnitCost: 30}},
+			},
+		},
+		{
+			name: "supplier override", supplier: &overrideID,
+			order: []int{9},
+			want: map[int][]PurchaseOrderItem{
+				9: {{MedicineID: 1, Quantity: 5, UnitCost: 10}, {MedicineID: 2, Quantity: 2, UnitCost: 20}, {MedicineID: 3, Quantity: 4, UnitCost: 30}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := ReplenishmentOrderRequest{SupplierID: tt.supplier, CreatedBy: &userID}
+			orders := PlanReplenishmentOrders(5, suggestions, request, unitCost)
+			if len(orders) != len(tt.order) {
+				t.Fatalf("orders = %+v, want suppliers %v", orders, tt.order)
+			}
+			for i, order := range orders {
+				if order.SupplierID != tt.order[i] || order.PharmacyID != 5 || order.Status != PurchaseStatusDraft || order.CreatedBy != &userID {
+					t.Errorf("orders[%d] = %+v, want draft for supplier %d in pharmacy 5 by user %d", i, order, tt.order[i], userID)
+				}
+				want := tt.want[order.SupplierID]
+				if len(order.Items) != len(want) {
+					t.Errorf("supplier %d items = %+v, want %+v", order.SupplierID, order.Items, want)
+					continue
+				}
+				for j := range want {
+					if order.Items[j] != want[j] {
+						t.Errorf("supplier %d items[%d] = %+v, want %+v", order.SupplierID, j, order.Items[j], want[j])
+					}
+				}
+			}
+		})
+	}
+}
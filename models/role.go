@@ -0,0 +1,86 @@
+package models
+
+// Разрешения, которые проверяются при доступе к API
+const (
+	PermPharmacyRead  = "pharmacy:read"
+	PermPharmacyWrite = "pharmacy:write"
+	PermMedicineRead  = "medicine:read"
+	PermMedicineWrite = "medicine:write"
+	PermStockRead     = "stock:read"
+	PermStockWrite    = "stock:write"
+	PermOrderRead     = "order:read"
+	PermOrderWrite    = "order:write"
+	PermUserAdmin     = "user:admin"
+	PermRoleAdmin     = "role:admin"
+	PermSystemAdmin   = "system:admin"
+
+	PermPrescriptionRead  = "prescription:read"
+	PermPrescriptionWrite = "prescription:write"
+
+	PermPurchaseRead  = "purchase:read"
+	PermPurchaseWrite = "purchase:write"
+)
+
+// DefaultSignupRole роль, которую получает пользователь при самостоятельной регистрации
+const DefaultSignupRole = "Buyer"
+
+// Permission именованное разрешение
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Role роль пользователя — набор разрешений. Имя роли хранится в user_details.position
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// HasPermission проверяет, входит ли разрешение в роль
+func (r Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions возвращает справочник всех разрешений системы
+func Permissions() []Permission {
+	return []Permission{
+		{PermPharmacyRead, "Просмотр аптек"},
+		{PermPharmacyWrite, "Создание, изменение и удаление аптек"},
+		{PermMedicineRead, "Просмотр каталога лекарств"},
+		{PermMedicineWrite, "Создание, изменение и удаление лекарств"},
+		{PermStockRead, "Просмотр остатков и партий"},
+		{PermStockWrite, "Изменение остатков, списание и приёмка партий"},
+		{PermOrderRead, "Просмотр заказов"},
+		{PermOrderWrite, "Создание заказов и смена их статуса"},
+		{PermPrescriptionRead, "Просмотр рецептов"},
+		{PermPrescriptionWrite, "Регистрация и отмена рецептов"},
+		{PermPurchaseRead, "Просмотр поставщиков и заказов поставщикам"},
+		{PermPurchaseWrite, "Управление поставщиками, заказами поставщикам и приёмка поставок"},
+		{PermUserAdmin, "Управление пользователями"},
+		{PermRoleAdmin, "Управление ролями и их разрешениями"},
+		{PermSystemAdmin, "Служебная информация о системе"},
+	}
+}
+
+// DefaultRoles возвращает встроенные роли; совпадают с начальными данными миграций 0006_rbac, 0011_prescriptions и 0014_purchase_orders
+func DefaultRoles() []Role {
+	all := make([]string, 0, len(Permissions()))
+	for _, p := range Permissions() {
+		all = append(all, p.Name)
+	}
+	return []Role{
+		{Name: "Developer", Description: "Полный доступ", Permissions: all},
+		{Name: "Seller", Description: "Продавец аптеки", Permissions: []string{
+			PermPharmacyRead, PermPharmacyWrite, PermMedicineRead, PermMedicineWrite,
+			PermStockRead, PermStockWrite, PermOrderRead, PermOrderWrite,
+			PermPrescriptionRead, PermPrescriptionWrite, PermPurchaseRead, PermPurchaseWrite,
+		}},
+		{Name: "Buyer", Description: "Покупатель", Permissions: []string{PermPharmacyRead, PermMedicineRead}},
+	}
+}
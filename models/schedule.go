@@ -0,0 +1,136 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	// Часовые пояса встраиваются в бинарный файл, чтобы расписание не зависело от tzdata в системе
+	_ "time/tzdata"
+)
+
+// DefaultTimezone — часовой пояс аптеки, если он не указан
+const DefaultTimezone = "Europe/Moscow"
+
+// ClockLayout — формат времени открытия и закрытия; закрытие в полночь записывается как "24:00"
+const ClockLayout = "15:04"
+
+// scheduleHorizonDays — на сколько дней вперёд ищется ближайшее открытие
+const scheduleHorizonDays = 366
+
+// TimeRange интервал работы в пределах одних суток по местному времени: [opens, closes)
+type TimeRange struct {
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// OpeningHours интервал работы в день недели. Для перерыва на обед задаются два интервала,
+// для круглосуточной работы — "00:00"–"24:00"
+type OpeningHours struct {
+	// Weekday — день недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+	Weekday int `json:"weekday"`
+	TimeRange
+}
+
+// ScheduleException заменяет недельное расписание на одну дату: праздник, санитарный день, временное закрытие
+type ScheduleException struct {
+	Date string `json:"date"`
+	// Hours — интервалы работы в этот день; пустой список означает, что аптека закрыта весь день
+	Hours []TimeRange `json:"hours"`
+	Note  string      `json:"note,omitempty"`
+}
+
+// ParseClock разбирает время "ЧЧ:ММ" в минуты от начала суток; допускается "24:00"
+func ParseClock(value string) (int, bool) {
+	if value == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse(ClockLayout, value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// ISOWeekday возвращает день недели по ISO 8601: 1 — понедельник, 7 — воскресенье
+func ISOWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// HasSchedule сообщает, задано ли для аптеки расписание
+func (p Pharmacy) HasSchedule() bool {
+	return len(p.Hours) > 0 || len(p.Exceptions) > 0
+}
+
+// dayHours возвращает интервалы работы на дату: из исключения, если оно есть, иначе из недельного расписания
+func (p Pharmacy) dayHours(date time.Time) []TimeRange {
+	day := date.Format(DateLayout)
+	for _, exception := range p.Exceptions {
+		if exception.Date == day {
+			return append([]TimeRange(nil), exception.Hours...)
+		}
+	}
+	var hours []TimeRange
+	weekday := ISOWeekday(date)
+	for _, h := range p.Hours {
+		if h.Weekday == weekday {
+			hours = append(hours, h.TimeRange)
+		}
+	}
+	return hours
+}
+
+// ScheduleStatus вычисляет, открыта ли аптека в момент at, и если нет — когда она откроется.
+// next равен nil, если аптека открыта или не откроется в ближайший год.
+// ok равен false, если расписание не задано или часовой пояс неизвестен
+func (p Pharmacy) ScheduleStatus(at time.Time) (open bool, next *time.Time, ok bool) {
+	if !p.HasSchedule() {
+		return false, nil, false
+	}
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return false, nil, false
+	}
+
+	local := at.In(location)
+	for day := 0; day <= scheduleHorizonDays; day++ {
+		date := time.Date(local.Year(), local.Month(), local.Day()+day, 0, 0, 0, 0, location)
+		hours := p.dayHours(date)
+		sort.Slice(hours, func(i, j int) bool { return hours[i].Opens < hours[j].Opens })
+		for _, h := range hours {
+			opens, okOpens := ParseClock(h.Opens)
+			closes, okCloses := ParseClock(h.Closes)
+			if !okOpens || !okCloses {
+				continue
+			}
+			start := time.Date(date.Year(), date.Month(), date.Day(), 0, opens, 0, 0, location)
+			end := time.Date(date.Year(), date.Month(), date.Day(), 0, closes, 0, 0, location)
+			if !at.Before(end) {
+				continue
+			}
+			if !at.Before(start) {
+				return true, nil, true
+			}
+			return false, &start, true
+		}
+	}
+	return false, nil, true
+}
+
+// IsOpenAt сообщает, открыта ли аптека в момент at
+func (p Pharmacy) IsOpenAt(at time.Time) bool {
+	open, _, _ := p.ScheduleStatus(at)
+	return open
+}
+
+// FillScheduleStatus заполняет вычисляемые поля open_now и next_opening на момент now
+func (p *Pharmacy) FillScheduleStatus(now time.Time) {
+	open, next, ok := p.ScheduleStatus(now)
+	if !ok {
+		p.OpenNow, p.NextOpening = nil, nil
+		return
+	}
+	p.OpenNow, p.NextOpening = &open, next
+}
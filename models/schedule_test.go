@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPharmacyScheduleStatus(t *testing.T) {
+	moscow, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2025-03-03 — понедельник
+	local := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.March, day, hour, minute, 0, 0, moscow)
+	}
+
+	var weekly []OpeningHours
+	for weekday := 1; weekday <= 5; weekday++ {
+		weekly = append(weekly,
+			OpeningHours{Weekday: weekday, TimeRange: TimeRange{Opens: "14:00", Closes: "20:00"}},
+			OpeningHours{Weekday: weekday, TimeRange: TimeRange{Opens: "09:00", Closes: "13:00"}},
+		)
+	}
+	weekly = append(weekly, OpeningHours{Weekday: 6, TimeRange: TimeRange{Opens: "10:00", Closes: "16:00"}})
+	pharmacy := Pharmacy{
+		Timezone: DefaultTimezone,
+		Hours:    weekly,
+		Exceptions: []ScheduleException{
+			{Date: "2025-03-08", Note: "Праздник"},
+			{Date: "2025-03-16", Hours: []TimeRange{{Opens: "11:00", Closes: "15:00"}}},
+		},
+	}
+
+	var allDay []OpeningHours
+	for weekday := 1; weekday <= 7; weekday++ {
+		allDay = append(allDay, OpeningHours{Weekday: weekday, TimeRange: TimeRange{Opens: "00:00", Closes: "24:00"}})
+	}
+
+	tests := []struct {
+		name     string
+		pharmacy Pharmacy
+		at       time.Time
+		open     bool
+		next     *time.Time
+		ok       bool
+	}{
+		{name: "open in the morning", pharmacy: pharmacy, at: local(3, 10, 0), open: true, ok: true},
+		{name: "opens at start", pharmacy: pharmacy, at: local(3, 9, 0), open: true, ok: true},
+		{name: "before opening", pharmacy: pharmacy, at: local(3, 8, 0), next: timePtr(local(3, 9, 0)), ok: true},
+		{name: "lunch break", pharmacy: pharmacy, at: local(3, 13, 30), next: timePtr(local(3, 14, 0)), ok: true},
+		{name: "closes at end", pharmacy: pharmacy, at: local(3, 20, 0), next: timePtr(local(4, 9, 0)), ok: true},
+		{name: "instant in another zone", pharmacy: pharmacy, at: time.Date(2025, time.March, 3, 6, 30, 0, 0, time.UTC), open: true, ok: true},
+		{name: "holiday and closed sunday", pharmacy: pharmacy, at: local(7, 21, 0), next: timePtr(local(10, 9, 0)), ok: true},
+		{name: "saturday", pharmacy: pharmacy, at: local(15, 12, 0), open: true, ok: true},
+		{name: "exception opens sunday", pharmacy: pharmacy, at: local(15, 17, 0), next: timePtr(local(16, 11, 0)), ok: true},
+		{name: "around the clock", pharmacy: Pharmacy{Timezone: DefaultTimezone, Hours: allDay}, at: local(9, 23, 59), open: true, ok: true},
+		{
+			name:     "never opens",
+			pharmacy: Pharmacy{Timezone: DefaultTimezone, Exceptions: []ScheduleException{{Date: "2025-03-03"}}},
+			at:       local(3, 12, 0), ok: true,
+		},
+		{name: "no schedule", pharmacy: Pharmacy{Timezone: DefaultTimezone}, at: local(3, 12, 0)},
+		{name: "unknown timezone", pharmacy: Pharmacy{Timezone: "Mars/Olympus", Hours: weekly}, at: local(3, 12, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, next, ok := tt.pharmacy.ScheduleStatus(tt.at)
+			if open != tt.open || ok != tt.ok {
+				t.Errorf("open, ok = %v, %v; want %v, %v", open, ok, tt.open, tt.ok)
+			}
+			switch {
+			case tt.next == nil && next != nil:
+				t.Errorf("next = %v, want nil", *next)
+			case tt.next != nil && (next == nil || !next.Equal(*tt.next)):
+				t.Errorf("next = %v, want %v", next, *tt.next)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
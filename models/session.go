@@ -0,0 +1,26 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Session сессия пользователя на одном устройстве
+type Session struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	TokenHash  string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"`
+}
+
+// HashToken возвращает SHA-256 хеш токена сессии в шестнадцатеричном виде
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Статусы инвентаризации
+const (
+	StockCountStatusOpen      = "open"
+	StockCountStatusApproved  = "approved"
+	StockCountStatusCancelled = "cancelled"
+)
+
+// StockCount represents a physical inventory count of a pharmacy.
+type StockCount struct {
+	ID           int               `json:"id"`
+	PharmacyID   int               `json:"pharmacy_id"`
+	PharmacyName string            `json:"pharmacy_name,omitempty"`
+	Status       string            `json:"status"`
+	Notes        string            `json:"notes,omitempty"`
+	Lines        []StockCountLine  `json:"lines,omitempty"`
+	Adjustments  []StockAdjustment `json:"adjustments,omitempty"`
+	OpenedBy     *int              `json:"opened_by,omitempty"`
+	ClosedBy     *int              `json:"closed_by,omitempty"`
+	OpenedAt     time.Time         `json:"opened_at"`
+	ClosedAt     *time.Time        `json:"closed_at,omitempty"`
+}
+
+// StockCountLine строка инвентаризации по лекарству.
+// ExpectedQuantity — остаток на момент открытия, BookQuantity — учётный остаток на момент последнего подсчёта.
+// CountedQuantity — сумма подсчётов всех устройств, Variance — расхождение с BookQuantity;
+// оба nil, пока лекарство не посчитано
+type StockCountLine struct {
+	MedicineID       int               `json:"medicine_id"`
+	MedicineName     string            `json:"medicine_name,omitempty"`
+	ExpectedQuantity int               `json:"expected_quantity"`
+	BookQuantity     int               `json:"book_quantity"`
+	CountedQuantity  *int              `json:"counted_quantity,omitempty"`
+	Variance         *int              `json:"variance,omitempty"`
+	Reason           string            `json:"reason,omitempty"`
+	Entries          []StockCountEntry `json:"entries,omitempty"`
+}
+
+// StockCountEntry подсчёт лекарства одним устройством; повторный подсчёт тем же устройством заменяет предыдущий
+type StockCountEntry struct {
+	MedicineID int       `json:"medicine_id"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	Quantity   int       `json:"quantity"`
+	Reason     string    `json:"reason,omitempty"`
+	CountedBy  *int      `json:"counted_by,omitempty"`
+	CountedAt  time.Time `json:"counted_at"`
+}
+
+// StockAdjustment корректировка остатка лекарства в аптеке с причиной
+type StockAdjustment struct {
+	ID           int       `json:"id"`
+	PharmacyID   int       `json:"pharmacy_id"`
+	MedicineID   int       `json:"medicine_id"`
+	MedicineName string    `json:"medicine_name,omitempty"`
+	StockCountID *int      `json:"stock_count_id,omitempty"`
+	Quantity     int       `json:"quantity"`
+	Reason       string    `json:"reason"`
+	CreatedBy    *int      `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StockCountFilter задаёт необязательные фильтры списка инвентаризаций
+type StockCountFilter struct {
+	PharmacyID int
+	Status     string
+}
+
+// Tally пересчитывает подсчитанное количество, расхождение и причину строки по подсчётам устройств.
+// Причиной считается последняя непустая причина
+func (l *StockCountLine) Tally() {
+	l.CountedQuantity, l.Variance, l.Reason = nil, nil, ""
+	if len(l.Entries) == 0 {
+		return
+	}
+	sort.Slice(l.Entries, func(i, j int) bool {
+		if !l.Entries[i].CountedAt.Equal(l.Entries[j].CountedAt) {
+			return l.Entries[i].CountedAt.Before(l.Entries[j].CountedAt)
+		}
+		return l.Entries[i].DeviceID < l.Entries[j].DeviceID
+	})
+	counted := 0
+	for _, entry := range l.Entries {
+		counted += entry.Quantity
+		if entry.Reason != "" {
+			l.Reason = entry.Reason
+		}
+	}
+	variance := counted - l.BookQuantity
+	l.CountedQuantity, l.Variance = &counted, &variance
+}
+
+// Line возвращает строку инвентаризации с лекарством medicineID или nil
+func (c *StockCount) Line(medicineID int) *StockCountLine {
+	for i := range c.Lines {
+		if c.Lines[i].MedicineID == medicineID {
+			return &c.Lines[i]
+		}
+	}
+	return nil
+}
+
+// MissingReasons возвращает лекарства с расхождением, для которого не указана причина
+func (c StockCount) MissingReasons() []int {
+	var missing []int
+	for _, line := range c.Lines {
+		if line.Variance != nil && *line.Variance != 0 && line.Reason == "" {
+			missing = append(missing, line.MedicineID)
+		}
+	}
+	return missing
+}
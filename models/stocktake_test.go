@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStockCountLineTally(t *testing.T) {
+	at := time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+	entry := func(device string, minutes, quantity int, reason string) StockCountEntry {
+		return StockCountEntry{DeviceID: device, Quantity: quantity, Reason: reason, CountedAt: at.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	tests := []struct {
+		name     string
+		book     int
+		entries  []StockCountEntry
+		counted  *int
+		variance *int
+		reason   string
+	}{
+		{name: "not counted", book: 10},
+		{name: "matches book", book: 10, entries: []StockCountEntry{entry("a", 0, 10, "")}, counted: intPtr(10), variance: intPtr(0)},
+		{
+			name: "sums devices", book: 10,
+			entries: []StockCountEntry{entry("a", 0, 6, ""), entry("b", 1, 3, "")},
+			counted: intPtr(9), variance: intPtr(-1),
+		},
+		{
+			name: "surplus", book: 4,
+			entries: []StockCountEntry{entry("a", 0, 5, "найдено на витрине")},
+			counted: intPtr(5), variance: intPtr(1), reason: "найдено на витрине",
+		},
+		{
+			name: "latest non-empty reason", book: 10,
+			entries: []StockCountEntry{entry("b", 5, 2, ""), entry("a", 0, 3, "бой"), entry("c", 2, 1, "недостача")},
+			counted: intPtr(6), variance: intPtr(-4), reason: "недостача",
+		},
+		{
+			name: "same time ordered by device", book: 10,
+			entries: []StockCountEntry{entry("b", 0, 2, "истёк срок"), entry("a", 0, 3, "бой")},
+			counted: intPtr(5), variance: intPtr(-5), reason: "истёк срок",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := StockCountLine{
+				BookQuantity:    tt.book,
+				Entries:         tt.entries,
+				CountedQuantity: intPtr(99),
+				Variance:        intPtr(99),
+				Reason:          "устаревшая причина",
+			}
+			line.Tally()
+			if !sameIntPtr(line.CountedQuantity, tt.counted) || !sameIntPtr(line.Variance, tt.variance) {
+				t.Errorf("counted, variance = %s, %s; want %s, %s",
+					formatIntPtr(line.CountedQuantity), formatIntPtr(line.Variance), formatIntPtr(tt.counted), formatIntPtr(tt.variance))
+			}
+			if line.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", line.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func sameIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatIntPtr(v *int) string {
+	if v == nil {
+		return "nil"
+	}
+	return strconv.Itoa(*v)
+}
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// Статусы перемещения между аптеками
+const (
+	TransferStatusRequested = "requested"
+	TransferStatusApproved  = "approved"
+	TransferStatusInTransit = "in_transit"
+	TransferStatusReceived  = "received"
+	TransferStatusRejected  = "rejected"
+	TransferStatusCancelled = "cancelled"
+)
+
+// Направления перемещений относительно аптеки в фильтре
+const (
+	TransferDirectionIncoming = "incoming"
+	TransferDirectionOutgoing = "outgoing"
+)
+
+// transferTransitions описывает допустимые переходы между статусами перемещения.
+// Отправленное перемещение можно только принять: товар уже списан в аптеке-отправителе
+var transferTransitions = map[string][]string{
+	TransferStatusRequested: {TransferStatusApproved, TransferStatusRejected, TransferStatusCancelled},
+	TransferStatusApproved:  {TransferStatusInTransit, TransferStatusCancelled},
+	TransferStatusInTransit: {TransferStatusReceived},
+}
+
+// Transfer represents a request to move medicines from one pharmacy to another.
+type Transfer struct {
+	ID               int            `json:"id"`
+	FromPharmacyID   int            `json:"from_pharmacy_id"`
+	FromPharmacyName string         `json:"from_pharmacy_name,omitempty"`
+	ToPharmacyID     int            `json:"to_pharmacy_id"`
+	ToPharmacyName   string         `json:"to_pharmacy_name,omitempty"`
+	Status           string         `json:"status"`
+	Notes            string         `json:"notes,omitempty"`
+	Items            []TransferItem `json:"items"`
+	RequestedBy      *int           `json:"requested_by,omitempty"`
+	ApprovedBy       *int           `json:"approved_by,omitempty"`
+	ShippedBy        *int           `json:"shipped_by,omitempty"`
+	ReceivedBy       *int           `json:"received_by,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	ApprovedAt       *time.Time     `json:"approved_at,omitempty"`
+	ShippedAt        *time.Time     `json:"shipped_at,omitempty"`
+	ReceivedAt       *time.Time     `json:"received_at,omitempty"`
+}
+
+// TransferItem перемещаемое лекарство. Allocations — партии, списанные в аптеке-отправителе
+// при отправке; они же оприходуются в аптеке-получателе
+type TransferItem struct {
+	ID           int             `json:"id"`
+	MedicineID   int             `json:"medicine_id"`
+	MedicineName string          `json:"medicine_name,omitempty"`
+	Quantity     int             `json:"quantity"`
+	Allocations  []LotAllocation `json:"allocations,omitempty"`
+}
+
+// TransferFilter задаёт необязательные фильтры списка перемещений.
+// Direction без PharmacyID не применяется; пустой Direction выбирает оба направления
+type TransferFilter struct {
+	PharmacyID int
+	Direction  string
+	Status     string
+}
+
+// CanTransitionTransfer сообщает, допустим ли переход перемещения из статуса from в статус to
+func CanTransitionTransfer(from, to string) bool {
+	for _, status := range transferTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanDecide сообщает, может ли пользователь userID одобрить или отклонить запрос на перемещение.
+// Решение принимает аптека-отправитель, поэтому автор запроса решить его сам не может
+func (t Transfer) CanDecide(userID *int) bool {
+	if userID == nil {
+		return false
+	}
+	return t.RequestedBy == nil || *t.RequestedBy != *userID
+}
+
+// Unlotted возвращает количество позиции, отправленное из остатка без партии
+func (item TransferItem) Unlotted() int {
+	unlotted := item.Quantity
+	for _, allocation := range item.Allocations {
+		unlotted -= allocation.Quantity
+	}
+	return unlotted
+}
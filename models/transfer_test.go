@@ -0,0 +1,52 @@
+package models
+
+import "testing"
+
+func TestCanTransitionTransfer(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{TransferStatusRequested, TransferStatusApproved}:  true,
+		{TransferStatusRequested, TransferStatusRejected}:  true,
+		{TransferStatusRequested, TransferStatusCancelled}: true,
+		{TransferStatusApproved, TransferStatusInTransit}:  true,
+		{TransferStatusApproved, TransferStatusCancelled}:  true,
+		{TransferStatusInTransit, TransferStatusReceived}:  true,
+	}
+
+	// Проверяются все пары статусов, включая переход в тот же статус и неизвестный статус
+	statuses := []string{
+		"lost", TransferStatusRequested, TransferStatusApproved, TransferStatusInTransit,
+		TransferStatusReceived, TransferStatusRejected, TransferStatusCancelled,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransitionTransfer(from, to); got != want {
+				t.Errorf("CanTransitionTransfer(%q, %q) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestTransferCanDecide(t *testing.T) {
+	requester, approver := 1, 2
+
+	tests := []struct {
+		name        string
+		requestedBy *int
+		userID      *int
+		want        bool
+	}{
+		{"another user", &requester, &approver, true},
+		{"requester", &requester, &requester, false},
+		{"requester deleted", nil, &approver, true},
+		{"no user", &requester, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer := Transfer{RequestedBy: tt.requestedBy}
+			if got := transfer.CanDecide(tt.userID); got != tt.want {
+				t.Errorf("CanDecide = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type UserWithDetails struct {
+	ID        int         `json:"id"`
+	Username  string      `json:"username"`
+	Password  string      `json:"password"`
+	CreatedAt time.Time   `json:"created_at"`
+	LoginAt   time.Time   `json:"login_at"`
+	Details   UserDetails `json:"details"`
+}
+
+type UserDetails struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	FirstName   string `json:"first_name"`
+	SecondName  string `json:"second_name"`
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+	Position    string `json:"position"`
+}
@@ -0,0 +1,157 @@
+// Package search содержит нормализацию текста для поиска лекарств:
+// транслитерацию кириллицы в латиницу, разбиение запроса на слова,
+// триграммное сходство в духе pg_trgm и подсветку найденных фрагментов.
+//
+// Транслитерация должна совпадать с SQL-функцией search_translit
+// из миграции 0007_medicine_search, иначе поиск в PostgreSQL и подсветка разойдутся.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SimilarityThreshold — минимальное сходство слова с запросом, при котором слово считается опечаткой запроса
+const SimilarityThreshold = 0.4
+
+// translit — замены кириллических букв. Ц и Х передаются как c и h,
+// чтобы латинские корни названий совпадали: Парацетамол → paracetamol
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "c",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// Translit приводит текст к нижнему регистру и записывает кириллицу латиницей
+func Translit(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if latin, ok := translit[r]; ok {
+			b.WriteString(latin)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Tokens разбивает запрос на слова из букв и цифр в транслитерированном виде
+func Tokens(query string) []string {
+	return strings.FieldsFunc(Translit(query), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+}
+
+// words разбивает текст на слова, сохраняя их границы в исходной строке
+func words(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// trigrams возвращает множество триграмм слова так же, как pg_trgm: с двумя пробелами в начале и одним в конце
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	result := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		result[string(runes[i:i+3])] = true
+	}
+	return result
+}
+
+// Similarity возвращает триграммное сходство двух слов от 0 до 1
+func Similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	total := len(ta) + len(tb) - common
+	if total == 0 {
+		return 0
+	}
+	return float64(common) / float64(total)
+}
+
+// WordScore оценивает совпадение слова текста с токеном запроса:
+// 1 — слово начинается с токена, иначе триграммное сходство, если оно не ниже порога
+func WordScore(token, word string) float64 {
+	word = Translit(word)
+	if strings.HasPrefix(word, token) {
+		return 1
+	}
+	if similarity := Similarity(token, word); similarity >= SimilarityThreshold {
+		return similarity
+	}
+	return 0
+}
+
+// Score оценивает, насколько текст соответствует всем токенам запроса: среднее лучших совпадений.
+// Если хотя бы один токен не нашёлся, возвращается 0
+func Score(text string, tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 0
+	}
+	spans := words(text)
+	total := 0.0
+	for _, token := range tokens {
+		best := 0.0
+		for _, span := range spans {
+			if score := WordScore(token, text[span[0]:span[1]]); score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total / float64(len(tokens))
+}
+
+// Highlight экранирует текст как HTML и оборачивает в <mark> слова, совпавшие с токенами запроса.
+// Второе значение сообщает, было ли хотя бы одно совпадение
+func Highlight(text string, tokens []string) (string, bool) {
+	var b strings.Builder
+	matched := false
+	last := 0
+	for _, span := range words(text) {
+		word := text[span[0]:span[1]]
+		hit := false
+		for _, token := range tokens {
+			if WordScore(token, word) > 0 {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			continue
+		}
+		matched = true
+		b.WriteString(html.EscapeString(text[last:span[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(word))
+		b.WriteString("</mark>")
+		last = span[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), matched
+}
@@ -0,0 +1,215 @@
+package search
+
+import (
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTranslit(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"абвгдеёжзийклмнопрстуфхцчшщъыьэюя", "abvgdeezhziyklmnoprstufhcchshshchyeyuya"},
+		{"АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ", "abvgdeezhziyklmnoprstufhcchshshchyeyuya"},
+		{"Парацетамол", "paracetamol"},
+		{"Хлоргексидин", "hlorgeksidin"},
+		{"Но-шпа 40 мг", "no-shpa 40 mg"},
+		{"Подъезд и Ёлка", "podezd i elka"},
+		{"Nurofen Экспресс", "nurofen ekspress"},
+		{"Ñandú", "ñandú"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Translit(tt.text); got != tt.want {
+				t.Errorf("Translit(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// sqlTranslit повторяет SQL-функцию search_translit из миграции: цепочку replace, затем translate,
+// где буквы без пары во втором аргументе удаляются
+func sqlTranslit(t *testing.T) func(string) string {
+	t.Helper()
+	data, err := os.ReadFile("../db/migrations/0007_medicine_search.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(data)
+	start := strings.Index(body, "CREATE FUNCTION search_translit")
+	end := strings.Index(body[start:], "$$;")
+	if start < 0 || end < 0 {
+		t.Fatal("search_translit not found in migration")
+	}
+	body = body[start : start+end]
+
+	replaces := regexp.MustCompile(`'(\p{Cyrillic})', '([a-z]+)'\)`).FindAllStringSubmatch(body, -1)
+	translate := regexp.MustCompile(`'(\p{Cyrillic}{2,})',\s*'([a-z]+)'\)`).FindStringSubmatch(body)
+	if len(replaces) == 0 || translate == nil {
+		t.Fatal("cannot parse search_translit")
+	}
+	from, to := []rune(translate[1]), []rune(translate[2])
+
+	return func(text string) string {
+		text = strings.ToLower(text)
+		for _, r := range replaces {
+			text = strings.ReplaceAll(text, r[1], r[2])
+		}
+		return strings.Map(func(r rune) rune {
+			for i, f := range from {
+				if f == r {
+					if i < len(to) {
+						return to[i]
+					}
+					return -1
+				}
+			}
+			return r
+		}, text)
+	}
+}
+
+func TestTranslitMatchesMigration(t *testing.T) {
+	sql := sqlTranslit(t)
+	for _, text := range []string{
+		"абвгдеёжзийклмнопрстуфхцчшщъыьэюя",
+		"АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ",
+		"Щёлковский Фармзавод, Ибупрофен 200 мг",
+	} {
+		if got, want := Translit(text), sql(text); got != want {
+			t.Errorf("Translit(%q) = %q, search_translit = %q", text, got, want)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Парацетамол, 500 мг!", []string{"paracetamol", "500", "mg"}},
+		{"Но-шпа", []string{"no", "shpa"}},
+		{"  ибупрофен   NUROFEN ", []string{"ibuprofen", "nurofen"}},
+		{"Ñandú", []string{"and"}},
+		{" -- !", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := Tokens(tt.query)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Tokens(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"paracetamol", "paracetamol", 1},
+		// Триграммы "  a", " ab", "abc", "bc " и "  a", " ab", "abd", "bd ": две общие из шести
+		{"abc", "abd", 1.0 / 3},
+		{"abc", "xyz", 0},
+		// Триграммы считаются по символам, а не по байтам
+		{"мг", "мг", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got, back := Similarity(tt.a, tt.b), Similarity(tt.b, tt.a); got != back {
+				t.Errorf("Similarity is not symmetric: %v and %v", got, back)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	const text = "Нурофен Экспресс, Рекитт Бенкизер"
+
+	tests := []struct {
+		name  string
+		query string
+		// exact — точная ожидаемая оценка; иначе ожидается опечатка: оценка между порогом и 1
+		exact bool
+		want  float64
+	}{
+		{name: "prefix", query: "нуро", exact: true, want: 1},
+		{name: "latin query", query: "nurofen rekitt", exact: true, want: 1},
+		{name: "typo", query: "нурафен"},
+		{name: "missing word", query: "нурофен аспирин", exact: true, want: 0},
+		{name: "no tokens", query: "!!", exact: true, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Score(text, Tokens(tt.query))
+			if tt.exact && got != tt.want {
+				t.Errorf("Score(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			if !tt.exact && (got < SimilarityThreshold || got >= 1) {
+				t.Errorf("Score(%q) = %v, want a typo score in [%v, 1)", tt.query, got, SimilarityThreshold)
+			}
+		})
+	}
+
+	// Оценка — среднее лучших совпадений токенов
+	typo := Score(text, Tokens("нурафен"))
+	if got, want := Score(text, Tokens("нурафен экспресс")), (typo+1)/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Score of two tokens = %v, want average %v", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		query   string
+		want    string
+		matched bool
+	}{
+		{
+			name:    "multi-byte words",
+			text:    "Парацетамол <500> мг & Ко",
+			query:   "параце мг",
+			want:    "<mark>Парацетамол</mark> &lt;500&gt; <mark>мг</mark> &amp; Ко",
+			matched: true,
+		},
+		{
+			name:    "latin query over cyrillic text",
+			text:    "Таблетки Ибупрофен, 20 шт.",
+			query:   "ibuprofen",
+			want:    "Таблетки <mark>Ибупрофен</mark>, 20 шт.",
+			matched: true,
+		},
+		{
+			name:    "typo",
+			text:    "Нурофен Экспресс",
+			query:   "нурафен",
+			want:    "<mark>Нурофен</mark> Экспресс",
+			matched: true,
+		},
+		{
+			name:  "no match is escaped",
+			text:  "Ацикловир \"Акрихин\"",
+			query: "ибупрофен",
+			want:  "Ацикловир &#34;Акрихин&#34;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := Highlight(tt.text, Tokens(tt.query))
+			if got != tt.want || matched != tt.matched {
+				t.Errorf("Highlight(%q, %q) = %q, %v; want %q, %v", tt.text, tt.query, got, matched, tt.want, tt.matched)
+			}
+		})
+	}
+}
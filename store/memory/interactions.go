@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+func (s *Store) ListInteractions(ctx context.Context, ingredient string) ([]models.Interaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ingredient = models.IngredientName(ingredient)
+	interactions := []models.Interaction{}
+	for _, interaction := range s.interactions {
+		if ingredient != "" && interaction.IngredientA != ingredient && interaction.IngredientB != ingredient {
+			continue
+		}
+		interactions = append(interactions, interaction)
+	}
+	sort.Slice(interactions, func(i, j int) bool {
+		if interactions[i].IngredientA != interactions[j].IngredientA {
+			return interactions[i].IngredientA < interactions[j].IngredientA
+		}
+		return interactions[i].IngredientB < interactions[j].IngredientB
+	})
+	return interactions, nil
+}
+
+func (s *Store) ImportInteractions(ctx context.Context, interactions []models.Interaction) (models.InteractionImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := make(map[[2]string]int, len(s.interactions))
+	for id, interaction := range s.interactions {
+		existing[[2]string{interaction.IngredientA, interaction.IngredientB}] = id
+	}
+
+	var result models.InteractionImportResult
+	now := s.Now()
+	for i := range interactions {
+		interaction := &interactions[i]
+		interaction.Normalize()
+		interaction.UpdatedAt = now
+		key := [2]string{interaction.IngredientA, interaction.IngredientB}
+		if id, ok := existing[key]; ok {
+			interaction.ID = id
+			result.Updated++
+		} else {
+			interaction.ID = s.newID("ingredient_interactions")
+			existing[key] = interaction.ID
+			result.Created++
+		}
+		s.interactions[interaction.ID] = *interaction
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteInteraction(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.interactions[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.interactions, id)
+	return nil
+}
+
+func (s *Store) CheckInteractions(ctx context.Context, medicineIDs []int) ([]models.InteractionWarning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medicines := make([]models.Medicine, 0, len(medicineIDs))
+	seen := map[int]bool{}
+	for _, id := range medicineIDs {
+		medicine, ok := s.medicines[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: medicine %d", store.ErrNotFound, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			medicines = append(medicines, medicine)
+		}
+	}
+
+	interactions := make([]models.Interaction, 0, len(s.interactions))
+	for _, interaction := range s.interactions {
+		interactions = append(interactions, interaction)
+	}
+	return models.FindInteractions(medicines, interactions), nil
+}
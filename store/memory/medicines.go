@@ -0,0 +1,346 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/search"
+	"pharmacy-test/store"
+)
+
+// availability возвращает наличие лекарства по аптекам; вызывается под блокировкой
+func (s *Store) availability(medicineID int) []models.StockItem {
+	items := []models.StockItem{}
+	for key, quantity := range s.stock {
+		if key.medicineID != medicineID {
+			continue
+		}
+		items = append(items, models.StockItem{
+			PharmacyID:   key.pharmacyID,
+			PharmacyName: s.pharmacies[key.pharmacyID].Name,
+			MedicineID:   medicineID,
+			Quantity:     quantity,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].PharmacyID < items[j].PharmacyID })
+	return items
+}
+
+// hasIngredient сообщает, входит ли вещество в состав лекарства
+func hasIngredient(medicine models.Medicine, name string) bool {
+	for _, ingredient := range medicine.Ingredients {
+		if models.IngredientName(ingredient.Name) == models.IngredientName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) ListMedicines(ctx context.Context, filter models.MedicineFilter, page models.PageRequest) (models.Page[models.Medicine], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medicines := []models.Medicine{}
+	for _, medicine := range s.medicines {
+		if filter.Manufacturer != "" && !strings.EqualFold(medicine.Manufacturer, filter.Manufacturer) {
+			continue
+		}
+		if filter.MinPrice != nil && medicine.Price < *filter.MinPrice {
+			continue
+		}
+		if filter.MaxPrice != nil && medicine.Price > *filter.MaxPrice {
+			continue
+		}
+		if _, ok := s.stock[stockKey{filter.PharmacyID, medicine.ID}]; filter.PharmacyID != 0 && !ok {
+			continue
+		}
+		if filter.RxRequired != nil && medicine.RxRequired != *filter.RxRequired {
+			continue
+		}
+		if !strings.HasPrefix(medicine.ATCCode, filter.ATCCode) {
+			continue
+		}
+		if filter.Ingredient != "" && !hasIngredient(medicine, filter.Ingredient) {
+			continue
+		}
+		medicines = append(medicines, medicine)
+	}
+
+	result, err := paginate(medicines, page, map[string]sortKey[models.Medicine]{
+		"id":           func(m models.Medicine) interface{} { return m.ID },
+		"name":         func(m models.Medicine) interface{} { return m.Name },
+		"manufacturer": func(m models.Medicine) interface{} { return m.Manufacturer },
+		"price":        func(m models.Medicine) interface{} { return m.Price },
+	}, func(m models.Medicine) int { return m.ID })
+	for i := range result.Items {
+		result.Items[i].Availability = s.availability(result.Items[i].ID)
+	}
+	return result, err
+}
+
+func (s *Store) GetMedicine(ctx context.Context, id int) (models.Medicine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medicine, ok := s.medicines[id]
+	if !ok {
+		return medicine, store.ErrNotFound
+	}
+	medicine.Availability = s.availability(id)
+	medicine.Lots = s.filterLots(func(lot models.Lot) bool { return lot.MedicineID == id && lot.Quantity > 0 })
+	return medicine, nil
+}
+
+// gtinTaken проверяет, присвоен ли GTIN другому лекарству; вызывается под блокировкой
+func (s *Store) gtinTaken(gtin string, id int) bool {
+	for _, existing := range s.medicines {
+		if gtin != "" && existing.ID != id && existing.GTIN == gtin {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetMedicineByGTIN(ctx context.Context, gtin string) (models.Medicine, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, medicine := range s.medicines {
+		if medicine.GTIN != gtin {
+			continue
+		}
+		medicine.Availability = s.availability(medicine.ID)
+		medicine.Lots = s.filterLots(func(lot models.Lot) bool { return lot.MedicineID == medicine.ID && lot.Quantity > 0 })
+		return medicine, nil
+	}
+	return models.Medicine{}, store.ErrNotFound
+}
+
+func (s *Store) CreateMedicine(ctx context.Context, medicine *models.Medicine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gtinTaken(medicine.GTIN, 0) {
+		return &store.ConflictError{Field: "gtin"}
+	}
+	for _, pharmacyID := range medicine.PharmacyIDs {
+		if _, ok := s.pharmacies[pharmacyID]; !ok {
+			return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, pharmacyID)
+		}
+	}
+
+	medicine.ID = s.newID("medicines")
+	medicine.Availability = []models.StockItem{}
+	for _, pharmacyID := range medicine.PharmacyIDs {
+		key := stockKey{pharmacyID, medicine.ID}
+		if _, ok := s.stock[key]; ok {
+			continue
+		}
+		s.stock[key] = 0
+		medicine.Availability = append(medicine.Availability, models.StockItem{PharmacyID: pharmacyID, MedicineID: medicine.ID})
+	}
+
+	stored := *medicine
+	stored.PharmacyIDs = nil
+	stored.Availability = nil
+	stored.ChangedBy = nil
+	stored.Ingredients = append([]models.ActiveIngredient(nil), medicine.Ingredients...)
+	s.medicines[medicine.ID] = stored
+
+	price := medicine.Price
+	s.recordPrice(&models.PriceChange{MedicineID: medicine.ID, NewPrice: &price, ChangedBy: medicine.ChangedBy})
+	return nil
+}
+
+func (s *Store) UpdateMedicine(ctx context.Context, medicine *models.Medicine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.medicines[medicine.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if s.gtinTaken(medicine.GTIN, medicine.ID) {
+		return &store.ConflictError{Field: "gtin"}
+	}
+	stored := *medicine
+	stored.PharmacyIDs = nil
+	stored.Availability = nil
+	stored.Lots = nil
+	stored.ChangedBy = nil
+	stored.Ingredients = append([]models.ActiveIngredient(nil), medicine.Ingredients...)
+	s.medicines[medicine.ID] = stored
+
+	if models.RoundMoney(existing.Price) != models.RoundMoney(medicine.Price) {
+		old, price := existing.Price, medicine.Price
+		s.recordPrice(&models.PriceChange{MedicineID: medicine.ID, OldPrice: &old, NewPrice: &price, ChangedBy: medicine.ChangedBy})
+	}
+	return nil
+}
+
+func (s *Store) ListSubstitutes(ctx context.Context, medicineID, pharmacyID int) ([]models.MedicineSubstitute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medicine, ok := s.medicines[medicineID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if _, ok := s.pharmacies[pharmacyID]; pharmacyID != 0 && !ok {
+		return nil, &store.NotFoundError{Field: "pharmacy_id", ID: pharmacyID}
+	}
+	substitutes := []models.MedicineSubstitute{}
+	key := models.IngredientsKey(medicine.Ingredients)
+	if key == "" {
+		return substitutes, nil
+	}
+
+	basePrice := s.effectivePrice(pharmacyID, medicine)
+	for _, candidate := range s.medicines {
+		if candidate.ID == medicineID || models.IngredientsKey(candidate.Ingredients) != key {
+			continue
+		}
+		candidate.Availability = s.availability(candidate.ID)
+		substitute := models.MedicineSubstitute{
+			Medicine:       candidate,
+			Price:          s.effectivePrice(pharmacyID, candidate),
+			SameDosageForm: candidate.DosageForm == medicine.DosageForm,
+		}
+		substitute.PriceDifference = models.RoundMoney(substitute.Price - basePrice)
+		if pharmacyID != 0 {
+			quantity := s.stock[stockKey{pharmacyID, candidate.ID}]
+			substitute.Quantity = &quantity
+		}
+		substitutes = append(substitutes, substitute)
+	}
+	models.SortSubstitutes(substitutes)
+	return substitutes, nil
+}
+
+func (s *Store) DeleteMedicine(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.medicines[id]; !ok {
+		return store.ErrNotFound
+	}
+	// Как и внешние ключи order_items, prescription_items, purchase_order_items и transfer_items,
+	// проданные, выписанные, заказанные и перемещаемые лекарства удалять нельзя
+	for _, order := range s.orders {
+		for _, item := range order.Items {
+			if item.MedicineID == id {
+				return store.ErrConflict
+			}
+		}
+	}
+	for _, prescription := range s.prescriptions {
+		if prescription.Item(id) != nil {
+			return store.ErrConflict
+		}
+	}
+	for _, order := range s.purchaseOrders {
+		if order.Item(id) != nil {
+			return store.ErrConflict
+		}
+	}
+	for _, transfer := range s.transfers {
+		for _, item := range transfer.Items {
+			if item.MedicineID == id {
+				return store.ErrConflict
+			}
+		}
+	}
+	for _, count := range s.stockCounts {
+		for _, adjustment := range count.Adjustments {
+			if adjustment.MedicineID == id {
+				return store.ErrConflict
+			}
+		}
+	}
+	for _, movement := range s.movements {
+		if movement.MedicineID == id {
+			return store.ErrConflict
+		}
+	}
+	for _, recall := range s.recalls {
+		for _, item := range recall.Items {
+			if item.MedicineID == id {
+				return store.ErrConflict
+			}
+		}
+	}
+	for _, pack := range s.packs {
+		if pack.MedicineID == id {
+			return store.ErrConflict
+		}
+	}
+
+	delete(s.medicines, id)
+	for key := range s.stock {
+		if key.medicineID == id {
+			delete(s.stock, key)
+		}
+	}
+	for lotID, lot := range s.lots {
+		if lot.MedicineID == id {
+			delete(s.lots, lotID)
+		}
+	}
+	for key := range s.stockLevels {
+		if key.medicineID == id {
+			delete(s.stockLevels, key)
+			delete(s.replenishment, key)
+		}
+	}
+	for countID, count := range s.stockCounts {
+		lines := count.Lines[:0:0]
+		for _, line := range count.Lines {
+			if line.MedicineID != id {
+				lines = append(lines, line)
+			}
+		}
+		count.Lines = lines
+		s.stockCounts[countID] = count
+	}
+	s.deletePrices(func(medicineID int, pharmacyID *int) bool { return medicineID == id })
+	return nil
+}
+
+func (s *Store) SearchMedicines(ctx context.Context, query string, limit int) ([]models.MedicineSearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []models.MedicineSearchResult{}
+	tokens := search.Tokens(query)
+	if len(tokens) == 0 {
+		return results, nil
+	}
+
+	for _, medicine := range s.medicines {
+		// Все слова запроса должны найтись хотя бы в одном из полей
+		rank := search.Score(medicine.Name+" "+medicine.Manufacturer+" "+medicine.Packaging, tokens)
+		if rank == 0 {
+			continue
+		}
+		// Как и в PostgreSQL, название весит больше производителя, а производитель — больше упаковки
+		rank += 0.5*search.Score(medicine.Name, tokens) +
+			0.2*search.Score(medicine.Manufacturer, tokens) +
+			0.1*search.Score(medicine.Packaging, tokens)
+		medicine.Availability = s.availability(medicine.ID)
+		results = append(results, models.MedicineSearchResult{Medicine: medicine, Rank: rank})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Medicine.ID < results[j].Medicine.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
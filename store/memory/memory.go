@@ -0,0 +1,107 @@
+// Package memory реализует слой хранения в памяти процесса.
+// Используется для тестов обработчиков без PostgreSQL.
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+type stockKey struct {
+	pharmacyID int
+	medicineID int
+}
+
+// Store реализует store.Store, храня данные в памяти.
+// Все операции выполняются под общей блокировкой, поэтому атомарны.
+type Store struct {
+	mu sync.Mutex
+
+	// Now возвращает текущее время; его можно подменить в тестах
+	Now func() time.Time
+
+	nextID     map[string]int
+	pharmacies map[int]models.Pharmacy
+	medicines  map[int]models.Medicine
+	stock      map[stockKey]int
+	lots       map[int]models.Lot
+	orders     map[int]models.Order
+	users      map[int]models.UserWithDetails
+	sessions   map[int]models.Session
+	roles      map[string]models.Role
+
+	prescriptions map[int]models.Prescription
+
+	priceOverrides  map[stockKey]float64
+	scheduledPrices map[int]models.ScheduledPrice
+	priceHistory    []models.PriceChange
+
+	interactions map[int]models.Interaction
+
+	suppliers      map[int]models.Supplier
+	purchaseOrders map[int]models.PurchaseOrder
+
+	stockLevels   map[stockKey]models.StockLevel
+	replenishment map[stockKey]models.ReplenishmentSuggestion
+
+	transfers   map[int]models.Transfer
+	stockCounts map[int]models.StockCount
+	movements   []models.StockMovement
+	recalls     map[int]models.Recall
+	packs       map[int]models.Pack
+
+	permissions []models.Permission
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New создаёт пустое хранилище в памяти
+func New() *Store {
+	s := &Store{
+		Now:        time.Now,
+		nextID:     map[string]int{},
+		pharmacies: map[int]models.Pharmacy{},
+		medicines:  map[int]models.Medicine{},
+		stock:      map[stockKey]int{},
+		lots:       map[int]models.Lot{},
+		orders:     map[int]models.Order{},
+		users:      map[int]models.UserWithDetails{},
+		sessions:   map[int]models.Session{},
+		roles:      map[string]models.Role{},
+
+		prescriptions: map[int]models.Prescription{},
+
+		priceOverrides:  map[stockKey]float64{},
+		scheduledPrices: map[int]models.ScheduledPrice{},
+
+		interactions: map[int]models.Interaction{},
+
+		suppliers:      map[int]models.Supplier{},
+		purchaseOrders: map[int]models.PurchaseOrder{},
+
+		stockLevels:   map[stockKey]models.StockLevel{},
+		replenishment: map[stockKey]models.ReplenishmentSuggestion{},
+
+		transfers:   map[int]models.Transfer{},
+		stockCounts: map[int]models.StockCount{},
+		recalls:     map[int]models.Recall{},
+		packs:       map[int]models.Pack{},
+
+		permissions: models.Permissions(),
+	}
+	for _, role := range models.DefaultRoles() {
+		sort.Strings(role.Permissions)
+		s.roles[role.Name] = role
+	}
+	return s
+}
+
+// newID выдаёт следующий идентификатор для сущности, как SERIAL в PostgreSQL
+func (s *Store) newID(entity string) int {
+	s.nextID[entity]++
+	return s.nextID[entity]
+}
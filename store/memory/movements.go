@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+func (s *Store) ListStockMovements(ctx context.Context, filter models.MovementFilter, page models.PageRequest) (models.Page[models.StockMovement], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[filter.PharmacyID]; !ok {
+		return models.Page[models.StockMovement]{}, store.ErrNotFound
+	}
+	movements := []models.StockMovement{}
+	for _, movement := range s.movements {
+		if movement.PharmacyID != filter.PharmacyID {
+			continue
+		}
+		if filter.MedicineID != 0 && movement.MedicineID != filter.MedicineID {
+			continue
+		}
+		if filter.Type != "" && movement.Type != filter.Type {
+			continue
+		}
+		if filter.From != nil && movement.CreatedAt.Before(*filter.From) {
+			continue
+		}
+		if filter.To != nil && !movement.CreatedAt.Before(*filter.To) {
+			continue
+		}
+		movement.MedicineName = s.medicines[movement.MedicineID].Name
+		if movement.LotID != nil {
+			movement.LotNumber = s.lots[*movement.LotID].LotNumber
+		}
+		movements = append(movements, movement)
+	}
+	return paginate(movements, page, map[string]sortKey[models.StockMovement]{
+		"id":         func(m models.StockMovement) interface{} { return m.ID },
+		"created_at": func(m models.StockMovement) interface{} { return m.CreatedAt },
+	}, func(m models.StockMovement) int { return m.ID })
+}
+
+func (s *Store) ListStockBalances(ctx context.Context, pharmacyID int, at time.Time) ([]models.StockBalance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[pharmacyID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	quantities := map[int]int{}
+	for _, movement := range s.movements {
+		if movement.PharmacyID == pharmacyID && !movement.CreatedAt.After(at) {
+			quantities[movement.MedicineID] += movement.Quantity
+		}
+	}
+	balances := []models.StockBalance{}
+	for medicineID, quantity := range quantities {
+		if quantity == 0 {
+			continue
+		}
+		balances = append(balances, models.StockBalance{
+			MedicineID:   medicineID,
+			MedicineName: s.medicines[medicineID].Name,
+			Quantity:     quantity,
+		})
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].MedicineName != balances[j].MedicineName {
+			return balances[i].MedicineName < balances[j].MedicineName
+		}
+		return balances[i].MedicineID < balances[j].MedicineID
+	})
+	return balances, nil
+}
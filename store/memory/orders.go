@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// cloneOrder копирует заказ вместе с позициями, чтобы вызывающий код не менял хранилище
+func cloneOrder(order models.Order) models.Order {
+	items := make([]models.OrderItem, len(order.Items))
+	for i, item := range order.Items {
+		item.Allocations = append([]models.LotAllocation(nil), item.Allocations...)
+		items[i] = item
+	}
+	order.Items = items
+	return order
+}
+
+// payOrder списывает товары заказа с рецептов и по FEFO от имени userID; вызывается под блокировкой.
+// При нехватке остатка уже выполненные списания откатываются.
+func (s *Store) payOrder(order *models.Order, userID *int) error {
+	prescriptions, err := s.dispensePrescriptions(*order)
+	if err != nil {
+		return err
+	}
+
+	stock, lots := s.snapshotStock()
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		allocations, err := s.consumeStock(order.PharmacyID, item.MedicineID, item.Quantity)
+		if err != nil {
+			s.stock, s.lots = stock, lots
+			return err
+		}
+		item.Allocations = nil
+		for _, allocation := range allocations {
+			if allocation.LotID != 0 {
+				item.Allocations = append(item.Allocations, allocation)
+			}
+		}
+	}
+
+	for id, prescription := range prescriptions {
+		s.prescriptions[id] = prescription
+	}
+	for _, item := range order.Items {
+		s.recordMovements(orderMovement(*order, item, models.MovementSale, -item.Quantity, userID), item.Allocations)
+	}
+
+	now := s.Now()
+	order.Status = models.OrderStatusPaid
+	order.PaidAt = &now
+	order.UpdatedAt = now
+	return nil
+}
+
+// orderMovement описывает движение остатка по позиции заказа
+func orderMovement(order models.Order, item models.OrderItem, movementType string, quantity int, userID *int) models.StockMovement {
+	orderID := order.ID
+	return models.StockMovement{
+		PharmacyID:    order.PharmacyID,
+		MedicineID:    item.MedicineID,
+		Type:          movementType,
+		Quantity:      quantity,
+		ReferenceType: models.MovementRefOrder,
+		ReferenceID:   &orderID,
+		UserID:        userID,
+	}
+}
+
+func (s *Store) CreateOrder(ctx context.Context, order *models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[order.PharmacyID]; !ok {
+		return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, order.PharmacyID)
+	}
+
+	// Фиксация действующих цен: переопределения в аптеке или цены каталога
+	order.Total = 0
+	for i := range order.Items {
+		item := &order.Items[i]
+		medicine, ok := s.medicines[item.MedicineID]
+		if !ok {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		if err := s.checkPrescription(*item); err != nil {
+			return err
+		}
+		item.MedicineName = medicine.Name
+		item.UnitPrice = s.effectivePrice(order.PharmacyID, medicine)
+		item.LineTotal = models.RoundMoney(item.UnitPrice * float64(item.Quantity))
+		item.Allocations = nil
+		order.Total = models.RoundMoney(order.Total + item.LineTotal)
+	}
+
+	paid := order.Status == models.OrderStatusPaid
+	order.Status = models.OrderStatusDraft
+	order.CreatedAt = s.Now()
+	order.UpdatedAt = order.CreatedAt
+	order.PaidAt = nil
+	// Как и последовательности PostgreSQL, идентификаторы не возвращаются, если оплата не прошла
+	order.ID = s.newID("orders")
+	for i := range order.Items {
+		order.Items[i].ID = s.newID("order_items")
+	}
+	if paid {
+		if err := s.payOrder(order, order.SellerID); err != nil {
+			return err
+		}
+	}
+	s.orders[order.ID] = cloneOrder(*order)
+	return nil
+}
+
+func (s *Store) GetOrder(ctx context.Context, id int) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[id]
+	if !ok {
+		return order, store.ErrNotFound
+	}
+	return cloneOrder(order), nil
+}
+
+func (s *Store) ListOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := []models.Order{}
+	for _, order := range s.orders {
+		if filter.PharmacyID != 0 && order.PharmacyID != filter.PharmacyID {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, cloneOrder(order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID > orders[j].ID
+	})
+	return orders, nil
+}
+
+func (s *Store) UpdateOrderStatus(ctx context.Context, id int, status string, userID *int) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.orders[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	order := cloneOrder(stored)
+	if !models.CanTransitionOrder(order.Status, status) {
+		return order, store.ErrInvalidTransition
+	}
+
+	switch status {
+	case models.OrderStatusPaid:
+		if err := s.payOrder(&order, userID); err != nil {
+			return stored, err
+		}
+	case models.OrderStatusRefunded:
+		// Возврат товара в те же партии аптеки и отпущенных единиц на рецепты
+		s.restorePrescriptions(order)
+		for _, item := range order.Items {
+			for _, allocation := range item.Allocations {
+				lot := s.lots[allocation.LotID]
+				lot.Quantity += allocation.Quantity
+				s.lots[allocation.LotID] = lot
+			}
+			s.stock[stockKey{order.PharmacyID, item.MedicineID}] += item.Quantity
+			s.recordMovements(orderMovement(order, item, models.MovementReturn, item.Quantity, userID), item.Allocations)
+		}
+		order.Status = status
+		order.UpdatedAt = s.Now()
+	default:
+		order.Status = status
+		order.UpdatedAt = s.Now()
+	}
+
+	s.orders[id] = cloneOrder(order)
+	return order, nil
+}
@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// clonePack копирует упаковку вместе с историей статусов
+func clonePack(pack models.Pack) models.Pack {
+	pack.Events = append([]models.PackEvent(nil), pack.Events...)
+	return pack
+}
+
+// appendPackEvent дописывает событие в историю упаковки; вызывается под блокировкой
+func (s *Store) appendPackEvent(pack *models.Pack, event models.PackEvent) {
+	event.ID = s.newID("pack_events")
+	event.PackID, event.PharmacyID = pack.ID, pack.PharmacyID
+	event.CreatedAt = pack.UpdatedAt
+	pack.Events = append(pack.Events, event)
+}
+
+// checkPackOrder проверяет, что по заказу можно продать упаковку; вызывается под блокировкой
+func (s *Store) checkPackOrder(pack models.Pack, orderID int) error {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return &store.NotFoundError{Field: "order_id", ID: orderID}
+	}
+	if order.Status != models.OrderStatusPaid {
+		return fmt.Errorf("%w: order %d is %s", store.ErrConflict, orderID, order.Status)
+	}
+	if order.PharmacyID != pack.PharmacyID {
+		return fmt.Errorf("%w: order %d belongs to another pharmacy", store.ErrConflict, orderID)
+	}
+
+	ordered, dispensed := 0, 0
+	for _, item := range order.Items {
+		if item.MedicineID == pack.MedicineID {
+			ordered += item.Quantity
+		}
+	}
+	for _, other := range s.packs {
+		if other.OrderID != nil && *other.OrderID == orderID && other.MedicineID == pack.MedicineID && other.Status == models.PackStatusDispensed {
+			dispensed++
+		}
+	}
+	if dispensed >= ordered {
+		return fmt.Errorf("%w: order %d has no more units of medicine %d to dispense as packs", store.ErrConflict, orderID, pack.MedicineID)
+	}
+	return nil
+}
+
+func (s *Store) ListPacks(ctx context.Context, filter models.PackFilter, page models.PageRequest) (models.Page[models.Pack], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packs := []models.Pack{}
+	for _, pack := range s.packs {
+		if filter.PharmacyID != 0 && pack.PharmacyID != filter.PharmacyID {
+			continue
+		}
+		if filter.MedicineID != 0 && pack.MedicineID != filter.MedicineID {
+			continue
+		}
+		if filter.Status != "" && pack.Status != filter.Status {
+			continue
+		}
+		if filter.GTIN != "" && pack.GTIN != filter.GTIN {
+			continue
+		}
+		if filter.Serial != "" && pack.Serial != filter.Serial {
+			continue
+		}
+		pack.MedicineName = s.medicines[pack.MedicineID].Name
+		pack.Events = nil
+		packs = append(packs, pack)
+	}
+	return paginate(packs, page, map[string]sortKey[models.Pack]{
+		"id":         func(p models.Pack) interface{} { return p.ID },
+		"updated_at": func(p models.Pack) interface{} { return p.UpdatedAt },
+	}, func(p models.Pack) int { return p.ID })
+}
+
+func (s *Store) GetPack(ctx context.Context, id int) (models.Pack, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pack, ok := s.packs[id]
+	if !ok {
+		return pack, store.ErrNotFound
+	}
+	pack = clonePack(pack)
+	pack.MedicineName = s.medicines[pack.MedicineID].Name
+	return pack, nil
+}
+
+func (s *Store) RegisterPacks(ctx context.Context, packs []models.Pack, userID *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Все проверки выполняются до изменений: упаковки регистрируются все или ни одной
+	registered := map[string]bool{}
+	for _, pack := range s.packs {
+		registered[pack.GTIN+"/"+pack.Serial] = true
+	}
+	medicineIDs := make([]int, len(packs))
+	for i, pack := range packs {
+		if _, ok := s.pharmacies[pack.PharmacyID]; !ok {
+			return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, pack.PharmacyID)
+		}
+		for _, medicine := range s.medicines {
+			if medicine.GTIN == pack.GTIN {
+				medicineIDs[i] = medicine.ID
+			}
+		}
+		if medicineIDs[i] == 0 {
+			return fmt.Errorf("%w: no medicine with GTIN %s", store.ErrNotFound, pack.GTIN)
+		}
+		key := pack.GTIN + "/" + pack.Serial
+		if registered[key] {
+			return fmt.Errorf("%w: pack %s is already registered", store.ErrConflict, key)
+		}
+		registered[key] = true
+	}
+
+	for i := range packs {
+		pack := &packs[i]
+		pack.ID = s.newID("packs")
+		pack.MedicineID = medicineIDs[i]
+		pack.MedicineName = s.medicines[pack.MedicineID].Name
+		pack.Status = models.PackStatusReceived
+		pack.OrderID = nil
+		pack.CreatedAt = s.Now()
+		pack.UpdatedAt = pack.CreatedAt
+		pack.Events = nil
+
+		stored := *pack
+		stored.MedicineName = ""
+		s.appendPackEvent(&stored, models.PackEvent{ToStatus: pack.Status, UserID: userID})
+		s.packs[pack.ID] = stored
+	}
+	return nil
+}
+
+func (s *Store) UpdatePackStatus(ctx context.Context, id int, change models.PackStatusChange) (models.Pack, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.packs[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	pack := clonePack(stored)
+	if !models.CanTransitionPack(pack.Status, change.Status) {
+		return pack, store.ErrInvalidTransition
+	}
+	if change.OrderID != nil {
+		if err := s.checkPackOrder(pack, *change.OrderID); err != nil {
+			return pack, err
+		}
+	}
+
+	event := models.PackEvent{
+		FromStatus: pack.Status,
+		ToStatus:   change.Status,
+		OrderID:    change.OrderID,
+		Reason:     change.Reason,
+		UserID:     change.UserID,
+	}
+	// Заказ хранится у упаковки, только пока она продана
+	pack.Status, pack.OrderID = change.Status, change.OrderID
+	pack.UpdatedAt = s.Now()
+	s.appendPackEvent(&pack, event)
+	s.packs[id] = clonePack(pack)
+
+	pack.MedicineName = s.medicines[pack.MedicineID].Name
+	return pack, nil
+}
+
+func (s *Store) ListDispensingEvents(ctx context.Context, filter models.DispensingFilter) ([]models.DispensingEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[filter.PharmacyID]; filter.PharmacyID != 0 && !ok {
+		return nil, store.ErrNotFound
+	}
+	type dispensing struct {
+		event models.DispensingEvent
+		at    time.Time
+	}
+	var found []dispensing
+	for _, pack := range s.packs {
+		for _, event := range pack.Events {
+			if event.ToStatus != models.PackStatusDispensed {
+				continue
+			}
+			if filter.PharmacyID != 0 && event.PharmacyID != filter.PharmacyID {
+				continue
+			}
+			if filter.From != nil && event.CreatedAt.Before(*filter.From) {
+				continue
+			}
+			if filter.To != nil && !event.CreatedAt.Before(*filter.To) {
+				continue
+			}
+			found = append(found, dispensing{at: event.CreatedAt, event: models.DispensingEvent{
+				ID:          event.ID,
+				DispensedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+				PharmacyID:  event.PharmacyID,
+				GTIN:        pack.GTIN,
+				Serial:      pack.Serial,
+				LotNumber:   pack.LotNumber,
+				ExpiryDate:  pack.ExpiryDate,
+				OrderID:     event.OrderID,
+			}})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].at.Equal(found[j].at) {
+			return found[i].at.Before(found[j].at)
+		}
+		return found[i].event.ID < found[j].event.ID
+	})
+
+	events := make([]models.DispensingEvent, 0, len(found))
+	for _, d := range found {
+		events = append(events, d.event)
+	}
+	return events, nil
+}
@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pharmacy-test/models"
+)
+
+// sortKey возвращает значение поля сортировки: int, float64, string или time.Time
+type sortKey[T any] func(item T) interface{}
+
+// paginate сортирует отфильтрованные записи по ключу и id, пропускает записи до курсора
+// и возвращает страницу — так же, как keyset-выборка в PostgreSQL
+func paginate[T any](items []T, page models.PageRequest, keys map[string]sortKey[T], id func(T) int) (models.Page[T], error) {
+	result := models.Page[T]{Items: []T{}, Total: len(items)}
+	key, ok := keys[page.Sort]
+	if !ok {
+		return result, fmt.Errorf("unknown sort field %q", page.Sort)
+	}
+
+	// less сравнивает пару (ключ, id) в направлении сортировки
+	less := func(keyA interface{}, idA int, keyB interface{}, idB int) bool {
+		c := compareKeys(keyA, keyB)
+		if c == 0 {
+			c = idA - idB
+		}
+		if page.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(items, func(i, j int) bool { return less(key(items[i]), id(items[i]), key(items[j]), id(items[j])) })
+
+	start := 0
+	if page.After != nil && len(items) > 0 {
+		after, err := parseKey(key(items[0]), page.After.Value)
+		if err != nil {
+			return result, err
+		}
+		for start < len(items) && !less(after, page.After.ID, key(items[start]), id(items[start])) {
+			start++
+		}
+	}
+
+	end := start + page.Limit
+	if end < len(items) {
+		last := items[end-1]
+		result.NextCursor = page.NextCursor(formatKey(key(last)), id(last))
+	} else {
+		end = len(items)
+	}
+	result.Items = append(result.Items, items[start:end]...)
+	return result, nil
+}
+
+func compareKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return a - b.(int)
+	case float64:
+		switch bf := b.(float64); {
+		case a < bf:
+			return -1
+		case a > bf:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+func formatKey(key interface{}) string {
+	switch key := key.(type) {
+	case int:
+		return strconv.Itoa(key)
+	case float64:
+		return strconv.FormatFloat(key, 'f', -1, 64)
+	case time.Time:
+		return key.Format(time.RFC3339Nano)
+	default:
+		return key.(string)
+	}
+}
+
+// parseKey разбирает значение курсора в тип, которым является sample
+func parseKey(sample interface{}, value string) (interface{}, error) {
+	var parsed interface{}
+	var err error
+	switch sample.(type) {
+	case int:
+		parsed, err = strconv.Atoi(value)
+	case float64:
+		parsed, err = strconv.ParseFloat(value, 64)
+	case time.Time:
+		parsed, err = time.Parse(time.RFC3339Nano, value)
+	default:
+		parsed = value
+	}
+	if err != nil {
+		return nil, models.ErrInvalidCursor
+	}
+	return parsed, nil
+}
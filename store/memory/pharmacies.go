@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+func (s *Store) ListPharmacies(ctx context.Context, filter models.PharmacyFilter, page models.PageRequest) (models.Page[models.Pharmacy], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pharmacies := []models.Pharmacy{}
+	for _, pharmacy := range s.pharmacies {
+		if filter.City != "" && !strings.EqualFold(pharmacy.Address.City, filter.City) {
+			continue
+		}
+		if filter.Country != "" && !strings.EqualFold(pharmacy.Address.Country, filter.Country) {
+			continue
+		}
+		if filter.OpenAt != nil && !pharmacy.IsOpenAt(*filter.OpenAt) {
+			continue
+		}
+		pharmacies = append(pharmacies, pharmacy)
+	}
+	return paginate(pharmacies, page, map[string]sortKey[models.Pharmacy]{
+		"id":   func(p models.Pharmacy) interface{} { return p.ID },
+		"name": func(p models.Pharmacy) interface{} { return p.Name },
+		"city": func(p models.Pharmacy) interface{} { return p.Address.City },
+	}, func(p models.Pharmacy) int { return p.ID })
+}
+
+func (s *Store) GetPharmacy(ctx context.Context, id int) (models.Pharmacy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pharmacy, ok := s.pharmacies[id]
+	if !ok {
+		return pharmacy, store.ErrNotFound
+	}
+	return pharmacy, nil
+}
+
+func (s *Store) CreatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pharmacy.ID = s.newID("pharmacies")
+	pharmacy.Address.ID = s.newID("addresses")
+	s.pharmacies[pharmacy.ID] = *pharmacy
+	return nil
+}
+
+func (s *Store) UpdatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.pharmacies[pharmacy.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	pharmacy.Address.ID = existing.Address.ID
+	s.pharmacies[pharmacy.ID] = *pharmacy
+	return nil
+}
+
+func (s *Store) DeletePharmacy(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[id]; !ok {
+		return store.ErrNotFound
+	}
+	for _, order := range s.orders {
+		if order.PharmacyID == id {
+			return store.ErrConflict
+		}
+	}
+	for _, order := range s.purchaseOrders {
+		if order.PharmacyID == id {
+			return store.ErrConflict
+		}
+	}
+	for _, transfer := range s.transfers {
+		if transfer.FromPharmacyID == id || transfer.ToPharmacyID == id {
+			return store.ErrConflict
+		}
+	}
+	// Как и внешний ключ stock_movements, аптеку с историей движений удалять нельзя
+	for _, movement := range s.movements {
+		if movement.PharmacyID == id {
+			return store.ErrConflict
+		}
+	}
+	for _, recall := range s.recalls {
+		for _, action := range recall.Actions {
+			if action.PharmacyID == id {
+				return store.ErrConflict
+			}
+		}
+	}
+	for _, pack := range s.packs {
+		if pack.PharmacyID == id {
+			return store.ErrConflict
+		}
+	}
+
+	delete(s.pharmacies, id)
+	for key := range s.stock {
+		if key.pharmacyID == id {
+			delete(s.stock, key)
+		}
+	}
+	for lotID, lot := range s.lots {
+		if lot.PharmacyID == id {
+			delete(s.lots, lotID)
+		}
+	}
+	for key := range s.stockLevels {
+		if key.pharmacyID == id {
+			delete(s.stockLevels, key)
+			delete(s.replenishment, key)
+		}
+	}
+	for countID, count := range s.stockCounts {
+		if count.PharmacyID == id {
+			delete(s.stockCounts, countID)
+		}
+	}
+	s.deletePrices(func(medicineID int, pharmacyID *int) bool { return pharmacyID != nil && *pharmacyID == id })
+	return nil
+}
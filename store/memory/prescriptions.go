@@ -0,0 +1,193 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// clonePrescription копирует рецепт вместе с позициями и отпусками
+func clonePrescription(prescription models.Prescription) models.Prescription {
+	items := make([]models.PrescriptionItem, len(prescription.Items))
+	for i, item := range prescription.Items {
+		item.Dispensings = append([]models.PrescriptionDispensing(nil), item.Dispensings...)
+		items[i] = item
+	}
+	prescription.Items = items
+	return prescription
+}
+
+// checkPrescription проверяет, что рецептурная позиция заказа ссылается на рецепт с этим лекарством;
+// вызывается под блокировкой
+func (s *Store) checkPrescription(item models.OrderItem) error {
+	if item.PrescriptionID == nil {
+		if s.medicines[item.MedicineID].RxRequired {
+			return fmt.Errorf("%w: medicine %d", store.ErrPrescriptionRequired, item.MedicineID)
+		}
+		return nil
+	}
+	prescription, ok := s.prescriptions[*item.PrescriptionID]
+	if !ok {
+		return fmt.Errorf("%w: prescription %d", store.ErrNotFound, *item.PrescriptionID)
+	}
+	if prescription.Item(item.MedicineID) == nil {
+		return fmt.Errorf("%w: prescription %d does not include medicine %d", store.ErrPrescriptionInvalid, prescription.ID, item.MedicineID)
+	}
+	return nil
+}
+
+// dispensePrescriptions списывает позиции заказа с рецептов на копиях; вызывается под блокировкой.
+// Изменённые рецепты сохраняются вызывающим кодом, только если оплата прошла целиком
+func (s *Store) dispensePrescriptions(order models.Order) (map[int]models.Prescription, error) {
+	changed := map[int]models.Prescription{}
+	now := s.Now()
+	for _, item := range order.Items {
+		if item.PrescriptionID == nil {
+			continue
+		}
+		prescription, ok := changed[*item.PrescriptionID]
+		if !ok {
+			stored, found := s.prescriptions[*item.PrescriptionID]
+			if !found {
+				return nil, fmt.Errorf("%w: prescription %d", store.ErrNotFound, *item.PrescriptionID)
+			}
+			prescription = clonePrescription(stored)
+		}
+
+		if status := prescription.StatusAt(now); status != models.PrescriptionActive {
+			return nil, fmt.Errorf("%w: prescription %d is %s", store.ErrPrescriptionInvalid, prescription.ID, status)
+		}
+		prescribed := prescription.Item(item.MedicineID)
+		if prescribed == nil {
+			return nil, fmt.Errorf("%w: prescription %d does not include medicine %d", store.ErrPrescriptionInvalid, prescription.ID, item.MedicineID)
+		}
+		fill := prescribed.CurrentFill()
+		if !prescribed.Dispense(item.Quantity) {
+			return nil, fmt.Errorf("%w: prescription %d allows at most %d units of medicine %d now",
+				store.ErrPrescriptionInvalid, prescription.ID, prescribed.RemainingQuantity, item.MedicineID)
+		}
+		prescribed.Dispensings = append(prescribed.Dispensings, models.PrescriptionDispensing{
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			Quantity:    item.Quantity,
+			Fill:        fill,
+			DispensedAt: now,
+		})
+		changed[prescription.ID] = prescription
+	}
+	return changed, nil
+}
+
+// restorePrescriptions возвращает на рецепты единицы, отпущенные позициями возвращаемого заказа,
+// и отмечает отпуски возвращёнными; вызывается под блокировкой
+func (s *Store) restorePrescriptions(order models.Order) {
+	now := s.Now()
+	for _, item := range order.Items {
+		if item.PrescriptionID == nil {
+			continue
+		}
+		stored, ok := s.prescriptions[*item.PrescriptionID]
+		if !ok {
+			continue
+		}
+		prescription := clonePrescription(stored)
+		prescribed := prescription.Item(item.MedicineID)
+		if prescribed == nil {
+			continue
+		}
+		for i := range prescribed.Dispensings {
+			dispensing := &prescribed.Dispensings[i]
+			if dispensing.OrderItemID != item.ID || dispensing.RefundedAt != nil {
+				continue
+			}
+			refundedAt := now
+			dispensing.RefundedAt = &refundedAt
+			prescribed.Restore(dispensing.Quantity)
+		}
+		s.prescriptions[prescription.ID] = prescription
+	}
+}
+
+func (s *Store) ListPrescriptions(ctx context.Context, filter models.PrescriptionFilter) ([]models.Prescription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescriptions := []models.Prescription{}
+	for _, prescription := range s.prescriptions {
+		if filter.Number != "" && prescription.Number != filter.Number {
+			continue
+		}
+		if filter.Patient != "" && !strings.Contains(strings.ToLower(prescription.PatientName), strings.ToLower(filter.Patient)) {
+			continue
+		}
+		prescriptions = append(prescriptions, clonePrescription(prescription))
+	}
+	sort.Slice(prescriptions, func(i, j int) bool {
+		if !prescriptions[i].CreatedAt.Equal(prescriptions[j].CreatedAt) {
+			return prescriptions[i].CreatedAt.After(prescriptions[j].CreatedAt)
+		}
+		return prescriptions[i].ID > prescriptions[j].ID
+	})
+	return prescriptions, nil
+}
+
+func (s *Store) GetPrescription(ctx context.Context, id int) (models.Prescription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, ok := s.prescriptions[id]
+	if !ok {
+		return prescription, store.ErrNotFound
+	}
+	return clonePrescription(prescription), nil
+}
+
+func (s *Store) CreatePrescription(ctx context.Context, prescription *models.Prescription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range prescription.Items {
+		item := &prescription.Items[i]
+		medicine, ok := s.medicines[item.MedicineID]
+		if !ok {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		item.MedicineName = medicine.Name
+	}
+	for _, existing := range s.prescriptions {
+		if existing.Number == prescription.Number {
+			return &store.ConflictError{Field: "number"}
+		}
+	}
+
+	prescription.ID = s.newID("prescriptions")
+	prescription.CreatedAt = s.Now()
+	prescription.CancelledAt = nil
+	for i := range prescription.Items {
+		prescription.Items[i].ID = s.newID("prescription_items")
+		prescription.Items[i].Dispensings = nil
+	}
+	s.prescriptions[prescription.ID] = clonePrescription(*prescription)
+	return nil
+}
+
+func (s *Store) CancelPrescription(ctx context.Context, id int) (models.Prescription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prescription, ok := s.prescriptions[id]
+	if !ok {
+		return prescription, store.ErrNotFound
+	}
+	if prescription.CancelledAt != nil {
+		return prescription, fmt.Errorf("%w: prescription is already cancelled", store.ErrInvalidTransition)
+	}
+	now := s.Now()
+	prescription.CancelledAt = &now
+	s.prescriptions[id] = prescription
+	return clonePrescription(prescription), nil
+}
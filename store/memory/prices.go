@@ -0,0 +1,207 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// recordPrice добавляет запись в историю цен; вызывается под блокировкой
+func (s *Store) recordPrice(change *models.PriceChange) {
+	change.ID = s.newID("medicine_price_history")
+	change.ChangedAt = s.Now()
+	s.priceHistory = append(s.priceHistory, *change)
+}
+
+// changePrice меняет цену каталога или переопределение и записывает историю; вызывается под блокировкой
+func (s *Store) changePrice(change *models.PriceChange) error {
+	medicine, ok := s.medicines[change.MedicineID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if change.PharmacyID == nil {
+		old := medicine.Price
+		change.OldPrice = &old
+		medicine.Price = *change.NewPrice
+		s.medicines[medicine.ID] = medicine
+	} else {
+		if _, ok := s.pharmacies[*change.PharmacyID]; !ok {
+			return &store.NotFoundError{Field: "pharmacy_id", ID: *change.PharmacyID}
+		}
+		key := stockKey{*change.PharmacyID, change.MedicineID}
+		change.OldPrice = nil
+		if old, ok := s.priceOverrides[key]; ok {
+			change.OldPrice = &old
+		}
+		if change.NewPrice == nil {
+			delete(s.priceOverrides, key)
+		} else {
+			s.priceOverrides[key] = *change.NewPrice
+		}
+	}
+	s.recordPrice(change)
+	return nil
+}
+
+// effectivePrice возвращает цену лекарства в аптеке с учётом переопределения; вызывается под блокировкой
+func (s *Store) effectivePrice(pharmacyID int, medicine models.Medicine) float64 {
+	if price, ok := s.priceOverrides[stockKey{pharmacyID, medicine.ID}]; ok {
+		return price
+	}
+	return medicine.Price
+}
+
+func (s *Store) GetMedicinePrices(ctx context.Context, medicineID int) (models.MedicinePrices, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	medicine, ok := s.medicines[medicineID]
+	if !ok {
+		return models.MedicinePrices{}, store.ErrNotFound
+	}
+	prices := models.MedicinePrices{
+		MedicineID:   medicineID,
+		CatalogPrice: medicine.Price,
+		Overrides:    []models.PriceOverride{},
+		Scheduled:    []models.ScheduledPrice{},
+		History:      []models.PriceChange{},
+	}
+	for key, price := range s.priceOverrides {
+		if key.medicineID == medicineID {
+			prices.Overrides = append(prices.Overrides, models.PriceOverride{
+				PharmacyID:   key.pharmacyID,
+				PharmacyName: s.pharmacies[key.pharmacyID].Name,
+				Price:        price,
+			})
+		}
+	}
+	sort.Slice(prices.Overrides, func(i, j int) bool { return prices.Overrides[i].PharmacyID < prices.Overrides[j].PharmacyID })
+
+	for _, price := range s.scheduledPrices {
+		if price.MedicineID == medicineID && price.Status == models.ScheduledPricePending {
+			prices.Scheduled = append(prices.Scheduled, price)
+		}
+	}
+	sortScheduledPrices(prices.Scheduled)
+
+	for i := len(s.priceHistory) - 1; i >= 0; i-- {
+		if s.priceHistory[i].MedicineID == medicineID {
+			prices.History = append(prices.History, s.priceHistory[i])
+		}
+	}
+	return prices, nil
+}
+
+// sortScheduledPrices упорядочивает изменения по сроку вступления в силу
+func sortScheduledPrices(prices []models.ScheduledPrice) {
+	sort.Slice(prices, func(i, j int) bool {
+		if !prices[i].EffectiveAt.Equal(prices[j].EffectiveAt) {
+			return prices[i].EffectiveAt.Before(prices[j].EffectiveAt)
+		}
+		return prices[i].ID < prices[j].ID
+	})
+}
+
+func (s *Store) ChangePrice(ctx context.Context, change *models.PriceChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changePrice(change)
+}
+
+func (s *Store) SchedulePrice(ctx context.Context, price *models.ScheduledPrice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.medicines[price.MedicineID]; !ok {
+		return store.ErrNotFound
+	}
+	if price.PharmacyID != nil {
+		if _, ok := s.pharmacies[*price.PharmacyID]; !ok {
+			return &store.NotFoundError{Field: "pharmacy_id", ID: *price.PharmacyID}
+		}
+	}
+
+	price.ID = s.newID("medicine_scheduled_prices")
+	price.Status = models.ScheduledPricePending
+	price.CreatedAt = s.Now()
+	price.AppliedAt, price.CancelledAt = nil, nil
+	s.scheduledPrices[price.ID] = *price
+	return nil
+}
+
+func (s *Store) CancelScheduledPrice(ctx context.Context, medicineID, id int) (models.ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	price, ok := s.scheduledPrices[id]
+	if !ok || price.MedicineID != medicineID {
+		return price, store.ErrNotFound
+	}
+	if price.Status != models.ScheduledPricePending {
+		return price, fmt.Errorf("%w: scheduled price is already %s", store.ErrInvalidTransition, price.Status)
+	}
+	now := s.Now()
+	price.Status = models.ScheduledPriceCancelled
+	price.CancelledAt = &now
+	s.scheduledPrices[id] = price
+	return price, nil
+}
+
+func (s *Store) ApplyDuePrices(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	var due []models.ScheduledPrice
+	for _, price := range s.scheduledPrices {
+		if price.Status == models.ScheduledPricePending && !price.EffectiveAt.After(now) {
+			due = append(due, price)
+		}
+	}
+	sortScheduledPrices(due)
+
+	for _, price := range due {
+		newPrice, scheduledID := price.Price, price.ID
+		change := models.PriceChange{
+			MedicineID:       price.MedicineID,
+			PharmacyID:       price.PharmacyID,
+			NewPrice:         &newPrice,
+			ChangedBy:        price.CreatedBy,
+			ScheduledPriceID: &scheduledID,
+		}
+		if err := s.changePrice(&change); err != nil {
+			return 0, err
+		}
+		price.Status = models.ScheduledPriceApplied
+		price.AppliedAt = &now
+		s.scheduledPrices[price.ID] = price
+	}
+	return len(due), nil
+}
+
+// deletePrices удаляет цены, запланированные изменения и историю, подходящие под условие,
+// как каскадное удаление в PostgreSQL; вызывается под блокировкой
+func (s *Store) deletePrices(match func(medicineID int, pharmacyID *int) bool) {
+	for key := range s.priceOverrides {
+		pharmacyID := key.pharmacyID
+		if match(key.medicineID, &pharmacyID) {
+			delete(s.priceOverrides, key)
+		}
+	}
+	for id, price := range s.scheduledPrices {
+		if match(price.MedicineID, price.PharmacyID) {
+			delete(s.scheduledPrices, id)
+		}
+	}
+	history := s.priceHistory[:0]
+	for _, change := range s.priceHistory {
+		if !match(change.MedicineID, change.PharmacyID) {
+			history = append(history, change)
+		}
+	}
+	s.priceHistory = history
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// clonePurchaseOrder копирует заказ поставщику вместе с позициями и приёмками
+func clonePurchaseOrder(order models.PurchaseOrder) models.PurchaseOrder {
+	order.Items = append([]models.PurchaseOrderItem(nil), order.Items...)
+	receipts := make([]models.GoodsReceipt, len(order.Receipts))
+	for i, receipt := range order.Receipts {
+		receipt.Items = append([]models.GoodsReceiptItem(nil), receipt.Items...)
+		receipts[i] = receipt
+	}
+	order.Receipts = receipts
+	return order
+}
+
+func (s *Store) ListSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	suppliers := make([]models.Supplier, 0, len(s.suppliers))
+	for _, supplier := range s.suppliers {
+		suppliers = append(suppliers, supplier)
+	}
+	sort.Slice(suppliers, func(i, j int) bool {
+		if suppliers[i].Name != suppliers[j].Name {
+			return suppliers[i].Name < suppliers[j].Name
+		}
+		return suppliers[i].ID < suppliers[j].ID
+	})
+	return suppliers, nil
+}
+
+func (s *Store) GetSupplier(ctx context.Context, id int) (models.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	supplier, ok := s.suppliers[id]
+	if !ok {
+		return supplier, store.ErrNotFound
+	}
+	return supplier, nil
+}
+
+// supplierNameTaken проверяет, занято ли название другим поставщиком; вызывается под блокировкой
+func (s *Store) supplierNameTaken(name string, id int) bool {
+	for _, existing := range s.suppliers {
+		if existing.ID != id && existing.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.supplierNameTaken(supplier.Name, 0) {
+		return &store.ConflictError{Field: "name"}
+	}
+	supplier.ID = s.newID("suppliers")
+	supplier.CreatedAt = s.Now()
+	s.suppliers[supplier.ID] = *supplier
+	return nil
+}
+
+func (s *Store) UpdateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.suppliers[supplier.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if s.supplierNameTaken(supplier.Name, supplier.ID) {
+		return &store.ConflictError{Field: "name"}
+	}
+	supplier.CreatedAt = existing.CreatedAt
+	s.suppliers[supplier.ID] = *supplier
+	return nil
+}
+
+func (s *Store) DeleteSupplier(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.suppliers[id]; !ok {
+		return store.ErrNotFound
+	}
+	// Как и внешний ключ purchase_orders, поставщика с заказами удалять нельзя
+	for _, order := range s.purchaseOrders {
+		if order.SupplierID == id {
+			return store.ErrConflict
+		}
+	}
+	delete(s.suppliers, id)
+	// Как ON DELETE SET NULL: у порогов и предложений пропадает поставщик по умолчанию
+	for key, level := range s.stockLevels {
+		if level.SupplierID != nil && *level.SupplierID == id {
+			level.SupplierID = nil
+			s.stockLevels[key] = level
+		}
+	}
+	for key, suggestion := range s.replenishment {
+		if suggestion.SupplierID != nil && *suggestion.SupplierID == id {
+			suggestion.SupplierID = nil
+			s.replenishment[key] = suggestion
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orders := []models.PurchaseOrder{}
+	for _, order := range s.purchaseOrders {
+		if filter.PharmacyID != 0 && order.PharmacyID != filter.PharmacyID {
+			continue
+		}
+		if filter.SupplierID != 0 && order.SupplierID != filter.SupplierID {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		// Приёмки в список не входят, как и в PostgreSQL
+		order = clonePurchaseOrder(order)
+		order.Receipts = nil
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID > orders[j].ID
+	})
+	return orders, nil
+}
+
+func (s *Store) GetPurchaseOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.purchaseOrders[id]
+	if !ok {
+		return order, store.ErrNotFound
+	}
+	return clonePurchaseOrder(order), nil
+}
+
+func (s *Store) CreatePurchaseOrder(ctx context.Context, order *models.PurchaseOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createPurchaseOrder(order)
+}
+
+// createPurchaseOrder сохраняет заказ поставщику; вызывается под блокировкой
+func (s *Store) createPurchaseOrder(order *models.PurchaseOrder) error {
+	if _, ok := s.pharmacies[order.PharmacyID]; !ok {
+		return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, order.PharmacyID)
+	}
+	supplier, ok := s.suppliers[order.SupplierID]
+	if !ok {
+		return fmt.Errorf("%w: supplier %d", store.ErrNotFound, order.SupplierID)
+	}
+
+	order.Total = 0
+	for i := range order.Items {
+		item := &order.Items[i]
+		medicine, ok := s.medicines[item.MedicineID]
+		if !ok {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		item.MedicineName = medicine.Name
+		item.ReceivedQuantity = 0
+		item.LineTotal = models.RoundMoney(item.UnitCost * float64(item.Quantity))
+		order.Total = models.RoundMoney(order.Total + item.LineTotal)
+	}
+
+	order.ID = s.newID("purchase_orders")
+	order.SupplierName = supplier.Name
+	order.CreatedAt = s.Now()
+	order.UpdatedAt = order.CreatedAt
+	order.SentAt = nil
+	if order.Status == models.PurchaseStatusSent {
+		order.SentAt = &order.CreatedAt
+	}
+	order.Receipts = nil
+	for i := range order.Items {
+		order.Items[i].ID = s.newID("purchase_order_items")
+	}
+	s.purchaseOrders[order.ID] = clonePurchaseOrder(*order)
+	return nil
+}
+
+func (s *Store) UpdatePurchaseOrderStatus(ctx context.Context, id int, status string) (models.PurchaseOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.purchaseOrders[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	order := clonePurchaseOrder(stored)
+	if !models.CanTransitionPurchase(order.Status, status) {
+		return order, store.ErrInvalidTransition
+	}
+
+	order.Status = status
+	order.UpdatedAt = s.Now()
+	if status == models.PurchaseStatusSent {
+		order.SentAt = &order.UpdatedAt
+	}
+	s.purchaseOrders[id] = clonePurchaseOrder(order)
+	return order, nil
+}
+
+func (s *Store) ReceiveGoods(ctx context.Context, receipt *models.GoodsReceipt) (models.PurchaseOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.purchaseOrders[receipt.PurchaseOrderID]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	order := clonePurchaseOrder(stored)
+	if order.Status != models.PurchaseStatusSent && order.Status != models.PurchaseStatusPartiallyReceived {
+		return stored, fmt.Errorf("%w: purchase order is %s", store.ErrInvalidTransition, order.Status)
+	}
+	for _, received := range receipt.Items {
+		item := order.Item(received.MedicineID)
+		if item == nil {
+			return stored, fmt.Errorf("%w: purchase order %d does not include medicine %d", store.ErrConflict, order.ID, received.MedicineID)
+		}
+		if received.Quantity > item.Remaining() {
+			return stored, fmt.Errorf("%w: only %d units of medicine %d remain to be received", store.ErrConflict, item.Remaining(), item.MedicineID)
+		}
+		item.ReceivedQuantity += received.Quantity
+	}
+
+	// Оприходование партий; при конфликте серии уже принятые партии откатываются
+	stock, lots := s.snapshotStock()
+	for i := range receipt.Items {
+		received := &receipt.Items[i]
+		lot := models.Lot{
+			MedicineID:     received.MedicineID,
+			PharmacyID:     order.PharmacyID,
+			LotNumber:      received.LotNumber,
+			ProductionDate: received.ProductionDate,
+			ExpiryDate:     received.ExpiryDate,
+			Quantity:       received.Quantity,
+		}
+		if err := s.receiveLot(&lot); err != nil {
+			s.stock, s.lots = stock, lots
+			return stored, fmt.Errorf("%w: lot %s already exists with a different expiry date", err, received.LotNumber)
+		}
+		received.LotID = lot.ID
+	}
+
+	receipt.ID = s.newID("goods_receipts")
+	receipt.ReceivedAt = s.Now()
+	for _, received := range receipt.Items {
+		s.recordLotMovement(models.StockMovement{
+			Type:          models.MovementReceipt,
+			Quantity:      received.Quantity,
+			ReferenceType: models.MovementRefGoodsReceipt,
+			ReferenceID:   &receipt.ID,
+			UserID:        receipt.ReceivedBy,
+		}, s.lots[received.LotID])
+	}
+	order.Status = order.ReceivedStatus()
+	order.UpdatedAt = receipt.ReceivedAt
+	order.Receipts = append(order.Receipts, *receipt)
+	s.purchaseOrders[order.ID] = clonePurchaseOrder(order)
+	return order, nil
+}
@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// cloneRecall копирует отзыв вместе с сериями и действиями
+func cloneRecall(recall models.Recall) models.Recall {
+	recall.Items = append([]models.RecallItem{}, recall.Items...)
+	recall.Actions = append([]models.RecallAction(nil), recall.Actions...)
+	return recall
+}
+
+// isRecalled сообщает, входит ли серия лекарства в неотменённый отзыв; вызывается под блокировкой
+func (s *Store) isRecalled(medicineID int, lotNumber string) bool {
+	for _, recall := range s.recalls {
+		if recall.Blocks() && recall.Covers(medicineID, lotNumber) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) ListRecalls(ctx context.Context, filter models.RecallFilter) ([]models.Recall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recalls := []models.Recall{}
+	for _, recall := range s.recalls {
+		if filter.Status != "" && recall.Status != filter.Status {
+			continue
+		}
+		if filter.MedicineID != 0 {
+			found := false
+			for _, item := range recall.Items {
+				found = found || item.MedicineID == filter.MedicineID
+			}
+			if !found {
+				continue
+			}
+		}
+		// Действия в список не входят, как и в PostgreSQL
+		recall = cloneRecall(recall)
+		recall.Actions = nil
+		recalls = append(recalls, recall)
+	}
+	sort.Slice(recalls, func(i, j int) bool {
+		if recalls[i].RecallDate != recalls[j].RecallDate {
+			return recalls[i].RecallDate > recalls[j].RecallDate
+		}
+		return recalls[i].ID > recalls[j].ID
+	})
+	return recalls, nil
+}
+
+func (s *Store) GetRecall(ctx context.Context, id int) (models.Recall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recall, ok := s.recalls[id]
+	if !ok {
+		return recall, store.ErrNotFound
+	}
+	return cloneRecall(recall), nil
+}
+
+func (s *Store) CreateRecall(ctx context.Context, recall *models.Recall) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range recall.Items {
+		item := &recall.Items[i]
+		medicine, ok := s.medicines[item.MedicineID]
+		if !ok {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		item.MedicineName = medicine.Name
+	}
+	sort.Slice(recall.Items, func(i, j int) bool {
+		a, b := recall.Items[i], recall.Items[j]
+		if a.MedicineName != b.MedicineName {
+			return a.MedicineName < b.MedicineName
+		}
+		if a.MedicineID != b.MedicineID {
+			return a.MedicineID < b.MedicineID
+		}
+		return a.LotNumber < b.LotNumber
+	})
+
+	recall.ID = s.newID("recalls")
+	recall.Status = models.RecallStatusActive
+	recall.CreatedAt = s.Now()
+	recall.UpdatedAt = recall.CreatedAt
+	recall.ClosedAt = nil
+	recall.Actions = nil
+	s.recalls[recall.ID] = cloneRecall(*recall)
+	return nil
+}
+
+func (s *Store) UpdateRecallStatus(ctx context.Context, id int, status string) (models.Recall, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.recalls[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	recall := cloneRecall(stored)
+	if !models.CanTransitionRecall(recall.Status, status) {
+		return recall, store.ErrInvalidTransition
+	}
+
+	now := s.Now()
+	recall.Status = status
+	recall.UpdatedAt = now
+	recall.ClosedAt = &now
+	s.recalls[id] = cloneRecall(recall)
+	return recall, nil
+}
+
+func (s *Store) RecordRecallAction(ctx context.Context, action *models.RecallAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recall, ok := s.recalls[action.RecallID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if recall.Status != models.RecallStatusActive {
+		return fmt.Errorf("%w: recall is %s", store.ErrInvalidTransition, recall.Status)
+	}
+	lot, ok := s.lots[action.LotID]
+	if !ok {
+		return fmt.Errorf("%w: lot %d", store.ErrNotFound, action.LotID)
+	}
+	if !recall.Covers(lot.MedicineID, lot.LotNumber) {
+		return fmt.Errorf("%w: lot %s of medicine %d is not part of recall %d", store.ErrConflict, lot.LotNumber, lot.MedicineID, recall.ID)
+	}
+
+	quarantined, _ := recall.LotActions(lot.ID)
+	switch action.Action {
+	case models.RecallActionQuarantine:
+		if quarantined+action.Quantity > lot.Quantity {
+			return fmt.Errorf("%w: only %d units of lot %s are not yet quarantined", store.ErrConflict, lot.Quantity-quarantined, lot.LotNumber)
+		}
+	case models.RecallActionReturn:
+		if action.Quantity > quarantined {
+			return fmt.Errorf("%w: only %d units of lot %s are in quarantine", store.ErrConflict, quarantined, lot.LotNumber)
+		}
+		if action.Quantity > lot.Quantity {
+			return fmt.Errorf("%w: only %d units of lot %s are left", store.ErrConflict, lot.Quantity, lot.LotNumber)
+		}
+	}
+
+	action.ID = s.newID("recall_actions")
+	action.PharmacyID, action.MedicineID, action.LotNumber = lot.PharmacyID, lot.MedicineID, lot.LotNumber
+	action.CreatedAt = s.Now()
+	recall = cloneRecall(recall)
+	recall.Actions = append(recall.Actions, *action)
+	s.recalls[recall.ID] = recall
+
+	if action.Action == models.RecallActionReturn {
+		// Возвращённые единицы уходят из партии и остатка аптеки
+		lot.Quantity -= action.Quantity
+		s.lots[lot.ID] = lot
+		s.stock[stockKey{lot.PharmacyID, lot.MedicineID}] -= action.Quantity
+		recallID := recall.ID
+		s.recordLotMovement(models.StockMovement{
+			Type:          models.MovementRecallReturn,
+			Quantity:      -action.Quantity,
+			ReferenceType: models.MovementRefRecall,
+			ReferenceID:   &recallID,
+			Reason:        action.Notes,
+			UserID:        action.CreatedBy,
+		}, lot)
+	}
+	return nil
+}
+
+func (s *Store) GetRecallReport(ctx context.Context, id int) (models.RecallReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := models.RecallReport{Stock: []models.RecallStock{}, Dispensed: []models.RecallDispensed{}}
+	recall, ok := s.recalls[id]
+	if !ok {
+		return report, store.ErrNotFound
+	}
+	report.Recall = cloneRecall(recall)
+
+	// Партии с остатком и партии, по которым уже были действия
+	for _, lot := range s.lots {
+		if !recall.Covers(lot.MedicineID, lot.LotNumber) {
+			continue
+		}
+		quarantined, returned := recall.LotActions(lot.ID)
+		if lot.Quantity == 0 && quarantined == 0 && returned == 0 {
+			continue
+		}
+		report.Stock = append(report.Stock, models.RecallStock{
+			PharmacyID:   lot.PharmacyID,
+			PharmacyName: s.pharmacies[lot.PharmacyID].Name,
+			MedicineID:   lot.MedicineID,
+			MedicineName: s.medicines[lot.MedicineID].Name,
+			LotID:        lot.ID,
+			LotNumber:    lot.LotNumber,
+			ExpiryDate:   lot.ExpiryDate,
+			Quantity:     lot.Quantity,
+			Quarantined:  quarantined,
+			Returned:     returned,
+		})
+	}
+	sort.Slice(report.Stock, func(i, j int) bool {
+		a, b := report.Stock[i], report.Stock[j]
+		if a.PharmacyName != b.PharmacyName {
+			return a.PharmacyName < b.PharmacyName
+		}
+		if a.PharmacyID != b.PharmacyID {
+			return a.PharmacyID < b.PharmacyID
+		}
+		if a.MedicineName != b.MedicineName {
+			return a.MedicineName < b.MedicineName
+		}
+		if a.MedicineID != b.MedicineID {
+			return a.MedicineID < b.MedicineID
+		}
+		return a.LotNumber < b.LotNumber
+	})
+
+	// Возвращённые заказы не входят: их товар вернулся в партии
+	for _, order := range s.orders {
+		if order.Status != models.OrderStatusPaid {
+			continue
+		}
+		for _, item := range order.Items {
+			for _, allocation := range item.Allocations {
+				lot := s.lots[allocation.LotID]
+				if !recall.Covers(lot.MedicineID, lot.LotNumber) {
+					continue
+				}
+				report.Dispensed = append(report.Dispensed, models.RecallDispensed{
+					OrderID:      order.ID,
+					PharmacyID:   order.PharmacyID,
+					PharmacyName: s.pharmacies[order.PharmacyID].Name,
+					MedicineID:   item.MedicineID,
+					MedicineName: item.MedicineName,
+					LotID:        lot.ID,
+					LotNumber:    lot.LotNumber,
+					Quantity:     allocation.Quantity,
+					PaidAt:       order.PaidAt,
+				})
+			}
+		}
+	}
+	sort.Slice(report.Dispensed, func(i, j int) bool {
+		a, b := report.Dispensed[i], report.Dispensed[j]
+		if !a.PaidAt.Equal(*b.PaidAt) {
+			return a.PaidAt.Before(*b.PaidAt)
+		}
+		if a.OrderID != b.OrderID {
+			return a.OrderID < b.OrderID
+		}
+		return a.LotID < b.LotID
+	})
+	return report, nil
+}
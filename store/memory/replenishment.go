@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+func (s *Store) ListStockLevels(ctx context.Context, pharmacyID int) ([]models.StockLevel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[pharmacyID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	levels := []models.StockLevel{}
+	for key, level := range s.stockLevels {
+		if key.pharmacyID != pharmacyID {
+			continue
+		}
+		level.MedicineName = s.medicines[key.medicineID].Name
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].MedicineName != levels[j].MedicineName {
+			return levels[i].MedicineName < levels[j].MedicineName
+		}
+		return levels[i].MedicineID < levels[j].MedicineID
+	})
+	return levels, nil
+}
+
+func (s *Store) SetStockLevel(ctx context.Context, level *models.StockLevel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[level.PharmacyID]; !ok {
+		return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, level.PharmacyID)
+	}
+	medicine, ok := s.medicines[level.MedicineID]
+	if !ok {
+		return fmt.Errorf("%w: medicine %d", store.ErrNotFound, level.MedicineID)
+	}
+	if level.SupplierID != nil {
+		if _, ok := s.suppliers[*level.SupplierID]; !ok {
+			return fmt.Errorf("%w: supplier %d", store.ErrNotFound, *level.SupplierID)
+		}
+	}
+	level.MedicineName = medicine.Name
+	level.UpdatedAt = s.Now()
+	s.stockLevels[stockKey{level.PharmacyID, level.MedicineID}] = *level
+	return nil
+}
+
+func (s *Store) DeleteStockLevel(ctx context.Context, pharmacyID, medicineID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := stockKey{pharmacyID, medicineID}
+	if _, ok := s.stockLevels[key]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.stockLevels, key)
+	delete(s.replenishment, key)
+	return nil
+}
+
+func (s *Store) RefreshReplenishment(ctx context.Context, pharmacyID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pharmacyID != 0 {
+		if _, ok := s.pharmacies[pharmacyID]; !ok {
+			return 0, store.ErrNotFound
+		}
+	}
+
+	now := s.Now()
+	since := now.AddDate(0, 0, -models.ReplenishmentSalesWindowDays)
+	sold := map[stockKey]int{}
+	for _, order := range s.orders {
+		if order.Status != models.OrderStatusPaid || order.PaidAt == nil || order.PaidAt.Before(since) {
+			continue
+		}
+		for _, item := range order.Items {
+			sold[stockKey{order.PharmacyID, item.MedicineID}] += item.Quantity
+		}
+	}
+	onOrder := s.onOrder()
+
+	for key := range s.replenishment {
+		if pharmacyID == 0 || key.pharmacyID == pharmacyID {
+			delete(s.replenishment, key)
+		}
+	}
+	count := 0
+	for key, level := range s.stockLevels {
+		if pharmacyID != 0 && key.pharmacyID != pharmacyID {
+			continue
+		}
+		level.MedicineName = s.medicines[key.medicineID].Name
+		suggestion := level.Suggest(s.stock[key], onOrder[key], sold[key])
+		if suggestion.SuggestedQuantity == 0 {
+			continue
+		}
+		suggestion.CalculatedAt = now
+		s.replenishment[key] = suggestion
+		count++
+	}
+	return count, nil
+}
+
+// onOrder возвращает ещё не принятые количества открытых заказов поставщикам; вызывается под блокировкой
+func (s *Store) onOrder() map[stockKey]int {
+	onOrder := map[stockKey]int{}
+	for _, order := range s.purchaseOrders {
+		switch order.Status {
+		case models.PurchaseStatusDraft, models.PurchaseStatusSent, models.PurchaseStatusPartiallyReceived:
+			for _, item := range order.Items {
+				onOrder[stockKey{order.PharmacyID, item.MedicineID}] += item.Remaining()
+			}
+		}
+	}
+	return onOrder
+}
+
+// listReplenishment возвращает предложения аптеки по названию лекарства; вызывается под блокировкой
+func (s *Store) listReplenishment(pharmacyID int) []models.ReplenishmentSuggestion {
+	suggestions := []models.ReplenishmentSuggestion{}
+	for key, suggestion := range s.replenishment {
+		if key.pharmacyID == pharmacyID {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].MedicineName != suggestions[j].MedicineName {
+			return suggestions[i].MedicineName < suggestions[j].MedicineName
+		}
+		return suggestions[i].MedicineID < suggestions[j].MedicineID
+	})
+	return suggestions
+}
+
+func (s *Store) ListReplenishment(ctx context.Context, pharmacyID int) ([]models.ReplenishmentSuggestion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[pharmacyID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	return s.listReplenishment(pharmacyID), nil
+}
+
+// lastUnitCost возвращает закупочную цену лекарства из последнего заказа поставщику; вызывается под блокировкой
+func (s *Store) lastUnitCost(medicineID int) float64 {
+	var last *models.PurchaseOrder
+	cost := 0.0
+	for _, order := range s.purchaseOrders {
+		item := order.Item(medicineID)
+		if item == nil {
+			continue
+		}
+		if last == nil || order.CreatedAt.After(last.CreatedAt) || (order.CreatedAt.Equal(last.CreatedAt) && order.ID > last.ID) {
+			order := order
+			last, cost = &order, item.UnitCost
+		}
+	}
+	return cost
+}
+
+func (s *Store) CreateReplenishmentOrders(ctx context.Context, pharmacyID int, request models.ReplenishmentOrderRequest) ([]models.PurchaseOrder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[pharmacyID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	suggestions, err := models.SelectReplenishment(s.listReplenishment(pharmacyID), request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", store.ErrConflict, err)
+	}
+
+	orders := models.PlanReplenishmentOrders(pharmacyID, suggestions, request, s.lastUnitCost)
+	for i := range orders {
+		if err := s.createPurchaseOrder(&orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	// Заказанные лекарства учтены в открытых заказах, предложения по ним больше не нужны
+	for _, suggestion := range suggestions {
+		delete(s.replenishment, stockKey{pharmacyID, suggestion.MedicineID})
+	}
+	return orders, nil
+}
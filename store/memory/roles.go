@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// normalizePermissions проверяет разрешения и возвращает их без повторов в порядке имён; вызывается под блокировкой
+func (s *Store) normalizePermissions(permissions []string) ([]string, error) {
+	known := map[string]bool{}
+	for _, permission := range s.permissions {
+		known[permission.Name] = true
+	}
+	seen := map[string]bool{}
+	result := []string{}
+	for _, permission := range permissions {
+		if !known[permission] {
+			return nil, fmt.Errorf("%w: permission %s", store.ErrNotFound, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			result = append(result, permission)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (s *Store) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	permissions := append([]models.Permission{}, s.permissions...)
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+	return permissions, nil
+}
+
+func (s *Store) ListRoles(ctx context.Context) ([]models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := make([]models.Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		role.Permissions = append([]string{}, role.Permissions...)
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (s *Store) GetRole(ctx context.Context, name string) (models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[name]
+	if !ok {
+		return models.Role{}, store.ErrNotFound
+	}
+	role.Permissions = append([]string{}, role.Permissions...)
+	return role, nil
+}
+
+func (s *Store) CreateRole(ctx context.Context, role *models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[role.Name]; ok {
+		return &store.ConflictError{Field: "name"}
+	}
+	permissions, err := s.normalizePermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	s.roles[role.Name] = *role
+	return nil
+}
+
+func (s *Store) UpdateRole(ctx context.Context, role *models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[role.Name]; !ok {
+		return store.ErrNotFound
+	}
+	permissions, err := s.normalizePermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	s.roles[role.Name] = *role
+	return nil
+}
+
+func (s *Store) DeleteRole(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[name]; !ok {
+		return store.ErrNotFound
+	}
+	for _, user := range s.users {
+		if user.Details.Position == name {
+			return fmt.Errorf("%w: role %s is assigned to users", store.ErrConflict, name)
+		}
+	}
+	delete(s.roles, name)
+	return nil
+}
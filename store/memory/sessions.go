@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// Отозванные сессии удаляются из памяти, поэтому активной считается любая неистёкшая сессия
+
+func (s *Store) CreateSession(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[session.UserID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.sessions {
+		if existing.TokenHash == session.TokenHash {
+			return store.ErrConflict
+		}
+	}
+
+	now := s.Now()
+	session.ID = s.newID("sessions")
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(ttl)
+	session.Current = false
+	s.sessions[session.ID] = *session
+
+	user.LoginAt = now
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *Store) TouchSession(ctx context.Context, tokenHash string, ttl time.Duration) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for id, session := range s.sessions {
+		if session.TokenHash != tokenHash || !session.ExpiresAt.After(now) {
+			continue
+		}
+		session.LastSeenAt = now
+		session.ExpiresAt = now.Add(ttl)
+		s.sessions[id] = session
+		return session, nil
+	}
+	return models.Session{}, store.ErrNotFound
+}
+
+func (s *Store) ListUserSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	sessions := []models.Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (s *Store) RevokeSession(ctx context.Context, userID, sessionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID || !session.ExpiresAt.After(s.Now()) {
+		return store.ErrNotFound
+	}
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *Store) RevokeSessionByToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
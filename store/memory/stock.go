@@ -0,0 +1,303 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"pharmacy-test/geo"
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// filterLots возвращает подходящие партии в порядке FEFO; вызывается под блокировкой
+func (s *Store) filterLots(match func(lot models.Lot) bool) []models.Lot {
+	today := s.Now()
+	lots := []models.Lot{}
+	for _, lot := range s.lots {
+		if match(lot) {
+			lot.Expired = lot.IsExpired(today)
+			lot.Recalled = s.isRecalled(lot.MedicineID, lot.LotNumber)
+			lots = append(lots, lot)
+		}
+	}
+	sort.Slice(lots, func(i, j int) bool {
+		if lots[i].ExpiryDate != lots[j].ExpiryDate {
+			return lots[i].ExpiryDate < lots[j].ExpiryDate
+		}
+		return lots[i].ID < lots[j].ID
+	})
+	return lots
+}
+
+func (s *Store) stockLots(pharmacyID, medicineID int) []models.Lot {
+	return s.filterLots(func(lot models.Lot) bool {
+		return lot.PharmacyID == pharmacyID && lot.MedicineID == medicineID && lot.Quantity > 0
+	})
+}
+
+// takeFromLots уменьшает партии на распределённые количества; вызывается под блокировкой
+func (s *Store) takeFromLots(allocations []models.LotAllocation) {
+	for _, allocation := range allocations {
+		if allocation.LotID == 0 {
+			continue
+		}
+		lot := s.lots[allocation.LotID]
+		lot.Quantity -= allocation.Quantity
+		s.lots[allocation.LotID] = lot
+	}
+}
+
+// consumeStock списывает остаток по FEFO; вызывается под блокировкой
+func (s *Store) consumeStock(pharmacyID, medicineID, quantity int) ([]models.LotAllocation, error) {
+	key := stockKey{pharmacyID, medicineID}
+	onHand, ok := s.stock[key]
+	if !ok {
+		return nil, store.ErrInsufficientStock
+	}
+	allocations, ok := models.PlanConsumption(onHand, s.stockLots(pharmacyID, medicineID), quantity)
+	if !ok {
+		return nil, store.ErrInsufficientStock
+	}
+	s.takeFromLots(allocations)
+	s.stock[key] = onHand - quantity
+	return allocations, nil
+}
+
+// snapshotStock копирует остатки и партии для отката; вызывается под блокировкой
+func (s *Store) snapshotStock() (map[stockKey]int, map[int]models.Lot) {
+	stock := make(map[stockKey]int, len(s.stock))
+	for key, quantity := range s.stock {
+		stock[key] = quantity
+	}
+	lots := make(map[int]models.Lot, len(s.lots))
+	for id, lot := range s.lots {
+		lots[id] = lot
+	}
+	return stock, lots
+}
+
+// adjustStock изменяет остаток на movement.Quantity, не опуская его ниже нуля, и записывает
+// фактическое изменение в журнал; вызывается под блокировкой. Недостача сначала списывается
+// с остатка без партии, затем с партий. Возвращает фактическое изменение
+func (s *Store) adjustStock(movement models.StockMovement) int {
+	key := stockKey{movement.PharmacyID, movement.MedicineID}
+	onHand, delta := s.stock[key], movement.Quantity
+	if onHand+delta < 0 {
+		delta = -onHand
+	}
+	s.stock[key] = onHand + delta
+	var trims []models.LotAllocation
+	if delta < 0 {
+		trims = models.PlanTrim(onHand+delta, s.stockLots(movement.PharmacyID, movement.MedicineID))
+		s.takeFromLots(trims)
+	}
+	movement.Quantity = delta
+	s.recordMovements(movement, trims)
+	return delta
+}
+
+// recordMovements записывает движение, разложенное по партиям allocations; вызывается под блокировкой
+func (s *Store) recordMovements(movement models.StockMovement, allocations []models.LotAllocation) {
+	now := s.Now()
+	for _, m := range movement.Split(allocations) {
+		m.ID = s.newID("stock_movements")
+		m.CreatedAt = now
+		s.movements = append(s.movements, m)
+	}
+}
+
+// recordLotMovement записывает движение целиком по партии lot; вызывается под блокировкой
+func (s *Store) recordLotMovement(movement models.StockMovement, lot models.Lot) {
+	movement.PharmacyID, movement.MedicineID = lot.PharmacyID, lot.MedicineID
+	// Распределение по партиям хранит количество без знака
+	quantity := movement.Quantity
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	s.recordMovements(movement, []models.LotAllocation{{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: quantity}})
+}
+
+func (s *Store) ListPharmacyStock(ctx context.Context, pharmacyID int) ([]models.StockItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[pharmacyID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	items := []models.StockItem{}
+	for key, quantity := range s.stock {
+		if key.pharmacyID != pharmacyID {
+			continue
+		}
+		items = append(items, models.StockItem{
+			PharmacyID:   pharmacyID,
+			MedicineID:   key.medicineID,
+			MedicineName: s.medicines[key.medicineID].Name,
+			Quantity:     quantity,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].MedicineName != items[j].MedicineName {
+			return items[i].MedicineName < items[j].MedicineName
+		}
+		return items[i].MedicineID < items[j].MedicineID
+	})
+	return items, nil
+}
+
+func (s *Store) SetStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) (models.StockItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pharmacy, ok := s.pharmacies[pharmacyID]
+	if !ok {
+		return models.StockItem{}, store.ErrNotFound
+	}
+	medicine, ok := s.medicines[medicineID]
+	if !ok {
+		return models.StockItem{}, store.ErrNotFound
+	}
+
+	key := stockKey{pharmacyID, medicineID}
+	onHand := s.stock[key]
+	s.stock[key] = quantity
+	trims := models.PlanTrim(quantity, s.stockLots(pharmacyID, medicineID))
+	s.takeFromLots(trims)
+	s.recordMovements(models.StockMovement{
+		PharmacyID: pharmacyID,
+		MedicineID: medicineID,
+		Type:       models.MovementAdjustment,
+		Quantity:   quantity - onHand,
+		UserID:     userID,
+	}, trims)
+	return models.StockItem{
+		PharmacyID:   pharmacyID,
+		PharmacyName: pharmacy.Name,
+		MedicineID:   medicineID,
+		MedicineName: medicine.Name,
+		Quantity:     quantity,
+	}, nil
+}
+
+func (s *Store) ConsumeStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) ([]models.LotAllocation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	allocations, err := s.consumeStock(pharmacyID, medicineID, quantity)
+	if err != nil {
+		return nil, err
+	}
+	s.recordMovements(models.StockMovement{
+		PharmacyID: pharmacyID,
+		MedicineID: medicineID,
+		Type:       models.MovementWriteOff,
+		Quantity:   -quantity,
+		UserID:     userID,
+	}, allocations)
+	return allocations, nil
+}
+
+// receiveLot добавляет партию и увеличивает остаток; вызывается под блокировкой
+func (s *Store) receiveLot(lot *models.Lot) error {
+	for id, existing := range s.lots {
+		if existing.MedicineID != lot.MedicineID || existing.PharmacyID != lot.PharmacyID || existing.LotNumber != lot.LotNumber {
+			continue
+		}
+		if existing.ExpiryDate != lot.ExpiryDate {
+			return store.ErrConflict
+		}
+		s.stock[stockKey{lot.PharmacyID, lot.MedicineID}] += lot.Quantity
+		existing.Quantity += lot.Quantity
+		s.lots[id] = existing
+		*lot = existing
+		lot.Expired = lot.IsExpired(s.Now())
+		lot.Recalled = s.isRecalled(lot.MedicineID, lot.LotNumber)
+		return nil
+	}
+
+	lot.ID = s.newID("medicine_lots")
+	lot.CreatedAt = s.Now()
+	lot.Expired = lot.IsExpired(lot.CreatedAt)
+	lot.Recalled = s.isRecalled(lot.MedicineID, lot.LotNumber)
+	s.lots[lot.ID] = *lot
+	s.stock[stockKey{lot.PharmacyID, lot.MedicineID}] += lot.Quantity
+	return nil
+}
+
+func (s *Store) ReceiveLot(ctx context.Context, lot *models.Lot, userID *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pharmacies[lot.PharmacyID]; !ok {
+		return store.ErrNotFound
+	}
+	if _, ok := s.medicines[lot.MedicineID]; !ok {
+		return store.ErrNotFound
+	}
+	received := lot.Quantity
+	if err := s.receiveLot(lot); err != nil {
+		return err
+	}
+	s.recordLotMovement(models.StockMovement{Type: models.MovementReceipt, Quantity: received, UserID: userID}, *lot)
+	return nil
+}
+
+func (s *Store) ListMedicineLots(ctx context.Context, medicineID int) ([]models.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filterLots(func(lot models.Lot) bool { return lot.MedicineID == medicineID && lot.Quantity > 0 }), nil
+}
+
+func (s *Store) ListExpiringLots(ctx context.Context, pharmacyID, days int) ([]models.Lot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := s.Now().AddDate(0, 0, days).Format(models.DateLayout)
+	return s.filterLots(func(lot models.Lot) bool {
+		return lot.PharmacyID == pharmacyID && lot.Quantity > 0 && lot.ExpiryDate <= limit
+	}), nil
+}
+
+func (s *Store) ListNearbyAvailability(ctx context.Context, medicineID int, origin geo.Point, radiusKm float64) ([]models.NearbyPharmacy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.medicines[medicineID]; !ok {
+		return nil, store.ErrNotFound
+	}
+	result := []models.NearbyPharmacy{}
+	for key, onHand := range s.stock {
+		if key.medicineID != medicineID {
+			continue
+		}
+		quantity := models.SellableQuantity(onHand, s.stockLots(key.pharmacyID, medicineID))
+		if quantity <= 0 {
+			continue
+		}
+		// Расписание в ответ не входит, как и в PostgreSQL
+		pharmacy := s.pharmacies[key.pharmacyID]
+		pharmacy.Hours, pharmacy.Exceptions = nil, nil
+		address := pharmacy.Address
+		if address.Latitude == nil || address.Longitude == nil {
+			continue
+		}
+		distance := geo.Distance(origin, geo.Point{Lat: *address.Latitude, Lon: *address.Longitude})
+		if distance > radiusKm {
+			continue
+		}
+		result = append(result, models.NearbyPharmacy{
+			Pharmacy:   pharmacy,
+			Quantity:   quantity,
+			Price:      s.effectivePrice(key.pharmacyID, s.medicines[medicineID]),
+			DistanceKm: distance,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DistanceKm != result[j].DistanceKm {
+			return result[i].DistanceKm < result[j].DistanceKm
+		}
+		return result[i].Pharmacy.ID < result[j].Pharmacy.ID
+	})
+	return result, nil
+}
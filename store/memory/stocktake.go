@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// cloneStockCount копирует инвентаризацию вместе со строками, подсчётами и корректировками
+func cloneStockCount(count models.StockCount) models.StockCount {
+	lines := make([]models.StockCountLine, len(count.Lines))
+	for i, line := range count.Lines {
+		line.Entries = append([]models.StockCountEntry(nil), line.Entries...)
+		line.Tally()
+		lines[i] = line
+	}
+	count.Lines = lines
+	count.Adjustments = append([]models.StockAdjustment(nil), count.Adjustments...)
+	return count
+}
+
+// sortStockCountLines упорядочивает строки по названию лекарства
+func sortStockCountLines(lines []models.StockCountLine) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].MedicineName != lines[j].MedicineName {
+			return lines[i].MedicineName < lines[j].MedicineName
+		}
+		return lines[i].MedicineID < lines[j].MedicineID
+	})
+}
+
+func (s *Store) ListStockCounts(ctx context.Context, filter models.StockCountFilter) ([]models.StockCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := []models.StockCount{}
+	for _, count := range s.stockCounts {
+		if filter.PharmacyID != 0 && count.PharmacyID != filter.PharmacyID {
+			continue
+		}
+		if filter.Status != "" && count.Status != filter.Status {
+			continue
+		}
+		// Строки и корректировки в список не входят, как и в PostgreSQL
+		count.Lines, count.Adjustments = nil, nil
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if !counts[i].OpenedAt.Equal(counts[j].OpenedAt) {
+			return counts[i].OpenedAt.After(counts[j].OpenedAt)
+		}
+		return counts[i].ID > counts[j].ID
+	})
+	return counts, nil
+}
+
+func (s *Store) GetStockCount(ctx context.Context, id int) (models.StockCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, ok := s.stockCounts[id]
+	if !ok {
+		return count, store.ErrNotFound
+	}
+	return cloneStockCount(count), nil
+}
+
+func (s *Store) OpenStockCount(ctx context.Context, count *models.StockCount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pharmacy, ok := s.pharmacies[count.PharmacyID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range s.stockCounts {
+		if existing.PharmacyID == count.PharmacyID && existing.Status == models.StockCountStatusOpen {
+			return fmt.Errorf("%w: pharmacy %d already has an open stock count %d", store.ErrConflict, count.PharmacyID, existing.ID)
+		}
+	}
+
+	count.Lines = []models.StockCountLine{}
+	for key, quantity := range s.stock {
+		if key.pharmacyID != count.PharmacyID {
+			continue
+		}
+		count.Lines = append(count.Lines, models.StockCountLine{
+			MedicineID:       key.medicineID,
+			MedicineName:     s.medicines[key.medicineID].Name,
+			ExpectedQuantity: quantity,
+			BookQuantity:     quantity,
+		})
+	}
+	sortStockCountLines(count.Lines)
+
+	count.ID = s.newID("stock_counts")
+	count.PharmacyName = pharmacy.Name
+	count.Status = models.StockCountStatusOpen
+	count.OpenedAt = s.Now()
+	count.ClosedBy, count.ClosedAt = nil, nil
+	count.Adjustments = nil
+	s.stockCounts[count.ID] = cloneStockCount(*count)
+	return nil
+}
+
+// openStockCount возвращает копию открытой инвентаризации; вызывается под блокировкой
+func (s *Store) openStockCount(id int) (models.StockCount, error) {
+	stored, ok := s.stockCounts[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	count := cloneStockCount(stored)
+	if count.Status != models.StockCountStatusOpen {
+		return count, fmt.Errorf("%w: stock count is %s", store.ErrInvalidTransition, count.Status)
+	}
+	return count, nil
+}
+
+func (s *Store) SubmitStockCountEntries(ctx context.Context, id int, entries []models.StockCountEntry, userID *int) (models.StockCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.openStockCount(id)
+	if err != nil {
+		return count, err
+	}
+
+	now := s.Now()
+	for _, entry := range entries {
+		medicine, ok := s.medicines[entry.MedicineID]
+		if !ok {
+			return count, fmt.Errorf("%w: medicine %d", store.ErrNotFound, entry.MedicineID)
+		}
+		line := count.Line(entry.MedicineID)
+		if line == nil {
+			// Лекарство, которого не было в учёте на момент открытия
+			count.Lines = append(count.Lines, models.StockCountLine{MedicineID: medicine.ID, MedicineName: medicine.Name})
+			line = &count.Lines[len(count.Lines)-1]
+		}
+		line.BookQuantity = s.stock[stockKey{count.PharmacyID, entry.MedicineID}]
+
+		entry.CountedBy, entry.CountedAt = userID, now
+		replaced := false
+		for i := range line.Entries {
+			if line.Entries[i].DeviceID == entry.DeviceID {
+				line.Entries[i], replaced = entry, true
+			}
+		}
+		if !replaced {
+			line.Entries = append(line.Entries, entry)
+		}
+		line.Tally()
+	}
+	sortStockCountLines(count.Lines)
+
+	s.stockCounts[id] = cloneStockCount(count)
+	return count, nil
+}
+
+func (s *Store) ApproveStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.openStockCount(id)
+	if err != nil {
+		return count, err
+	}
+	if missing := count.MissingReasons(); len(missing) > 0 {
+		return count, fmt.Errorf("%w: variance reason is required for medicines %v", store.ErrConflict, missing)
+	}
+
+	now, countID := s.Now(), count.ID
+	for _, line := range count.Lines {
+		if line.Variance == nil || *line.Variance == 0 {
+			continue
+		}
+		// Расхождение применяется к текущему остатку: продажи после подсчёта сохраняются
+		applied := s.adjustStock(models.StockMovement{
+			PharmacyID:    count.PharmacyID,
+			MedicineID:    line.MedicineID,
+			Type:          models.MovementAdjustment,
+			Quantity:      *line.Variance,
+			ReferenceType: models.MovementRefStockCount,
+			ReferenceID:   &countID,
+			Reason:        line.Reason,
+			UserID:        userID,
+		})
+		if applied == 0 {
+			continue
+		}
+		count.Adjustments = append(count.Adjustments, models.StockAdjustment{
+			ID:           s.newID("stock_adjustments"),
+			PharmacyID:   count.PharmacyID,
+			MedicineID:   line.MedicineID,
+			MedicineName: line.MedicineName,
+			StockCountID: &countID,
+			Quantity:     applied,
+			Reason:       line.Reason,
+			CreatedBy:    userID,
+			CreatedAt:    now,
+		})
+	}
+
+	count.Status = models.StockCountStatusApproved
+	count.ClosedBy, count.ClosedAt = userID, &now
+	s.stockCounts[id] = cloneStockCount(count)
+	return count, nil
+}
+
+func (s *Store) CancelStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.openStockCount(id)
+	if err != nil {
+		return count, err
+	}
+
+	now := s.Now()
+	count.Status = models.StockCountStatusCancelled
+	count.ClosedBy, count.ClosedAt = userID, &now
+	s.stockCounts[id] = cloneStockCount(count)
+	return count, nil
+}
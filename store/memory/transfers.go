@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// cloneTransfer копирует перемещение вместе с позициями и партиями
+func cloneTransfer(transfer models.Transfer) models.Transfer {
+	items := make([]models.TransferItem, len(transfer.Items))
+	for i, item := range transfer.Items {
+		item.Allocations = append([]models.LotAllocation(nil), item.Allocations...)
+		items[i] = item
+	}
+	transfer.Items = items
+	return transfer
+}
+
+func (s *Store) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if filter.PharmacyID != 0 {
+		if _, ok := s.pharmacies[filter.PharmacyID]; !ok {
+			return nil, store.ErrNotFound
+		}
+	}
+	transfers := []models.Transfer{}
+	for _, transfer := range s.transfers {
+		if filter.PharmacyID != 0 {
+			outgoing := transfer.FromPharmacyID == filter.PharmacyID
+			incoming := transfer.ToPharmacyID == filter.PharmacyID
+			switch filter.Direction {
+			case models.TransferDirectionIncoming:
+				outgoing = false
+			case models.TransferDirectionOutgoing:
+				incoming = false
+			}
+			if !outgoing && !incoming {
+				continue
+			}
+		}
+		if filter.Status != "" && transfer.Status != filter.Status {
+			continue
+		}
+		transfers = append(transfers, cloneTransfer(transfer))
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].CreatedAt.Equal(transfers[j].CreatedAt) {
+			return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+		}
+		return transfers[i].ID > transfers[j].ID
+	})
+	return transfers, nil
+}
+
+func (s *Store) GetTransfer(ctx context.Context, id int) (models.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[id]
+	if !ok {
+		return transfer, store.ErrNotFound
+	}
+	return cloneTransfer(transfer), nil
+}
+
+func (s *Store) CreateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, ok := s.pharmacies[transfer.FromPharmacyID]
+	if !ok {
+		return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, transfer.FromPharmacyID)
+	}
+	to, ok := s.pharmacies[transfer.ToPharmacyID]
+	if !ok {
+		return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, transfer.ToPharmacyID)
+	}
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		medicine, ok := s.medicines[item.MedicineID]
+		if !ok {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		item.MedicineName = medicine.Name
+		item.Allocations = nil
+	}
+
+	transfer.ID = s.newID("transfers")
+	transfer.FromPharmacyName = from.Name
+	transfer.ToPharmacyName = to.Name
+	transfer.Status = models.TransferStatusRequested
+	transfer.CreatedAt = s.Now()
+	transfer.UpdatedAt = transfer.CreatedAt
+	transfer.ApprovedBy, transfer.ShippedBy, transfer.ReceivedBy = nil, nil, nil
+	transfer.ApprovedAt, transfer.ShippedAt, transfer.ReceivedAt = nil, nil, nil
+	for i := range transfer.Items {
+		transfer.Items[i].ID = s.newID("transfer_items")
+	}
+	s.transfers[transfer.ID] = cloneTransfer(*transfer)
+	return nil
+}
+
+// shipTransfer списывает позиции перемещения в аптеке-отправителе по FEFO; вызывается под блокировкой.
+// При нехватке остатка уже выполненные списания откатываются
+func (s *Store) shipTransfer(transfer *models.Transfer, userID *int) error {
+	stock, lots := s.snapshotStock()
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		allocations, err := s.consumeStock(transfer.FromPharmacyID, item.MedicineID, item.Quantity)
+		if err != nil {
+			s.stock, s.lots = stock, lots
+			return fmt.Errorf("%w: medicine %d", err, item.MedicineID)
+		}
+		item.Allocations = nil
+		for _, allocation := range allocations {
+			if allocation.LotID != 0 {
+				item.Allocations = append(item.Allocations, allocation)
+			}
+		}
+	}
+	for _, item := range transfer.Items {
+		movement := transferMovement(*transfer, item, models.MovementTransferOut, transfer.FromPharmacyID, -item.Quantity, userID)
+		s.recordMovements(movement, item.Allocations)
+	}
+	return nil
+}
+
+// receiveTransfer оприходует отправленные партии в аптеке-получателе; вызывается под блокировкой.
+// При конфликте серии уже оприходованные партии откатываются
+func (s *Store) receiveTransfer(transfer *models.Transfer, userID *int) error {
+	stock, lots := s.snapshotStock()
+	received := make([][]models.LotAllocation, len(transfer.Items))
+	for i, item := range transfer.Items {
+		for _, allocation := range item.Allocations {
+			source := s.lots[allocation.LotID]
+			lot := models.Lot{
+				MedicineID:     item.MedicineID,
+				PharmacyID:     transfer.ToPharmacyID,
+				LotNumber:      source.LotNumber,
+				ProductionDate: source.ProductionDate,
+				ExpiryDate:     source.ExpiryDate,
+				Quantity:       allocation.Quantity,
+			}
+			if err := s.receiveLot(&lot); err != nil {
+				s.stock, s.lots = stock, lots
+				return fmt.Errorf("%w: lot %s already exists with a different expiry date", err, source.LotNumber)
+			}
+			received[i] = append(received[i], models.LotAllocation{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: allocation.Quantity})
+		}
+		if unlotted := item.Unlotted(); unlotted > 0 {
+			s.stock[stockKey{transfer.ToPharmacyID, item.MedicineID}] += unlotted
+		}
+	}
+	for i, item := range transfer.Items {
+		movement := transferMovement(*transfer, item, models.MovementTransferIn, transfer.ToPharmacyID, item.Quantity, userID)
+		s.recordMovements(movement, received[i])
+	}
+	return nil
+}
+
+// transferMovement описывает движение остатка по позиции перемещения в аптеке pharmacyID
+func transferMovement(transfer models.Transfer, item models.TransferItem, movementType string, pharmacyID, quantity int, userID *int) models.StockMovement {
+	transferID := transfer.ID
+	return models.StockMovement{
+		PharmacyID:    pharmacyID,
+		MedicineID:    item.MedicineID,
+		Type:          movementType,
+		Quantity:      quantity,
+		ReferenceType: models.MovementRefTransfer,
+		ReferenceID:   &transferID,
+		UserID:        userID,
+	}
+}
+
+func (s *Store) UpdateTransferStatus(ctx context.Context, id int, status string, userID *int) (models.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.transfers[id]
+	if !ok {
+		return stored, store.ErrNotFound
+	}
+	transfer := cloneTransfer(stored)
+	if !models.CanTransitionTransfer(transfer.Status, status) {
+		return transfer, store.ErrInvalidTransition
+	}
+	// Запрос решает аптека-отправитель, а не его автор
+	if (status == models.TransferStatusApproved || status == models.TransferStatusRejected) && !transfer.CanDecide(userID) {
+		return transfer, fmt.Errorf("%w: the requester cannot approve or reject the transfer", store.ErrForbidden)
+	}
+
+	now := s.Now()
+	switch status {
+	case models.TransferStatusApproved:
+		transfer.ApprovedBy, transfer.ApprovedAt = userID, &now
+	case models.TransferStatusInTransit:
+		if err := s.shipTransfer(&transfer, userID); err != nil {
+			return stored, err
+		}
+		transfer.ShippedBy, transfer.ShippedAt = userID, &now
+	case models.TransferStatusReceived:
+		if err := s.receiveTransfer(&transfer, userID); err != nil {
+			return stored, err
+		}
+		transfer.ReceivedBy, transfer.ReceivedAt = userID, &now
+	}
+	transfer.Status = status
+	transfer.UpdatedAt = now
+	s.transfers[id] = cloneTransfer(transfer)
+	return transfer, nil
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// checkUnique проверяет уникальность логина, email и телефона; вызывается под блокировкой
+func (s *Store) checkUnique(user *models.UserWithDetails) error {
+	for id, existing := range s.users {
+		if id == user.ID {
+			continue
+		}
+		switch {
+		case existing.Username == user.Username:
+			return &store.ConflictError{Field: "username"}
+		case existing.Details.Email == user.Details.Email:
+			return &store.ConflictError{Field: "email"}
+		case existing.Details.PhoneNumber == user.Details.PhoneNumber:
+			return &store.ConflictError{Field: "phone_number"}
+		}
+	}
+	// Как внешний ключ user_details.position → roles.name в PostgreSQL
+	if position := user.Details.Position; position != "" {
+		if _, ok := s.roles[position]; !ok {
+			return fmt.Errorf("%w: role %s does not exist", store.ErrConflict, position)
+		}
+	}
+	return nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.UserWithDetails) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.ID = 0
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+	user.ID = s.newID("users")
+	user.CreatedAt = s.Now()
+	user.Details.ID = s.newID("user_details")
+	user.Details.UserID = user.ID
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, user *models.UserWithDetails) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if err := s.checkUnique(user); err != nil {
+		return err
+	}
+	existing.Username = user.Username
+	existing.Password = user.Password
+	user.Details.ID = existing.Details.ID
+	user.Details.UserID = user.ID
+	existing.Details = user.Details
+	s.users[user.ID] = existing
+	return nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.users, id)
+	for sessionID, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sessionID)
+		}
+	}
+	for orderID, order := range s.orders {
+		if order.SellerID != nil && *order.SellerID == id {
+			order.SellerID = nil
+			s.orders[orderID] = order
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListUsers(ctx context.Context, filter models.UserFilter, page models.PageRequest) (models.Page[models.UserWithDetails], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []models.UserWithDetails{}
+	for _, user := range s.users {
+		if filter.Position != "" && user.Details.Position != filter.Position {
+			continue
+		}
+		users = append(users, user)
+	}
+	return paginate(users, page, map[string]sortKey[models.UserWithDetails]{
+		"id":         func(u models.UserWithDetails) interface{} { return u.ID },
+		"username":   func(u models.UserWithDetails) interface{} { return u.Username },
+		"created_at": func(u models.UserWithDetails) interface{} { return u.CreatedAt },
+	}, func(u models.UserWithDetails) int { return u.ID })
+}
+
+func (s *Store) findUser(match func(user models.UserWithDetails) bool) (models.UserWithDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.UserWithDetails{}, store.ErrNotFound
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (models.UserWithDetails, error) {
+	return s.findUser(func(user models.UserWithDetails) bool { return user.Username == username })
+}
+
+func (s *Store) GetUser(ctx context.Context, id int) (models.UserWithDetails, error) {
+	return s.findUser(func(user models.UserWithDetails) bool { return user.ID == id })
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const interactionColumns = "id, ingredient_a, ingredient_b, severity, description, updated_at"
+
+func scanInteraction(row rowScanner) (models.Interaction, error) {
+	var interaction models.Interaction
+	err := row.Scan(&interaction.ID, &interaction.IngredientA, &interaction.IngredientB, &interaction.Severity,
+		&interaction.Description, &interaction.UpdatedAt)
+	return interaction, err
+}
+
+func queryInteractions(ctx context.Context, q querier, query string, args ...interface{}) ([]models.Interaction, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	interactions := []models.Interaction{}
+	for rows.Next() {
+		interaction, err := scanInteraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, rows.Err()
+}
+
+func (s *Store) ListInteractions(ctx context.Context, ingredient string) ([]models.Interaction, error) {
+	if ingredient == "" {
+		return queryInteractions(ctx, s.db, "SELECT "+interactionColumns+" FROM ingredient_interactions ORDER BY ingredient_a, ingredient_b")
+	}
+	return queryInteractions(ctx, s.db, "SELECT "+interactionColumns+` FROM ingredient_interactions
+		WHERE ingredient_a = $1 OR ingredient_b = $1
+		ORDER BY ingredient_a, ingredient_b`, models.IngredientName(ingredient))
+}
+
+func (s *Store) ImportInteractions(ctx context.Context, interactions []models.Interaction) (models.InteractionImportResult, error) {
+	var result models.InteractionImportResult
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		for i := range interactions {
+			interaction := &interactions[i]
+			interaction.Normalize()
+			// xmax равен нулю только у только что вставленной строки
+			var inserted bool
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO ingredient_interactions(ingredient_a, ingredient_b, severity, description) VALUES($1, $2, $3, $4)
+				ON CONFLICT (ingredient_a, ingredient_b) DO UPDATE
+					SET severity = EXCLUDED.severity, description = EXCLUDED.description, updated_at = CURRENT_TIMESTAMP
+				RETURNING id, updated_at, xmax = 0
+			`, interaction.IngredientA, interaction.IngredientB, interaction.Severity, interaction.Description,
+			).Scan(&interaction.ID, &interaction.UpdatedAt, &inserted)
+			if err != nil {
+				return mapError(err)
+			}
+			if inserted {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.InteractionImportResult{}, err
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteInteraction(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM ingredient_interactions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (s *Store) CheckInteractions(ctx context.Context, medicineIDs []int) ([]models.InteractionWarning, error) {
+	ids := make([]int64, 0, len(medicineIDs))
+	seen := map[int]bool{}
+	for _, id := range medicineIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, COALESCE(name, '') FROM medicines WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	composition, err := ingredients(ctx, s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	medicines := make([]models.Medicine, 0, len(ids))
+	var substances []string
+	for _, id := range ids {
+		name, ok := names[int(id)]
+		if !ok {
+			return nil, fmt.Errorf("%w: medicine %d", store.ErrNotFound, id)
+		}
+		medicines = append(medicines, models.Medicine{ID: int(id), Name: name, Ingredients: composition[int(id)]})
+		for _, ingredient := range composition[int(id)] {
+			substances = append(substances, models.IngredientName(ingredient.Name))
+		}
+	}
+	if len(medicines) < 2 || len(substances) == 0 {
+		return []models.InteractionWarning{}, nil
+	}
+
+	interactions, err := queryInteractions(ctx, s.db, "SELECT "+interactionColumns+` FROM ingredient_interactions
+		WHERE ingredient_a = ANY($1) AND ingredient_b = ANY($1)`, pq.Array(substances))
+	if err != nil {
+		return nil, err
+	}
+	return models.FindInteractions(medicines, interactions), nil
+}
@@ -0,0 +1,383 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pharmacy-test/models"
+	"pharmacy-test/search"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const medicineColumns = "id, COALESCE(name, ''), COALESCE(manufacturer, ''), production_date, COALESCE(packaging, ''), COALESCE(price, 0), rx_required, " +
+	"COALESCE(atc_code, ''), dosage_form, COALESCE(gtin, '')"
+
+func scanMedicine(row rowScanner) (models.Medicine, error) {
+	var medicine models.Medicine
+	var productionDate sql.NullTime
+	err := row.Scan(&medicine.ID, &medicine.Name, &medicine.Manufacturer, &productionDate, &medicine.Packaging, &medicine.Price, &medicine.RxRequired,
+		&medicine.ATCCode, &medicine.DosageForm, &medicine.GTIN)
+	if productionDate.Valid {
+		medicine.ProductionDate = productionDate.Time.Format(models.DateLayout)
+	}
+	return medicine, err
+}
+
+// availability загружает наличие по аптекам для набора лекарств
+func availability(ctx context.Context, q querier, medicineIDs []int64) (map[int][]models.StockItem, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT pm.pharmacy_id, p.name, pm.medicine_id, pm.quantity
+		FROM pharmacy_medicines pm
+		JOIN pharmacies p ON p.id = pm.pharmacy_id
+		WHERE pm.medicine_id = ANY($1)
+		ORDER BY pm.medicine_id, pm.pharmacy_id
+	`, pq.Array(medicineIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int][]models.StockItem{}
+	for rows.Next() {
+		var item models.StockItem
+		if err := rows.Scan(&item.PharmacyID, &item.PharmacyName, &item.MedicineID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		result[item.MedicineID] = append(result[item.MedicineID], item)
+	}
+	return result, rows.Err()
+}
+
+// ingredients загружает действующие вещества для набора лекарств
+func ingredients(ctx context.Context, q querier, medicineIDs []int64) (map[int][]models.ActiveIngredient, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT medicine_id, name, strength, unit
+		FROM medicine_ingredients
+		WHERE medicine_id = ANY($1)
+		ORDER BY medicine_id, id
+	`, pq.Array(medicineIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[int][]models.ActiveIngredient{}
+	for rows.Next() {
+		var medicineID int
+		var ingredient models.ActiveIngredient
+		if err := rows.Scan(&medicineID, &ingredient.Name, &ingredient.Strength, &ingredient.Unit); err != nil {
+			return nil, err
+		}
+		result[medicineID] = append(result[medicineID], ingredient)
+	}
+	return result, rows.Err()
+}
+
+// fillDetails загружает наличие по аптекам и состав для набора лекарств
+func fillDetails(ctx context.Context, q querier, medicines []*models.Medicine) error {
+	ids := make([]int64, 0, len(medicines))
+	for _, medicine := range medicines {
+		ids = append(ids, int64(medicine.ID))
+	}
+	stock, err := availability(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	composition, err := ingredients(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	for _, medicine := range medicines {
+		medicine.Availability = stock[medicine.ID]
+		if medicine.Availability == nil {
+			medicine.Availability = []models.StockItem{}
+		}
+		medicine.Ingredients = composition[medicine.ID]
+	}
+	return nil
+}
+
+// saveIngredients заменяет состав лекарства
+func saveIngredients(ctx context.Context, tx *sql.Tx, medicine *models.Medicine) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM medicine_ingredients WHERE medicine_id = $1", medicine.ID); err != nil {
+		return err
+	}
+	for _, ingredient := range medicine.Ingredients {
+		_, err := tx.ExecContext(ctx, "INSERT INTO medicine_ingredients(medicine_id, name, strength, unit) VALUES($1, $2, $3, $4)",
+			medicine.ID, ingredient.Name, ingredient.Strength, ingredient.Unit)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListMedicines(ctx context.Context, filter models.MedicineFilter, page models.PageRequest) (models.Page[models.Medicine], error) {
+	q := listQuery{
+		columns: medicineColumns,
+		from:    "medicines",
+		idExpr:  "id",
+		sorts: map[string]sortColumn{
+			"id":           {"id", "int"},
+			"name":         {"COALESCE(name, '')", "text"},
+			"manufacturer": {"COALESCE(manufacturer, '')", "text"},
+			"price":        {"COALESCE(price, 0)", "numeric"},
+		},
+	}
+	if filter.Manufacturer != "" {
+		q.filter("lower(manufacturer) = lower(?)", filter.Manufacturer)
+	}
+	if filter.MinPrice != nil {
+		q.filter("COALESCE(price, 0) >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		q.filter("COALESCE(price, 0) <= ?", *filter.MaxPrice)
+	}
+	if filter.PharmacyID != 0 {
+		q.filter("EXISTS(SELECT 1 FROM pharmacy_medicines pm WHERE pm.medicine_id = medicines.id AND pm.pharmacy_id = ?)", filter.PharmacyID)
+	}
+	if filter.RxRequired != nil {
+		q.filter("rx_required = ?", *filter.RxRequired)
+	}
+	if filter.ATCCode != "" {
+		q.filter("atc_code LIKE ? || '%'", filter.ATCCode)
+	}
+	if filter.Ingredient != "" {
+		q.filter("EXISTS(SELECT 1 FROM medicine_ingredients mi WHERE mi.medicine_id = medicines.id AND lower(mi.name) = lower(?))", filter.Ingredient)
+	}
+
+	result, err := queryPage(ctx, s.db, q, page, scanMedicine, func(m models.Medicine) int { return m.ID })
+	if err != nil {
+		return result, err
+	}
+
+	medicines := make([]*models.Medicine, len(result.Items))
+	for i := range result.Items {
+		medicines[i] = &result.Items[i]
+	}
+	return result, fillDetails(ctx, s.db, medicines)
+}
+
+func (s *Store) GetMedicine(ctx context.Context, id int) (models.Medicine, error) {
+	medicine, err := scanMedicine(s.db.QueryRowContext(ctx, "SELECT "+medicineColumns+" FROM medicines WHERE id = $1", id))
+	if err != nil {
+		return medicine, mapError(err)
+	}
+
+	if err := fillDetails(ctx, s.db, []*models.Medicine{&medicine}); err != nil {
+		return medicine, err
+	}
+
+	medicine.Lots, err = s.ListMedicineLots(ctx, id)
+	return medicine, err
+}
+
+func (s *Store) GetMedicineByGTIN(ctx context.Context, gtin string) (models.Medicine, error) {
+	var id int
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM medicines WHERE gtin = $1", gtin).Scan(&id); err != nil {
+		return models.Medicine{}, mapError(err)
+	}
+	return s.GetMedicine(ctx, id)
+}
+
+func (s *Store) CreateMedicine(ctx context.Context, medicine *models.Medicine) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		// Проверка наличия аптек, к которым привязывается лекарство
+		for _, pharmacyID := range medicine.PharmacyIDs {
+			found, err := exists(ctx, tx, "pharmacies", pharmacyID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, pharmacyID)
+			}
+		}
+
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO medicines(name, manufacturer, production_date, packaging, price, rx_required, atc_code, dosage_form, ingredients_key, gtin)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+			medicine.Name, medicine.Manufacturer, nullString(medicine.ProductionDate), medicine.Packaging, medicine.Price, medicine.RxRequired,
+			nullString(medicine.ATCCode), medicine.DosageForm, models.IngredientsKey(medicine.Ingredients), nullString(medicine.GTIN),
+		).Scan(&medicine.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		if err := saveIngredients(ctx, tx, medicine); err != nil {
+			return err
+		}
+		price := medicine.Price
+		if err := recordPrice(ctx, tx, &models.PriceChange{MedicineID: medicine.ID, NewPrice: &price, ChangedBy: medicine.ChangedBy}); err != nil {
+			return err
+		}
+
+		medicine.Availability = []models.StockItem{}
+		for _, pharmacyID := range medicine.PharmacyIDs {
+			_, err := tx.ExecContext(ctx, "INSERT INTO pharmacy_medicines(pharmacy_id, medicine_id) VALUES($1, $2) ON CONFLICT DO NOTHING",
+				pharmacyID, medicine.ID)
+			if err != nil {
+				return mapError(err)
+			}
+			medicine.Availability = append(medicine.Availability, models.StockItem{PharmacyID: pharmacyID, MedicineID: medicine.ID})
+		}
+		return nil
+	})
+}
+
+func (s *Store) UpdateMedicine(ctx context.Context, medicine *models.Medicine) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var old sql.NullFloat64
+		if err := tx.QueryRowContext(ctx, "SELECT price FROM medicines WHERE id = $1 FOR UPDATE", medicine.ID).Scan(&old); err != nil {
+			return mapError(err)
+		}
+
+		_, err := tx.ExecContext(ctx,
+			`UPDATE medicines SET name = $1, manufacturer = $2, production_date = $3, packaging = $4, price = $5, rx_required = $6,
+				atc_code = $7, dosage_form = $8, ingredients_key = $9, gtin = $10
+			WHERE id = $11`,
+			medicine.Name, medicine.Manufacturer, nullString(medicine.ProductionDate), medicine.Packaging, medicine.Price, medicine.RxRequired,
+			nullString(medicine.ATCCode), medicine.DosageForm, models.IngredientsKey(medicine.Ingredients), nullString(medicine.GTIN), medicine.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		if err := saveIngredients(ctx, tx, medicine); err != nil {
+			return err
+		}
+		if old.Valid && models.RoundMoney(old.Float64) == models.RoundMoney(medicine.Price) {
+			return nil
+		}
+		price := medicine.Price
+		return recordPrice(ctx, tx, &models.PriceChange{MedicineID: medicine.ID, OldPrice: nullFloat(old), NewPrice: &price, ChangedBy: medicine.ChangedBy})
+	})
+}
+
+func (s *Store) ListSubstitutes(ctx context.Context, medicineID, pharmacyID int) ([]models.MedicineSubstitute, error) {
+	var key, dosageForm string
+	err := s.db.QueryRowContext(ctx, "SELECT ingredients_key, dosage_form FROM medicines WHERE id = $1", medicineID).Scan(&key, &dosageForm)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if pharmacyID != 0 {
+		if err := requirePharmacyField(ctx, s.db, "pharmacy_id", pharmacyID); err != nil {
+			return nil, err
+		}
+	}
+	substitutes := []models.MedicineSubstitute{}
+	if key == "" {
+		return substitutes, nil
+	}
+	_, basePrice, err := effectivePrice(ctx, s.db, pharmacyID, medicineID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Цена и остаток в аптеке запроса; без аптеки переопределений и остатков нет
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+medicineColumns+`,
+			COALESCE((SELECT o.price FROM medicine_price_overrides o WHERE o.medicine_id = medicines.id AND o.pharmacy_id = $3), price, 0),
+			COALESCE((SELECT pm.quantity FROM pharmacy_medicines pm WHERE pm.medicine_id = medicines.id AND pm.pharmacy_id = $3), 0)
+		FROM medicines
+		WHERE ingredients_key = $1 AND id <> $2
+	`, key, medicineID, pharmacyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var substitute models.MedicineSubstitute
+		var quantity int
+		substitute.Medicine, err = scanMedicine(appendScanner{rows, []interface{}{&substitute.Price, &quantity}})
+		if err != nil {
+			return nil, err
+		}
+		substitute.PriceDifference = models.RoundMoney(substitute.Price - basePrice)
+		substitute.SameDosageForm = substitute.Medicine.DosageForm == dosageForm
+		if pharmacyID != 0 {
+			substitute.Quantity = &quantity
+		}
+		substitutes = append(substitutes, substitute)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	medicines := make([]*models.Medicine, len(substitutes))
+	for i := range substitutes {
+		medicines[i] = &substitutes[i].Medicine
+	}
+	if err := fillDetails(ctx, s.db, medicines); err != nil {
+		return nil, err
+	}
+	models.SortSubstitutes(substitutes)
+	return substitutes, nil
+}
+
+func (s *Store) DeleteMedicine(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM medicines WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectAffected(result)
+}
+
+func (s *Store) SearchMedicines(ctx context.Context, query string, limit int) ([]models.MedicineSearchResult, error) {
+	results := []models.MedicineSearchResult{}
+	tokens := search.Tokens(query)
+	if len(tokens) == 0 {
+		return results, nil
+	}
+
+	// Каждое слово запроса ищется как префикс: "парац" находит "Парацетамол"
+	prefixes := make([]string, len(tokens))
+	for i, token := range tokens {
+		prefixes[i] = token + ":*"
+	}
+	tsQuery := strings.Join(prefixes, " & ")
+	normalized := strings.Join(tokens, " ")
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// Порог сходства для оператора <% действует только внутри транзакции
+		if _, err := tx.ExecContext(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)",
+			strconv.FormatFloat(search.SimilarityThreshold, 'f', -1, 64)); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `
+			SELECT `+medicineColumns+`,
+				ts_rank(search_document, q) + word_similarity($2, search_key) AS rank
+			FROM medicines, to_tsquery('simple', $1) q
+			WHERE search_document @@ q OR $2 <% search_key
+			ORDER BY rank DESC, id
+			LIMIT $3`, tsQuery, normalized, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var result models.MedicineSearchResult
+			result.Medicine, err = scanMedicine(appendScanner{rows, []interface{}{&result.Rank}})
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	medicines := make([]*models.Medicine, len(results))
+	for i := range results {
+		medicines[i] = &results[i].Medicine
+	}
+	if err := fillDetails(ctx, s.db, medicines); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
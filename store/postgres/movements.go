@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pharmacy-test/models"
+)
+
+const movementColumns = "sm.id, sm.pharmacy_id, sm.medicine_id, COALESCE(m.name, ''), sm.lot_id, COALESCE(l.lot_number, ''), sm.type, sm.quantity, " +
+	"sm.reference_type, sm.reference_id, sm.reason, sm.user_id, sm.created_at"
+
+const movementFrom = "stock_movements sm JOIN medicines m ON m.id = sm.medicine_id LEFT JOIN medicine_lots l ON l.id = sm.lot_id"
+
+func scanMovement(row rowScanner) (models.StockMovement, error) {
+	var movement models.StockMovement
+	var lotID, referenceID, userID sql.NullInt64
+	var referenceType sql.NullString
+	err := row.Scan(&movement.ID, &movement.PharmacyID, &movement.MedicineID, &movement.MedicineName, &lotID, &movement.LotNumber,
+		&movement.Type, &movement.Quantity, &referenceType, &referenceID, &movement.Reason, &userID, &movement.CreatedAt)
+	if err != nil {
+		return movement, err
+	}
+	movement.LotID = nullInt(lotID)
+	movement.ReferenceType = referenceType.String
+	movement.ReferenceID = nullInt(referenceID)
+	movement.UserID = nullInt(userID)
+	return movement, nil
+}
+
+// recordMovements записывает движение, разложенное по партиям allocations
+func recordMovements(ctx context.Context, tx *sql.Tx, movement models.StockMovement, allocations []models.LotAllocation) error {
+	for _, m := range movement.Split(allocations) {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO stock_movements(pharmacy_id, medicine_id, lot_id, type, quantity, reference_type, reference_id, reason, user_id)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, m.PharmacyID, m.MedicineID, m.LotID, m.Type, m.Quantity, nullString(m.ReferenceType), m.ReferenceID, m.Reason, m.UserID)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListStockMovements(ctx context.Context, filter models.MovementFilter, page models.PageRequest) (models.Page[models.StockMovement], error) {
+	if err := requirePharmacy(ctx, s.db, filter.PharmacyID); err != nil {
+		return models.Page[models.StockMovement]{}, err
+	}
+
+	q := listQuery{
+		columns: movementColumns,
+		from:    movementFrom,
+		idExpr:  "sm.id",
+		sorts: map[string]sortColumn{
+			"id":         {"sm.id", "int"},
+			"created_at": {"sm.created_at", "timestamp"},
+		},
+	}
+	q.filter("sm.pharmacy_id = ?", filter.PharmacyID)
+	if filter.MedicineID != 0 {
+		q.filter("sm.medicine_id = ?", filter.MedicineID)
+	}
+	if filter.Type != "" {
+		q.filter("sm.type = ?", filter.Type)
+	}
+	if filter.From != nil {
+		q.filter("sm.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q.filter("sm.created_at < ?", *filter.To)
+	}
+	return queryPage(ctx, s.db, q, page, scanMovement, func(m models.StockMovement) int { return m.ID })
+}
+
+func (s *Store) ListStockBalances(ctx context.Context, pharmacyID int, at time.Time) ([]models.StockBalance, error) {
+	if err := requirePharmacy(ctx, s.db, pharmacyID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sm.medicine_id, COALESCE(m.name, ''), SUM(sm.quantity)
+		FROM stock_movements sm
+		JOIN medicines m ON m.id = sm.medicine_id
+		WHERE sm.pharmacy_id = $1 AND sm.created_at <= $2
+		GROUP BY sm.medicine_id, m.name
+		HAVING SUM(sm.quantity) <> 0
+		ORDER BY m.name, sm.medicine_id
+	`, pharmacyID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []models.StockBalance{}
+	for rows.Next() {
+		var balance models.StockBalance
+		if err := rows.Scan(&balance.MedicineID, &balance.MedicineName, &balance.Quantity); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	return balances, rows.Err()
+}
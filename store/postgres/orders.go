@@ -0,0 +1,320 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const orderColumns = "id, pharmacy_id, seller_id, status, total, created_at, updated_at, paid_at"
+
+func scanOrder(row rowScanner) (models.Order, error) {
+	var order models.Order
+	var sellerID sql.NullInt64
+	var paidAt sql.NullTime
+	err := row.Scan(&order.ID, &order.PharmacyID, &sellerID, &order.Status, &order.Total, &order.CreatedAt, &order.UpdatedAt, &paidAt)
+	if err != nil {
+		return order, err
+	}
+	if sellerID.Valid {
+		id := int(sellerID.Int64)
+		order.SellerID = &id
+	}
+	if paidAt.Valid {
+		order.PaidAt = &paidAt.Time
+	}
+	order.Items = []models.OrderItem{}
+	return order, nil
+}
+
+// loadOrderItems загружает позиции и отпущенные партии для набора заказов
+func loadOrderItems(ctx context.Context, q querier, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Order, len(orders))
+	ids := make([]int64, 0, len(orders))
+	for i := range orders {
+		index[orders[i].ID] = &orders[i]
+		ids = append(ids, int64(orders[i].ID))
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT oi.order_id, oi.id, oi.medicine_id, COALESCE(m.name, ''), oi.quantity, oi.unit_price, oi.line_total, oi.prescription_id
+		FROM order_items oi
+		JOIN medicines m ON m.id = oi.medicine_id
+		WHERE oi.order_id = ANY($1)
+		ORDER BY oi.order_id, oi.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	var owners []int
+	var items []models.OrderItem
+	for rows.Next() {
+		var orderID int
+		var item models.OrderItem
+		var prescriptionID sql.NullInt64
+		err := rows.Scan(&orderID, &item.ID, &item.MedicineID, &item.MedicineName, &item.Quantity, &item.UnitPrice, &item.LineTotal, &prescriptionID)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		item.PrescriptionID = nullInt(prescriptionID)
+		owners = append(owners, orderID)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	lotRows, err := q.QueryContext(ctx, `
+		SELECT oil.order_item_id, oil.lot_id, l.lot_number, l.expiry_date, oil.quantity
+		FROM order_item_lots oil
+		JOIN medicine_lots l ON l.id = oil.lot_id
+		JOIN order_items oi ON oi.id = oil.order_item_id
+		WHERE oi.order_id = ANY($1)
+		ORDER BY l.expiry_date, oil.lot_id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	allocations := map[int][]models.LotAllocation{}
+	for lotRows.Next() {
+		var itemID int
+		var allocation models.LotAllocation
+		var expiry time.Time
+		if err := lotRows.Scan(&itemID, &allocation.LotID, &allocation.LotNumber, &expiry, &allocation.Quantity); err != nil {
+			lotRows.Close()
+			return err
+		}
+		allocation.ExpiryDate = expiry.Format(models.DateLayout)
+		allocations[itemID] = append(allocations[itemID], allocation)
+	}
+	lotRows.Close()
+	if err := lotRows.Err(); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		item.Allocations = allocations[item.ID]
+		order := index[owners[i]]
+		order.Items = append(order.Items, item)
+	}
+	return nil
+}
+
+// getOrder загружает заказ вместе с позициями
+func getOrder(ctx context.Context, q querier, id int, forUpdate bool) (models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	order, err := scanOrder(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return order, mapError(err)
+	}
+	orders := []models.Order{order}
+	if err := loadOrderItems(ctx, q, orders); err != nil {
+		return order, err
+	}
+	return orders[0], nil
+}
+
+// payOrder списывает товары заказа с рецептов и со склада аптеки по FEFO от имени userID
+// и отмечает заказ оплаченным
+func payOrder(ctx context.Context, tx *sql.Tx, order *models.Order, userID *int) error {
+	for i := range order.Items {
+		item := &order.Items[i]
+		if err := dispensePrescription(ctx, tx, *item); err != nil {
+			return err
+		}
+		allocations, err := consumeStock(ctx, tx, order.PharmacyID, item.MedicineID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		item.Allocations = nil
+		for _, allocation := range allocations {
+			if allocation.LotID == 0 {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO order_item_lots(order_item_id, lot_id, quantity) VALUES($1, $2, $3)",
+				item.ID, allocation.LotID, allocation.Quantity)
+			if err != nil {
+				return err
+			}
+			item.Allocations = append(item.Allocations, allocation)
+		}
+		err = recordMovements(ctx, tx, orderMovement(*order, *item, models.MovementSale, -item.Quantity, userID), allocations)
+		if err != nil {
+			return err
+		}
+	}
+	order.Status = models.OrderStatusPaid
+	return tx.QueryRowContext(ctx,
+		"UPDATE orders SET status = $1, paid_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING updated_at, paid_at",
+		order.Status, order.ID).Scan(&order.UpdatedAt, &order.PaidAt)
+}
+
+// refundOrder возвращает товары оплаченного заказа в те же партии аптеки и отпущенные единицы на рецепты
+// от имени userID
+func refundOrder(ctx context.Context, tx *sql.Tx, order *models.Order, userID *int) error {
+	for _, item := range order.Items {
+		if err := restorePrescription(ctx, tx, item); err != nil {
+			return err
+		}
+		for _, allocation := range item.Allocations {
+			if _, err := tx.ExecContext(ctx, "UPDATE medicine_lots SET quantity = quantity + $1 WHERE id = $2", allocation.Quantity, allocation.LotID); err != nil {
+				return err
+			}
+		}
+		if err := addStock(ctx, tx, order.PharmacyID, item.MedicineID, item.Quantity); err != nil {
+			return err
+		}
+		err := recordMovements(ctx, tx, orderMovement(*order, item, models.MovementReturn, item.Quantity, userID), item.Allocations)
+		if err != nil {
+			return err
+		}
+	}
+	return setOrderStatus(ctx, tx, order, models.OrderStatusRefunded)
+}
+
+// orderMovement описывает движение остатка по позиции заказа
+func orderMovement(order models.Order, item models.OrderItem, movementType string, quantity int, userID *int) models.StockMovement {
+	orderID := order.ID
+	return models.StockMovement{
+		PharmacyID:    order.PharmacyID,
+		MedicineID:    item.MedicineID,
+		Type:          movementType,
+		Quantity:      quantity,
+		ReferenceType: models.MovementRefOrder,
+		ReferenceID:   &orderID,
+		UserID:        userID,
+	}
+}
+
+func setOrderStatus(ctx context.Context, tx *sql.Tx, order *models.Order, status string) error {
+	order.Status = status
+	return tx.QueryRowContext(ctx, "UPDATE orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING updated_at",
+		status, order.ID).Scan(&order.UpdatedAt)
+}
+
+func (s *Store) CreateOrder(ctx context.Context, order *models.Order) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requirePharmacy(ctx, tx, order.PharmacyID); err != nil {
+			return fmt.Errorf("%w: pharmacy %d", err, order.PharmacyID)
+		}
+
+		// Фиксация действующих цен: переопределения в аптеке или цены каталога
+		order.Total = 0
+		for i := range order.Items {
+			item := &order.Items[i]
+			var err error
+			item.MedicineName, item.UnitPrice, err = effectivePrice(ctx, tx, order.PharmacyID, item.MedicineID)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+			}
+			if err != nil {
+				return err
+			}
+			if err := checkPrescription(ctx, tx, *item); err != nil {
+				return err
+			}
+			item.LineTotal = models.RoundMoney(item.UnitPrice * float64(item.Quantity))
+			item.Allocations = nil
+			order.Total = models.RoundMoney(order.Total + item.LineTotal)
+		}
+
+		err := tx.QueryRowContext(ctx,
+			"INSERT INTO orders(pharmacy_id, seller_id, status, total) VALUES($1, $2, $3, $4) RETURNING id, created_at, updated_at",
+			order.PharmacyID, order.SellerID, models.OrderStatusDraft, order.Total).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		for i := range order.Items {
+			item := &order.Items[i]
+			err := tx.QueryRowContext(ctx,
+				"INSERT INTO order_items(order_id, medicine_id, quantity, unit_price, line_total, prescription_id) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+				order.ID, item.MedicineID, item.Quantity, item.UnitPrice, item.LineTotal, item.PrescriptionID).Scan(&item.ID)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+
+		if order.Status == models.OrderStatusPaid {
+			return payOrder(ctx, tx, order, order.SellerID)
+		}
+		order.Status = models.OrderStatusDraft
+		return nil
+	})
+}
+
+func (s *Store) GetOrder(ctx context.Context, id int) (models.Order, error) {
+	return getOrder(ctx, s.db, id, false)
+}
+
+func (s *Store) ListOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	query := "SELECT " + orderColumns + " FROM orders WHERE 1 = 1"
+	var args []interface{}
+	if filter.PharmacyID != 0 {
+		args = append(args, filter.PharmacyID)
+		query += fmt.Sprintf(" AND pharmacy_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, loadOrderItems(ctx, s.db, orders)
+}
+
+func (s *Store) UpdateOrderStatus(ctx context.Context, id int, status string, userID *int) (models.Order, error) {
+	var order models.Order
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		order, err = getOrder(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !models.CanTransitionOrder(order.Status, status) {
+			return store.ErrInvalidTransition
+		}
+
+		switch status {
+		case models.OrderStatusPaid:
+			return payOrder(ctx, tx, &order, userID)
+		case models.OrderStatusRefunded:
+			return refundOrder(ctx, tx, &order, userID)
+		default:
+			return setOrderStatus(ctx, tx, &order, status)
+		}
+	})
+	return order, err
+}
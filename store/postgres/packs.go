@@ -0,0 +1,271 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+const packColumns = "p.id, p.medicine_id, COALESCE(m.name, ''), p.pharmacy_id, p.gtin, p.serial, p.lot_number, p.expiry_date, p.status, p.order_id, " +
+	"p.created_at, p.updated_at"
+
+const packFrom = "packs p JOIN medicines m ON m.id = p.medicine_id"
+
+func scanPack(row rowScanner) (models.Pack, error) {
+	var pack models.Pack
+	var expiryDate sql.NullTime
+	var orderID sql.NullInt64
+	err := row.Scan(&pack.ID, &pack.MedicineID, &pack.MedicineName, &pack.PharmacyID, &pack.GTIN, &pack.Serial, &pack.LotNumber,
+		&expiryDate, &pack.Status, &orderID, &pack.CreatedAt, &pack.UpdatedAt)
+	if err != nil {
+		return pack, err
+	}
+	if expiryDate.Valid {
+		pack.ExpiryDate = expiryDate.Time.Format(models.DateLayout)
+	}
+	pack.OrderID = nullInt(orderID)
+	return pack, nil
+}
+
+// loadPackEvents загружает историю статусов упаковки в порядке записи
+func loadPackEvents(ctx context.Context, q querier, pack *models.Pack) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, pack_id, pharmacy_id, COALESCE(from_status, ''), to_status, order_id, reason, user_id, created_at
+		FROM pack_events
+		WHERE pack_id = $1
+		ORDER BY id
+	`, pack.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	pack.Events = []models.PackEvent{}
+	for rows.Next() {
+		var event models.PackEvent
+		var orderID, userID sql.NullInt64
+		err := rows.Scan(&event.ID, &event.PackID, &event.PharmacyID, &event.FromStatus, &event.ToStatus, &orderID, &event.Reason,
+			&userID, &event.CreatedAt)
+		if err != nil {
+			return err
+		}
+		event.OrderID = nullInt(orderID)
+		event.UserID = nullInt(userID)
+		pack.Events = append(pack.Events, event)
+	}
+	return rows.Err()
+}
+
+// recordPackEvent записывает смену статуса упаковки
+func recordPackEvent(ctx context.Context, tx *sql.Tx, event models.PackEvent) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO pack_events(pack_id, pharmacy_id, from_status, to_status, order_id, reason, user_id)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+	`, event.PackID, event.PharmacyID, nullString(event.FromStatus), event.ToStatus, event.OrderID, event.Reason, event.UserID)
+	return mapError(err)
+}
+
+// checkPackOrder проверяет, что по заказу можно продать упаковку: заказ оплачен в аптеке упаковки,
+// содержит её лекарство, и проданных по нему упаковок этого лекарства меньше, чем единиц в заказе
+func checkPackOrder(ctx context.Context, tx *sql.Tx, pack models.Pack, orderID int) error {
+	var pharmacyID int
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT pharmacy_id, status FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&pharmacyID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &store.NotFoundError{Field: "order_id", ID: orderID}
+		}
+		return err
+	}
+	if status != models.OrderStatusPaid {
+		return fmt.Errorf("%w: order %d is %s", store.ErrConflict, orderID, status)
+	}
+	if pharmacyID != pack.PharmacyID {
+		return fmt.Errorf("%w: order %d belongs to another pharmacy", store.ErrConflict, orderID)
+	}
+
+	var ordered, dispensed int
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COALESCE(SUM(quantity), 0) FROM order_items WHERE order_id = $1 AND medicine_id = $2),
+			(SELECT COUNT(*) FROM packs WHERE order_id = $1 AND medicine_id = $2 AND status = 'dispensed')
+	`, orderID, pack.MedicineID).Scan(&ordered, &dispensed)
+	if err != nil {
+		return err
+	}
+	if dispensed >= ordered {
+		return fmt.Errorf("%w: order %d has no more units of medicine %d to dispense as packs", store.ErrConflict, orderID, pack.MedicineID)
+	}
+	return nil
+}
+
+func (s *Store) ListPacks(ctx context.Context, filter models.PackFilter, page models.PageRequest) (models.Page[models.Pack], error) {
+	q := listQuery{
+		columns: packColumns,
+		from:    packFrom,
+		idExpr:  "p.id",
+		sorts: map[string]sortColumn{
+			"id":         {"p.id", "int"},
+			"updated_at": {"p.updated_at", "timestamptz"},
+		},
+	}
+	if filter.PharmacyID != 0 {
+		q.filter("p.pharmacy_id = ?", filter.PharmacyID)
+	}
+	if filter.MedicineID != 0 {
+		q.filter("p.medicine_id = ?", filter.MedicineID)
+	}
+	if filter.Status != "" {
+		q.filter("p.status = ?", filter.Status)
+	}
+	if filter.GTIN != "" {
+		q.filter("p.gtin = ?", filter.GTIN)
+	}
+	if filter.Serial != "" {
+		q.filter("p.serial = ?", filter.Serial)
+	}
+	return queryPage(ctx, s.db, q, page, scanPack, func(p models.Pack) int { return p.ID })
+}
+
+func (s *Store) GetPack(ctx context.Context, id int) (models.Pack, error) {
+	pack, err := scanPack(s.db.QueryRowContext(ctx, "SELECT "+packColumns+" FROM "+packFrom+" WHERE p.id = $1", id))
+	if err != nil {
+		return pack, mapError(err)
+	}
+	return pack, loadPackEvents(ctx, s.db, &pack)
+}
+
+func (s *Store) RegisterPacks(ctx context.Context, packs []models.Pack, userID *int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for i := range packs {
+			pack := &packs[i]
+			if err := requirePharmacy(ctx, tx, pack.PharmacyID); err != nil {
+				return fmt.Errorf("%w: pharmacy %d", err, pack.PharmacyID)
+			}
+			err := tx.QueryRowContext(ctx, "SELECT id, COALESCE(name, '') FROM medicines WHERE gtin = $1", pack.GTIN).
+				Scan(&pack.MedicineID, &pack.MedicineName)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: no medicine with GTIN %s", store.ErrNotFound, pack.GTIN)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO packs(medicine_id, pharmacy_id, gtin, serial, lot_number, expiry_date, status)
+				VALUES($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (gtin, serial) DO NOTHING
+				RETURNING id, created_at, updated_at
+			`, pack.MedicineID, pack.PharmacyID, pack.GTIN, pack.Serial, pack.LotNumber, nullString(pack.ExpiryDate), models.PackStatusReceived,
+			).Scan(&pack.ID, &pack.CreatedAt, &pack.UpdatedAt)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: pack %s/%s is already registered", store.ErrConflict, pack.GTIN, pack.Serial)
+			}
+			if err != nil {
+				return mapError(err)
+			}
+			pack.Status = models.PackStatusReceived
+			pack.OrderID = nil
+
+			event := models.PackEvent{PackID: pack.ID, PharmacyID: pack.PharmacyID, ToStatus: pack.Status, UserID: userID}
+			if err := recordPackEvent(ctx, tx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) UpdatePackStatus(ctx context.Context, id int, change models.PackStatusChange) (models.Pack, error) {
+	var pack models.Pack
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		pack, err = scanPack(tx.QueryRowContext(ctx, "SELECT "+packColumns+" FROM "+packFrom+" WHERE p.id = $1 FOR UPDATE OF p", id))
+		if err != nil {
+			return mapError(err)
+		}
+		if !models.CanTransitionPack(pack.Status, change.Status) {
+			return store.ErrInvalidTransition
+		}
+		if change.OrderID != nil {
+			if err := checkPackOrder(ctx, tx, pack, *change.OrderID); err != nil {
+				return err
+			}
+		}
+
+		event := models.PackEvent{
+			PackID:     pack.ID,
+			PharmacyID: pack.PharmacyID,
+			FromStatus: pack.Status,
+			ToStatus:   change.Status,
+			OrderID:    change.OrderID,
+			Reason:     change.Reason,
+			UserID:     change.UserID,
+		}
+		// Заказ хранится у упаковки, только пока она продана
+		pack.Status, pack.OrderID = change.Status, change.OrderID
+		err = tx.QueryRowContext(ctx, "UPDATE packs SET status = $1, order_id = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at",
+			pack.Status, pack.OrderID, pack.ID).Scan(&pack.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if err := recordPackEvent(ctx, tx, event); err != nil {
+			return err
+		}
+		return loadPackEvents(ctx, tx, &pack)
+	})
+	return pack, err
+}
+
+func (s *Store) ListDispensingEvents(ctx context.Context, filter models.DispensingFilter) ([]models.DispensingEvent, error) {
+	if filter.PharmacyID != 0 {
+		if err := requirePharmacy(ctx, s.db, filter.PharmacyID); err != nil {
+			return nil, err
+		}
+	}
+
+	q := listQuery{}
+	q.filter("e.to_status = ?", models.PackStatusDispensed)
+	if filter.PharmacyID != 0 {
+		q.filter("e.pharmacy_id = ?", filter.PharmacyID)
+	}
+	if filter.From != nil {
+		q.filter("e.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q.filter("e.created_at < ?", *filter.To)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.id, e.created_at, e.pharmacy_id, p.gtin, p.serial, p.lot_number, p.expiry_date, e.order_id
+		FROM pack_events e
+		JOIN packs p ON p.id = e.pack_id`+whereClause(q.where)+`
+		ORDER BY e.created_at, e.id
+	`, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.DispensingEvent{}
+	for rows.Next() {
+		var event models.DispensingEvent
+		var dispensedAt time.Time
+		var expiryDate sql.NullTime
+		var orderID sql.NullInt64
+		err := rows.Scan(&event.ID, &dispensedAt, &event.PharmacyID, &event.GTIN, &event.Serial, &event.LotNumber, &expiryDate, &orderID)
+		if err != nil {
+			return nil, err
+		}
+		event.DispensedAt = dispensedAt.UTC().Format(time.RFC3339)
+		if expiryDate.Valid {
+			event.ExpiryDate = expiryDate.Time.Format(models.DateLayout)
+		}
+		event.OrderID = nullInt(orderID)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"pharmacy-test/models"
+)
+
+// sortColumn описывает поле сортировки: SQL-выражение и тип, к которому приводится значение курсора
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// listQuery — выборка списка с фильтрами, из которой строятся запрос страницы и подсчёт total
+type listQuery struct {
+	columns string
+	from    string
+	idExpr  string
+	sorts   map[string]sortColumn
+	where   []string
+	args    []interface{}
+}
+
+// filter добавляет условие; "?" в условии заменяется на номер нового аргумента
+func (q *listQuery) filter(condition string, arg interface{}) {
+	q.args = append(q.args, arg)
+	q.where = append(q.where, strings.Replace(condition, "?", fmt.Sprintf("$%d", len(q.args)), 1))
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// appendScanner дополняет сканирование строки дополнительными колонками в конце выборки
+type appendScanner struct {
+	rowScanner
+	extra []interface{}
+}
+
+func (s appendScanner) Scan(dest ...interface{}) error {
+	return s.rowScanner.Scan(append(dest, s.extra...)...)
+}
+
+// queryPage выбирает страницу по ключу (поле сортировки, id) и считает общее число записей под фильтром
+func queryPage[T any](ctx context.Context, db querier, q listQuery, page models.PageRequest,
+	scan func(rowScanner) (T, error), id func(T) int) (models.Page[T], error) {
+	result := models.Page[T]{Items: []T{}}
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+q.from+whereClause(q.where), q.args...).Scan(&result.Total); err != nil {
+		return result, err
+	}
+
+	column, ok := q.sorts[page.Sort]
+	if !ok {
+		return result, fmt.Errorf("unknown sort field %q", page.Sort)
+	}
+	direction, op := "ASC", ">"
+	if page.Desc {
+		direction, op = "DESC", "<"
+	}
+
+	where := append([]string{}, q.where...)
+	args := append([]interface{}{}, q.args...)
+	if page.After != nil {
+		args = append(args, page.After.Value, page.After.ID)
+		where = append(where, fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", column.expr, q.idExpr, op, len(args)-1, column.cast, len(args)))
+	}
+	args = append(args, page.Limit+1)
+	query := fmt.Sprintf("SELECT %s, (%s)::text FROM %s%s ORDER BY %s %s, %s %s LIMIT $%d",
+		q.columns, column.expr, q.from, whereClause(where), column.expr, direction, q.idExpr, direction, len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		item, err := scan(appendScanner{rows, []interface{}{&key}})
+		if err != nil {
+			return result, err
+		}
+		result.Items = append(result.Items, item)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	// Лишняя запись означает, что есть следующая страница
+	if len(result.Items) > page.Limit {
+		result.Items = result.Items[:page.Limit]
+		last := page.Limit - 1
+		result.NextCursor = page.NextCursor(keys[last], id(result.Items[last]))
+	}
+	return result, nil
+}
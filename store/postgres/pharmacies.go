@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+const pharmacyColumns = `p.id, p.name, COALESCE(a.id, 0), COALESCE(a.street, ''), COALESCE(a.city, ''),
+	COALESCE(a.state, ''), COALESCE(a.postal_code, ''), COALESCE(a.country, ''), a.latitude, a.longitude, p.timezone`
+
+func scanPharmacy(row rowScanner) (models.Pharmacy, error) {
+	var pharmacy models.Pharmacy
+	var latitude, longitude sql.NullFloat64
+	err := row.Scan(&pharmacy.ID, &pharmacy.Name, &pharmacy.Address.ID, &pharmacy.Address.Street, &pharmacy.Address.City,
+		&pharmacy.Address.State, &pharmacy.Address.PostalCode, &pharmacy.Address.Country, &latitude, &longitude, &pharmacy.Timezone)
+	if latitude.Valid && longitude.Valid {
+		pharmacy.Address.Latitude, pharmacy.Address.Longitude = &latitude.Float64, &longitude.Float64
+	}
+	return pharmacy, err
+}
+
+func (s *Store) ListPharmacies(ctx context.Context, filter models.PharmacyFilter, page models.PageRequest) (models.Page[models.Pharmacy], error) {
+	q := listQuery{
+		columns: pharmacyColumns,
+		from:    "pharmacies p LEFT JOIN addresses a ON a.id = p.address_id",
+		idExpr:  "p.id",
+		sorts: map[string]sortColumn{
+			"id":   {"p.id", "int"},
+			"name": {"COALESCE(p.name, '')", "text"},
+			"city": {"COALESCE(a.city, '')", "text"},
+		},
+	}
+	if filter.City != "" {
+		q.filter("lower(a.city) = lower(?)", filter.City)
+	}
+	if filter.Country != "" {
+		q.filter("lower(a.country) = lower(?)", filter.Country)
+	}
+	if filter.OpenAt != nil {
+		q.filter("pharmacy_is_open(p.id, ?)", *filter.OpenAt)
+	}
+	result, err := queryPage(ctx, s.db, q, page, scanPharmacy, func(p models.Pharmacy) int { return p.ID })
+	if err != nil {
+		return result, err
+	}
+	return result, loadSchedules(ctx, s.db, result.Items)
+}
+
+func (s *Store) GetPharmacy(ctx context.Context, id int) (models.Pharmacy, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+pharmacyColumns+" FROM pharmacies p LEFT JOIN addresses a ON a.id = p.address_id WHERE p.id = $1", id)
+	pharmacy, err := scanPharmacy(row)
+	if err != nil {
+		return pharmacy, mapError(err)
+	}
+	pharmacies := []models.Pharmacy{pharmacy}
+	err = loadSchedules(ctx, s.db, pharmacies)
+	return pharmacies[0], err
+}
+
+func (s *Store) CreatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		address := &pharmacy.Address
+		if err := insertAddress(ctx, tx, address); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx, "INSERT INTO pharmacies(name, address_id, timezone) VALUES($1, $2, $3) RETURNING id",
+			pharmacy.Name, address.ID, pharmacy.Timezone).Scan(&pharmacy.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		return saveSchedule(ctx, tx, pharmacy)
+	})
+}
+
+func (s *Store) UpdatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var addressID sql.NullInt64
+		err := tx.QueryRowContext(ctx, "SELECT address_id FROM pharmacies WHERE id = $1 FOR UPDATE", pharmacy.ID).Scan(&addressID)
+		if err != nil {
+			return mapError(err)
+		}
+
+		address := &pharmacy.Address
+		if addressID.Valid {
+			address.ID = int(addressID.Int64)
+			_, err = tx.ExecContext(ctx,
+				`UPDATE addresses SET street = $1, city = $2, state = $3, postal_code = $4, country = $5,
+					latitude = $6, longitude = $7 WHERE id = $8`,
+				address.Street, address.City, address.State, address.PostalCode, address.Country,
+				address.Latitude, address.Longitude, address.ID)
+			err = mapError(err)
+		} else {
+			err = insertAddress(ctx, tx, address)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE pharmacies SET name = $1, address_id = $2, timezone = $3 WHERE id = $4",
+			pharmacy.Name, address.ID, pharmacy.Timezone, pharmacy.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		return saveSchedule(ctx, tx, pharmacy)
+	})
+}
+
+func (s *Store) DeletePharmacy(ctx context.Context, id int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var addressID sql.NullInt64
+		err := tx.QueryRowContext(ctx, "DELETE FROM pharmacies WHERE id = $1 RETURNING address_id", id).Scan(&addressID)
+		if err != nil {
+			return mapError(err)
+		}
+		if addressID.Valid {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM addresses WHERE id = $1", addressID.Int64); err != nil {
+				return mapError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// insertAddress сохраняет адрес вместе с координатами и заполняет его id
+func insertAddress(ctx context.Context, tx *sql.Tx, address *models.Address) error {
+	err := tx.QueryRowContext(ctx,
+		"INSERT INTO addresses(street, city, state, postal_code, country, latitude, longitude) VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+		address.Street, address.City, address.State, address.PostalCode, address.Country, address.Latitude, address.Longitude,
+	).Scan(&address.ID)
+	return mapError(err)
+}
+
+// requirePharmacy возвращает store.ErrNotFound, если аптеки не существует
+func requirePharmacy(ctx context.Context, q querier, id int) error {
+	found, err := exists(ctx, q, "pharmacies", id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// requirePharmacyField как requirePharmacy, но отсутствие аптеки, указанной в поле запроса field, даёт store.NotFoundError
+func requirePharmacyField(ctx context.Context, q querier, field string, id int) error {
+	err := requirePharmacy(ctx, q, id)
+	if err == store.ErrNotFound {
+		return &store.NotFoundError{Field: field, ID: id}
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+// Package postgres реализует слой хранения поверх PostgreSQL.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+// Store реализует store.Store поверх пула подключений к PostgreSQL
+type Store struct {
+	db *sql.DB
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New создаёт хранилище, использующее переданный пул подключений
+func New(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// withTx выполняет fn в транзакции и фиксирует её, если fn не вернула ошибку
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// uniqueFields сопоставляет ограничения уникальности с полями API
+var uniqueFields = map[string]string{
+	"users_username_key":            "username",
+	"user_details_email_key":        "email",
+	"user_details_phone_number_key": "phone_number",
+	"roles_pkey":                    "name",
+	"medicine_lots_medicine_id_pharmacy_id_lot_number_key": "lot_number",
+	"prescriptions_number_key":                             "number",
+	"suppliers_name_key":                                   "name",
+	"medicines_gtin_key":                                   "gtin",
+}
+
+// mapError переводит ошибки PostgreSQL в ошибки пакета store
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			if field, ok := uniqueFields[pqErr.Constraint]; ok {
+				return &store.ConflictError{Field: field}
+			}
+			return fmt.Errorf("%w: duplicate value", store.ErrConflict)
+		case "23503": // foreign_key_violation
+			return fmt.Errorf("%w: record is referenced by other data or refers to a missing one", store.ErrConflict)
+		}
+	}
+	return err
+}
+
+// expectAffected возвращает store.ErrNotFound, если запрос не затронул ни одной строки
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// exists проверяет наличие строки с указанным id в таблице
+func exists(ctx context.Context, q querier, table string, id int) (bool, error) {
+	var found bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&found)
+	return found, err
+}
+
+// nullString превращает пустую строку в NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
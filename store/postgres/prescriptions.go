@@ -0,0 +1,325 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const prescriptionColumns = "id, number, patient_name, patient_birth_date, prescriber_name, prescriber_license, " +
+	"issued_on, valid_until, created_by, created_at, cancelled_at"
+
+func scanPrescription(row rowScanner) (models.Prescription, error) {
+	var prescription models.Prescription
+	var birthDate, cancelledAt sql.NullTime
+	var issuedOn, validUntil time.Time
+	var createdBy sql.NullInt64
+	err := row.Scan(&prescription.ID, &prescription.Number, &prescription.PatientName, &birthDate,
+		&prescription.PrescriberName, &prescription.PrescriberLicense, &issuedOn, &validUntil,
+		&createdBy, &prescription.CreatedAt, &cancelledAt)
+	if err != nil {
+		return prescription, err
+	}
+	if birthDate.Valid {
+		prescription.PatientBirthDate = birthDate.Time.Format(models.DateLayout)
+	}
+	prescription.IssuedOn = issuedOn.Format(models.DateLayout)
+	prescription.ValidUntil = validUntil.Format(models.DateLayout)
+	prescription.CreatedBy = nullInt(createdBy)
+	if cancelledAt.Valid {
+		prescription.CancelledAt = &cancelledAt.Time
+	}
+	prescription.Items = []models.PrescriptionItem{}
+	return prescription, nil
+}
+
+// loadPrescriptionItems загружает позиции и отпуски для набора рецептов
+func loadPrescriptionItems(ctx context.Context, q querier, prescriptions []models.Prescription) error {
+	if len(prescriptions) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Prescription, len(prescriptions))
+	ids := make([]int64, 0, len(prescriptions))
+	for i := range prescriptions {
+		index[prescriptions[i].ID] = &prescriptions[i]
+		ids = append(ids, int64(prescriptions[i].ID))
+	}
+
+	dispensingRows, err := q.QueryContext(ctx, `
+		SELECT d.prescription_item_id, oi.order_id, d.order_item_id, d.quantity, d.fill, d.dispensed_at, d.refunded_at
+		FROM prescription_dispensings d
+		JOIN prescription_items pi ON pi.id = d.prescription_item_id
+		JOIN order_items oi ON oi.id = d.order_item_id
+		WHERE pi.prescription_id = ANY($1)
+		ORDER BY d.dispensed_at, d.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	dispensings := map[int][]models.PrescriptionDispensing{}
+	for dispensingRows.Next() {
+		var itemID int
+		var dispensing models.PrescriptionDispensing
+		var refundedAt sql.NullTime
+		err := dispensingRows.Scan(&itemID, &dispensing.OrderID, &dispensing.OrderItemID, &dispensing.Quantity, &dispensing.Fill,
+			&dispensing.DispensedAt, &refundedAt)
+		if err != nil {
+			dispensingRows.Close()
+			return err
+		}
+		if refundedAt.Valid {
+			dispensing.RefundedAt = &refundedAt.Time
+		}
+		dispensings[itemID] = append(dispensings[itemID], dispensing)
+	}
+	dispensingRows.Close()
+	if err := dispensingRows.Err(); err != nil {
+		return err
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT pi.prescription_id, pi.id, pi.medicine_id, COALESCE(m.name, ''), pi.quantity, pi.refills,
+			pi.remaining_quantity, pi.remaining_refills
+		FROM prescription_items pi
+		JOIN medicines m ON m.id = pi.medicine_id
+		WHERE pi.prescription_id = ANY($1)
+		ORDER BY pi.prescription_id, pi.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var prescriptionID int
+		var item models.PrescriptionItem
+		err := rows.Scan(&prescriptionID, &item.ID, &item.MedicineID, &item.MedicineName, &item.Quantity, &item.Refills,
+			&item.RemainingQuantity, &item.RemainingRefills)
+		if err != nil {
+			return err
+		}
+		item.Dispensings = dispensings[item.ID]
+		prescription := index[prescriptionID]
+		prescription.Items = append(prescription.Items, item)
+	}
+	return rows.Err()
+}
+
+// getPrescription загружает рецепт вместе с позициями
+func getPrescription(ctx context.Context, q querier, id int, forUpdate bool) (models.Prescription, error) {
+	query := "SELECT " + prescriptionColumns + " FROM prescriptions WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	prescription, err := scanPrescription(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return prescription, mapError(err)
+	}
+	prescriptions := []models.Prescription{prescription}
+	if err := loadPrescriptionItems(ctx, q, prescriptions); err != nil {
+		return prescription, err
+	}
+	return prescriptions[0], nil
+}
+
+// checkPrescription проверяет, что рецептурная позиция заказа ссылается на рецепт с этим лекарством
+func checkPrescription(ctx context.Context, q querier, item models.OrderItem) error {
+	var rxRequired bool
+	if err := q.QueryRowContext(ctx, "SELECT rx_required FROM medicines WHERE id = $1", item.MedicineID).Scan(&rxRequired); err != nil {
+		return mapError(err)
+	}
+	if item.PrescriptionID == nil {
+		if rxRequired {
+			return fmt.Errorf("%w: medicine %d", store.ErrPrescriptionRequired, item.MedicineID)
+		}
+		return nil
+	}
+
+	var found, included bool
+	err := q.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM prescriptions WHERE id = $1),
+			EXISTS(SELECT 1 FROM prescription_items WHERE prescription_id = $1 AND medicine_id = $2)
+	`, *item.PrescriptionID, item.MedicineID).Scan(&found, &included)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: prescription %d", store.ErrNotFound, *item.PrescriptionID)
+	}
+	if !included {
+		return fmt.Errorf("%w: prescription %d does not include medicine %d", store.ErrPrescriptionInvalid, *item.PrescriptionID, item.MedicineID)
+	}
+	return nil
+}
+
+// dispensePrescription списывает количество позиции заказа с её рецепта. Строка рецепта блокируется,
+// поэтому одновременные продажи по одному рецепту не отпустят больше назначенного
+func dispensePrescription(ctx context.Context, tx *sql.Tx, item models.OrderItem) error {
+	if item.PrescriptionID == nil {
+		return nil
+	}
+	prescription, err := getPrescription(ctx, tx, *item.PrescriptionID, true)
+	if err != nil {
+		return err
+	}
+	if status := prescription.StatusAt(time.Now()); status != models.PrescriptionActive {
+		return fmt.Errorf("%w: prescription %d is %s", store.ErrPrescriptionInvalid, prescription.ID, status)
+	}
+	prescribed := prescription.Item(item.MedicineID)
+	if prescribed == nil {
+		return fmt.Errorf("%w: prescription %d does not include medicine %d", store.ErrPrescriptionInvalid, prescription.ID, item.MedicineID)
+	}
+	fill := prescribed.CurrentFill()
+	if !prescribed.Dispense(item.Quantity) {
+		return fmt.Errorf("%w: prescription %d allows at most %d units of medicine %d now",
+			store.ErrPrescriptionInvalid, prescription.ID, prescribed.RemainingQuantity, item.MedicineID)
+	}
+
+	if err := savePrescriptionItem(ctx, tx, *prescribed); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO prescription_dispensings(prescription_item_id, order_item_id, quantity, fill) VALUES($1, $2, $3, $4)",
+		prescribed.ID, item.ID, item.Quantity, fill)
+	return err
+}
+
+// restorePrescription возвращает на рецепт единицы, отпущенные позицией заказа, и отмечает отпуск возвращённым.
+// Вызывается при возврате заказа в той же транзакции, что и возврат на склад
+func restorePrescription(ctx context.Context, tx *sql.Tx, item models.OrderItem) error {
+	if item.PrescriptionID == nil {
+		return nil
+	}
+	prescription, err := getPrescription(ctx, tx, *item.PrescriptionID, true)
+	if err != nil {
+		return err
+	}
+	prescribed := prescription.Item(item.MedicineID)
+	if prescribed == nil {
+		return nil
+	}
+
+	var quantity int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE prescription_dispensings SET refunded_at = CURRENT_TIMESTAMP
+		WHERE order_item_id = $1 AND prescription_item_id = $2 AND refunded_at IS NULL
+		RETURNING quantity
+	`, item.ID, prescribed.ID).Scan(&quantity)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	prescribed.Restore(quantity)
+	return savePrescriptionItem(ctx, tx, *prescribed)
+}
+
+// savePrescriptionItem сохраняет остатки позиции рецепта
+func savePrescriptionItem(ctx context.Context, tx *sql.Tx, item models.PrescriptionItem) error {
+	_, err := tx.ExecContext(ctx, "UPDATE prescription_items SET remaining_quantity = $1, remaining_refills = $2 WHERE id = $3",
+		item.RemainingQuantity, item.RemainingRefills, item.ID)
+	return err
+}
+
+func (s *Store) ListPrescriptions(ctx context.Context, filter models.PrescriptionFilter) ([]models.Prescription, error) {
+	query := "SELECT " + prescriptionColumns + " FROM prescriptions WHERE 1 = 1"
+	var args []interface{}
+	if filter.Number != "" {
+		args = append(args, filter.Number)
+		query += fmt.Sprintf(" AND number = $%d", len(args))
+	}
+	if filter.Patient != "" {
+		args = append(args, filter.Patient)
+		query += fmt.Sprintf(" AND strpos(lower(patient_name), lower($%d)) > 0", len(args))
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	prescriptions := []models.Prescription{}
+	for rows.Next() {
+		prescription, err := scanPrescription(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		prescriptions = append(prescriptions, prescription)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prescriptions, loadPrescriptionItems(ctx, s.db, prescriptions)
+}
+
+func (s *Store) GetPrescription(ctx context.Context, id int) (models.Prescription, error) {
+	return getPrescription(ctx, s.db, id, false)
+}
+
+func (s *Store) CreatePrescription(ctx context.Context, prescription *models.Prescription) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for i := range prescription.Items {
+			item := &prescription.Items[i]
+			err := tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", item.MedicineID).Scan(&item.MedicineName)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO prescriptions(number, patient_name, patient_birth_date, prescriber_name, prescriber_license,
+				issued_on, valid_until, created_by)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at
+		`, prescription.Number, prescription.PatientName, nullString(prescription.PatientBirthDate), prescription.PrescriberName,
+			prescription.PrescriberLicense, prescription.IssuedOn, prescription.ValidUntil, prescription.CreatedBy,
+		).Scan(&prescription.ID, &prescription.CreatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		for i := range prescription.Items {
+			item := &prescription.Items[i]
+			item.Dispensings = nil
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO prescription_items(prescription_id, medicine_id, quantity, refills, remaining_quantity, remaining_refills)
+				VALUES($1, $2, $3, $4, $5, $6) RETURNING id
+			`, prescription.ID, item.MedicineID, item.Quantity, item.Refills, item.RemainingQuantity, item.RemainingRefills).Scan(&item.ID)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		prescription.CancelledAt = nil
+		return nil
+	})
+}
+
+func (s *Store) CancelPrescription(ctx context.Context, id int) (models.Prescription, error) {
+	var prescription models.Prescription
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		prescription, err = getPrescription(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if prescription.CancelledAt != nil {
+			return fmt.Errorf("%w: prescription is already cancelled", store.ErrInvalidTransition)
+		}
+		var cancelledAt time.Time
+		err = tx.QueryRowContext(ctx, "UPDATE prescriptions SET cancelled_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING cancelled_at", id).
+			Scan(&cancelledAt)
+		prescription.CancelledAt = &cancelledAt
+		return err
+	})
+	return prescription, err
+}
@@ -0,0 +1,297 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+const scheduledPriceColumns = "id, medicine_id, pharmacy_id, price, effective_at, created_by, created_at, applied_at, cancelled_at"
+
+func scanScheduledPrice(row rowScanner) (models.ScheduledPrice, error) {
+	var price models.ScheduledPrice
+	var pharmacyID, createdBy sql.NullInt64
+	var appliedAt, cancelledAt sql.NullTime
+	err := row.Scan(&price.ID, &price.MedicineID, &pharmacyID, &price.Price, &price.EffectiveAt, &createdBy,
+		&price.CreatedAt, &appliedAt, &cancelledAt)
+	if err != nil {
+		return price, err
+	}
+	price.PharmacyID = nullInt(pharmacyID)
+	price.CreatedBy = nullInt(createdBy)
+	price.Status = models.ScheduledPricePending
+	if appliedAt.Valid {
+		price.AppliedAt = &appliedAt.Time
+		price.Status = models.ScheduledPriceApplied
+	}
+	if cancelledAt.Valid {
+		price.CancelledAt = &cancelledAt.Time
+		price.Status = models.ScheduledPriceCancelled
+	}
+	return price, nil
+}
+
+// nullInt превращает NULL в nil
+func nullInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	n := int(value.Int64)
+	return &n
+}
+
+// nullFloat превращает NULL в nil
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+// recordPrice добавляет запись в историю цен
+func recordPrice(ctx context.Context, tx *sql.Tx, change *models.PriceChange) error {
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO medicine_price_history(medicine_id, pharmacy_id, old_price, new_price, changed_by, scheduled_price_id)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING id, changed_at
+	`, change.MedicineID, change.PharmacyID, change.OldPrice, change.NewPrice, change.ChangedBy, change.ScheduledPriceID,
+	).Scan(&change.ID, &change.ChangedAt)
+}
+
+// changePrice меняет цену каталога или переопределение в аптеке и записывает изменение в историю
+func changePrice(ctx context.Context, tx *sql.Tx, change *models.PriceChange) error {
+	var old sql.NullFloat64
+	if change.PharmacyID == nil {
+		err := tx.QueryRowContext(ctx, "SELECT price FROM medicines WHERE id = $1 FOR UPDATE", change.MedicineID).Scan(&old)
+		if err != nil {
+			return mapError(err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE medicines SET price = $1 WHERE id = $2", change.NewPrice, change.MedicineID); err != nil {
+			return mapError(err)
+		}
+	} else {
+		if err := requirePharmacyField(ctx, tx, "pharmacy_id", *change.PharmacyID); err != nil {
+			return err
+		}
+		// Блокировка лекарства упорядочивает одновременные изменения его цен
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM medicines WHERE id = $1 FOR UPDATE", change.MedicineID).Scan(new(int)); err != nil {
+			return mapError(err)
+		}
+		err := tx.QueryRowContext(ctx, "SELECT price FROM medicine_price_overrides WHERE pharmacy_id = $1 AND medicine_id = $2",
+			*change.PharmacyID, change.MedicineID).Scan(&old)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if change.NewPrice == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM medicine_price_overrides WHERE pharmacy_id = $1 AND medicine_id = $2",
+				*change.PharmacyID, change.MedicineID)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO medicine_price_overrides(pharmacy_id, medicine_id, price) VALUES($1, $2, $3)
+				ON CONFLICT (pharmacy_id, medicine_id) DO UPDATE SET price = EXCLUDED.price
+			`, *change.PharmacyID, change.MedicineID, *change.NewPrice)
+		}
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	change.OldPrice = nullFloat(old)
+	return recordPrice(ctx, tx, change)
+}
+
+// effectivePrice возвращает название лекарства и его цену в аптеке с учётом переопределения
+func effectivePrice(ctx context.Context, q querier, pharmacyID, medicineID int) (string, float64, error) {
+	var name string
+	var price float64
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(m.name, ''), COALESCE(o.price, m.price, 0)
+		FROM medicines m
+		LEFT JOIN medicine_price_overrides o ON o.medicine_id = m.id AND o.pharmacy_id = $2
+		WHERE m.id = $1
+	`, medicineID, pharmacyID).Scan(&name, &price)
+	return name, price, err
+}
+
+func (s *Store) GetMedicinePrices(ctx context.Context, medicineID int) (models.MedicinePrices, error) {
+	prices := models.MedicinePrices{
+		MedicineID: medicineID,
+		Overrides:  []models.PriceOverride{},
+		Scheduled:  []models.ScheduledPrice{},
+		History:    []models.PriceChange{},
+	}
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(price, 0) FROM medicines WHERE id = $1", medicineID).Scan(&prices.CatalogPrice)
+	if err != nil {
+		return prices, mapError(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT o.pharmacy_id, p.name, o.price
+		FROM medicine_price_overrides o
+		JOIN pharmacies p ON p.id = o.pharmacy_id
+		WHERE o.medicine_id = $1
+		ORDER BY o.pharmacy_id
+	`, medicineID)
+	if err != nil {
+		return prices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var override models.PriceOverride
+		if err := rows.Scan(&override.PharmacyID, &override.PharmacyName, &override.Price); err != nil {
+			return prices, err
+		}
+		prices.Overrides = append(prices.Overrides, override)
+	}
+	if err := rows.Err(); err != nil {
+		return prices, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT "+scheduledPriceColumns+` FROM medicine_scheduled_prices
+		WHERE medicine_id = $1 AND applied_at IS NULL AND cancelled_at IS NULL
+		ORDER BY effective_at, id`, medicineID)
+	if err != nil {
+		return prices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		price, err := scanScheduledPrice(rows)
+		if err != nil {
+			return prices, err
+		}
+		prices.Scheduled = append(prices.Scheduled, price)
+	}
+	if err := rows.Err(); err != nil {
+		return prices, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT id, medicine_id, pharmacy_id, old_price, new_price, changed_by, changed_at, scheduled_price_id
+		FROM medicine_price_history
+		WHERE medicine_id = $1
+		ORDER BY changed_at DESC, id DESC
+	`, medicineID)
+	if err != nil {
+		return prices, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var change models.PriceChange
+		var pharmacyID, changedBy, scheduledID sql.NullInt64
+		var oldPrice, newPrice sql.NullFloat64
+		err := rows.Scan(&change.ID, &change.MedicineID, &pharmacyID, &oldPrice, &newPrice, &changedBy, &change.ChangedAt, &scheduledID)
+		if err != nil {
+			return prices, err
+		}
+		change.PharmacyID = nullInt(pharmacyID)
+		change.OldPrice, change.NewPrice = nullFloat(oldPrice), nullFloat(newPrice)
+		change.ChangedBy = nullInt(changedBy)
+		change.ScheduledPriceID = nullInt(scheduledID)
+		prices.History = append(prices.History, change)
+	}
+	return prices, rows.Err()
+}
+
+func (s *Store) ChangePrice(ctx context.Context, change *models.PriceChange) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		return changePrice(ctx, tx, change)
+	})
+}
+
+func (s *Store) SchedulePrice(ctx context.Context, price *models.ScheduledPrice) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		found, err := exists(ctx, tx, "medicines", price.MedicineID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return store.ErrNotFound
+		}
+		if price.PharmacyID != nil {
+			if err := requirePharmacyField(ctx, tx, "pharmacy_id", *price.PharmacyID); err != nil {
+				return err
+			}
+		}
+
+		row := tx.QueryRowContext(ctx, `
+			INSERT INTO medicine_scheduled_prices(medicine_id, pharmacy_id, price, effective_at, created_by)
+			VALUES($1, $2, $3, $4, $5) RETURNING `+scheduledPriceColumns,
+			price.MedicineID, price.PharmacyID, price.Price, price.EffectiveAt, price.CreatedBy)
+		stored, err := scanScheduledPrice(row)
+		if err != nil {
+			return mapError(err)
+		}
+		*price = stored
+		return nil
+	})
+}
+
+func (s *Store) CancelScheduledPrice(ctx context.Context, medicineID, id int) (models.ScheduledPrice, error) {
+	var price models.ScheduledPrice
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		price, err = scanScheduledPrice(tx.QueryRowContext(ctx,
+			"SELECT "+scheduledPriceColumns+" FROM medicine_scheduled_prices WHERE id = $1 AND medicine_id = $2 FOR UPDATE", id, medicineID))
+		if err != nil {
+			return mapError(err)
+		}
+		if price.Status != models.ScheduledPricePending {
+			return fmt.Errorf("%w: scheduled price is already %s", store.ErrInvalidTransition, price.Status)
+		}
+		price, err = scanScheduledPrice(tx.QueryRowContext(ctx,
+			"UPDATE medicine_scheduled_prices SET cancelled_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING "+scheduledPriceColumns, id))
+		return err
+	})
+	return price, err
+}
+
+func (s *Store) ApplyDuePrices(ctx context.Context) (int, error) {
+	applied := 0
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// SKIP LOCKED позволяет нескольким репликам применять изменения без двойной обработки
+		rows, err := tx.QueryContext(ctx, "SELECT "+scheduledPriceColumns+` FROM medicine_scheduled_prices
+			WHERE applied_at IS NULL AND cancelled_at IS NULL AND effective_at <= CURRENT_TIMESTAMP
+			ORDER BY effective_at, id
+			FOR UPDATE SKIP LOCKED`)
+		if err != nil {
+			return err
+		}
+		var due []models.ScheduledPrice
+		for rows.Next() {
+			price, err := scanScheduledPrice(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			due = append(due, price)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, price := range due {
+			newPrice, scheduledID := price.Price, price.ID
+			change := models.PriceChange{
+				MedicineID:       price.MedicineID,
+				PharmacyID:       price.PharmacyID,
+				NewPrice:         &newPrice,
+				ChangedBy:        price.CreatedBy,
+				ScheduledPriceID: &scheduledID,
+			}
+			if err := changePrice(ctx, tx, &change); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "UPDATE medicine_scheduled_prices SET applied_at = CURRENT_TIMESTAMP WHERE id = $1", price.ID); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
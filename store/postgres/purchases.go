@@ -0,0 +1,414 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const supplierColumns = "id, name, tax_id, contact_name, phone, email, address, created_at"
+
+const purchaseOrderColumns = "po.id, po.pharmacy_id, po.supplier_id, s.name, po.status, po.notes, po.total, " +
+	"po.created_by, po.created_at, po.updated_at, po.sent_at"
+
+const purchaseOrderFrom = "purchase_orders po JOIN suppliers s ON s.id = po.supplier_id"
+
+func scanSupplier(row rowScanner) (models.Supplier, error) {
+	var supplier models.Supplier
+	err := row.Scan(&supplier.ID, &supplier.Name, &supplier.TaxID, &supplier.ContactName, &supplier.Phone,
+		&supplier.Email, &supplier.Address, &supplier.CreatedAt)
+	return supplier, err
+}
+
+func scanPurchaseOrder(row rowScanner) (models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	var createdBy sql.NullInt64
+	var sentAt sql.NullTime
+	err := row.Scan(&order.ID, &order.PharmacyID, &order.SupplierID, &order.SupplierName, &order.Status, &order.Notes,
+		&order.Total, &createdBy, &order.CreatedAt, &order.UpdatedAt, &sentAt)
+	if err != nil {
+		return order, err
+	}
+	order.CreatedBy = nullInt(createdBy)
+	if sentAt.Valid {
+		order.SentAt = &sentAt.Time
+	}
+	order.Items = []models.PurchaseOrderItem{}
+	return order, nil
+}
+
+// loadPurchaseOrderItems загружает позиции для набора заказов поставщикам
+func loadPurchaseOrderItems(ctx context.Context, q querier, orders []models.PurchaseOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.PurchaseOrder, len(orders))
+	ids := make([]int64, 0, len(orders))
+	for i := range orders {
+		index[orders[i].ID] = &orders[i]
+		ids = append(ids, int64(orders[i].ID))
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT poi.purchase_order_id, poi.id, poi.medicine_id, COALESCE(m.name, ''), poi.quantity, poi.received_quantity,
+			poi.unit_cost, poi.line_total
+		FROM purchase_order_items poi
+		JOIN medicines m ON m.id = poi.medicine_id
+		WHERE poi.purchase_order_id = ANY($1)
+		ORDER BY poi.purchase_order_id, poi.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var item models.PurchaseOrderItem
+		err := rows.Scan(&orderID, &item.ID, &item.MedicineID, &item.MedicineName, &item.Quantity, &item.ReceivedQuantity,
+			&item.UnitCost, &item.LineTotal)
+		if err != nil {
+			return err
+		}
+		order := index[orderID]
+		order.Items = append(order.Items, item)
+	}
+	return rows.Err()
+}
+
+// loadGoodsReceipts загружает приёмки заказа поставщику вместе с оприходованными партиями
+func loadGoodsReceipts(ctx context.Context, q querier, order *models.PurchaseOrder) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT gr.id, gr.received_by, gr.received_at, poi.medicine_id, l.id, l.lot_number, l.production_date, l.expiry_date, gri.quantity
+		FROM goods_receipts gr
+		JOIN goods_receipt_items gri ON gri.goods_receipt_id = gr.id
+		JOIN purchase_order_items poi ON poi.id = gri.purchase_order_item_id
+		JOIN medicine_lots l ON l.id = gri.lot_id
+		WHERE gr.purchase_order_id = $1
+		ORDER BY gr.received_at, gr.id, gri.id
+	`, order.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	order.Receipts = nil
+	for rows.Next() {
+		var receipt models.GoodsReceipt
+		var receivedBy sql.NullInt64
+		var item models.GoodsReceiptItem
+		var productionDate sql.NullTime
+		var expiryDate time.Time
+		err := rows.Scan(&receipt.ID, &receivedBy, &receipt.ReceivedAt, &item.MedicineID, &item.LotID, &item.LotNumber,
+			&productionDate, &expiryDate, &item.Quantity)
+		if err != nil {
+			return err
+		}
+		if productionDate.Valid {
+			item.ProductionDate = productionDate.Time.Format(models.DateLayout)
+		}
+		item.ExpiryDate = expiryDate.Format(models.DateLayout)
+
+		last := len(order.Receipts) - 1
+		if last < 0 || order.Receipts[last].ID != receipt.ID {
+			receipt.PurchaseOrderID = order.ID
+			receipt.ReceivedBy = nullInt(receivedBy)
+			order.Receipts = append(order.Receipts, receipt)
+			last++
+		}
+		order.Receipts[last].Items = append(order.Receipts[last].Items, item)
+	}
+	return rows.Err()
+}
+
+// getPurchaseOrder загружает заказ поставщику вместе с позициями
+func getPurchaseOrder(ctx context.Context, q querier, id int, forUpdate bool) (models.PurchaseOrder, error) {
+	query := "SELECT " + purchaseOrderColumns + " FROM " + purchaseOrderFrom + " WHERE po.id = $1"
+	if forUpdate {
+		query += " FOR UPDATE OF po"
+	}
+	order, err := scanPurchaseOrder(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return order, mapError(err)
+	}
+	orders := []models.PurchaseOrder{order}
+	if err := loadPurchaseOrderItems(ctx, q, orders); err != nil {
+		return order, err
+	}
+	return orders[0], nil
+}
+
+func (s *Store) ListSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+supplierColumns+" FROM suppliers ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := []models.Supplier{}
+	for rows.Next() {
+		supplier, err := scanSupplier(rows)
+		if err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, rows.Err()
+}
+
+func (s *Store) GetSupplier(ctx context.Context, id int) (models.Supplier, error) {
+	supplier, err := scanSupplier(s.db.QueryRowContext(ctx, "SELECT "+supplierColumns+" FROM suppliers WHERE id = $1", id))
+	return supplier, mapError(err)
+}
+
+func (s *Store) CreateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO suppliers(name, tax_id, contact_name, phone, email, address) VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, supplier.Name, supplier.TaxID, supplier.ContactName, supplier.Phone, supplier.Email, supplier.Address,
+	).Scan(&supplier.ID, &supplier.CreatedAt)
+	return mapError(err)
+}
+
+func (s *Store) UpdateSupplier(ctx context.Context, supplier *models.Supplier) error {
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE suppliers SET name = $1, tax_id = $2, contact_name = $3, phone = $4, email = $5, address = $6
+		WHERE id = $7 RETURNING created_at
+	`, supplier.Name, supplier.TaxID, supplier.ContactName, supplier.Phone, supplier.Email, supplier.Address, supplier.ID,
+	).Scan(&supplier.CreatedAt)
+	return mapError(err)
+}
+
+func (s *Store) DeleteSupplier(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM suppliers WHERE id = $1", id)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectAffected(result)
+}
+
+func (s *Store) ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	query := "SELECT " + purchaseOrderColumns + " FROM " + purchaseOrderFrom + " WHERE 1 = 1"
+	var args []interface{}
+	if filter.PharmacyID != 0 {
+		args = append(args, filter.PharmacyID)
+		query += fmt.Sprintf(" AND po.pharmacy_id = $%d", len(args))
+	}
+	if filter.SupplierID != 0 {
+		args = append(args, filter.SupplierID)
+		query += fmt.Sprintf(" AND po.supplier_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND po.status = $%d", len(args))
+	}
+	query += " ORDER BY po.created_at DESC, po.id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	orders := []models.PurchaseOrder{}
+	for rows.Next() {
+		order, err := scanPurchaseOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, loadPurchaseOrderItems(ctx, s.db, orders)
+}
+
+func (s *Store) GetPurchaseOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	order, err := getPurchaseOrder(ctx, s.db, id, false)
+	if err != nil {
+		return order, err
+	}
+	return order, loadGoodsReceipts(ctx, s.db, &order)
+}
+
+func (s *Store) CreatePurchaseOrder(ctx context.Context, order *models.PurchaseOrder) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		return createPurchaseOrder(ctx, tx, order)
+	})
+}
+
+// createPurchaseOrder сохраняет заказ поставщику вместе с позициями
+func createPurchaseOrder(ctx context.Context, tx *sql.Tx, order *models.PurchaseOrder) error {
+	if err := requirePharmacy(ctx, tx, order.PharmacyID); err != nil {
+		return fmt.Errorf("%w: pharmacy %d", err, order.PharmacyID)
+	}
+	err := tx.QueryRowContext(ctx, "SELECT name FROM suppliers WHERE id = $1", order.SupplierID).Scan(&order.SupplierName)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: supplier %d", store.ErrNotFound, order.SupplierID)
+	}
+	if err != nil {
+		return err
+	}
+
+	order.Total = 0
+	for i := range order.Items {
+		item := &order.Items[i]
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", item.MedicineID).Scan(&item.MedicineName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+		}
+		if err != nil {
+			return err
+		}
+		item.ReceivedQuantity = 0
+		item.LineTotal = models.RoundMoney(item.UnitCost * float64(item.Quantity))
+		order.Total = models.RoundMoney(order.Total + item.LineTotal)
+	}
+
+	var sentAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO purchase_orders(pharmacy_id, supplier_id, status, notes, total, created_by, sent_at)
+		VALUES($1, $2, $3, $4, $5, $6, CASE WHEN $7 THEN CURRENT_TIMESTAMP END)
+		RETURNING id, created_at, updated_at, sent_at
+	`, order.PharmacyID, order.SupplierID, order.Status, order.Notes, order.Total, order.CreatedBy,
+		order.Status == models.PurchaseStatusSent,
+	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt, &sentAt)
+	if err != nil {
+		return mapError(err)
+	}
+	order.SentAt = nil
+	if sentAt.Valid {
+		order.SentAt = &sentAt.Time
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO purchase_order_items(purchase_order_id, medicine_id, quantity, unit_cost, line_total)
+			VALUES($1, $2, $3, $4, $5) RETURNING id
+		`, order.ID, item.MedicineID, item.Quantity, item.UnitCost, item.LineTotal).Scan(&item.ID)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	order.Receipts = nil
+	return nil
+}
+
+func (s *Store) UpdatePurchaseOrderStatus(ctx context.Context, id int, status string) (models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		order, err = getPurchaseOrder(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !models.CanTransitionPurchase(order.Status, status) {
+			return store.ErrInvalidTransition
+		}
+
+		order.Status = status
+		var sentAt sql.NullTime
+		err = tx.QueryRowContext(ctx, `
+			UPDATE purchase_orders SET status = $1, updated_at = CURRENT_TIMESTAMP,
+				sent_at = CASE WHEN $2 THEN CURRENT_TIMESTAMP ELSE sent_at END
+			WHERE id = $3 RETURNING updated_at, sent_at
+		`, status, status == models.PurchaseStatusSent, id).Scan(&order.UpdatedAt, &sentAt)
+		if err != nil {
+			return err
+		}
+		if sentAt.Valid {
+			order.SentAt = &sentAt.Time
+		}
+		return loadGoodsReceipts(ctx, tx, &order)
+	})
+	return order, err
+}
+
+func (s *Store) ReceiveGoods(ctx context.Context, receipt *models.GoodsReceipt) (models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// Строка заказа блокируется, поэтому одновременные приёмки не примут больше заказанного
+		var err error
+		order, err = getPurchaseOrder(ctx, tx, receipt.PurchaseOrderID, true)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseStatusSent && order.Status != models.PurchaseStatusPartiallyReceived {
+			return fmt.Errorf("%w: purchase order is %s", store.ErrInvalidTransition, order.Status)
+		}
+		for _, received := range receipt.Items {
+			item := order.Item(received.MedicineID)
+			if item == nil {
+				return fmt.Errorf("%w: purchase order %d does not include medicine %d", store.ErrConflict, order.ID, received.MedicineID)
+			}
+			if received.Quantity > item.Remaining() {
+				return fmt.Errorf("%w: only %d units of medicine %d remain to be received", store.ErrConflict, item.Remaining(), item.MedicineID)
+			}
+			item.ReceivedQuantity += received.Quantity
+		}
+
+		err = tx.QueryRowContext(ctx, "INSERT INTO goods_receipts(purchase_order_id, received_by) VALUES($1, $2) RETURNING id, received_at",
+			order.ID, receipt.ReceivedBy).Scan(&receipt.ID, &receipt.ReceivedAt)
+		if err != nil {
+			return mapError(err)
+		}
+		for i := range receipt.Items {
+			received := &receipt.Items[i]
+			lot := models.Lot{
+				MedicineID:     received.MedicineID,
+				PharmacyID:     order.PharmacyID,
+				LotNumber:      received.LotNumber,
+				ProductionDate: received.ProductionDate,
+				ExpiryDate:     received.ExpiryDate,
+				Quantity:       received.Quantity,
+			}
+			err := receiveLot(ctx, tx, &lot)
+			if err == store.ErrConflict {
+				return fmt.Errorf("%w: lot %s already exists with a different expiry date", err, received.LotNumber)
+			}
+			if err != nil {
+				return err
+			}
+			received.LotID = lot.ID
+			err = recordLotMovement(ctx, tx, models.StockMovement{
+				Type:          models.MovementReceipt,
+				Quantity:      received.Quantity,
+				ReferenceType: models.MovementRefGoodsReceipt,
+				ReferenceID:   &receipt.ID,
+				UserID:        receipt.ReceivedBy,
+			}, lot)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO goods_receipt_items(goods_receipt_id, purchase_order_item_id, lot_id, quantity) VALUES($1, $2, $3, $4)",
+				receipt.ID, order.Item(received.MedicineID).ID, lot.ID, received.Quantity)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+
+		for _, item := range order.Items {
+			_, err := tx.ExecContext(ctx, "UPDATE purchase_order_items SET received_quantity = $1 WHERE id = $2", item.ReceivedQuantity, item.ID)
+			if err != nil {
+				return err
+			}
+		}
+		order.Status = order.ReceivedStatus()
+		err = tx.QueryRowContext(ctx, "UPDATE purchase_orders SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING updated_at",
+			order.Status, order.ID).Scan(&order.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return loadGoodsReceipts(ctx, tx, &order)
+	})
+	return order, err
+}
@@ -0,0 +1,349 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const recallColumns = "r.id, r.manufacturer, r.reason, r.recall_date, r.status, r.created_by, r.created_at, r.updated_at, r.closed_at"
+
+func scanRecall(row rowScanner) (models.Recall, error) {
+	var recall models.Recall
+	var recallDate time.Time
+	var createdBy sql.NullInt64
+	var closedAt sql.NullTime
+	err := row.Scan(&recall.ID, &recall.Manufacturer, &recall.Reason, &recallDate, &recall.Status,
+		&createdBy, &recall.CreatedAt, &recall.UpdatedAt, &closedAt)
+	if err != nil {
+		return recall, err
+	}
+	recall.RecallDate = recallDate.Format(models.DateLayout)
+	recall.CreatedBy = nullInt(createdBy)
+	recall.ClosedAt = nullTime(closedAt)
+	recall.Items = []models.RecallItem{}
+	return recall, nil
+}
+
+// loadRecallItems загружает отозванные серии для набора отзывов
+func loadRecallItems(ctx context.Context, q querier, recalls []models.Recall) error {
+	if len(recalls) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Recall, len(recalls))
+	ids := make([]int64, 0, len(recalls))
+	for i := range recalls {
+		index[recalls[i].ID] = &recalls[i]
+		ids = append(ids, int64(recalls[i].ID))
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT ri.recall_id, ri.medicine_id, COALESCE(m.name, ''), ri.lot_number
+		FROM recall_items ri
+		JOIN medicines m ON m.id = ri.medicine_id
+		WHERE ri.recall_id = ANY($1)
+		ORDER BY ri.recall_id, m.name, ri.medicine_id, ri.lot_number
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recallID int
+		var item models.RecallItem
+		if err := rows.Scan(&recallID, &item.MedicineID, &item.MedicineName, &item.LotNumber); err != nil {
+			return err
+		}
+		recall := index[recallID]
+		recall.Items = append(recall.Items, item)
+	}
+	return rows.Err()
+}
+
+// loadRecallActions загружает действия с отозванными единицами в порядке записи
+func loadRecallActions(ctx context.Context, q querier, recall *models.Recall) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT a.id, a.recall_id, a.lot_id, a.pharmacy_id, l.medicine_id, l.lot_number, a.action, a.quantity, a.notes, a.created_by, a.created_at
+		FROM recall_actions a
+		JOIN medicine_lots l ON l.id = a.lot_id
+		WHERE a.recall_id = $1
+		ORDER BY a.id
+	`, recall.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	recall.Actions = nil
+	for rows.Next() {
+		var action models.RecallAction
+		var createdBy sql.NullInt64
+		err := rows.Scan(&action.ID, &action.RecallID, &action.LotID, &action.PharmacyID, &action.MedicineID, &action.LotNumber,
+			&action.Action, &action.Quantity, &action.Notes, &createdBy, &action.CreatedAt)
+		if err != nil {
+			return err
+		}
+		action.CreatedBy = nullInt(createdBy)
+		recall.Actions = append(recall.Actions, action)
+	}
+	return rows.Err()
+}
+
+// getRecall загружает отзыв вместе с сериями и действиями
+func getRecall(ctx context.Context, q querier, id int, forUpdate bool) (models.Recall, error) {
+	query := "SELECT " + recallColumns + " FROM recalls r WHERE r.id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	recall, err := scanRecall(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return recall, mapError(err)
+	}
+	recalls := []models.Recall{recall}
+	if err := loadRecallItems(ctx, q, recalls); err != nil {
+		return recall, err
+	}
+	recall = recalls[0]
+	return recall, loadRecallActions(ctx, q, &recall)
+}
+
+func (s *Store) ListRecalls(ctx context.Context, filter models.RecallFilter) ([]models.Recall, error) {
+	query := "SELECT " + recallColumns + " FROM recalls r WHERE 1 = 1"
+	var args []interface{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND r.status = $%d", len(args))
+	}
+	if filter.MedicineID != 0 {
+		args = append(args, filter.MedicineID)
+		query += fmt.Sprintf(" AND EXISTS(SELECT 1 FROM recall_items ri WHERE ri.recall_id = r.id AND ri.medicine_id = $%d)", len(args))
+	}
+	query += " ORDER BY r.recall_date DESC, r.id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	recalls := []models.Recall{}
+	for rows.Next() {
+		recall, err := scanRecall(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		recalls = append(recalls, recall)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return recalls, loadRecallItems(ctx, s.db, recalls)
+}
+
+func (s *Store) GetRecall(ctx context.Context, id int) (models.Recall, error) {
+	return getRecall(ctx, s.db, id, false)
+}
+
+func (s *Store) CreateRecall(ctx context.Context, recall *models.Recall) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		recall.Status = models.RecallStatusActive
+		recall.ClosedAt = nil
+		recall.Actions = nil
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO recalls(manufacturer, reason, recall_date, status, created_by)
+			VALUES($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at
+		`, recall.Manufacturer, recall.Reason, recall.RecallDate, recall.Status, recall.CreatedBy,
+		).Scan(&recall.ID, &recall.CreatedAt, &recall.UpdatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		for i := range recall.Items {
+			item := &recall.Items[i]
+			err := tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", item.MedicineID).Scan(&item.MedicineName)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+			}
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO recall_items(recall_id, medicine_id, lot_number) VALUES($1, $2, $3)",
+				recall.ID, item.MedicineID, item.LotNumber)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) UpdateRecallStatus(ctx context.Context, id int, status string) (models.Recall, error) {
+	var recall models.Recall
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		recall, err = getRecall(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !models.CanTransitionRecall(recall.Status, status) {
+			return store.ErrInvalidTransition
+		}
+
+		recall.Status = status
+		var closedAt sql.NullTime
+		err = tx.QueryRowContext(ctx,
+			"UPDATE recalls SET status = $1, updated_at = CURRENT_TIMESTAMP, closed_at = CURRENT_TIMESTAMP WHERE id = $2 RETURNING updated_at, closed_at",
+			status, id).Scan(&recall.UpdatedAt, &closedAt)
+		recall.ClosedAt = nullTime(closedAt)
+		return err
+	})
+	return recall, err
+}
+
+func (s *Store) RecordRecallAction(ctx context.Context, action *models.RecallAction) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		// Блокировка отзыва упорядочивает действия, поэтому карантин и возврат не превысят допустимого
+		recall, err := getRecall(ctx, tx, action.RecallID, true)
+		if err != nil {
+			return err
+		}
+		if recall.Status != models.RecallStatusActive {
+			return fmt.Errorf("%w: recall is %s", store.ErrInvalidTransition, recall.Status)
+		}
+		lot, err := scanLot(tx.QueryRowContext(ctx, "SELECT "+lotColumns+" FROM medicine_lots WHERE id = $1 FOR UPDATE", action.LotID))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: lot %d", store.ErrNotFound, action.LotID)
+		}
+		if err != nil {
+			return err
+		}
+		if !recall.Covers(lot.MedicineID, lot.LotNumber) {
+			return fmt.Errorf("%w: lot %s of medicine %d is not part of recall %d", store.ErrConflict, lot.LotNumber, lot.MedicineID, recall.ID)
+		}
+
+		quarantined, _ := recall.LotActions(lot.ID)
+		switch action.Action {
+		case models.RecallActionQuarantine:
+			if quarantined+action.Quantity > lot.Quantity {
+				return fmt.Errorf("%w: only %d units of lot %s are not yet quarantined", store.ErrConflict, lot.Quantity-quarantined, lot.LotNumber)
+			}
+		case models.RecallActionReturn:
+			if action.Quantity > quarantined {
+				return fmt.Errorf("%w: only %d units of lot %s are in quarantine", store.ErrConflict, quarantined, lot.LotNumber)
+			}
+			if action.Quantity > lot.Quantity {
+				return fmt.Errorf("%w: only %d units of lot %s are left", store.ErrConflict, lot.Quantity, lot.LotNumber)
+			}
+		}
+
+		action.PharmacyID, action.MedicineID, action.LotNumber = lot.PharmacyID, lot.MedicineID, lot.LotNumber
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO recall_actions(recall_id, lot_id, pharmacy_id, action, quantity, notes, created_by)
+			VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at
+		`, recall.ID, lot.ID, lot.PharmacyID, action.Action, action.Quantity, action.Notes, action.CreatedBy,
+		).Scan(&action.ID, &action.CreatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+		if action.Action != models.RecallActionReturn {
+			return nil
+		}
+
+		// Возвращённые единицы уходят из партии и остатка аптеки
+		if _, err := tx.ExecContext(ctx, "UPDATE medicine_lots SET quantity = quantity - $1 WHERE id = $2", action.Quantity, lot.ID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE pharmacy_medicines SET quantity = quantity - $1 WHERE pharmacy_id = $2 AND medicine_id = $3",
+			action.Quantity, lot.PharmacyID, lot.MedicineID)
+		if err != nil {
+			return err
+		}
+		return recordLotMovement(ctx, tx, models.StockMovement{
+			Type:          models.MovementRecallReturn,
+			Quantity:      -action.Quantity,
+			ReferenceType: models.MovementRefRecall,
+			ReferenceID:   &recall.ID,
+			Reason:        action.Notes,
+			UserID:        action.CreatedBy,
+		}, lot)
+	})
+}
+
+func (s *Store) GetRecallReport(ctx context.Context, id int) (models.RecallReport, error) {
+	report := models.RecallReport{Stock: []models.RecallStock{}, Dispensed: []models.RecallDispensed{}}
+	var err error
+	report.Recall, err = getRecall(ctx, s.db, id, false)
+	if err != nil {
+		return report, err
+	}
+
+	// Партии с остатком и партии, по которым уже были действия
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT l.pharmacy_id, p.name, l.medicine_id, COALESCE(m.name, ''), l.id, l.lot_number, l.expiry_date, l.quantity
+		FROM medicine_lots l
+		JOIN recall_items ri ON ri.medicine_id = l.medicine_id AND ri.lot_number = l.lot_number
+		JOIN pharmacies p ON p.id = l.pharmacy_id
+		JOIN medicines m ON m.id = l.medicine_id
+		WHERE ri.recall_id = $1
+			AND (l.quantity > 0 OR EXISTS(SELECT 1 FROM recall_actions a WHERE a.recall_id = ri.recall_id AND a.lot_id = l.id))
+		ORDER BY p.name, l.pharmacy_id, m.name, l.medicine_id, l.lot_number
+	`, id)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var stock models.RecallStock
+		var expiry time.Time
+		err := rows.Scan(&stock.PharmacyID, &stock.PharmacyName, &stock.MedicineID, &stock.MedicineName,
+			&stock.LotID, &stock.LotNumber, &expiry, &stock.Quantity)
+		if err != nil {
+			rows.Close()
+			return report, err
+		}
+		stock.ExpiryDate = expiry.Format(models.DateLayout)
+		stock.Quarantined, stock.Returned = report.Recall.LotActions(stock.LotID)
+		report.Stock = append(report.Stock, stock)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	// Возвращённые заказы не входят: их товар вернулся в партии
+	dispensedRows, err := s.db.QueryContext(ctx, `
+		SELECT o.id, o.pharmacy_id, p.name, oi.medicine_id, COALESCE(m.name, ''), l.id, l.lot_number, oil.quantity, o.paid_at
+		FROM order_item_lots oil
+		JOIN order_items oi ON oi.id = oil.order_item_id
+		JOIN orders o ON o.id = oi.order_id
+		JOIN medicine_lots l ON l.id = oil.lot_id
+		JOIN recall_items ri ON ri.medicine_id = l.medicine_id AND ri.lot_number = l.lot_number
+		JOIN pharmacies p ON p.id = o.pharmacy_id
+		JOIN medicines m ON m.id = oi.medicine_id
+		WHERE ri.recall_id = $1 AND o.status = $2
+		ORDER BY o.paid_at, o.id, l.id
+	`, id, models.OrderStatusPaid)
+	if err != nil {
+		return report, err
+	}
+	defer dispensedRows.Close()
+	for dispensedRows.Next() {
+		var dispensed models.RecallDispensed
+		var paidAt sql.NullTime
+		err := dispensedRows.Scan(&dispensed.OrderID, &dispensed.PharmacyID, &dispensed.PharmacyName, &dispensed.MedicineID,
+			&dispensed.MedicineName, &dispensed.LotID, &dispensed.LotNumber, &dispensed.Quantity, &paidAt)
+		if err != nil {
+			return report, err
+		}
+		dispensed.PaidAt = nullTime(paidAt)
+		report.Dispensed = append(report.Dispensed, dispensed)
+	}
+	return report, dispensedRows.Err()
+}
@@ -0,0 +1,273 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+const suggestionColumns = "r.pharmacy_id, r.medicine_id, COALESCE(m.name, ''), r.on_hand, r.on_order, r.daily_sales, " +
+	"r.reorder_point, r.target_quantity, r.suggested_quantity, r.supplier_id, r.calculated_at"
+
+func scanStockLevel(row rowScanner) (models.StockLevel, error) {
+	var level models.StockLevel
+	var supplierID sql.NullInt64
+	err := row.Scan(&level.PharmacyID, &level.MedicineID, &level.MedicineName, &level.MinQuantity, &level.MaxQuantity,
+		&supplierID, &level.UpdatedAt)
+	level.SupplierID = nullInt(supplierID)
+	return level, err
+}
+
+func scanSuggestion(row rowScanner) (models.ReplenishmentSuggestion, error) {
+	var suggestion models.ReplenishmentSuggestion
+	var supplierID sql.NullInt64
+	err := row.Scan(&suggestion.PharmacyID, &suggestion.MedicineID, &suggestion.MedicineName, &suggestion.OnHand,
+		&suggestion.OnOrder, &suggestion.DailySales, &suggestion.ReorderPoint, &suggestion.TargetQuantity,
+		&suggestion.SuggestedQuantity, &supplierID, &suggestion.CalculatedAt)
+	suggestion.SupplierID = nullInt(supplierID)
+	return suggestion, err
+}
+
+// listReplenishment возвращает сохранённые предложения аптеки по названию лекарства
+func listReplenishment(ctx context.Context, q querier, pharmacyID int) ([]models.ReplenishmentSuggestion, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+suggestionColumns+`
+		FROM replenishment_suggestions r
+		JOIN medicines m ON m.id = r.medicine_id
+		WHERE r.pharmacy_id = $1
+		ORDER BY m.name, r.medicine_id
+	`, pharmacyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.ReplenishmentSuggestion{}
+	for rows.Next() {
+		suggestion, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+func (s *Store) ListStockLevels(ctx context.Context, pharmacyID int) ([]models.StockLevel, error) {
+	if err := requirePharmacy(ctx, s.db, pharmacyID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sl.pharmacy_id, sl.medicine_id, COALESCE(m.name, ''), sl.min_quantity, sl.max_quantity, sl.supplier_id, sl.updated_at
+		FROM stock_levels sl
+		JOIN medicines m ON m.id = sl.medicine_id
+		WHERE sl.pharmacy_id = $1
+		ORDER BY m.name, sl.medicine_id
+	`, pharmacyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := []models.StockLevel{}
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+func (s *Store) SetStockLevel(ctx context.Context, level *models.StockLevel) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requirePharmacy(ctx, tx, level.PharmacyID); err != nil {
+			return fmt.Errorf("%w: pharmacy %d", err, level.PharmacyID)
+		}
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", level.MedicineID).Scan(&level.MedicineName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: medicine %d", store.ErrNotFound, level.MedicineID)
+		}
+		if err != nil {
+			return err
+		}
+		if level.SupplierID != nil {
+			found, err := exists(ctx, tx, "suppliers", *level.SupplierID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%w: supplier %d", store.ErrNotFound, *level.SupplierID)
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO stock_levels(pharmacy_id, medicine_id, min_quantity, max_quantity, supplier_id) VALUES($1, $2, $3, $4, $5)
+			ON CONFLICT (pharmacy_id, medicine_id) DO UPDATE
+				SET min_quantity = EXCLUDED.min_quantity, max_quantity = EXCLUDED.max_quantity,
+					supplier_id = EXCLUDED.supplier_id, updated_at = CURRENT_TIMESTAMP
+			RETURNING updated_at
+		`, level.PharmacyID, level.MedicineID, level.MinQuantity, level.MaxQuantity, level.SupplierID).Scan(&level.UpdatedAt)
+		return mapError(err)
+	})
+}
+
+func (s *Store) DeleteStockLevel(ctx context.Context, pharmacyID, medicineID int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM stock_levels WHERE pharmacy_id = $1 AND medicine_id = $2", pharmacyID, medicineID)
+		if err != nil {
+			return err
+		}
+		if err := expectAffected(result); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM replenishment_suggestions WHERE pharmacy_id = $1 AND medicine_id = $2", pharmacyID, medicineID)
+		return err
+	})
+}
+
+func (s *Store) RefreshReplenishment(ctx context.Context, pharmacyID int) (int, error) {
+	count := 0
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if pharmacyID != 0 {
+			if err := requirePharmacy(ctx, tx, pharmacyID); err != nil {
+				return err
+			}
+		}
+
+		// Доступное количество — остаток плюс непринятое в открытых заказах поставщикам, включая черновики
+		rows, err := tx.QueryContext(ctx, `
+			SELECT sl.pharmacy_id, sl.medicine_id, COALESCE(m.name, ''), sl.min_quantity, sl.max_quantity, sl.supplier_id, sl.updated_at,
+				COALESCE(pm.quantity, 0), COALESCE(oo.quantity, 0), COALESCE(sold.quantity, 0)
+			FROM stock_levels sl
+			JOIN medicines m ON m.id = sl.medicine_id
+			LEFT JOIN pharmacy_medicines pm ON pm.pharmacy_id = sl.pharmacy_id AND pm.medicine_id = sl.medicine_id
+			LEFT JOIN LATERAL (
+				SELECT SUM(poi.quantity - poi.received_quantity) AS quantity
+				FROM purchase_order_items poi
+				JOIN purchase_orders po ON po.id = poi.purchase_order_id
+				WHERE po.pharmacy_id = sl.pharmacy_id AND poi.medicine_id = sl.medicine_id
+					AND po.status IN ('draft', 'sent', 'partially_received')
+			) oo ON TRUE
+			LEFT JOIN LATERAL (
+				SELECT SUM(oi.quantity) AS quantity
+				FROM order_items oi
+				JOIN orders o ON o.id = oi.order_id
+				WHERE o.pharmacy_id = sl.pharmacy_id AND oi.medicine_id = sl.medicine_id
+					AND o.status = 'paid' AND o.paid_at >= CURRENT_TIMESTAMP - $2 * INTERVAL '1 day'
+			) sold ON TRUE
+			WHERE $1::int = 0 OR sl.pharmacy_id = $1
+		`, pharmacyID, models.ReplenishmentSalesWindowDays)
+		if err != nil {
+			return err
+		}
+		var suggestions []models.ReplenishmentSuggestion
+		for rows.Next() {
+			var onHand, onOrder, sold int
+			level, err := scanStockLevel(appendScanner{rows, []interface{}{&onHand, &onOrder, &sold}})
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if suggestion := level.Suggest(onHand, onOrder, sold); suggestion.SuggestedQuantity > 0 {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM replenishment_suggestions WHERE $1::int = 0 OR pharmacy_id = $1", pharmacyID); err != nil {
+			return err
+		}
+		for _, suggestion := range suggestions {
+			// Одновременный пересчёт другой реплики перезаписывается последним
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO replenishment_suggestions(pharmacy_id, medicine_id, on_hand, on_order, daily_sales,
+					reorder_point, target_quantity, suggested_quantity, supplier_id)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				ON CONFLICT (pharmacy_id, medicine_id) DO UPDATE
+					SET on_hand = EXCLUDED.on_hand, on_order = EXCLUDED.on_order, daily_sales = EXCLUDED.daily_sales,
+						reorder_point = EXCLUDED.reorder_point, target_quantity = EXCLUDED.target_quantity,
+						suggested_quantity = EXCLUDED.suggested_quantity, supplier_id = EXCLUDED.supplier_id,
+						calculated_at = CURRENT_TIMESTAMP
+			`, suggestion.PharmacyID, suggestion.MedicineID, suggestion.OnHand, suggestion.OnOrder, suggestion.DailySales,
+				suggestion.ReorderPoint, suggestion.TargetQuantity, suggestion.SuggestedQuantity, suggestion.SupplierID)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		count = len(suggestions)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Store) ListReplenishment(ctx context.Context, pharmacyID int) ([]models.ReplenishmentSuggestion, error) {
+	if err := requirePharmacy(ctx, s.db, pharmacyID); err != nil {
+		return nil, err
+	}
+	return listReplenishment(ctx, s.db, pharmacyID)
+}
+
+func (s *Store) CreateReplenishmentOrders(ctx context.Context, pharmacyID int, request models.ReplenishmentOrderRequest) ([]models.PurchaseOrder, error) {
+	var orders []models.PurchaseOrder
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requirePharmacy(ctx, tx, pharmacyID); err != nil {
+			return err
+		}
+		// Блокировка аптеки не даёт двум запросам заказать одни и те же предложения дважды
+		if _, err := tx.ExecContext(ctx, "SELECT 1 FROM pharmacies WHERE id = $1 FOR UPDATE", pharmacyID); err != nil {
+			return err
+		}
+		suggestions, err := listReplenishment(ctx, tx, pharmacyID)
+		if err != nil {
+			return err
+		}
+		suggestions, err = models.SelectReplenishment(suggestions, request)
+		if err != nil {
+			return fmt.Errorf("%w: %v", store.ErrConflict, err)
+		}
+
+		// Закупочная цена берётся из последнего заказа этого лекарства
+		costs := map[int]float64{}
+		for _, suggestion := range suggestions {
+			var cost float64
+			err := tx.QueryRowContext(ctx, `
+				SELECT poi.unit_cost FROM purchase_order_items poi
+				JOIN purchase_orders po ON po.id = poi.purchase_order_id
+				WHERE poi.medicine_id = $1
+				ORDER BY po.created_at DESC, po.id DESC LIMIT 1
+			`, suggestion.MedicineID).Scan(&cost)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			costs[suggestion.MedicineID] = cost
+		}
+
+		orders = models.PlanReplenishmentOrders(pharmacyID, suggestions, request, func(medicineID int) float64 { return costs[medicineID] })
+		for i := range orders {
+			if err := createPurchaseOrder(ctx, tx, &orders[i]); err != nil {
+				return err
+			}
+		}
+		// Заказанные лекарства учтены в открытых заказах, предложения по ним больше не нужны
+		for _, suggestion := range suggestions {
+			_, err := tx.ExecContext(ctx, "DELETE FROM replenishment_suggestions WHERE pharmacy_id = $1 AND medicine_id = $2",
+				pharmacyID, suggestion.MedicineID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return orders, err
+}
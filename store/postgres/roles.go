@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const roleQuery = `
+	SELECT r.name, r.description,
+		COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_name = r.name`
+
+func scanRole(row rowScanner) (models.Role, error) {
+	var role models.Role
+	err := row.Scan(&role.Name, &role.Description, pq.Array(&role.Permissions))
+	return role, err
+}
+
+func (s *Store) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, description FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []models.Permission{}
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (s *Store) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := s.db.QueryContext(ctx, roleQuery+" GROUP BY r.name ORDER BY r.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (s *Store) GetRole(ctx context.Context, name string) (models.Role, error) {
+	role, err := scanRole(s.db.QueryRowContext(ctx, roleQuery+" WHERE r.name = $1 GROUP BY r.name", name))
+	return role, mapError(err)
+}
+
+func (s *Store) CreateRole(ctx context.Context, role *models.Role) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO roles(name, description) VALUES($1, $2)", role.Name, role.Description); err != nil {
+			return mapError(err)
+		}
+		return setRolePermissions(ctx, tx, role.Name, role.Permissions)
+	})
+}
+
+func (s *Store) UpdateRole(ctx context.Context, role *models.Role) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE roles SET description = $1 WHERE name = $2", role.Description, role.Name)
+		if err != nil {
+			return mapError(err)
+		}
+		if err := expectAffected(result); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_name = $1", role.Name); err != nil {
+			return err
+		}
+		return setRolePermissions(ctx, tx, role.Name, role.Permissions)
+	})
+}
+
+func (s *Store) DeleteRole(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM roles WHERE name = $1", name)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectAffected(result)
+}
+
+// setRolePermissions привязывает разрешения к роли, предварительно проверив, что все они существуют
+func setRolePermissions(ctx context.Context, tx *sql.Tx, roleName string, permissions []string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM permissions WHERE name = ANY($1)", pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		known[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !known[permission] {
+			return fmt.Errorf("%w: permission %s", store.ErrNotFound, permission)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO role_permissions(role_name, permission) SELECT DISTINCT $1, unnest($2::text[])",
+		roleName, pq.Array(permissions))
+	return mapError(err)
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pharmacy-test/models"
+
+	"github.com/lib/pq"
+)
+
+// loadSchedules дополняет аптеки недельным расписанием и исключениями
+func loadSchedules(ctx context.Context, q querier, pharmacies []models.Pharmacy) error {
+	if len(pharmacies) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(pharmacies))
+	index := map[int]int{}
+	for i, pharmacy := range pharmacies {
+		ids[i] = int64(pharmacy.ID)
+		index[pharmacy.ID] = i
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT pharmacy_id, weekday, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI')
+		FROM pharmacy_hours WHERE pharmacy_id = ANY($1)
+		ORDER BY pharmacy_id, weekday, opens
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pharmacyID int
+		var hours models.OpeningHours
+		if err := rows.Scan(&pharmacyID, &hours.Weekday, &hours.Opens, &hours.Closes); err != nil {
+			return err
+		}
+		pharmacy := &pharmacies[index[pharmacyID]]
+		pharmacy.Hours = append(pharmacy.Hours, hours)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Интервалы исключения присоединяются слева: дата без интервалов означает выходной
+	rows, err = q.QueryContext(ctx, `
+		SELECT e.pharmacy_id, e.date, e.note, to_char(h.opens, 'HH24:MI'), to_char(h.closes, 'HH24:MI')
+		FROM pharmacy_exceptions e
+		LEFT JOIN pharmacy_exception_hours h ON h.pharmacy_id = e.pharmacy_id AND h.date = e.date
+		WHERE e.pharmacy_id = ANY($1)
+		ORDER BY e.pharmacy_id, e.date, h.opens
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pharmacyID int
+		var date time.Time
+		var note string
+		var opens, closes sql.NullString
+		if err := rows.Scan(&pharmacyID, &date, &note, &opens, &closes); err != nil {
+			return err
+		}
+		pharmacy := &pharmacies[index[pharmacyID]]
+		day := date.Format(models.DateLayout)
+		if n := len(pharmacy.Exceptions); n == 0 || pharmacy.Exceptions[n-1].Date != day {
+			pharmacy.Exceptions = append(pharmacy.Exceptions, models.ScheduleException{Date: day, Hours: []models.TimeRange{}, Note: note})
+		}
+		if opens.Valid {
+			exception := &pharmacy.Exceptions[len(pharmacy.Exceptions)-1]
+			exception.Hours = append(exception.Hours, models.TimeRange{Opens: opens.String, Closes: closes.String})
+		}
+	}
+	return rows.Err()
+}
+
+// saveSchedule заменяет расписание и исключения аптеки
+func saveSchedule(ctx context.Context, tx *sql.Tx, pharmacy *models.Pharmacy) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pharmacy_hours WHERE pharmacy_id = $1", pharmacy.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pharmacy_exceptions WHERE pharmacy_id = $1", pharmacy.ID); err != nil {
+		return err
+	}
+
+	for _, hours := range pharmacy.Hours {
+		_, err := tx.ExecContext(ctx, "INSERT INTO pharmacy_hours(pharmacy_id, weekday, opens, closes) VALUES($1, $2, $3, $4)",
+			pharmacy.ID, hours.Weekday, hours.Opens, hours.Closes)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	for _, exception := range pharmacy.Exceptions {
+		_, err := tx.ExecContext(ctx, "INSERT INTO pharmacy_exceptions(pharmacy_id, date, note) VALUES($1, $2, $3)",
+			pharmacy.ID, exception.Date, exception.Note)
+		if err != nil {
+			return mapError(err)
+		}
+		for _, hours := range exception.Hours {
+			_, err := tx.ExecContext(ctx, "INSERT INTO pharmacy_exception_hours(pharmacy_id, date, opens, closes) VALUES($1, $2, $3, $4)",
+				pharmacy.ID, exception.Date, hours.Opens, hours.Closes)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pharmacy-test/models"
+)
+
+const sessionColumns = "id, user_id, token_hash, created_at, last_seen_at, expires_at, user_agent, ip"
+
+// activeSession — условие для неотозванных и неистёкших сессий
+const activeSession = "revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP"
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.TokenHash, &session.CreatedAt,
+		&session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IP)
+	return session, err
+}
+
+func (s *Store) CreateSession(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO sessions(user_id, token_hash, expires_at, user_agent, ip)
+			VALUES($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 second', $4, $5)
+			RETURNING id, created_at, last_seen_at, expires_at`,
+			session.UserID, session.TokenHash, ttl.Seconds(), session.UserAgent, session.IP,
+		).Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		result, err := tx.ExecContext(ctx, "UPDATE users SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1", session.UserID)
+		if err != nil {
+			return mapError(err)
+		}
+		return expectAffected(result)
+	})
+}
+
+func (s *Store) TouchSession(ctx context.Context, tokenHash string, ttl time.Duration) (models.Session, error) {
+	row := s.db.QueryRowContext(ctx, `
+		UPDATE sessions
+		SET last_seen_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+		WHERE token_hash = $1 AND `+activeSession+`
+		RETURNING `+sessionColumns, tokenHash, ttl.Seconds())
+	session, err := scanSession(row)
+	return session, mapError(err)
+}
+
+func (s *Store) ListUserSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE user_id = $1 AND "+activeSession+
+		" ORDER BY last_seen_at DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *Store) RevokeSession(ctx context.Context, userID, sessionID int) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND "+activeSession,
+		sessionID, userID)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectAffected(result)
+}
+
+func (s *Store) RevokeSessionByToken(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND revoked_at IS NULL", tokenHash)
+	return mapError(err)
+}
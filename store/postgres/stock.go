@@ -0,0 +1,379 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"pharmacy-test/geo"
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+// lotRecalled проверяет строку medicine_lots: партия отозвана, если её серия входит в неотменённый отзыв
+const lotRecalled = "EXISTS(SELECT 1 FROM recall_items ri JOIN recalls r ON r.id = ri.recall_id " +
+	"WHERE ri.medicine_id = medicine_lots.medicine_id AND ri.lot_number = medicine_lots.lot_number AND r.status <> 'cancelled')"
+
+// lotColumns выбирает партию из medicine_lots
+const lotColumns = "id, medicine_id, pharmacy_id, lot_number, production_date, expiry_date, quantity, expiry_date < CURRENT_DATE, " +
+	lotRecalled + ", created_at"
+
+func scanLot(row rowScanner) (models.Lot, error) {
+	var lot models.Lot
+	var productionDate sql.NullTime
+	var expiryDate time.Time
+	err := row.Scan(&lot.ID, &lot.MedicineID, &lot.PharmacyID, &lot.LotNumber, &productionDate, &expiryDate, &lot.Quantity, &lot.Expired, &lot.Recalled, &lot.CreatedAt)
+	if err != nil {
+		return lot, err
+	}
+	if productionDate.Valid {
+		lot.ProductionDate = productionDate.Time.Format(models.DateLayout)
+	}
+	lot.ExpiryDate = expiryDate.Format(models.DateLayout)
+	return lot, nil
+}
+
+func queryLots(ctx context.Context, q querier, query string, args ...interface{}) ([]models.Lot, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []models.Lot{}
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// lockedLots блокирует и возвращает партии лекарства в аптеке в порядке FEFO
+func lockedLots(ctx context.Context, tx *sql.Tx, pharmacyID, medicineID int) ([]models.Lot, error) {
+	return queryLots(ctx, tx,
+		"SELECT "+lotColumns+" FROM medicine_lots WHERE pharmacy_id = $1 AND medicine_id = $2 AND quantity > 0 ORDER BY expiry_date, id FOR UPDATE",
+		pharmacyID, medicineID)
+}
+
+// addStock увеличивает остаток лекарства в аптеке, создавая связь при необходимости
+func addStock(ctx context.Context, tx *sql.Tx, pharmacyID, medicineID, quantity int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO pharmacy_medicines(pharmacy_id, medicine_id, quantity) VALUES($1, $2, $3)
+		ON CONFLICT (pharmacy_id, medicine_id) DO UPDATE SET quantity = pharmacy_medicines.quantity + EXCLUDED.quantity
+	`, pharmacyID, medicineID, quantity)
+	return mapError(err)
+}
+
+// consumeStock списывает количество лекарства в аптеке по правилу FEFO
+func consumeStock(ctx context.Context, tx *sql.Tx, pharmacyID, medicineID, quantity int) ([]models.LotAllocation, error) {
+	var onHand int
+	err := tx.QueryRowContext(ctx, "SELECT quantity FROM pharmacy_medicines WHERE pharmacy_id = $1 AND medicine_id = $2 FOR UPDATE",
+		pharmacyID, medicineID).Scan(&onHand)
+	if err == sql.ErrNoRows {
+		return nil, store.ErrInsufficientStock
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lots, err := lockedLots(ctx, tx, pharmacyID, medicineID)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations, ok := models.PlanConsumption(onHand, lots, quantity)
+	if !ok {
+		return nil, store.ErrInsufficientStock
+	}
+	for _, allocation := range allocations {
+		if allocation.LotID == 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE medicine_lots SET quantity = quantity - $1 WHERE id = $2", allocation.Quantity, allocation.LotID); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE pharmacy_medicines SET quantity = quantity - $1 WHERE pharmacy_id = $2 AND medicine_id = $3",
+		quantity, pharmacyID, medicineID)
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// adjustStock изменяет остаток на movement.Quantity, не опуская его ниже нуля, и записывает
+// фактическое изменение в журнал. Недостача сначала списывается с остатка без партии, затем с партий.
+// Возвращает фактическое изменение
+func adjustStock(ctx context.Context, tx *sql.Tx, movement models.StockMovement) (int, error) {
+	pharmacyID, medicineID, delta := movement.PharmacyID, movement.MedicineID, movement.Quantity
+	onHand, err := lockedQuantity(ctx, tx, pharmacyID, medicineID)
+	if err != nil {
+		return 0, err
+	}
+	if onHand+delta < 0 {
+		delta = -onHand
+	}
+	movement.Quantity = delta
+	switch {
+	case delta == 0:
+		return 0, nil
+	case delta > 0:
+		if err := addStock(ctx, tx, pharmacyID, medicineID, delta); err != nil {
+			return 0, err
+		}
+		return delta, recordMovements(ctx, tx, movement, nil)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE pharmacy_medicines SET quantity = quantity + $1 WHERE pharmacy_id = $2 AND medicine_id = $3",
+		delta, pharmacyID, medicineID)
+	if err != nil {
+		return 0, err
+	}
+	trims, err := trimLots(ctx, tx, pharmacyID, medicineID, onHand+delta)
+	if err != nil {
+		return 0, err
+	}
+	return delta, recordMovements(ctx, tx, movement, trims)
+}
+
+// lockedQuantity блокирует и возвращает остаток лекарства в аптеке; отсутствующая связь даёт ноль
+func lockedQuantity(ctx context.Context, tx *sql.Tx, pharmacyID, medicineID int) (int, error) {
+	var onHand int
+	err := tx.QueryRowContext(ctx, "SELECT quantity FROM pharmacy_medicines WHERE pharmacy_id = $1 AND medicine_id = $2 FOR UPDATE",
+		pharmacyID, medicineID).Scan(&onHand)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return onHand, nil
+}
+
+// trimLots уменьшает партии так, чтобы они не превышали остаток onHand, и возвращает уменьшения
+func trimLots(ctx context.Context, tx *sql.Tx, pharmacyID, medicineID, onHand int) ([]models.LotAllocation, error) {
+	lots, err := lockedLots(ctx, tx, pharmacyID, medicineID)
+	if err != nil {
+		return nil, err
+	}
+	trims := models.PlanTrim(onHand, lots)
+	for _, allocation := range trims {
+		if _, err := tx.ExecContext(ctx, "UPDATE medicine_lots SET quantity = quantity - $1 WHERE id = $2", allocation.Quantity, allocation.LotID); err != nil {
+			return nil, err
+		}
+	}
+	return trims, nil
+}
+
+func (s *Store) ListPharmacyStock(ctx context.Context, pharmacyID int) ([]models.StockItem, error) {
+	if err := requirePharmacy(ctx, s.db, pharmacyID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT pm.pharmacy_id, pm.medicine_id, COALESCE(m.name, ''), pm.quantity
+		FROM pharmacy_medicines pm
+		JOIN medicines m ON m.id = pm.medicine_id
+		WHERE pm.pharmacy_id = $1
+		ORDER BY m.name, pm.medicine_id
+	`, pharmacyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := []models.StockItem{}
+	for rows.Next() {
+		var item models.StockItem
+		if err := rows.Scan(&item.PharmacyID, &item.MedicineID, &item.MedicineName, &item.Quantity); err != nil {
+			return nil, err
+		}
+		stock = append(stock, item)
+	}
+	return stock, rows.Err()
+}
+
+func (s *Store) SetStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) (models.StockItem, error) {
+	item := models.StockItem{PharmacyID: pharmacyID, MedicineID: medicineID, Quantity: quantity}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT name FROM pharmacies WHERE id = $1", pharmacyID).Scan(&item.PharmacyName)
+		if err != nil {
+			return mapError(err)
+		}
+		err = tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", medicineID).Scan(&item.MedicineName)
+		if err != nil {
+			return mapError(err)
+		}
+
+		onHand, err := lockedQuantity(ctx, tx, pharmacyID, medicineID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO pharmacy_medicines(pharmacy_id, medicine_id, quantity) VALUES($1, $2, $3)
+			ON CONFLICT (pharmacy_id, medicine_id) DO UPDATE SET quantity = EXCLUDED.quantity
+		`, pharmacyID, medicineID, quantity)
+		if err != nil {
+			return mapError(err)
+		}
+
+		// Партии не могут содержать больше, чем есть в наличии
+		trims, err := trimLots(ctx, tx, pharmacyID, medicineID, quantity)
+		if err != nil {
+			return err
+		}
+		return recordMovements(ctx, tx, models.StockMovement{
+			PharmacyID: pharmacyID,
+			MedicineID: medicineID,
+			Type:       models.MovementAdjustment,
+			Quantity:   quantity - onHand,
+			UserID:     userID,
+		}, trims)
+	})
+	return item, err
+}
+
+func (s *Store) ConsumeStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) ([]models.LotAllocation, error) {
+	var allocations []models.LotAllocation
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		allocations, err = consumeStock(ctx, tx, pharmacyID, medicineID, quantity)
+		if err != nil {
+			return err
+		}
+		return recordMovements(ctx, tx, models.StockMovement{
+			PharmacyID: pharmacyID,
+			MedicineID: medicineID,
+			Type:       models.MovementWriteOff,
+			Quantity:   -quantity,
+			UserID:     userID,
+		}, allocations)
+	})
+	return allocations, err
+}
+
+// receiveLot добавляет партию в аптеку и увеличивает остаток лекарства
+func receiveLot(ctx context.Context, tx *sql.Tx, lot *models.Lot) error {
+	received := lot.Quantity
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO medicine_lots(medicine_id, pharmacy_id, lot_number, production_date, expiry_date, quantity)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (medicine_id, pharmacy_id, lot_number) DO UPDATE
+		SET quantity = medicine_lots.quantity + EXCLUDED.quantity
+		WHERE medicine_lots.expiry_date = EXCLUDED.expiry_date
+		RETURNING `+lotColumns,
+		lot.MedicineID, lot.PharmacyID, lot.LotNumber, nullString(lot.ProductionDate), lot.ExpiryDate, lot.Quantity)
+	stored, err := scanLot(row)
+	if err == sql.ErrNoRows {
+		// Серия уже есть с другим сроком годности
+		return store.ErrConflict
+	}
+	if err != nil {
+		return mapError(err)
+	}
+	*lot = stored
+
+	return addStock(ctx, tx, lot.PharmacyID, lot.MedicineID, received)
+}
+
+func (s *Store) ReceiveLot(ctx context.Context, lot *models.Lot, userID *int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if err := requirePharmacy(ctx, tx, lot.PharmacyID); err != nil {
+			return err
+		}
+		found, err := exists(ctx, tx, "medicines", lot.MedicineID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return store.ErrNotFound
+		}
+		received := lot.Quantity
+		if err := receiveLot(ctx, tx, lot); err != nil {
+			return err
+		}
+		return recordLotMovement(ctx, tx, models.StockMovement{
+			Type:     models.MovementReceipt,
+			Quantity: received,
+			UserID:   userID,
+		}, *lot)
+	})
+}
+
+// recordLotMovement записывает движение целиком по партии lot
+func recordLotMovement(ctx context.Context, tx *sql.Tx, movement models.StockMovement, lot models.Lot) error {
+	movement.PharmacyID, movement.MedicineID = lot.PharmacyID, lot.MedicineID
+	// Распределение по партиям хранит количество без знака
+	quantity := movement.Quantity
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	return recordMovements(ctx, tx, movement, []models.LotAllocation{{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: quantity}})
+}
+
+func (s *Store) ListMedicineLots(ctx context.Context, medicineID int) ([]models.Lot, error) {
+	return queryLots(ctx, s.db,
+		"SELECT "+lotColumns+" FROM medicine_lots WHERE medicine_id = $1 AND quantity > 0 ORDER BY expiry_date, id", medicineID)
+}
+
+func (s *Store) ListExpiringLots(ctx context.Context, pharmacyID, days int) ([]models.Lot, error) {
+	// Уже просроченные партии тоже попадают в список
+	return queryLots(ctx, s.db,
+		"SELECT "+lotColumns+" FROM medicine_lots WHERE pharmacy_id = $1 AND quantity > 0 AND expiry_date <= CURRENT_DATE + $2::int ORDER BY expiry_date, id",
+		pharmacyID, days)
+}
+
+func (s *Store) ListNearbyAvailability(ctx context.Context, medicineID int, origin geo.Point, radiusKm float64) ([]models.NearbyPharmacy, error) {
+	found, err := exists(ctx, s.db, "medicines", medicineID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, store.ErrNotFound
+	}
+
+	// Прямоугольник отсекает дальние аптеки по индексу, точное расстояние считается по формуле гаверсинусов.
+	// В наличии считается пригодный остаток, как в models.SellableQuantity: партии без просроченных
+	// и отозванных и остаток, не привязанный к партиям
+	box := geo.BoundingBox(origin, radiusKm)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+pharmacyColumns+`, s.quantity, COALESCE(o.price, m.price, 0), d.distance_km
+		FROM pharmacy_medicines pm
+		JOIN medicines m ON m.id = pm.medicine_id
+		JOIN pharmacies p ON p.id = pm.pharmacy_id
+		JOIN addresses a ON a.id = p.address_id
+		LEFT JOIN medicine_price_overrides o ON o.pharmacy_id = pm.pharmacy_id AND o.medicine_id = pm.medicine_id,
+		LATERAL (
+			SELECT COALESCE(SUM(quantity), 0) AS lotted,
+				COALESCE(SUM(quantity) FILTER (WHERE expiry_date >= CURRENT_DATE AND NOT `+lotRecalled+`), 0) AS sellable
+			FROM medicine_lots
+			WHERE medicine_lots.pharmacy_id = pm.pharmacy_id AND medicine_lots.medicine_id = pm.medicine_id
+				AND medicine_lots.quantity > 0
+		) l,
+		LATERAL (SELECT LEAST(pm.quantity, l.sellable + GREATEST(pm.quantity - l.lotted, 0)) AS quantity) s,
+		LATERAL (SELECT 2 * $4::float8 * asin(LEAST(1, sqrt(
+			power(sin(radians(a.latitude - $2) / 2), 2) +
+			cos(radians($2)) * cos(radians(a.latitude)) * power(sin(radians(a.longitude - $3) / 2), 2)
+		))) AS distance_km) d
+		WHERE pm.medicine_id = $1 AND pm.quantity > 0 AND s.quantity > 0
+			AND a.latitude BETWEEN $5 AND $6 AND a.longitude BETWEEN $7 AND $8
+			AND d.distance_km <= $9
+		ORDER BY d.distance_km, p.id
+	`, medicineID, origin.Lat, origin.Lon, geo.EarthRadiusKm, box.MinLat, box.MaxLat, box.MinLon, box.MaxLon, radiusKm)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.NearbyPharmacy{}
+	for rows.Next() {
+		var nearby models.NearbyPharmacy
+		nearby.Pharmacy, err = scanPharmacy(appendScanner{rows, []interface{}{&nearby.Quantity, &nearby.Price, &nearby.DistanceKm}})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nearby)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,327 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+)
+
+const stockCountColumns = "c.id, c.pharmacy_id, p.name, c.status, c.notes, c.opened_by, c.closed_by, c.opened_at, c.closed_at"
+
+const stockCountFrom = `
+	FROM stock_counts c
+	JOIN pharmacies p ON p.id = c.pharmacy_id`
+
+func scanStockCount(row rowScanner) (models.StockCount, error) {
+	var count models.StockCount
+	var openedBy, closedBy sql.NullInt64
+	var closedAt sql.NullTime
+	err := row.Scan(&count.ID, &count.PharmacyID, &count.PharmacyName, &count.Status, &count.Notes,
+		&openedBy, &closedBy, &count.OpenedAt, &closedAt)
+	count.OpenedBy = nullInt(openedBy)
+	count.ClosedBy = nullInt(closedBy)
+	count.ClosedAt = nullTime(closedAt)
+	return count, err
+}
+
+// loadStockCountDetails загружает строки с подсчётами устройств и корректировки инвентаризации
+func loadStockCountDetails(ctx context.Context, q querier, count *models.StockCount) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT l.medicine_id, COALESCE(m.name, ''), l.expected_quantity, l.book_quantity
+		FROM stock_count_lines l
+		JOIN medicines m ON m.id = l.medicine_id
+		WHERE l.stock_count_id = $1
+		ORDER BY m.name, l.medicine_id
+	`, count.ID)
+	if err != nil {
+		return err
+	}
+	count.Lines = []models.StockCountLine{}
+	for rows.Next() {
+		var line models.StockCountLine
+		if err := rows.Scan(&line.MedicineID, &line.MedicineName, &line.ExpectedQuantity, &line.BookQuantity); err != nil {
+			rows.Close()
+			return err
+		}
+		count.Lines = append(count.Lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	entryRows, err := q.QueryContext(ctx, `
+		SELECT medicine_id, device_id, quantity, reason, counted_by, counted_at
+		FROM stock_count_entries
+		WHERE stock_count_id = $1
+	`, count.ID)
+	if err != nil {
+		return err
+	}
+	for entryRows.Next() {
+		var entry models.StockCountEntry
+		var countedBy sql.NullInt64
+		if err := entryRows.Scan(&entry.MedicineID, &entry.DeviceID, &entry.Quantity, &entry.Reason, &countedBy, &entry.CountedAt); err != nil {
+			entryRows.Close()
+			return err
+		}
+		entry.CountedBy = nullInt(countedBy)
+		if line := count.Line(entry.MedicineID); line != nil {
+			line.Entries = append(line.Entries, entry)
+		}
+	}
+	entryRows.Close()
+	if err := entryRows.Err(); err != nil {
+		return err
+	}
+	for i := range count.Lines {
+		count.Lines[i].Tally()
+	}
+
+	adjustmentRows, err := q.QueryContext(ctx, `
+		SELECT a.id, a.pharmacy_id, a.medicine_id, COALESCE(m.name, ''), a.stock_count_id, a.quantity, a.reason, a.created_by, a.created_at
+		FROM stock_adjustments a
+		JOIN medicines m ON m.id = a.medicine_id
+		WHERE a.stock_count_id = $1
+		ORDER BY a.id
+	`, count.ID)
+	if err != nil {
+		return err
+	}
+	defer adjustmentRows.Close()
+	count.Adjustments = nil
+	for adjustmentRows.Next() {
+		var adjustment models.StockAdjustment
+		var countID, createdBy sql.NullInt64
+		err := adjustmentRows.Scan(&adjustment.ID, &adjustment.PharmacyID, &adjustment.MedicineID, &adjustment.MedicineName,
+			&countID, &adjustment.Quantity, &adjustment.Reason, &createdBy, &adjustment.CreatedAt)
+		if err != nil {
+			return err
+		}
+		adjustment.StockCountID = nullInt(countID)
+		adjustment.CreatedBy = nullInt(createdBy)
+		count.Adjustments = append(count.Adjustments, adjustment)
+	}
+	return adjustmentRows.Err()
+}
+
+// getStockCount загружает инвентаризацию со строками; forUpdate блокирует её до конца транзакции
+func getStockCount(ctx context.Context, q querier, id int, forUpdate bool) (models.StockCount, error) {
+	query := "SELECT " + stockCountColumns + stockCountFrom + " WHERE c.id = $1"
+	if forUpdate {
+		query += " FOR UPDATE OF c"
+	}
+	count, err := scanStockCount(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return count, mapError(err)
+	}
+	return count, loadStockCountDetails(ctx, q, &count)
+}
+
+// lockOpenStockCount блокирует инвентаризацию и проверяет, что она ещё открыта
+func lockOpenStockCount(ctx context.Context, tx *sql.Tx, id int) (models.StockCount, error) {
+	count, err := getStockCount(ctx, tx, id, true)
+	if err != nil {
+		return count, err
+	}
+	if count.Status != models.StockCountStatusOpen {
+		return count, fmt.Errorf("%w: stock count is %s", store.ErrInvalidTransition, count.Status)
+	}
+	return count, nil
+}
+
+// closeStockCount переводит инвентаризацию в итоговый статус
+func closeStockCount(ctx context.Context, tx *sql.Tx, count *models.StockCount, status string, userID *int) error {
+	var closedBy sql.NullInt64
+	var closedAt sql.NullTime
+	err := tx.QueryRowContext(ctx,
+		"UPDATE stock_counts SET status = $1, closed_by = $2, closed_at = CURRENT_TIMESTAMP WHERE id = $3 RETURNING closed_by, closed_at",
+		status, userID, count.ID).Scan(&closedBy, &closedAt)
+	if err != nil {
+		return err
+	}
+	count.Status = status
+	count.ClosedBy, count.ClosedAt = nullInt(closedBy), nullTime(closedAt)
+	return nil
+}
+
+func (s *Store) ListStockCounts(ctx context.Context, filter models.StockCountFilter) ([]models.StockCount, error) {
+	query := "SELECT " + stockCountColumns + stockCountFrom + " WHERE 1 = 1"
+	var args []interface{}
+	if filter.PharmacyID != 0 {
+		args = append(args, filter.PharmacyID)
+		query += fmt.Sprintf(" AND c.pharmacy_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND c.status = $%d", len(args))
+	}
+	query += " ORDER BY c.opened_at DESC, c.id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.StockCount{}
+	for rows.Next() {
+		count, err := scanStockCount(rows)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (s *Store) GetStockCount(ctx context.Context, id int) (models.StockCount, error) {
+	return getStockCount(ctx, s.db, id, false)
+}
+
+func (s *Store) OpenStockCount(ctx context.Context, count *models.StockCount) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		// Блокировка аптеки не даёт открыть две инвентаризации одновременно
+		err := tx.QueryRowContext(ctx, "SELECT name FROM pharmacies WHERE id = $1 FOR UPDATE", count.PharmacyID).Scan(&count.PharmacyName)
+		if err != nil {
+			return mapError(err)
+		}
+		var openID int
+		err = tx.QueryRowContext(ctx, "SELECT id FROM stock_counts WHERE pharmacy_id = $1 AND status = $2",
+			count.PharmacyID, models.StockCountStatusOpen).Scan(&openID)
+		if err == nil {
+			return fmt.Errorf("%w: pharmacy %d already has an open stock count %d", store.ErrConflict, count.PharmacyID, openID)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO stock_counts(pharmacy_id, status, notes, opened_by) VALUES($1, $2, $3, $4) RETURNING id, opened_at",
+			count.PharmacyID, models.StockCountStatusOpen, count.Notes, count.OpenedBy).Scan(&count.ID, &count.OpenedAt)
+		if err != nil {
+			return mapError(err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_count_lines(stock_count_id, medicine_id, expected_quantity, book_quantity)
+			SELECT $1, medicine_id, quantity, quantity FROM pharmacy_medicines WHERE pharmacy_id = $2
+		`, count.ID, count.PharmacyID)
+		if err != nil {
+			return err
+		}
+
+		count.Status = models.StockCountStatusOpen
+		count.ClosedBy, count.ClosedAt = nil, nil
+		return loadStockCountDetails(ctx, tx, count)
+	})
+}
+
+func (s *Store) SubmitStockCountEntries(ctx context.Context, id int, entries []models.StockCountEntry, userID *int) (models.StockCount, error) {
+	var count models.StockCount
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// Блокировка инвентаризации упорядочивает подсчёты с разных устройств
+		var err error
+		count, err = lockOpenStockCount(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			found, err := exists(ctx, tx, "medicines", entry.MedicineID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%w: medicine %d", store.ErrNotFound, entry.MedicineID)
+			}
+
+			// Учётный остаток на момент подсчёта: с ним сравнивается посчитанное количество
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stock_count_lines(stock_count_id, medicine_id, book_quantity)
+				VALUES($1, $2, COALESCE((SELECT quantity FROM pharmacy_medicines WHERE pharmacy_id = $3 AND medicine_id = $2), 0))
+				ON CONFLICT (stock_count_id, medicine_id) DO UPDATE SET book_quantity = EXCLUDED.book_quantity
+			`, count.ID, entry.MedicineID, count.PharmacyID)
+			if err != nil {
+				return mapError(err)
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stock_count_entries(stock_count_id, medicine_id, device_id, quantity, reason, counted_by)
+				VALUES($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (stock_count_id, medicine_id, device_id) DO UPDATE
+					SET quantity = EXCLUDED.quantity, reason = EXCLUDED.reason,
+						counted_by = EXCLUDED.counted_by, counted_at = CURRENT_TIMESTAMP
+			`, count.ID, entry.MedicineID, entry.DeviceID, entry.Quantity, entry.Reason, userID)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		return loadStockCountDetails(ctx, tx, &count)
+	})
+	return count, err
+}
+
+func (s *Store) ApproveStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error) {
+	var count models.StockCount
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		count, err = lockOpenStockCount(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if missing := count.MissingReasons(); len(missing) > 0 {
+			return fmt.Errorf("%w: variance reason is required for medicines %v", store.ErrConflict, missing)
+		}
+
+		for _, line := range count.Lines {
+			if line.Variance == nil || *line.Variance == 0 {
+				continue
+			}
+			// Расхождение применяется к текущему остатку: продажи после подсчёта сохраняются
+			applied, err := adjustStock(ctx, tx, models.StockMovement{
+				PharmacyID:    count.PharmacyID,
+				MedicineID:    line.MedicineID,
+				Type:          models.MovementAdjustment,
+				Quantity:      *line.Variance,
+				ReferenceType: models.MovementRefStockCount,
+				ReferenceID:   &count.ID,
+				Reason:        line.Reason,
+				UserID:        userID,
+			})
+			if err != nil {
+				return err
+			}
+			if applied == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stock_adjustments(pharmacy_id, medicine_id, stock_count_id, quantity, reason, created_by)
+				VALUES($1, $2, $3, $4, $5, $6)
+			`, count.PharmacyID, line.MedicineID, count.ID, applied, line.Reason, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := closeStockCount(ctx, tx, &count, models.StockCountStatusApproved, userID); err != nil {
+			return err
+		}
+		return loadStockCountDetails(ctx, tx, &count)
+	})
+	return count, err
+}
+
+func (s *Store) CancelStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error) {
+	var count models.StockCount
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		count, err = lockOpenStockCount(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return closeStockCount(ctx, tx, &count, models.StockCountStatusCancelled, userID)
+	})
+	return count, err
+}
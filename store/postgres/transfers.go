@@ -0,0 +1,374 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-test/models"
+	"pharmacy-test/store"
+
+	"github.com/lib/pq"
+)
+
+const transferColumns = "t.id, t.from_pharmacy_id, fp.name, t.to_pharmacy_id, tp.name, t.status, t.notes, " +
+	"t.requested_by, t.approved_by, t.shipped_by, t.received_by, t.created_at, t.updated_at, t.approved_at, t.shipped_at, t.received_at"
+
+const transferFrom = `
+	FROM transfers t
+	JOIN pharmacies fp ON fp.id = t.from_pharmacy_id
+	JOIN pharmacies tp ON tp.id = t.to_pharmacy_id`
+
+func scanTransfer(row rowScanner) (models.Transfer, error) {
+	var transfer models.Transfer
+	var requestedBy, approvedBy, shippedBy, receivedBy sql.NullInt64
+	var approvedAt, shippedAt, receivedAt sql.NullTime
+	err := row.Scan(&transfer.ID, &transfer.FromPharmacyID, &transfer.FromPharmacyName, &transfer.ToPharmacyID, &transfer.ToPharmacyName,
+		&transfer.Status, &transfer.Notes, &requestedBy, &approvedBy, &shippedBy, &receivedBy,
+		&transfer.CreatedAt, &transfer.UpdatedAt, &approvedAt, &shippedAt, &receivedAt)
+	if err != nil {
+		return transfer, err
+	}
+	transfer.RequestedBy = nullInt(requestedBy)
+	transfer.ApprovedBy = nullInt(approvedBy)
+	transfer.ShippedBy = nullInt(shippedBy)
+	transfer.ReceivedBy = nullInt(receivedBy)
+	transfer.ApprovedAt = nullTime(approvedAt)
+	transfer.ShippedAt = nullTime(shippedAt)
+	transfer.ReceivedAt = nullTime(receivedAt)
+	transfer.Items = []models.TransferItem{}
+	return transfer, nil
+}
+
+// nullTime превращает NULL в nil
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+// loadTransferItems загружает позиции и отправленные партии для набора перемещений
+func loadTransferItems(ctx context.Context, q querier, transfers []models.Transfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+	index := make(map[int]*models.Transfer, len(transfers))
+	ids := make([]int64, 0, len(transfers))
+	for i := range transfers {
+		index[transfers[i].ID] = &transfers[i]
+		ids = append(ids, int64(transfers[i].ID))
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT ti.transfer_id, ti.id, ti.medicine_id, COALESCE(m.name, ''), ti.quantity
+		FROM transfer_items ti
+		JOIN medicines m ON m.id = ti.medicine_id
+		WHERE ti.transfer_id = ANY($1)
+		ORDER BY ti.transfer_id, ti.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	var owners []int
+	var items []models.TransferItem
+	for rows.Next() {
+		var transferID int
+		var item models.TransferItem
+		if err := rows.Scan(&transferID, &item.ID, &item.MedicineID, &item.MedicineName, &item.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		owners = append(owners, transferID)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	lotRows, err := q.QueryContext(ctx, `
+		SELECT til.transfer_item_id, til.lot_id, l.lot_number, l.expiry_date, til.quantity
+		FROM transfer_item_lots til
+		JOIN medicine_lots l ON l.id = til.lot_id
+		JOIN transfer_items ti ON ti.id = til.transfer_item_id
+		WHERE ti.transfer_id = ANY($1)
+		ORDER BY l.expiry_date, til.lot_id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	allocations := map[int][]models.LotAllocation{}
+	for lotRows.Next() {
+		var itemID int
+		var allocation models.LotAllocation
+		var expiry time.Time
+		if err := lotRows.Scan(&itemID, &allocation.LotID, &allocation.LotNumber, &expiry, &allocation.Quantity); err != nil {
+			lotRows.Close()
+			return err
+		}
+		allocation.ExpiryDate = expiry.Format(models.DateLayout)
+		allocations[itemID] = append(allocations[itemID], allocation)
+	}
+	lotRows.Close()
+	if err := lotRows.Err(); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		item.Allocations = allocations[item.ID]
+		transfer := index[owners[i]]
+		transfer.Items = append(transfer.Items, item)
+	}
+	return nil
+}
+
+// getTransfer загружает перемещение вместе с позициями
+func getTransfer(ctx context.Context, q querier, id int, forUpdate bool) (models.Transfer, error) {
+	query := "SELECT " + transferColumns + transferFrom + " WHERE t.id = $1"
+	if forUpdate {
+		query += " FOR UPDATE OF t"
+	}
+	transfer, err := scanTransfer(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return transfer, mapError(err)
+	}
+	transfers := []models.Transfer{transfer}
+	if err := loadTransferItems(ctx, q, transfers); err != nil {
+		return transfer, err
+	}
+	return transfers[0], nil
+}
+
+func (s *Store) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	query := "SELECT " + transferColumns + transferFrom + " WHERE 1 = 1"
+	var args []interface{}
+	if filter.PharmacyID != 0 {
+		if err := requirePharmacy(ctx, s.db, filter.PharmacyID); err != nil {
+			return nil, err
+		}
+		args = append(args, filter.PharmacyID)
+		switch filter.Direction {
+		case models.TransferDirectionIncoming:
+			query += fmt.Sprintf(" AND t.to_pharmacy_id = $%d", len(args))
+		case models.TransferDirectionOutgoing:
+			query += fmt.Sprintf(" AND t.from_pharmacy_id = $%d", len(args))
+		default:
+			query += fmt.Sprintf(" AND (t.from_pharmacy_id = $%d OR t.to_pharmacy_id = $%d)", len(args), len(args))
+		}
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND t.status = $%d", len(args))
+	}
+	query += " ORDER BY t.created_at DESC, t.id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	transfers := []models.Transfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transfers, loadTransferItems(ctx, s.db, transfers)
+}
+
+func (s *Store) GetTransfer(ctx context.Context, id int) (models.Transfer, error) {
+	return getTransfer(ctx, s.db, id, false)
+}
+
+func (s *Store) CreateTransfer(ctx context.Context, transfer *models.Transfer) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT name FROM pharmacies WHERE id = $1", transfer.FromPharmacyID).Scan(&transfer.FromPharmacyName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, transfer.FromPharmacyID)
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, "SELECT name FROM pharmacies WHERE id = $1", transfer.ToPharmacyID).Scan(&transfer.ToPharmacyName)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: pharmacy %d", store.ErrNotFound, transfer.ToPharmacyID)
+		}
+		if err != nil {
+			return err
+		}
+
+		transfer.Status = models.TransferStatusRequested
+		transfer.ApprovedBy, transfer.ShippedBy, transfer.ReceivedBy = nil, nil, nil
+		transfer.ApprovedAt, transfer.ShippedAt, transfer.ReceivedAt = nil, nil, nil
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO transfers(from_pharmacy_id, to_pharmacy_id, status, notes, requested_by)
+			VALUES($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at
+		`, transfer.FromPharmacyID, transfer.ToPharmacyID, transfer.Status, transfer.Notes, transfer.RequestedBy,
+		).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		for i := range transfer.Items {
+			item := &transfer.Items[i]
+			err := tx.QueryRowContext(ctx, "SELECT COALESCE(name, '') FROM medicines WHERE id = $1", item.MedicineID).Scan(&item.MedicineName)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: medicine %d", store.ErrNotFound, item.MedicineID)
+			}
+			if err != nil {
+				return err
+			}
+			item.Allocations = nil
+			err = tx.QueryRowContext(ctx,
+				"INSERT INTO transfer_items(transfer_id, medicine_id, quantity) VALUES($1, $2, $3) RETURNING id",
+				transfer.ID, item.MedicineID, item.Quantity).Scan(&item.ID)
+			if err != nil {
+				return mapError(err)
+			}
+		}
+		return nil
+	})
+}
+
+// shipTransfer списывает позиции перемещения в аптеке-отправителе по FEFO и запоминает партии
+func shipTransfer(ctx context.Context, tx *sql.Tx, transfer *models.Transfer, userID *int) error {
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		allocations, err := consumeStock(ctx, tx, transfer.FromPharmacyID, item.MedicineID, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("%w: medicine %d", err, item.MedicineID)
+		}
+		item.Allocations = nil
+		for _, allocation := range allocations {
+			if allocation.LotID == 0 {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO transfer_item_lots(transfer_item_id, lot_id, quantity) VALUES($1, $2, $3)",
+				item.ID, allocation.LotID, allocation.Quantity)
+			if err != nil {
+				return err
+			}
+			item.Allocations = append(item.Allocations, allocation)
+		}
+		movement := transferMovement(*transfer, *item, models.MovementTransferOut, transfer.FromPharmacyID, -item.Quantity, userID)
+		if err := recordMovements(ctx, tx, movement, allocations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receiveTransfer оприходует отправленные партии в аптеке-получателе с теми же сериями и сроками годности
+func receiveTransfer(ctx context.Context, tx *sql.Tx, transfer *models.Transfer, userID *int) error {
+	for _, item := range transfer.Items {
+		var received []models.LotAllocation
+		for _, allocation := range item.Allocations {
+			source, err := scanLot(tx.QueryRowContext(ctx, "SELECT "+lotColumns+" FROM medicine_lots WHERE id = $1", allocation.LotID))
+			if err != nil {
+				return mapError(err)
+			}
+			lot := models.Lot{
+				MedicineID:     item.MedicineID,
+				PharmacyID:     transfer.ToPharmacyID,
+				LotNumber:      source.LotNumber,
+				ProductionDate: source.ProductionDate,
+				ExpiryDate:     source.ExpiryDate,
+				Quantity:       allocation.Quantity,
+			}
+			if err := receiveLot(ctx, tx, &lot); err != nil {
+				if err == store.ErrConflict {
+					return fmt.Errorf("%w: lot %s already exists with a different expiry date", err, source.LotNumber)
+				}
+				return err
+			}
+			received = append(received, models.LotAllocation{LotID: lot.ID, LotNumber: lot.LotNumber, Quantity: allocation.Quantity})
+		}
+		if unlotted := item.Unlotted(); unlotted > 0 {
+			if err := addStock(ctx, tx, transfer.ToPharmacyID, item.MedicineID, unlotted); err != nil {
+				return err
+			}
+		}
+		movement := transferMovement(*transfer, item, models.MovementTransferIn, transfer.ToPharmacyID, item.Quantity, userID)
+		if err := recordMovements(ctx, tx, movement, received); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transferMovement описывает движение остатка по позиции перемещения в аптеке pharmacyID
+func transferMovement(transfer models.Transfer, item models.TransferItem, movementType string, pharmacyID, quantity int, userID *int) models.StockMovement {
+	transferID := transfer.ID
+	return models.StockMovement{
+		PharmacyID:    pharmacyID,
+		MedicineID:    item.MedicineID,
+		Type:          movementType,
+		Quantity:      quantity,
+		ReferenceType: models.MovementRefTransfer,
+		ReferenceID:   &transferID,
+		UserID:        userID,
+	}
+}
+
+func (s *Store) UpdateTransferStatus(ctx context.Context, id int, status string, userID *int) (models.Transfer, error) {
+	var transfer models.Transfer
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		transfer, err = getTransfer(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !models.CanTransitionTransfer(transfer.Status, status) {
+			return store.ErrInvalidTransition
+		}
+		if (status == models.TransferStatusApproved || status == models.TransferStatusRejected) && !transfer.CanDecide(userID) {
+			// Запрос решает аптека-отправитель, а не его автор
+			return fmt.Errorf("%w: the requester cannot approve or reject the transfer", store.ErrForbidden)
+		}
+
+		switch status {
+		case models.TransferStatusInTransit:
+			err = shipTransfer(ctx, tx, &transfer, userID)
+		case models.TransferStatusReceived:
+			err = receiveTransfer(ctx, tx, &transfer, userID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Переход отмечает время и автора своего шага
+		transfer.Status = status
+		var approvedAt, shippedAt, receivedAt sql.NullTime
+		var approvedBy, shippedBy, receivedBy sql.NullInt64
+		err = tx.QueryRowContext(ctx, `
+			UPDATE transfers SET status = $1, updated_at = CURRENT_TIMESTAMP,
+				approved_by = CASE WHEN $3 THEN $2 ELSE approved_by END,
+				approved_at = CASE WHEN $3 THEN CURRENT_TIMESTAMP ELSE approved_at END,
+				shipped_by = CASE WHEN $4 THEN $2 ELSE shipped_by END,
+				shipped_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP ELSE shipped_at END,
+				received_by = CASE WHEN $5 THEN $2 ELSE received_by END,
+				received_at = CASE WHEN $5 THEN CURRENT_TIMESTAMP ELSE received_at END
+			WHERE id = $6
+			RETURNING updated_at, approved_by, approved_at, shipped_by, shipped_at, received_by, received_at
+		`, status, userID, status == models.TransferStatusApproved, status == models.TransferStatusInTransit,
+			status == models.TransferStatusReceived, id,
+		).Scan(&transfer.UpdatedAt, &approvedBy, &approvedAt, &shippedBy, &shippedAt, &receivedBy, &receivedAt)
+		if err != nil {
+			return err
+		}
+		transfer.ApprovedBy, transfer.ApprovedAt = nullInt(approvedBy), nullTime(approvedAt)
+		transfer.ShippedBy, transfer.ShippedAt = nullInt(shippedBy), nullTime(shippedAt)
+		transfer.ReceivedBy, transfer.ReceivedAt = nullInt(receivedBy), nullTime(receivedAt)
+		return nil
+	})
+	return transfer, err
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"pharmacy-test/models"
+)
+
+const userColumns = `u.id, u.username, u.password, u.created_at, u.last_login_at,
+	ud.id, ud.user_id, ud.first_name, ud.second_name, ud.email, ud.phone_number, COALESCE(ud.position, '')`
+
+func scanUser(row rowScanner) (models.UserWithDetails, error) {
+	var user models.UserWithDetails
+	var loginAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &loginAt,
+		&user.Details.ID, &user.Details.UserID, &user.Details.FirstName, &user.Details.SecondName,
+		&user.Details.Email, &user.Details.PhoneNumber, &user.Details.Position)
+	if loginAt.Valid {
+		user.LoginAt = loginAt.Time
+	}
+	return user, err
+}
+
+func (s *Store) getUser(ctx context.Context, condition string, arg interface{}) (models.UserWithDetails, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users u JOIN user_details ud ON u.id = ud.user_id WHERE "+condition, arg)
+	user, err := scanUser(row)
+	return user, mapError(err)
+}
+
+func (s *Store) CreateUser(ctx context.Context, user *models.UserWithDetails) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "INSERT INTO users(username, password) VALUES($1, $2) RETURNING id, created_at",
+			user.Username, user.Password).Scan(&user.ID, &user.CreatedAt)
+		if err != nil {
+			return mapError(err)
+		}
+
+		details := &user.Details
+		details.UserID = user.ID
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO user_details(user_id, first_name, second_name, email, phone_number, position) VALUES($1, $2, $3, $4, $5, $6) RETURNING id",
+			details.UserID, details.FirstName, details.SecondName, details.Email, details.PhoneNumber, details.Position).Scan(&details.ID)
+		return mapError(err)
+	})
+}
+
+func (s *Store) UpdateUser(ctx context.Context, user *models.UserWithDetails) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE users SET username = $1, password = $2 WHERE id = $3", user.Username, user.Password, user.ID)
+		if err != nil {
+			return mapError(err)
+		}
+		if err := expectAffected(result); err != nil {
+			return err
+		}
+
+		details := &user.Details
+		details.UserID = user.ID
+		err = tx.QueryRowContext(ctx,
+			"UPDATE user_details SET first_name = $1, second_name = $2, email = $3, phone_number = $4, position = $5 WHERE user_id = $6 RETURNING id",
+			details.FirstName, details.SecondName, details.Email, details.PhoneNumber, details.Position, user.ID).Scan(&details.ID)
+		return mapError(err)
+	})
+}
+
+func (s *Store) DeleteUser(ctx context.Context, id int) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM user_details WHERE user_id = $1", id); err != nil {
+			return mapError(err)
+		}
+		result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+		if err != nil {
+			return mapError(err)
+		}
+		return expectAffected(result)
+	})
+}
+
+func (s *Store) ListUsers(ctx context.Context, filter models.UserFilter, page models.PageRequest) (models.Page[models.UserWithDetails], error) {
+	q := listQuery{
+		columns: userColumns,
+		from:    "users u JOIN user_details ud ON u.id = ud.user_id",
+		idExpr:  "u.id",
+		sorts: map[string]sortColumn{
+			"id":         {"u.id", "int"},
+			"username":   {"u.username", "text"},
+			"created_at": {"COALESCE(u.created_at, '-infinity'::timestamp)", "timestamp"},
+		},
+	}
+	if filter.Position != "" {
+		q.filter("ud.position = ?", filter.Position)
+	}
+	return queryPage(ctx, s.db, q, page, scanUser, func(u models.UserWithDetails) int { return u.ID })
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (models.UserWithDetails, error) {
+	return s.getUser(ctx, "u.username = $1", username)
+}
+
+func (s *Store) GetUser(ctx context.Context, id int) (models.UserWithDetails, error) {
+	return s.getUser(ctx, "u.id = $1", id)
+}
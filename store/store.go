@@ -0,0 +1,344 @@
+// Package store описывает слой хранения данных аптечной системы.
+// Реализации находятся в пакетах store/postgres и store/memory.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"pharmacy-test/geo"
+	"pharmacy-test/models"
+)
+
+var (
+	// ErrNotFound возвращается, если запрошенная запись не существует
+	ErrNotFound = errors.New("not found")
+	// ErrConflict возвращается при нарушении уникальности или конфликте данных
+	ErrConflict = errors.New("conflict")
+	// ErrInsufficientStock возвращается, если пригодного остатка не хватает для списания
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInvalidTransition возвращается при недопустимой смене статуса
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrPrescriptionRequired возвращается при продаже рецептурного лекарства без рецепта
+	ErrPrescriptionRequired = errors.New("prescription required")
+	// ErrPrescriptionInvalid возвращается, если по рецепту нельзя отпустить позицию:
+	// рецепт отменён или просрочен, не содержит лекарства или его остаток меньше продаваемого
+	ErrPrescriptionInvalid = errors.New("prescription is not valid")
+	// ErrForbidden возвращается, если правила предметной области не разрешают действие этому пользователю,
+	// например автору запроса на перемещение — одобрить его
+	ErrForbidden = errors.New("forbidden")
+)
+
+// ConflictError уточняет ErrConflict полем, значение которого уже занято
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return "conflict: " + e.Field + " already exists"
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrConflict)
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// NotFoundError уточняет ErrNotFound полем запроса, которое ссылается на несуществующую запись,
+// в отличие от голой ErrNotFound для самой запрошенной записи
+type NotFoundError struct {
+	Field string
+	ID    int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("not found: %s %d", e.Field, e.ID)
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrNotFound)
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// PharmacyStore хранит аптеки и их адреса
+type PharmacyStore interface {
+	// ListPharmacies возвращает страницу аптек, отобранных фильтром
+	ListPharmacies(ctx context.Context, filter models.PharmacyFilter, page models.PageRequest) (models.Page[models.Pharmacy], error)
+	GetPharmacy(ctx context.Context, id int) (models.Pharmacy, error)
+	CreatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error
+	UpdatePharmacy(ctx context.Context, pharmacy *models.Pharmacy) error
+	DeletePharmacy(ctx context.Context, id int) error
+}
+
+// MedicineStore хранит каталог лекарств
+type MedicineStore interface {
+	// ListMedicines возвращает страницу лекарств вместе с наличием по аптекам
+	ListMedicines(ctx context.Context, filter models.MedicineFilter, page models.PageRequest) (models.Page[models.Medicine], error)
+	// GetMedicine возвращает лекарство вместе с наличием по аптекам и партиями
+	GetMedicine(ctx context.Context, id int) (models.Medicine, error)
+	// GetMedicineByGTIN возвращает лекарство по GTIN, приведённому к 14 цифрам, как GetMedicine
+	GetMedicineByGTIN(ctx context.Context, gtin string) (models.Medicine, error)
+	// SearchMedicines ищет лекарства по названию, производителю и упаковке с учётом
+	// неполных слов, опечаток и транслитерации; результаты упорядочены по релевантности
+	SearchMedicines(ctx context.Context, query string, limit int) ([]models.MedicineSearchResult, error)
+	// CreateMedicine создаёт лекарство и привязывает его к аптекам из PharmacyIDs с нулевым остатком;
+	// начальная цена записывается в историю цен
+	CreateMedicine(ctx context.Context, medicine *models.Medicine) error
+	// UpdateMedicine обновляет лекарство; изменение цены записывается в историю от имени ChangedBy
+	UpdateMedicine(ctx context.Context, medicine *models.Medicine) error
+	DeleteMedicine(ctx context.Context, id int) error
+	// ListSubstitutes возвращает лекарства с теми же действующими веществами и дозировками.
+	// Если pharmacyID не равен нулю, цены и остатки берутся в этой аптеке; неизвестная аптека даёт NotFoundError
+	ListSubstitutes(ctx context.Context, medicineID, pharmacyID int) ([]models.MedicineSubstitute, error)
+}
+
+// PriceStore хранит историю цен, запланированные изменения и цены в аптеках
+type PriceStore interface {
+	// GetMedicinePrices возвращает цену каталога, переопределения в аптеках,
+	// ожидающие изменения и историю начиная с последнего изменения
+	GetMedicinePrices(ctx context.Context, medicineID int) (models.MedicinePrices, error)
+	// ChangePrice сразу меняет цену каталога или переопределение в аптеке и записывает изменение
+	// в историю; NewPrice, равный nil, снимает переопределение. Неизвестная аптека даёт NotFoundError
+	ChangePrice(ctx context.Context, change *models.PriceChange) error
+	// SchedulePrice планирует изменение цены на момент EffectiveAt; неизвестная аптека даёт NotFoundError
+	SchedulePrice(ctx context.Context, price *models.ScheduledPrice) error
+	// CancelScheduledPrice отменяет ожидающее изменение; применённое или отменённое даёт ErrInvalidTransition
+	CancelScheduledPrice(ctx context.Context, medicineID, id int) (models.ScheduledPrice, error)
+	// ApplyDuePrices применяет изменения, срок которых наступил, и возвращает их число
+	ApplyDuePrices(ctx context.Context) (int, error)
+}
+
+// InteractionStore хранит базу взаимодействий действующих веществ
+type InteractionStore interface {
+	// ListInteractions возвращает взаимодействия; непустой ingredient оставляет пары с этим веществом
+	ListInteractions(ctx context.Context, ingredient string) ([]models.Interaction, error)
+	// ImportInteractions добавляет взаимодействия одной транзакцией; существующие пары обновляются
+	ImportInteractions(ctx context.Context, interactions []models.Interaction) (models.InteractionImportResult, error)
+	DeleteInteraction(ctx context.Context, id int) error
+	// CheckInteractions возвращает взаимодействия между лекарствами; неизвестное лекарство даёт ErrNotFound
+	CheckInteractions(ctx context.Context, medicineIDs []int) ([]models.InteractionWarning, error)
+}
+
+// StockStore хранит остатки и партии лекарств в аптеках
+type StockStore interface {
+	ListPharmacyStock(ctx context.Context, pharmacyID int) ([]models.StockItem, error)
+	// SetStock устанавливает остаток и уменьшает партии, если они превышают новый остаток;
+	// изменение записывается в журнал движений от имени userID
+	SetStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) (models.StockItem, error)
+	// ConsumeStock списывает остаток по правилу FEFO
+	ConsumeStock(ctx context.Context, pharmacyID, medicineID, quantity int, userID *int) ([]models.LotAllocation, error)
+	// ReceiveLot оприходует партию и увеличивает остаток; повторная серия суммируется,
+	// а серия с другим сроком годности даёт ErrConflict
+	ReceiveLot(ctx context.Context, lot *models.Lot, userID *int) error
+	ListMedicineLots(ctx context.Context, medicineID int) ([]models.Lot, error)
+	// ListExpiringLots возвращает партии аптеки, срок годности которых истекает в ближайшие days дней
+	ListExpiringLots(ctx context.Context, pharmacyID, days int) ([]models.Lot, error)
+	// ListNearbyAvailability возвращает аптеки с ненулевым остатком лекарства в радиусе radiusKm
+	// от точки origin, начиная с ближайшей; аптеки без координат не учитываются
+	ListNearbyAvailability(ctx context.Context, medicineID int, origin geo.Point, radiusKm float64) ([]models.NearbyPharmacy, error)
+}
+
+// PrescriptionStore хранит рецепты
+type PrescriptionStore interface {
+	ListPrescriptions(ctx context.Context, filter models.PrescriptionFilter) ([]models.Prescription, error)
+	// GetPrescription возвращает рецепт вместе с отпусками по каждой позиции
+	GetPrescription(ctx context.Context, id int) (models.Prescription, error)
+	// CreatePrescription сохраняет рецепт; неизвестное лекарство даёт ErrNotFound, занятый номер — ConflictError
+	CreatePrescription(ctx context.Context, prescription *models.Prescription) error
+	// CancelPrescription отменяет рецепт; повторная отмена даёт ErrInvalidTransition
+	CancelPrescription(ctx context.Context, id int) (models.Prescription, error)
+}
+
+// OrderStore хранит заказы (продажи)
+type OrderStore interface {
+	// CreateOrder фиксирует цены из каталога и при статусе paid списывает остатки в той же транзакции.
+	// Рецептурное лекарство без рецепта даёт ErrPrescriptionRequired, рецепт без этого лекарства — ErrPrescriptionInvalid
+	CreateOrder(ctx context.Context, order *models.Order) error
+	GetOrder(ctx context.Context, id int) (models.Order, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	// UpdateOrderStatus выполняет переход статуса от имени userID со списанием или возвратом остатков.
+	// Оплата списывает проданное количество с рецептов позиций в той же транзакции
+	UpdateOrderStatus(ctx context.Context, id int, status string, userID *int) (models.Order, error)
+}
+
+// PurchaseStore хранит поставщиков, заказы поставщикам и приёмки поставок
+type PurchaseStore interface {
+	ListSuppliers(ctx context.Context) ([]models.Supplier, error)
+	GetSupplier(ctx context.Context, id int) (models.Supplier, error)
+	// CreateSupplier сохраняет поставщика; занятое название даёт ConflictError
+	CreateSupplier(ctx context.Context, supplier *models.Supplier) error
+	UpdateSupplier(ctx context.Context, supplier *models.Supplier) error
+	// DeleteSupplier удаляет поставщика; поставщик с заказами даёт ErrConflict
+	DeleteSupplier(ctx context.Context, id int) error
+	ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error)
+	// GetPurchaseOrder возвращает заказ поставщику вместе с позициями и приёмками
+	GetPurchaseOrder(ctx context.Context, id int) (models.PurchaseOrder, error)
+	// CreatePurchaseOrder сохраняет заказ в статусе draft или sent;
+	// неизвестные аптека, поставщик или лекарство дают ErrNotFound
+	CreatePurchaseOrder(ctx context.Context, order *models.PurchaseOrder) error
+	// UpdatePurchaseOrderStatus выполняет переход статуса, недопустимый переход даёт ErrInvalidTransition
+	UpdatePurchaseOrderStatus(ctx context.Context, id int, status string) (models.PurchaseOrder, error)
+	// ReceiveGoods оприходует партии приёмки в аптеку заказа и увеличивает принятые количества позиций
+	// в одной транзакции. Заказ не в статусе sent или partially_received даёт ErrInvalidTransition,
+	// лекарство вне заказа или приёмка сверх заказанного — ErrConflict
+	ReceiveGoods(ctx context.Context, receipt *models.GoodsReceipt) (models.PurchaseOrder, error)
+}
+
+// ReplenishmentStore хранит пороги остатков и предложения пополнения
+type ReplenishmentStore interface {
+	ListStockLevels(ctx context.Context, pharmacyID int) ([]models.StockLevel, error)
+	// SetStockLevel создаёт или заменяет пороги; неизвестные аптека, лекарство или поставщик дают ErrNotFound
+	SetStockLevel(ctx context.Context, level *models.StockLevel) error
+	DeleteStockLevel(ctx context.Context, pharmacyID, medicineID int) error
+	// RefreshReplenishment пересчитывает предложения аптеки по порогам, остаткам, открытым заказам
+	// поставщикам и продажам; pharmacyID, равный нулю, пересчитывает все аптеки. Возвращает число предложений
+	RefreshReplenishment(ctx context.Context, pharmacyID int) (int, error)
+	// ListReplenishment возвращает последние рассчитанные предложения аптеки
+	ListReplenishment(ctx context.Context, pharmacyID int) ([]models.ReplenishmentSuggestion, error)
+	// CreateReplenishmentOrders создаёт черновики заказов поставщикам по предложениям, по одному на поставщика.
+	// Лекарство без предложения или без поставщика даёт ErrConflict
+	CreateReplenishmentOrders(ctx context.Context, pharmacyID int, request models.ReplenishmentOrderRequest) ([]models.PurchaseOrder, error)
+}
+
+// TransferStore хранит перемещения лекарств между аптеками
+type TransferStore interface {
+	// ListTransfers возвращает перемещения, начиная с последних; фильтр по аптеке
+	// с неизвестной аптекой даёт ErrNotFound
+	ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error)
+	// GetTransfer возвращает перемещение вместе с позициями и отправленными партиями
+	GetTransfer(ctx context.Context, id int) (models.Transfer, error)
+	// CreateTransfer сохраняет запрос на перемещение в статусе requested;
+	// неизвестные аптеки или лекарства дают ErrNotFound
+	CreateTransfer(ctx context.Context, transfer *models.Transfer) error
+	// UpdateTransferStatus выполняет переход статуса от имени userID; недопустимый переход даёт ErrInvalidTransition.
+	// Отправка (in_transit) списывает позиции по FEFO в аптеке-отправителе, нехватка даёт ErrInsufficientStock;
+	// приёмка (received) оприходует те же партии в аптеке-получателе. Каждый переход выполняется в одной транзакции
+	UpdateTransferStatus(ctx context.Context, id int, status string, userID *int) (models.Transfer, error)
+}
+
+// StockCountStore хранит инвентаризации аптек и корректировки остатков по ним
+type StockCountStore interface {
+	// ListStockCounts возвращает инвентаризации без строк, начиная с последних
+	ListStockCounts(ctx context.Context, filter models.StockCountFilter) ([]models.StockCount, error)
+	// GetStockCount возвращает инвентаризацию со строками, подсчётами и корректировками
+	GetStockCount(ctx context.Context, id int) (models.StockCount, error)
+	// OpenStockCount открывает инвентаризацию, запоминая текущие остатки аптеки как ожидаемые.
+	// Неизвестная аптека даёт ErrNotFound, уже открытая инвентаризация аптеки — ErrConflict
+	OpenStockCount(ctx context.Context, count *models.StockCount) error
+	// SubmitStockCountEntries сохраняет подсчёты устройства и учётные остатки на момент подсчёта.
+	// Закрытая инвентаризация даёт ErrInvalidTransition, неизвестное лекарство — ErrNotFound
+	SubmitStockCountEntries(ctx context.Context, id int, entries []models.StockCountEntry, userID *int) (models.StockCount, error)
+	// ApproveStockCount корректирует остатки на расхождения посчитанных лекарств в одной транзакции
+	// и закрывает инвентаризацию. Расхождение без причины даёт ErrConflict
+	ApproveStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error)
+	// CancelStockCount закрывает инвентаризацию без корректировок
+	CancelStockCount(ctx context.Context, id int, userID *int) (models.StockCount, error)
+}
+
+// RecallStore хранит отзывы серий лекарств и действия с отозванными единицами
+type RecallStore interface {
+	// ListRecalls возвращает отзывы без действий, начиная с последних
+	ListRecalls(ctx context.Context, filter models.RecallFilter) ([]models.Recall, error)
+	// GetRecall возвращает отзыв вместе с сериями и действиями
+	GetRecall(ctx context.Context, id int) (models.Recall, error)
+	// CreateRecall сохраняет отзыв в статусе active; с этого момента партии его серий не продаются.
+	// Неизвестное лекарство даёт ErrNotFound
+	CreateRecall(ctx context.Context, recall *models.Recall) error
+	// UpdateRecallStatus завершает или отменяет отзыв; недопустимый переход даёт ErrInvalidTransition
+	UpdateRecallStatus(ctx context.Context, id int, status string) (models.Recall, error)
+	// RecordRecallAction помещает единицы партии в карантин или возвращает их производителю.
+	// Возврат уменьшает партию и остаток и записывается в журнал движений в той же транзакции.
+	// Неизвестная партия даёт ErrNotFound, завершённый или отменённый отзыв — ErrInvalidTransition,
+	// партия вне отзыва, карантин сверх остатка партии или возврат сверх карантина — ErrConflict
+	RecordRecallAction(ctx context.Context, action *models.RecallAction) error
+	// GetRecallReport возвращает аптеки с отозванными партиями и оплаченные заказы, в которых они проданы
+	GetRecallReport(ctx context.Context, id int) (models.RecallReport, error)
+}
+
+// PackStore ведёт реестр серийных упаковок: каждая упаковка отслеживается от приёмки до выбытия
+type PackStore interface {
+	// ListPacks возвращает страницу упаковок без истории статусов
+	ListPacks(ctx context.Context, filter models.PackFilter, page models.PageRequest) (models.Page[models.Pack], error)
+	// GetPack возвращает упаковку вместе с историей статусов
+	GetPack(ctx context.Context, id int) (models.Pack, error)
+	// RegisterPacks регистрирует принятые упаковки в статусе received: все или ни одной.
+	// Лекарство определяется по GTIN; неизвестная аптека или GTIN дают ErrNotFound,
+	// уже зарегистрированная упаковка — ErrConflict
+	RegisterPacks(ctx context.Context, packs []models.Pack, userID *int) error
+	// UpdatePackStatus переводит упаковку в новый статус и записывает событие. Недопустимый переход
+	// даёт ErrInvalidTransition, неизвестный заказ — NotFoundError, неоплаченный заказ, заказ другой аптеки
+	// или без лекарства упаковки — ErrConflict
+	UpdatePackStatus(ctx context.Context, id int, change models.PackStatusChange) (models.Pack, error)
+	// ListDispensingEvents возвращает продажи упаковок за период в порядке времени
+	ListDispensingEvents(ctx context.Context, filter models.DispensingFilter) ([]models.DispensingEvent, error)
+}
+
+// MovementStore хранит журнал движений остатков. Движения записываются хранилищами остатков,
+// заказов, поставок, перемещений и инвентаризаций в тех же транзакциях, что и изменения остатков
+type MovementStore interface {
+	// ListStockMovements возвращает страницу движений аптеки; неизвестная аптека даёт ErrNotFound
+	ListStockMovements(ctx context.Context, filter models.MovementFilter, page models.PageRequest) (models.Page[models.StockMovement], error)
+	// ListStockBalances возвращает остатки аптеки по журналу движений на момент at, без нулевых
+	ListStockBalances(ctx context.Context, pharmacyID int, at time.Time) ([]models.StockBalance, error)
+}
+
+// UserStore хранит пользователей и их данные
+type UserStore interface {
+	// CreateUser сохраняет пользователя; пароль должен быть уже захеширован
+	CreateUser(ctx context.Context, user *models.UserWithDetails) error
+	UpdateUser(ctx context.Context, user *models.UserWithDetails) error
+	DeleteUser(ctx context.Context, id int) error
+	ListUsers(ctx context.Context, filter models.UserFilter, page models.PageRequest) (models.Page[models.UserWithDetails], error)
+	// GetUserByUsername возвращает пользователя вместе с хешем пароля
+	GetUserByUsername(ctx context.Context, username string) (models.UserWithDetails, error)
+	GetUser(ctx context.Context, id int) (models.UserWithDetails, error)
+}
+
+// SessionStore хранит сессии пользователей; сессии ищутся по хешу токена
+type SessionStore interface {
+	// CreateSession сохраняет сессию со сроком действия ttl и отмечает время входа пользователя
+	CreateSession(ctx context.Context, session *models.Session, ttl time.Duration) error
+	// TouchSession находит активную сессию, обновляет время последнего обращения
+	// и продлевает срок действия на ttl; истёкшая или отозванная сессия даёт ErrNotFound
+	TouchSession(ctx context.Context, tokenHash string, ttl time.Duration) (models.Session, error)
+	// ListUserSessions возвращает активные сессии пользователя, начиная с последней использованной
+	ListUserSessions(ctx context.Context, userID int) ([]models.Session, error)
+	// RevokeSession отзывает сессию пользователя; чужая или уже отозванная сессия даёт ErrNotFound
+	RevokeSession(ctx context.Context, userID, sessionID int) error
+	RevokeSessionByToken(ctx context.Context, tokenHash string) error
+}
+
+// RoleStore хранит роли и их разрешения
+type RoleStore interface {
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	GetRole(ctx context.Context, name string) (models.Role, error)
+	// CreateRole создаёт роль; неизвестное разрешение даёт ErrNotFound, существующая роль — ErrConflict
+	CreateRole(ctx context.Context, role *models.Role) error
+	// UpdateRole заменяет описание и набор разрешений роли
+	UpdateRole(ctx context.Context, role *models.Role) error
+	// DeleteRole удаляет роль; роль, назначенная пользователям, даёт ErrConflict
+	DeleteRole(ctx context.Context, name string) error
+}
+
+// Store объединяет все хранилища системы
+type Store interface {
+	PharmacyStore
+	MedicineStore
+	PriceStore
+	InteractionStore
+	StockStore
+	PrescriptionStore
+	OrderStore
+	PurchaseStore
+	ReplenishmentStore
+	TransferStore
+	StockCountStore
+	RecallStore
+	PackStore
+	MovementStore
+	UserStore
+	SessionStore
+	RoleStore
+}